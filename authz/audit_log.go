@@ -0,0 +1,265 @@
+package authz
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// AuditFormat is the record format written by an AuditLog.
+type AuditFormat int
+
+const (
+	// AuditFormatJSON writes one JSON object per line. It is the default.
+	AuditFormatJSON AuditFormat = iota
+	// AuditFormatCEF writes one ArcSight Common Event Format record per line.
+	AuditFormatCEF
+)
+
+// AuditSampling controls which decisions an AuditLog records, so that a busy listener
+// does not flood the log. The zero value records every decision.
+type AuditSampling struct {
+	// AllowedEvery records one in every AllowedEvery allowed decisions. Zero or one
+	// records all of them.
+	AllowedEvery uint64
+	// DeniedEvery records one in every DeniedEvery denied decisions, including those let
+	// through by Listener.AuditOnly. Zero or one records all of them.
+	DeniedEvery uint64
+	// MaxPerSecond, if greater than zero, caps the number of records written in any one
+	// second, after the per-decision sampling.
+	MaxPerSecond int
+}
+
+// AuditLogOption configures an AuditLog.
+type AuditLogOption func(*AuditLog)
+
+// WithAuditFormat sets the record format. The default is AuditFormatJSON.
+func WithAuditFormat(format AuditFormat) AuditLogOption {
+	return func(l *AuditLog) {
+		l.format = format
+	}
+}
+
+// WithAuditSampling sets which decisions are recorded. By default all are.
+func WithAuditSampling(sampling AuditSampling) AuditLogOption {
+	return func(l *AuditLog) {
+		l.sampling = sampling
+	}
+}
+
+// WithAuditLogLogger sets the logger used to report failures to write records.
+func WithAuditLogLogger(logger zerolog.Logger) AuditLogOption {
+	return func(l *AuditLog) {
+		l.logger = logger
+	}
+}
+
+// AuditLog writes a structured record of each authorisation decision it observes, with
+// the remote and local addresses, the decision, the reason for it and how long it took.
+// It is a DecisionObserver, so it can be set as Listener.AuditLog or
+// Listener.DecisionObserver. Records are written synchronously, so w should be
+// buffered or fast.
+type AuditLog struct {
+	w        io.Writer
+	format   AuditFormat
+	sampling AuditSampling
+	logger   zerolog.Logger
+	now      func() time.Time
+
+	mu          sync.Mutex
+	allowedSeen uint64
+	deniedSeen  uint64
+	window      time.Time
+	windowCount int
+	dropped     uint64
+}
+
+// NewAuditLog creates an AuditLog that writes records to w.
+func NewAuditLog(w io.Writer, opts ...AuditLogOption) *AuditLog {
+	l := &AuditLog{
+		w:      w,
+		format: AuditFormatJSON,
+		logger: zerolog.Nop(),
+		now:    time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	return l
+}
+
+// ObserveDecision records d, unless it is sampled out.
+func (l *AuditLog) ObserveDecision(d Decision) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.sample(d) {
+		l.dropped++
+		return
+	}
+
+	var record []byte
+	if l.format == AuditFormatCEF {
+		record = formatCEFRecord(d)
+	} else {
+		record = formatJSONRecord(d)
+	}
+
+	if _, err := l.w.Write(record); err != nil {
+		l.logger.Error().Err(err).Msg("failed to write audit record")
+	}
+}
+
+// Dropped returns the number of decisions that were sampled out and not recorded.
+func (l *AuditLog) Dropped() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.dropped
+}
+
+// sample reports whether d should be recorded. The caller must hold l.mu.
+func (l *AuditLog) sample(d Decision) bool {
+	seen, every := &l.allowedSeen, l.sampling.AllowedEvery
+	if !d.Allowed {
+		seen, every = &l.deniedSeen, l.sampling.DeniedEvery
+	}
+	*seen++
+	if every > 1 && (*seen-1)%every != 0 {
+		return false
+	}
+
+	if l.sampling.MaxPerSecond <= 0 {
+		return true
+	}
+	if second := l.now().Truncate(time.Second); !second.Equal(l.window) {
+		l.window = second
+		l.windowCount = 0
+	}
+	if l.windowCount >= l.sampling.MaxPerSecond {
+		return false
+	}
+	l.windowCount++
+	return true
+}
+
+type auditRecord struct {
+	Time       time.Time `json:"time"`
+	RemoteAddr string    `json:"remote_addr"`
+	LocalAddr  string    `json:"local_addr,omitempty"`
+	Decision   string    `json:"decision"`
+	Reason     string    `json:"reason"`
+	AuditOnly  bool      `json:"audit_only,omitempty"`
+	DurationMS float64   `json:"duration_ms"`
+}
+
+func formatJSONRecord(d Decision) []byte {
+	record := auditRecord{
+		Time:       d.Time,
+		RemoteAddr: addrString(d.RemoteAddr),
+		LocalAddr:  addrString(d.LocalAddr),
+		Decision:   decisionAction(d),
+		Reason:     d.Reason,
+		AuditOnly:  d.AuditOnly,
+		DurationMS: float64(d.Duration) / float64(time.Millisecond),
+	}
+
+	// auditRecord has no fields that can fail to marshal
+	b, _ := json.Marshal(record)
+	return append(b, '\n')
+}
+
+// CEF header values identifying the records of an AuditLog.
+const (
+	auditCEFVendor  = "dioad"
+	auditCEFProduct = "net"
+	auditCEFVersion = "1"
+)
+
+func formatCEFRecord(d Decision) []byte {
+	action := decisionAction(d)
+	name := "connection allowed"
+	severity := 1
+	switch {
+	case d.AuditOnly:
+		name, severity = "connection would be denied", 5
+	case !d.Allowed:
+		name, severity = "connection denied", 5
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "CEF:0|%s|%s|%s|%s|%s|%d|",
+		cefHeader(auditCEFVendor), cefHeader(auditCEFProduct), cefHeader(auditCEFVersion),
+		cefHeader(action), cefHeader(name), severity)
+
+	ext := []string{"rt=" + strconv.FormatInt(d.Time.UnixMilli(), 10)}
+	ext = append(ext, cefAddr(d.RemoteAddr, "src", "c6a2", "spt")...)
+	ext = append(ext, cefAddr(d.LocalAddr, "dst", "c6a3", "dpt")...)
+	ext = append(ext,
+		"act="+cefValue(action),
+		"reason="+cefValue(d.Reason),
+		"cn1Label=durationMicros",
+		"cn1="+strconv.FormatInt(d.Duration.Microseconds(), 10),
+	)
+	if d.AuditOnly {
+		ext = append(ext, "cs1Label=auditOnly", "cs1=true")
+	}
+
+	b.WriteString(strings.Join(ext, " "))
+	b.WriteByte('\n')
+	return []byte(b.String())
+}
+
+// cefAddr returns the CEF extension fields for addr, using ipv4Key or ipv6Key for the
+// address and portKey for the port.
+func cefAddr(addr net.Addr, ipv4Key, ipv6Key, portKey string) []string {
+	if addr == nil {
+		return nil
+	}
+	addrPort, err := addrPortFromAddr(addr)
+	if err != nil {
+		return nil
+	}
+
+	ip := addrPort.Addr().Unmap().WithZone("")
+	key := ipv4Key
+	if ip.Is6() {
+		key = ipv6Key
+	}
+	fields := []string{key + "=" + ip.String()}
+	if addrPort.Port() != 0 {
+		fields = append(fields, portKey+"="+strconv.Itoa(int(addrPort.Port())))
+	}
+	return fields
+}
+
+var (
+	cefHeaderEscaper = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\n", " ", "\r", " ")
+	cefValueEscaper  = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`)
+)
+
+func cefHeader(s string) string { return cefHeaderEscaper.Replace(s) }
+
+func cefValue(s string) string { return cefValueEscaper.Replace(s) }
+
+func decisionAction(d Decision) string {
+	if d.Allowed {
+		return "allow"
+	}
+	return "deny"
+}
+
+func addrString(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+	return addr.String()
+}
@@ -0,0 +1,130 @@
+package authz
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testDecision(allowed bool) Decision {
+	return Decision{
+		RemoteAddr: &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 50000},
+		LocalAddr:  &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 443},
+		Allowed:    allowed,
+		Reason:     ReasonDenyList,
+		Time:       time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Duration:   1500 * time.Microsecond,
+	}
+}
+
+func TestAuditLogJSON(t *testing.T) {
+	var buf bytes.Buffer
+	log := NewAuditLog(&buf)
+
+	log.ObserveDecision(testDecision(false))
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, map[string]any{
+		"time":        "2024-01-02T03:04:05Z",
+		"remote_addr": "192.0.2.1:50000",
+		"local_addr":  "[2001:db8::1]:443",
+		"decision":    "deny",
+		"reason":      "deny list",
+		"duration_ms": 1.5,
+	}, record)
+	assert.True(t, strings.HasSuffix(buf.String(), "}\n"))
+}
+
+func TestAuditLogCEF(t *testing.T) {
+	var buf bytes.Buffer
+	log := NewAuditLog(&buf, WithAuditFormat(AuditFormatCEF))
+
+	d := testDecision(false)
+	d.Reason = "a=b|c"
+	d.AuditOnly = true
+	log.ObserveDecision(d)
+
+	assert.Equal(t,
+		"CEF:0|dioad|net|1|deny|connection would be denied|5|rt=1704164645000 src=192.0.2.1 spt=50000 "+
+			"c6a3=2001:db8::1 dpt=443 act=deny reason=a\\=b|c cn1Label=durationMicros cn1=1500 "+
+			"cs1Label=auditOnly cs1=true\n",
+		buf.String())
+
+	buf.Reset()
+	log.ObserveDecision(Decision{RemoteAddr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1")}, Allowed: true, Reason: ReasonAllowList})
+	assert.True(t, strings.HasPrefix(buf.String(), "CEF:0|dioad|net|1|allow|connection allowed|1|"))
+	assert.Contains(t, buf.String(), " src=10.0.0.1 act=allow ")
+}
+
+func TestAuditLogSampling(t *testing.T) {
+	var buf bytes.Buffer
+	log := NewAuditLog(&buf, WithAuditSampling(AuditSampling{AllowedEvery: 3}))
+
+	for range 6 {
+		log.ObserveDecision(testDecision(true))
+	}
+	for range 2 {
+		log.ObserveDecision(testDecision(false))
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 4) // allowed 1st and 4th, both denied
+	assert.Equal(t, uint64(4), log.Dropped())
+}
+
+func TestAuditLogMaxPerSecond(t *testing.T) {
+	var buf bytes.Buffer
+	log := NewAuditLog(&buf, WithAuditSampling(AuditSampling{MaxPerSecond: 2}))
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	log.now = func() time.Time { return now }
+
+	for range 5 {
+		log.ObserveDecision(testDecision(false))
+	}
+	assert.Equal(t, 2, strings.Count(buf.String(), "\n"))
+	assert.Equal(t, uint64(3), log.Dropped())
+
+	now = now.Add(time.Second)
+	log.ObserveDecision(testDecision(false))
+	assert.Equal(t, 3, strings.Count(buf.String(), "\n"))
+}
+
+func TestListenerAuditLog(t *testing.T) {
+	acl, err := NewNetworkACL(NetworkACLConfig{AllowedNets: []string{"127.0.0.0/8"}})
+	require.NoError(t, err)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	l := &Listener{
+		NetworkACL: acl,
+		Listener:   ln,
+		Logger:     zerolog.Nop(),
+		AuditLog:   NewAuditLog(&buf),
+	}
+	defer l.Close()
+
+	client, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	defer client.Close()
+
+	conn, err := l.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "allow", record["decision"])
+	assert.Equal(t, ReasonAllowList, record["reason"])
+	assert.Equal(t, client.LocalAddr().String(), record["remote_addr"])
+	assert.Equal(t, ln.Addr().String(), record["local_addr"])
+}
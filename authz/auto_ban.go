@@ -0,0 +1,283 @@
+package authz
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// Defaults for an AutoBan.
+const (
+	// DefaultBanThreshold is the number of failures within DefaultBanWindow that trigger
+	// a ban.
+	DefaultBanThreshold = 5
+	// DefaultBanWindow is the period over which failures are counted.
+	DefaultBanWindow = 1 * time.Minute
+	// DefaultBanDuration is the length of a first ban. Each further ban of the same
+	// network doubles it, up to DefaultMaxBanDuration.
+	DefaultBanDuration = 1 * time.Minute
+	// DefaultMaxBanDuration caps the length of a ban.
+	DefaultMaxBanDuration = 24 * time.Hour
+	// DefaultBanResetAfter is how long after a ban ends that a network's ban history is
+	// forgotten, so its next ban is a first ban again.
+	DefaultBanResetAfter = 24 * time.Hour
+)
+
+// AutoBanOption configures an AutoBan.
+type AutoBanOption func(*AutoBan)
+
+// WithBanThreshold sets how many failures within window trigger a ban.
+func WithBanThreshold(failures int, window time.Duration) AutoBanOption {
+	return func(b *AutoBan) {
+		b.threshold = failures
+		b.window = window
+	}
+}
+
+// WithBanDuration sets the length of a first ban and the cap that repeated bans, each
+// twice as long as the last, escalate to.
+func WithBanDuration(initial, maximum time.Duration) AutoBanOption {
+	return func(b *AutoBan) {
+		b.duration = initial
+		b.maxDuration = maximum
+	}
+}
+
+// WithBanResetAfter sets how long after a ban ends that a network's ban history is
+// forgotten.
+func WithBanResetAfter(d time.Duration) AutoBanOption {
+	return func(b *AutoBan) {
+		b.resetAfter = d
+	}
+}
+
+// WithBanPrefixLengths sets the size of the network banned for a failing address, e.g.
+// 24 and 64 to ban the /24 or /64 the address is in. The default bans single addresses.
+func WithBanPrefixLengths(ipv4, ipv6 int) AutoBanOption {
+	return func(b *AutoBan) {
+		b.ipv4Bits = ipv4
+		b.ipv6Bits = ipv6
+	}
+}
+
+// WithBanLogger sets the logger used to record bans and their expiry.
+func WithBanLogger(logger zerolog.Logger) AutoBanOption {
+	return func(b *AutoBan) {
+		b.logger = logger
+	}
+}
+
+// Ban describes a network banned by an AutoBan.
+type Ban struct {
+	// Network is the banned network in CIDR notation.
+	Network string
+	// Until is when the ban expires.
+	Until time.Time
+	// Count is the number of times the network has been banned, including this ban.
+	Count int
+}
+
+type banRecord struct {
+	failures []time.Time
+	count    int
+	until    time.Time
+	ended    time.Time
+	added    bool
+}
+
+// AutoBan temporarily adds networks to the deny list of a NetworkACL after repeated
+// failures from them, in the manner of fail2ban.
+//
+// Failures are reported with Report, e.g. by an authentication handler, or observed as
+// denied decisions when the AutoBan is set as a DecisionObserver, e.g. on a Listener.
+// Once a network reaches the failure threshold it is banned, for twice as long as its
+// previous ban, and Run or Expire lifts the ban when it ends. Networks that were already
+// in the deny list are left there.
+type AutoBan struct {
+	acl         *NetworkACL
+	threshold   int
+	window      time.Duration
+	duration    time.Duration
+	maxDuration time.Duration
+	resetAfter  time.Duration
+	ipv4Bits    int
+	ipv6Bits    int
+	logger      zerolog.Logger
+	now         func() time.Time
+
+	mu      sync.Mutex
+	records map[netip.Prefix]*banRecord
+}
+
+// NewAutoBan creates an AutoBan that bans networks in acl.
+func NewAutoBan(acl *NetworkACL, opts ...AutoBanOption) *AutoBan {
+	b := &AutoBan{
+		acl:         acl,
+		threshold:   DefaultBanThreshold,
+		window:      DefaultBanWindow,
+		duration:    DefaultBanDuration,
+		maxDuration: DefaultMaxBanDuration,
+		resetAfter:  DefaultBanResetAfter,
+		ipv4Bits:    32,
+		ipv6Bits:    128,
+		logger:      zerolog.Nop(),
+		now:         time.Now,
+		records:     make(map[netip.Prefix]*banRecord),
+	}
+
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	return b
+}
+
+// ObserveDecision reports a failure for each denied decision. Decisions let through by
+// Listener.AuditOnly are ignored.
+func (b *AutoBan) ObserveDecision(d Decision) {
+	if d.Allowed || d.AuditOnly || d.RemoteAddr == nil {
+		return
+	}
+	addrPort, err := addrPortFromAddr(d.RemoteAddr)
+	if err != nil {
+		return
+	}
+	b.Report(addrPort.Addr())
+}
+
+// Report records a failure from addr, banning its network if it has reached the
+// threshold. Failures from a network that is already banned are ignored.
+func (b *AutoBan) Report(addr netip.Addr) {
+	network, ok := b.network(addr)
+	if !ok {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	record, ok := b.records[network]
+	if !ok {
+		record = &banRecord{}
+		b.records[network] = record
+	}
+	if !record.until.IsZero() {
+		return
+	}
+
+	record.failures = append(pruneFailures(record.failures, now.Add(-b.window)), now)
+	if len(record.failures) < b.threshold {
+		return
+	}
+
+	if !record.ended.IsZero() && now.Sub(record.ended) >= b.resetAfter {
+		record.count = 0
+	}
+	record.count++
+	record.failures = nil
+	record.until = now.Add(b.banDuration(record.count))
+	record.added = b.acl.addDenied(prefixIPNet(network))
+
+	b.logger.Warn().
+		Str("network", network.String()).
+		Time("until", record.until).
+		Int("count", record.count).
+		Msg("network banned")
+}
+
+// Expire lifts the bans that have ended and forgets networks with no recent failures
+// or bans.
+func (b *AutoBan) Expire() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	for network, record := range b.records {
+		if !record.until.IsZero() && !now.Before(record.until) {
+			if record.added {
+				b.acl.removeDenied(prefixIPNet(network))
+			}
+			record.until = time.Time{}
+			record.ended = now
+			record.added = false
+			b.logger.Info().Str("network", network.String()).Msg("network ban expired")
+		}
+
+		record.failures = pruneFailures(record.failures, now.Add(-b.window))
+		if record.until.IsZero() && len(record.failures) == 0 &&
+			(record.ended.IsZero() || now.Sub(record.ended) >= b.resetAfter) {
+			delete(b.records, network)
+		}
+	}
+}
+
+// Run expires bans every interval until ctx is cancelled.
+func (b *AutoBan) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			b.Expire()
+		}
+	}
+}
+
+// Bans returns the current bans, ordered by network.
+func (b *AutoBan) Bans() []Ban {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var networks []netip.Prefix
+	for network, record := range b.records {
+		if !record.until.IsZero() {
+			networks = append(networks, network)
+		}
+	}
+	slices.SortFunc(networks, netip.Prefix.Compare)
+
+	bans := make([]Ban, len(networks))
+	for i, network := range networks {
+		record := b.records[network]
+		bans[i] = Ban{Network: network.String(), Until: record.until, Count: record.count}
+	}
+	return bans
+}
+
+// network returns the network to ban for addr.
+func (b *AutoBan) network(addr netip.Addr) (netip.Prefix, bool) {
+	addr = addr.Unmap().WithZone("")
+	bits := b.ipv6Bits
+	if addr.Is4() {
+		bits = b.ipv4Bits
+	}
+	network, err := addr.Prefix(bits)
+	return network, err == nil
+}
+
+// banDuration returns the length of the count'th ban of a network.
+func (b *AutoBan) banDuration(count int) time.Duration {
+	d := b.duration
+	for i := 1; i < count && d < b.maxDuration; i++ {
+		d *= 2
+	}
+	return min(d, b.maxDuration)
+}
+
+// pruneFailures returns failures without those before cutoff.
+func pruneFailures(failures []time.Time, cutoff time.Time) []time.Time {
+	return slices.DeleteFunc(failures, func(t time.Time) bool { return t.Before(cutoff) })
+}
+
+func prefixIPNet(p netip.Prefix) *net.IPNet {
+	return &net.IPNet{IP: net.IP(p.Addr().AsSlice()), Mask: net.CIDRMask(p.Bits(), p.Addr().BitLen())}
+}
@@ -0,0 +1,138 @@
+package authz
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestAutoBan(t *testing.T, config NetworkACLConfig, opts ...AutoBanOption) (*AutoBan, *NetworkACL, *time.Time) {
+	t.Helper()
+	acl, err := NewNetworkACL(config)
+	require.NoError(t, err)
+
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := NewAutoBan(acl, opts...)
+	b.now = func() time.Time { return now }
+	return b, acl, &now
+}
+
+func TestAutoBanEscalation(t *testing.T) {
+	b, acl, now := newTestAutoBan(t, NetworkACLConfig{AllowByDefault: true},
+		WithBanThreshold(3, time.Minute),
+		WithBanDuration(time.Minute, 3*time.Minute),
+		WithBanResetAfter(time.Hour),
+	)
+	addr := netip.MustParseAddr("192.0.2.1")
+
+	ban := func() {
+		t.Helper()
+		for range 3 {
+			b.Report(addr)
+		}
+	}
+
+	b.Report(addr)
+	b.Report(addr)
+	assert.True(t, acl.AuthoriseIP(addr))
+	assert.Empty(t, b.Bans())
+
+	b.Report(addr)
+	assert.False(t, acl.AuthoriseIP(addr))
+	assert.Equal(t, []Ban{{Network: "192.0.2.1/32", Until: now.Add(time.Minute), Count: 1}}, b.Bans())
+
+	// failures while banned are not counted
+	for range 5 {
+		b.Report(addr)
+	}
+	*now = now.Add(time.Minute)
+	b.Expire()
+	assert.True(t, acl.AuthoriseIP(addr))
+	assert.Empty(t, b.Bans())
+	assert.Empty(t, acl.ListDenied())
+
+	want := []time.Duration{2 * time.Minute, 3 * time.Minute, 3 * time.Minute}
+	for i, d := range want {
+		ban()
+		bans := b.Bans()
+		require.Len(t, bans, 1)
+		assert.Equal(t, now.Add(d), bans[0].Until)
+		assert.Equal(t, i+2, bans[0].Count)
+
+		*now = now.Add(d)
+		b.Expire()
+	}
+
+	// the history is forgotten once the reset period has passed
+	*now = now.Add(time.Hour)
+	b.Expire()
+	ban()
+	assert.Equal(t, []Ban{{Network: "192.0.2.1/32", Until: now.Add(time.Minute), Count: 1}}, b.Bans())
+}
+
+func TestAutoBanWindow(t *testing.T) {
+	b, acl, now := newTestAutoBan(t, NetworkACLConfig{AllowByDefault: true}, WithBanThreshold(2, time.Minute))
+	addr := netip.MustParseAddr("192.0.2.1")
+
+	b.Report(addr)
+	*now = now.Add(time.Minute + time.Second)
+	b.Report(addr)
+	assert.True(t, acl.AuthoriseIP(addr))
+
+	*now = now.Add(time.Second)
+	b.Report(addr)
+	assert.False(t, acl.AuthoriseIP(addr))
+}
+
+func TestAutoBanPrefixLengths(t *testing.T) {
+	b, acl, _ := newTestAutoBan(t, NetworkACLConfig{AllowByDefault: true},
+		WithBanThreshold(2, time.Minute),
+		WithBanPrefixLengths(24, 64),
+	)
+
+	b.Report(netip.MustParseAddr("192.0.2.1"))
+	b.Report(netip.MustParseAddr("::ffff:192.0.2.200"))
+	b.Report(netip.MustParseAddr("2001:db8::1"))
+	b.Report(netip.MustParseAddr("2001:db8::2"))
+
+	assert.ElementsMatch(t, []string{"192.0.2.0/24", "2001:db8::/64"}, acl.ListDenied())
+	assert.False(t, acl.AuthoriseIP(netip.MustParseAddr("192.0.2.99")))
+	assert.False(t, acl.AuthoriseIP(netip.MustParseAddr("2001:db8::99")))
+	assert.True(t, acl.AuthoriseIP(netip.MustParseAddr("198.51.100.1")))
+
+	bans := b.Bans()
+	require.Len(t, bans, 2)
+	assert.Equal(t, "192.0.2.0/24", bans[0].Network)
+	assert.Equal(t, "2001:db8::/64", bans[1].Network)
+}
+
+func TestAutoBanKeepsExistingDenials(t *testing.T) {
+	b, acl, now := newTestAutoBan(t, NetworkACLConfig{
+		AllowByDefault: true,
+		DeniedNets:     []string{"192.0.2.1/32"},
+	}, WithBanThreshold(1, time.Minute))
+
+	b.Report(netip.MustParseAddr("192.0.2.1"))
+	*now = now.Add(DefaultBanDuration)
+	b.Expire()
+
+	assert.Equal(t, []string{"192.0.2.1/32"}, acl.ListDenied())
+}
+
+func TestAutoBanObserveDecision(t *testing.T) {
+	b, acl, _ := newTestAutoBan(t, NetworkACLConfig{}, WithBanThreshold(2, time.Minute))
+	remote := &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 50000}
+
+	b.ObserveDecision(Decision{RemoteAddr: remote, Allowed: true})
+	b.ObserveDecision(Decision{RemoteAddr: remote, AuditOnly: true})
+	b.ObserveDecision(Decision{})
+	assert.Empty(t, acl.ListDenied())
+
+	b.ObserveDecision(Decision{RemoteAddr: remote})
+	b.ObserveDecision(Decision{RemoteAddr: remote})
+	assert.Equal(t, []string{"192.0.2.1/32"}, acl.ListDenied())
+}
@@ -0,0 +1,40 @@
+package authz
+
+import (
+	"net"
+	"time"
+)
+
+// Decision describes an authorisation decision, as passed to a DecisionObserver.
+type Decision struct {
+	// RemoteAddr is the address that was authorised.
+	RemoteAddr net.Addr
+	// LocalAddr is the address the connection was accepted on, if known.
+	LocalAddr net.Addr
+	// Allowed is the outcome of the decision.
+	Allowed bool
+	// Reason is the rule that made the decision, one of the Reason constants.
+	Reason string
+	// Time is when the decision was made.
+	Time time.Time
+	// Duration is how long the decision took.
+	Duration time.Duration
+	// AuditOnly is set by Listener when a denied connection was let through because
+	// Listener.AuditOnly is set. Allowed is false in that case.
+	AuditOnly bool
+}
+
+// DecisionObserver is notified of authorisation decisions, e.g. to feed a SIEM pipeline
+// or metrics. It is called synchronously on the accepting or authorising goroutine, so
+// it must be safe for concurrent use and should not block.
+type DecisionObserver interface {
+	ObserveDecision(d Decision)
+}
+
+// DecisionObserverFunc adapts a function to a DecisionObserver.
+type DecisionObserverFunc func(d Decision)
+
+// ObserveDecision calls f(d).
+func (f DecisionObserverFunc) ObserveDecision(d Decision) {
+	f(d)
+}
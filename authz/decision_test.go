@@ -0,0 +1,105 @@
+package authz
+
+import (
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingObserver records every Decision it observes.
+type recordingObserver struct {
+	mu        sync.Mutex
+	decisions []Decision
+}
+
+func (o *recordingObserver) ObserveDecision(d Decision) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.decisions = append(o.decisions, d)
+}
+
+func (o *recordingObserver) get() []Decision {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]Decision(nil), o.decisions...)
+}
+
+// fixedAuthoriser makes the same decision, or returns the same error, for every
+// connection.
+type fixedAuthoriser struct {
+	allowed bool
+	reason  string
+	err     error
+}
+
+func (a fixedAuthoriser) AuthoriseConnWithReason(net.Conn) (bool, string, error) {
+	return a.allowed, a.reason, a.err
+}
+
+func TestListenerDecisionObserverAuthoriser(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	observer := &recordingObserver{}
+	l := &Listener{
+		Authoriser:       fixedAuthoriser{allowed: true, reason: ReasonOPA},
+		Listener:         ln,
+		Logger:           zerolog.Nop(),
+		DecisionObserver: observer,
+	}
+	defer l.Close()
+
+	client, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	defer client.Close()
+
+	conn, err := l.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	decisions := observer.get()
+	require.Len(t, decisions, 1)
+	assert.Equal(t, conn.RemoteAddr(), decisions[0].RemoteAddr)
+	assert.Equal(t, conn.LocalAddr(), decisions[0].LocalAddr)
+	assert.True(t, decisions[0].Allowed)
+	assert.Equal(t, ReasonOPA, decisions[0].Reason)
+	assert.False(t, decisions[0].Time.IsZero())
+}
+
+func TestListenerDecisionObserver(t *testing.T) {
+	acl, err := NewNetworkACL(NetworkACLConfig{DeniedNets: []string{"127.0.0.0/8"}})
+	require.NoError(t, err)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	var decisions []Decision
+	l := &Listener{
+		NetworkACL: acl,
+		Listener:   ln,
+		Logger:     zerolog.Nop(),
+		AuditOnly:  true,
+		DecisionObserver: DecisionObserverFunc(func(d Decision) {
+			decisions = append(decisions, d)
+		}),
+	}
+	defer l.Close()
+
+	client, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	defer client.Close()
+
+	conn, err := l.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.Len(t, decisions, 1)
+	assert.Equal(t, conn.RemoteAddr(), decisions[0].RemoteAddr)
+	assert.False(t, decisions[0].Allowed)
+	assert.True(t, decisions[0].AuditOnly)
+	assert.Equal(t, ReasonDenyList, decisions[0].Reason)
+}
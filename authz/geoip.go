@@ -0,0 +1,146 @@
+package authz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// GeoIPLocation is the location of an IP address as reported by a GeoIPReader.
+type GeoIPLocation struct {
+	// CountryCode is the ISO 3166-1 alpha-2 country code, e.g. "GB".
+	CountryCode string
+	// ContinentCode is the two-letter continent code, e.g. "EU".
+	ContinentCode string
+}
+
+// GeoIPReader looks up the location of an IP address, for example by wrapping a MaxMind
+// GeoIP2 or GeoLite2 Country database reader. If the reader implements io.Closer it is
+// closed when replaced by GeoIPDatabase.Reload.
+type GeoIPReader interface {
+	Lookup(addr netip.Addr) (GeoIPLocation, error)
+}
+
+// GeoIPOpenFunc opens a GeoIPReader, typically from a database file on disk.
+type GeoIPOpenFunc func() (GeoIPReader, error)
+
+// GeoIPDatabase holds a GeoIPReader that can be reloaded, e.g. when a newer database is
+// downloaded, without interrupting lookups.
+type GeoIPDatabase struct {
+	open GeoIPOpenFunc
+
+	mu     sync.RWMutex
+	reader GeoIPReader
+}
+
+// NewGeoIPDatabase opens a reader with open and returns a GeoIPDatabase that uses open
+// again on each Reload.
+func NewGeoIPDatabase(open GeoIPOpenFunc) (*GeoIPDatabase, error) {
+	d := &GeoIPDatabase{open: open}
+	if err := d.Reload(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Reload opens a new reader and swaps it in. The previous reader is closed if it
+// implements io.Closer. If opening fails the current reader is kept.
+func (d *GeoIPDatabase) Reload() error {
+	reader, err := d.open()
+	if err != nil {
+		return fmt.Errorf("failed to open geoip database: %w", err)
+	}
+
+	d.mu.Lock()
+	previous := d.reader
+	d.reader = reader
+	d.mu.Unlock()
+
+	if closer, ok := previous.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			return fmt.Errorf("failed to close previous geoip database: %w", err)
+		}
+	}
+	return nil
+}
+
+// Run reloads the database every interval until ctx is cancelled. Failed reloads are
+// logged to the logger in ctx and the current reader is kept.
+func (d *GeoIPDatabase) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := d.Reload(); err != nil {
+				zerolog.Ctx(ctx).Warn().Err(err).Msg("geoip database reload failed")
+			}
+		}
+	}
+}
+
+// Lookup returns the location of addr from the current reader. A reader is not closed
+// by Reload while lookups using it are in progress.
+func (d *GeoIPDatabase) Lookup(addr netip.Addr) (GeoIPLocation, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if d.reader == nil {
+		return GeoIPLocation{}, errors.New("no geoip database loaded")
+	}
+	return d.reader.Lookup(addr)
+}
+
+// geoIPRules holds the upper-cased country and continent codes of a NetworkACLConfig.
+type geoIPRules struct {
+	allowCountries  map[string]bool
+	denyCountries   map[string]bool
+	allowContinents map[string]bool
+	denyContinents  map[string]bool
+}
+
+func newGeoIPRules(cfg NetworkACLConfig) geoIPRules {
+	return geoIPRules{
+		allowCountries:  codeSet(cfg.AllowedCountries),
+		denyCountries:   codeSet(cfg.DeniedCountries),
+		allowContinents: codeSet(cfg.AllowedContinents),
+		denyContinents:  codeSet(cfg.DeniedContinents),
+	}
+}
+
+func codeSet(codes []string) map[string]bool {
+	set := make(map[string]bool, len(codes))
+	for _, code := range codes {
+		set[strings.ToUpper(strings.TrimSpace(code))] = true
+	}
+	return set
+}
+
+func (r geoIPRules) empty() bool {
+	return len(r.allowCountries) == 0 && len(r.denyCountries) == 0 &&
+		len(r.allowContinents) == 0 && len(r.denyContinents) == 0
+}
+
+// decide applies the rules to location, with deny rules taking precedence.
+func (r geoIPRules) decide(location GeoIPLocation) (allowed bool, reason string, matched bool) {
+	country := strings.ToUpper(location.CountryCode)
+	continent := strings.ToUpper(location.ContinentCode)
+
+	if (country != "" && r.denyCountries[country]) || (continent != "" && r.denyContinents[continent]) {
+		return false, ReasonDenyGeoIP, true
+	}
+	if (country != "" && r.allowCountries[country]) || (continent != "" && r.allowContinents[continent]) {
+		return true, ReasonAllowGeoIP, true
+	}
+	return false, "", false
+}
@@ -0,0 +1,118 @@
+package authz
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mapGeoIPReader is a GeoIPReader backed by a map, recording whether it was closed.
+type mapGeoIPReader struct {
+	locations map[netip.Addr]GeoIPLocation
+	closed    bool
+}
+
+func (r *mapGeoIPReader) Lookup(addr netip.Addr) (GeoIPLocation, error) {
+	location, ok := r.locations[addr]
+	if !ok {
+		return GeoIPLocation{}, errors.New("address not found")
+	}
+	return location, nil
+}
+
+func (r *mapGeoIPReader) Close() error {
+	r.closed = true
+	return nil
+}
+
+func TestNetworkACLGeoIP(t *testing.T) {
+	reader := &mapGeoIPReader{locations: map[netip.Addr]GeoIPLocation{
+		netip.MustParseAddr("192.0.2.1"):    {CountryCode: "GB", ContinentCode: "EU"},
+		netip.MustParseAddr("192.0.2.2"):    {CountryCode: "FR", ContinentCode: "EU"},
+		netip.MustParseAddr("192.0.2.3"):    {CountryCode: "RU", ContinentCode: "EU"},
+		netip.MustParseAddr("192.0.2.4"):    {CountryCode: "US", ContinentCode: "NA"},
+		netip.MustParseAddr("198.51.100.1"): {CountryCode: "RU", ContinentCode: "EU"},
+		netip.MustParseAddr("198.51.100.2"): {CountryCode: "GB", ContinentCode: "EU"},
+	}}
+
+	acl, err := NewNetworkACL(NetworkACLConfig{
+		AllowedNets:       []string{"198.51.100.1"},
+		DeniedNets:        []string{"198.51.100.2"},
+		AllowedCountries:  []string{"gb"},
+		AllowedContinents: []string{"EU"},
+		DeniedCountries:   []string{"RU"},
+		GeoIP:             reader,
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		addr        string
+		wantAllowed bool
+		wantReason  string
+	}{
+		{addr: "192.0.2.1", wantAllowed: true, wantReason: ReasonAllowGeoIP},
+		{addr: "192.0.2.2", wantAllowed: true, wantReason: ReasonAllowGeoIP},
+		{addr: "192.0.2.3", wantAllowed: false, wantReason: ReasonDenyGeoIP},
+		{addr: "192.0.2.4", wantAllowed: false, wantReason: ReasonDefaultDeny},
+		{addr: "203.0.113.1", wantAllowed: false, wantReason: ReasonDefaultDeny},
+		// CIDR rules take precedence over location rules
+		{addr: "198.51.100.1", wantAllowed: true, wantReason: ReasonAllowList},
+		{addr: "198.51.100.2", wantAllowed: false, wantReason: ReasonDenyList},
+	}
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			allowed, reason := acl.AuthoriseWithReason(&net.TCPAddr{IP: net.ParseIP(tt.addr)})
+			assert.Equal(t, tt.wantAllowed, allowed)
+			assert.Equal(t, tt.wantReason, reason)
+		})
+	}
+
+	_, err = NewNetworkACL(NetworkACLConfig{AllowedCountries: []string{"GB"}})
+	assert.Error(t, err, "country rules without a GeoIP reader")
+}
+
+func TestGeoIPDatabaseReload(t *testing.T) {
+	addr := netip.MustParseAddr("192.0.2.1")
+	readers := []*mapGeoIPReader{
+		{locations: map[netip.Addr]GeoIPLocation{addr: {CountryCode: "GB"}}},
+		{locations: map[netip.Addr]GeoIPLocation{addr: {CountryCode: "FR"}}},
+	}
+	opened := 0
+	var openErr error
+	db, err := NewGeoIPDatabase(func() (GeoIPReader, error) {
+		if openErr != nil {
+			return nil, openErr
+		}
+		r := readers[opened]
+		opened++
+		return r, nil
+	})
+	require.NoError(t, err)
+
+	acl, err := NewNetworkACL(NetworkACLConfig{AllowedCountries: []string{"GB"}, GeoIP: db})
+	require.NoError(t, err)
+	assert.True(t, acl.Authorise(&net.TCPAddr{IP: net.ParseIP("192.0.2.1")}))
+
+	require.NoError(t, db.Reload())
+	assert.True(t, readers[0].closed, "previous reader should be closed")
+	assert.False(t, acl.Authorise(&net.TCPAddr{IP: net.ParseIP("192.0.2.1")}))
+
+	openErr = errors.New("corrupt database")
+	assert.Error(t, db.Reload())
+	location, err := db.Lookup(addr)
+	require.NoError(t, err)
+	assert.Equal(t, "FR", location.CountryCode, "failed reload should keep the current reader")
+
+	_, err = NewGeoIPDatabase(func() (GeoIPReader, error) { return nil, errors.New("missing") })
+	assert.Error(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	db.Run(ctx, time.Hour) // returns once ctx is cancelled
+}
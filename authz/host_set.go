@@ -0,0 +1,244 @@
+package authz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// DefaultNotFoundGracePeriod is how long a host that no longer exists keeps its last
+// resolved addresses before they are dropped.
+var DefaultNotFoundGracePeriod = 1 * time.Hour
+
+// HostResolver resolves a hostname to IP addresses. *net.Resolver satisfies it.
+type HostResolver interface {
+	LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error)
+}
+
+// HostSetOption configures a HostSet.
+type HostSetOption func(*HostSet)
+
+// WithHostResolver sets the resolver used to look up hosts. The default is net.DefaultResolver.
+func WithHostResolver(resolver HostResolver) HostSetOption {
+	return func(s *HostSet) {
+		s.resolver = resolver
+	}
+}
+
+// WithNotFoundGracePeriod sets how long a host whose name permanently fails to resolve
+// (NXDOMAIN) keeps its last resolved addresses before they are dropped.
+func WithNotFoundGracePeriod(d time.Duration) HostSetOption {
+	return func(s *HostSet) {
+		s.notFoundGracePeriod = d
+	}
+}
+
+// WithHostSetLogger sets the logger used to record resolution state changes.
+func WithHostSetLogger(logger zerolog.Logger) HostSetOption {
+	return func(s *HostSet) {
+		s.logger = logger
+	}
+}
+
+type hostState string
+
+const (
+	hostStateResolved  hostState = "resolved"
+	hostStateTransient hostState = "transient-failure"
+	hostStateNotFound  hostState = "not-found"
+	hostStateDropped   hostState = "dropped"
+)
+
+type hostEntry struct {
+	addrs         []netip.Addr
+	state         hostState
+	notFoundSince time.Time
+}
+
+// HostSet is a set of addresses resolved from hostnames, for use in ACLs that trust
+// hosts by name.
+//
+// Resolution failures are classified so that trust is neither flapping nor stale:
+// transient failures (timeouts, temporary DNS errors) keep the last good addresses,
+// while a host whose name is not found keeps its addresses only for the not-found
+// grace period and is then dropped, so a retired name stops being trusted.
+// Each change in a host's resolution state is logged.
+type HostSet struct {
+	hosts               []string
+	resolver            HostResolver
+	notFoundGracePeriod time.Duration
+	logger              zerolog.Logger
+	now                 func() time.Time
+
+	mu      sync.RWMutex
+	entries map[string]*hostEntry
+}
+
+// NewHostSet creates a HostSet for the given hostnames. Addresses are not resolved
+// until Refresh is called.
+func NewHostSet(hosts []string, opts ...HostSetOption) *HostSet {
+	s := &HostSet{
+		hosts:               slices.Clone(hosts),
+		resolver:            net.DefaultResolver,
+		notFoundGracePeriod: DefaultNotFoundGracePeriod,
+		logger:              zerolog.Nop(),
+		now:                 time.Now,
+		entries:             make(map[string]*hostEntry, len(hosts)),
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// Refresh resolves every host and updates the set. It returns the joined resolution
+// errors; hosts that fail keep or drop their addresses as described on HostSet.
+func (s *HostSet) Refresh(ctx context.Context) error {
+	var errs []error
+
+	for _, host := range s.hosts {
+		addrs, err := s.resolver.LookupNetIP(ctx, "ip", host)
+		s.update(host, addrs, err)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", host, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Run refreshes the set every interval until ctx is cancelled.
+func (s *HostSet) Run(ctx context.Context, interval time.Duration) {
+	_ = s.Refresh(ctx)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_ = s.Refresh(ctx)
+		}
+	}
+}
+
+func (s *HostSet) update(host string, addrs []netip.Addr, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[host]
+	if !ok {
+		entry = &hostEntry{}
+		s.entries[host] = entry
+	}
+
+	previous := entry.state
+	now := s.now()
+
+	switch {
+	case err == nil:
+		entry.addrs = unmapAddrs(addrs)
+		entry.state = hostStateResolved
+		entry.notFoundSince = time.Time{}
+	case isNotFound(err):
+		if entry.notFoundSince.IsZero() {
+			entry.notFoundSince = now
+		}
+		entry.state = hostStateNotFound
+		if entry.addrs == nil || now.Sub(entry.notFoundSince) >= s.notFoundGracePeriod {
+			entry.addrs = nil
+			entry.state = hostStateDropped
+		}
+	default:
+		// Transient or unclassified failures keep the last good addresses. A host that is
+		// already not found stays on its grace period clock.
+		if entry.state != hostStateNotFound && entry.state != hostStateDropped {
+			entry.state = hostStateTransient
+		}
+	}
+
+	if entry.state == previous {
+		return
+	}
+
+	event := s.logger.Info()
+	if err != nil {
+		event = s.logger.Warn().Err(err)
+	}
+	event.
+		Str("host", host).
+		Str("previousState", string(previous)).
+		Str("state", string(entry.state)).
+		Int("addresses", len(entry.addrs)).
+		Msg("host resolution state changed")
+}
+
+// isNotFound reports whether err is a permanent "no such host" resolution failure.
+func isNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	if !errors.As(err, &dnsErr) {
+		return false
+	}
+	return dnsErr.IsNotFound && !dnsErr.IsTemporary && !dnsErr.IsTimeout
+}
+
+func unmapAddrs(addrs []netip.Addr) []netip.Addr {
+	result := make([]netip.Addr, len(addrs))
+	for i, addr := range addrs {
+		result[i] = addr.Unmap()
+	}
+	return result
+}
+
+// Contains reports whether ip is one of the currently trusted host addresses.
+func (s *HostSet) Contains(ip net.IP) bool {
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return false
+	}
+	addr = addr.Unmap()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, entry := range s.entries {
+		if slices.Contains(entry.addrs, addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Addrs returns a copy of the currently trusted addresses for host.
+func (s *HostSet) Addrs(host string) []netip.Addr {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entry, ok := s.entries[host]
+	if !ok {
+		return nil
+	}
+	return slices.Clone(entry.addrs)
+}
+
+// AllowFunc returns an AllowFunc that allows addresses in the set and leaves every other
+// address to the NetworkACL's allow and deny lists.
+func (s *HostSet) AllowFunc() AllowFunc {
+	return func(ip net.IP) (bool, bool) {
+		if s.Contains(ip) {
+			return true, true
+		}
+		return false, false
+	}
+}
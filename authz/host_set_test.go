@@ -0,0 +1,84 @@
+package authz
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeHostResolver struct {
+	addrs []netip.Addr
+	err   error
+}
+
+func (r *fakeHostResolver) LookupNetIP(_ context.Context, _, _ string) ([]netip.Addr, error) {
+	return r.addrs, r.err
+}
+
+func TestHostSet_FailureClassification(t *testing.T) {
+	resolver := &fakeHostResolver{addrs: []netip.Addr{netip.MustParseAddr("192.0.2.10")}}
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	s := NewHostSet([]string{"svc.example.com"},
+		WithHostResolver(resolver),
+		WithNotFoundGracePeriod(10*time.Minute),
+	)
+	s.now = func() time.Time { return now }
+
+	ip := net.ParseIP("192.0.2.10")
+	ctx := context.Background()
+
+	require.NoError(t, s.Refresh(ctx))
+	assert.True(t, s.Contains(ip))
+
+	// transient failures keep the last good addresses
+	resolver.err = &net.DNSError{Err: "i/o timeout", Name: "svc.example.com", IsTimeout: true, IsTemporary: true}
+	resolver.addrs = nil
+	require.Error(t, s.Refresh(ctx))
+	assert.True(t, s.Contains(ip))
+
+	// not found keeps addresses during the grace period
+	resolver.err = &net.DNSError{Err: "no such host", Name: "svc.example.com", IsNotFound: true}
+	require.Error(t, s.Refresh(ctx))
+	assert.True(t, s.Contains(ip))
+
+	now = now.Add(5 * time.Minute)
+	require.Error(t, s.Refresh(ctx))
+	assert.True(t, s.Contains(ip), "within grace period")
+
+	// a transient failure does not restart the grace period
+	resolver.err = &net.DNSError{Err: "server misbehaving", Name: "svc.example.com", IsTemporary: true}
+	now = now.Add(1 * time.Minute)
+	require.Error(t, s.Refresh(ctx))
+	assert.True(t, s.Contains(ip))
+
+	resolver.err = &net.DNSError{Err: "no such host", Name: "svc.example.com", IsNotFound: true}
+	now = now.Add(5 * time.Minute)
+	require.Error(t, s.Refresh(ctx))
+	assert.False(t, s.Contains(ip), "dropped after grace period")
+	assert.Empty(t, s.Addrs("svc.example.com"))
+
+	// resolving again restores trust
+	resolver.err = nil
+	resolver.addrs = []netip.Addr{netip.MustParseAddr("192.0.2.11")}
+	require.NoError(t, s.Refresh(ctx))
+	assert.False(t, s.Contains(ip))
+	assert.True(t, s.Contains(net.ParseIP("192.0.2.11")))
+}
+
+func TestHostSet_AllowFunc(t *testing.T) {
+	resolver := &fakeHostResolver{addrs: []netip.Addr{netip.MustParseAddr("192.0.2.10")}}
+	s := NewHostSet([]string{"svc.example.com"}, WithHostResolver(resolver))
+	require.NoError(t, s.Refresh(context.Background()))
+
+	acl, err := NewNetworkACL(NetworkACLConfig{AllowFunc: s.AllowFunc()})
+	require.NoError(t, err)
+
+	assert.True(t, acl.Authorise(&net.TCPAddr{IP: net.ParseIP("192.0.2.10")}))
+	assert.False(t, acl.Authorise(&net.TCPAddr{IP: net.ParseIP("192.0.2.20")}))
+}
@@ -0,0 +1,49 @@
+package authz
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ListenerMetrics are Prometheus metrics for Listeners. A single set can be shared by
+// several Listeners, whose metrics are labelled with their Name.
+type ListenerMetrics struct {
+	// Connections counts decisions by listener, result ("allowed", "denied" or
+	// "would_deny" for connections let through by AuditOnly) and reason, one of the
+	// Reason constants or a reason reported by the Listener's Authoriser.
+	Connections *prometheus.CounterVec
+	// OpenConnections is the number of authorised connections that are open, by listener.
+	OpenConnections *prometheus.GaugeVec
+}
+
+// NewListenerMetrics creates a set of Listener metrics. They are not collected until
+// they are registered with Register.
+func NewListenerMetrics() *ListenerMetrics {
+	return &ListenerMetrics{
+		Connections: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "dioad_net_authz_connections_total",
+				Help: "Counter of connections authorised by a listener, by result and reason.",
+			},
+			[]string{"listener", "result", "reason"},
+		),
+		OpenConnections: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "dioad_net_authz_open_connections",
+				Help: "Gauge of authorised connections currently open.",
+			},
+			[]string{"listener"},
+		),
+	}
+}
+
+// Register registers the metrics with r.
+func (m *ListenerMetrics) Register(r prometheus.Registerer) {
+	r.MustRegister(
+		m.Connections,
+		m.OpenConnections,
+	)
+}
+
+func (m *ListenerMetrics) recordDecision(listener, result, reason string) {
+	m.Connections.WithLabelValues(listener, result, reason).Inc()
+}
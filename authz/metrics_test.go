@@ -0,0 +1,83 @@
+package authz
+
+import (
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListenerMetrics(t *testing.T) {
+	metrics := NewListenerMetrics()
+	metrics.Register(prometheus.NewRegistry())
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	acl, err := NewNetworkACL(NetworkACLConfig{AllowedNets: []string{"127.0.0.1"}})
+	require.NoError(t, err)
+
+	l := &Listener{NetworkACL: acl, Listener: ln, Logger: zerolog.Nop(), Name: "api", Metrics: metrics, DenyPolicy: DenyPolicyError}
+	defer l.Close()
+
+	client, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	defer client.Close()
+
+	conn, err := l.Accept()
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.Connections.WithLabelValues("api", "allowed", ReasonAllowList)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.OpenConnections.WithLabelValues("api")))
+
+	require.NoError(t, conn.Close())
+	assert.Error(t, conn.Close())
+	assert.Equal(t, 0.0, testutil.ToFloat64(metrics.OpenConnections.WithLabelValues("api")))
+
+	require.NoError(t, acl.RemoveAllowCIDR("127.0.0.1"))
+	denied, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	defer denied.Close()
+
+	_, err = l.Accept()
+	require.ErrorIs(t, err, ErrDenied)
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.Connections.WithLabelValues("api", "denied", ReasonDefaultDeny)))
+	assert.Equal(t, 0.0, testutil.ToFloat64(metrics.OpenConnections.WithLabelValues("api")))
+
+	// Concurrent closes, as made by HTTP servers, decrement the gauge once
+	require.NoError(t, acl.AllowCIDR("127.0.0.1"))
+	concurrent, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	defer concurrent.Close()
+
+	conn, err = l.Accept()
+	require.NoError(t, err)
+
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Go(func() {
+			<-start
+			_ = conn.Close()
+		})
+	}
+	close(start)
+	wg.Wait()
+	assert.Equal(t, 0.0, testutil.ToFloat64(metrics.OpenConnections.WithLabelValues("api")))
+	require.NoError(t, acl.RemoveAllowCIDR("127.0.0.1"))
+
+	l.AuditOnly = true
+	audited, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	defer audited.Close()
+
+	conn, err = l.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.Connections.WithLabelValues("api", "would_deny", ReasonDefaultDeny)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.OpenConnections.WithLabelValues("api")))
+}
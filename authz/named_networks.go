@@ -0,0 +1,60 @@
+package authz
+
+import (
+	"net"
+	"slices"
+	"strings"
+)
+
+// namedNetworks are the built-in network sets that may be used by name in place of a
+// network in AllowedNets, DeniedNets and rule expressions.
+var namedNetworks = map[string][]string{
+	// RFC 1918 and RFC 4193 unique local addresses
+	"private": {"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7"},
+	// RFC 1122 and RFC 4291
+	"loopback": {"127.0.0.0/8", "::1/128"},
+	// RFC 3927 and RFC 4291
+	"link-local": {"169.254.0.0/16", "fe80::/10"},
+	// RFC 6598 shared address space for carrier-grade NAT
+	"cgnat": {"100.64.0.0/10"},
+	// RFC 5771 and RFC 4291
+	"multicast": {"224.0.0.0/4", "ff00::/8"},
+}
+
+// NamedNetworks returns the names of the built-in network sets, which may be used in
+// place of a network in NetworkACLConfig.AllowedNets and DeniedNets and in rule
+// expressions, e.g. DeniedNets: []string{"private", "cgnat"}.
+func NamedNetworks() []string {
+	names := make([]string, 0, len(namedNetworks))
+	for name := range namedNetworks {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// NamedNetwork returns the networks of the built-in network set called name, matched
+// case-insensitively, in CIDR notation.
+func NamedNetwork(name string) ([]string, bool) {
+	networks, ok := namedNetworks[strings.ToLower(name)]
+	return slices.Clone(networks), ok
+}
+
+// parseNets parses networks, expanding the names of built-in network sets.
+func parseNets(networks []string) ([]*net.IPNet, error) {
+	var result []*net.IPNet
+	for _, n := range networks {
+		expanded, ok := NamedNetwork(n)
+		if !ok {
+			expanded = []string{n}
+		}
+		for _, e := range expanded {
+			ipNet, err := parseTCPNet(e)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, ipNet)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,72 @@
+package authz
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNamedNetworks(t *testing.T) {
+	assert.Equal(t, []string{"cgnat", "link-local", "loopback", "multicast", "private"}, NamedNetworks())
+
+	networks, ok := NamedNetwork("CGNAT")
+	require.True(t, ok)
+	assert.Equal(t, []string{"100.64.0.0/10"}, networks)
+
+	// the result is a copy
+	networks[0] = "0.0.0.0/0"
+	networks, _ = NamedNetwork("cgnat")
+	assert.Equal(t, []string{"100.64.0.0/10"}, networks)
+
+	_, ok = NamedNetwork("public")
+	assert.False(t, ok)
+}
+
+func TestNetworkACLNamedNetworks(t *testing.T) {
+	acl, err := NewNetworkACL(NetworkACLConfig{
+		AllowedNets: []string{"private", "loopback"},
+		DeniedNets:  []string{"192.168.1.0/24", "cgnat"},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{ip: "10.1.2.3", want: true},
+		{ip: "172.31.255.255", want: true},
+		{ip: "172.32.0.1", want: false},
+		{ip: "192.168.2.1", want: true},
+		{ip: "192.168.1.1", want: false},
+		{ip: "fd00::1", want: true},
+		{ip: "127.0.0.1", want: true},
+		{ip: "::1", want: true},
+		{ip: "100.64.0.1", want: false},
+		{ip: "8.8.8.8", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			assert.Equal(t, tt.want, acl.AuthoriseIP(netip.MustParseAddr(tt.ip)))
+		})
+	}
+
+	assert.Contains(t, acl.ListDenied(), "100.64.0.0/10")
+}
+
+func TestNamedNetworksInRulesAndMerge(t *testing.T) {
+	rule, err := ParseRule("ip in [link-local, multicast]")
+	require.NoError(t, err)
+	assert.True(t, rule.Match(ConnMetadata{RemoteAddr: netip.MustParseAddrPort("169.254.1.1:80")}))
+	assert.True(t, rule.Match(ConnMetadata{RemoteAddr: netip.MustParseAddrPort("[ff02::1]:80")}))
+	assert.False(t, rule.Match(ConnMetadata{RemoteAddr: netip.MustParseAddrPort("10.0.0.1:80")}))
+
+	merged, err := MergeConfigs(
+		NetworkACLConfig{DeniedNets: []string{"private", "10.0.0.0/8"}},
+		NetworkACLConfig{DeniedNets: []string{"Private", "cgnat"}},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"private", "10.0.0.0/8", "cgnat"}, merged.DeniedNets)
+}
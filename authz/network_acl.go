@@ -2,42 +2,317 @@
 package authz
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net"
+	"net/netip"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/dioad/generics"
+	"github.com/rs/zerolog"
+
+	"github.com/dioad/net/authz/prefixlist"
+)
+
+// ErrNetworkNotFound is returned when removing a network that is not in the ACL.
+var ErrNetworkNotFound = errors.New("network not found")
+
+// Reasons reported by NetworkACL.AuthoriseWithReason for a decision.
+const (
+	// ReasonAllowFunc means the configured AllowFunc made the decision.
+	ReasonAllowFunc = "allow func"
+	// ReasonAllowList means the address is in the allow list and not the deny list.
+	ReasonAllowList = "allow list"
+	// ReasonAllowHost means the address is one that an AllowedHosts name resolves to, and
+	// is not denied.
+	ReasonAllowHost = "allow host"
+	// ReasonAllowProvider means the address is published by one of the AllowedProviders
+	// or announced by one of the AllowedASNs, and is not denied.
+	ReasonAllowProvider = "allow provider"
+	// ReasonDenyList means the address is in the deny list, whether or not it is also allowed.
+	ReasonDenyList = "deny list"
+	// ReasonAllowRule means the connection matched one of the AllowedRules, and is not
+	// denied.
+	ReasonAllowRule = "allow rule"
+	// ReasonDenyRule means the connection matched one of the DeniedRules, whether or not
+	// it is also allowed.
+	ReasonDenyRule = "deny rule"
+	// ReasonAllowTimeWindow means the connection matched the allowed networks or rules of
+	// an active time window, and is not denied.
+	ReasonAllowTimeWindow = "allow time window"
+	// ReasonDenyTimeWindow means the connection matched the denied networks or rules of an
+	// active time window, whether or not it is also allowed.
+	ReasonDenyTimeWindow = "deny time window"
+	// ReasonDenyProvider means the address is announced by one of the DeniedASNs, whether
+	// or not it is also allowed.
+	ReasonDenyProvider = "deny provider"
+	// ReasonAllowGeoIP means the address's country or continent is allowed, and no
+	// other rule matched it.
+	ReasonAllowGeoIP = "allow geoip"
+	// ReasonDenyGeoIP means the address's country or continent is denied, and no other
+	// rule matched it.
+	ReasonDenyGeoIP = "deny geoip"
+	// ReasonDefaultAllow means no rule matched and the ACL allows by default.
+	ReasonDefaultAllow = "default allow"
+	// ReasonDefaultDeny means no rule matched and the ACL denies by default.
+	ReasonDefaultDeny = "default deny"
 )
 
 // NetworkACL describes network-based access control rules.
+// The allow and deny lists may be changed while the ACL is in use; all methods are safe
+// for concurrent use. Changes are copy-on-write, so authorisation never waits for a
+// change in progress and sees either the lists before it or the lists after it.
 type NetworkACL struct {
 	AllowByDefault bool
 
-	allowNetworks []*net.IPNet
-	denyNetworks  []*net.IPNet
+	order         EvaluationOrder
+	allowFunc     AllowFunc
+	allowRules    []*Rule
+	denyRules     []*Rule
+	timeWindows   []*timeWindow
+	now           func() time.Time
+	hosts         *HostSet
+	providers     *prefixlist.MultiProvider
+	denyProviders *prefixlist.MultiProvider
+	geoIP         GeoIPReader
+	geoIPRules    geoIPRules
+
+	// mu serialises changes to rules; readers load rules without it.
+	mu    sync.Mutex
+	rules atomic.Pointer[networkRules]
+}
+
+// networkRules is an immutable pair of allow and deny lists, with tries for looking up
+// addresses in them. A change replaces it rather than modifying it.
+type networkRules struct {
+	allow     []*net.IPNet
+	deny      []*net.IPNet
+	allowTrie *prefixTrie
+	denyTrie  *prefixTrie
+}
+
+// match reports whether ip is in the allow and deny lists, after reconciling an address
+// in both according to order, so that at most one of them is true.
+func (r *networkRules) match(ip net.IP, order EvaluationOrder) (inAllow, inDeny bool) {
+	if order != EvaluationOrderMostSpecific {
+		inAllow = r.allowTrie.contains(ip)
+		inDeny = r.denyTrie.contains(ip)
+		if inAllow && inDeny {
+			return order == EvaluationOrderAllowFirst, order != EvaluationOrderAllowFirst
+		}
+		return inAllow, inDeny
+	}
+
+	allowBits, inAllow := r.allowTrie.longestMatch(ip)
+	denyBits, inDeny := r.denyTrie.longestMatch(ip)
+	if inAllow && inDeny {
+		return allowBits > denyBits, allowBits <= denyBits
+	}
+	return inAllow, inDeny
+}
+
+func newNetworkRules(allow, deny []*net.IPNet) *networkRules {
+	return &networkRules{
+		allow:     allow,
+		deny:      deny,
+		allowTrie: newPrefixTrie(allow),
+		denyTrie:  newPrefixTrie(deny),
+	}
+}
+
+// NetworkACLSnapshot is a point-in-time copy of the allow and deny lists of a NetworkACL,
+// with networks in CIDR notation.
+type NetworkACLSnapshot struct {
+	AllowByDefault bool     `json:"allow_by_default"`
+	AllowedNets    []string `json:"allowed_nets"`
+	DeniedNets     []string `json:"denied_nets"`
 }
 
 // NewNetworkACL creates a new NetworkACL from the provided configuration.
 func NewNetworkACL(cfg NetworkACLConfig) (*NetworkACL, error) {
-	allowNetworks, err := generics.Map(parseTCPNet, cfg.AllowedNets)
+	allowNetworks, err := parseNets(cfg.AllowedNets)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse allowed networks: %w", err)
 	}
 
-	denyNetworks, err := generics.Map(parseTCPNet, cfg.DeniedNets)
+	denyNetworks, err := parseNets(cfg.DeniedNets)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse denied networks: %w", err)
 	}
 
+	allowRules, err := generics.Map(ParseRule, cfg.AllowedRules)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse allowed rules: %w", err)
+	}
+
+	denyRules, err := generics.Map(ParseRule, cfg.DeniedRules)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse denied rules: %w", err)
+	}
+
+	timeWindows, err := generics.Map(newTimeWindow, cfg.TimeWindows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse time windows: %w", err)
+	}
+
+	providers, err := newProviders(cfg.AllowedProviders, cfg.AllowedASNs, cfg.ASNResolver, cfg.FetcherPool)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create allowed providers: %w", err)
+	}
+
+	denyProviders, err := newProviders(nil, cfg.DeniedASNs, cfg.ASNResolver, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create denied providers: %w", err)
+	}
+
+	allowByDefault := cfg.AllowByDefault
+	switch cfg.DefaultAction {
+	case "":
+	case DefaultActionAllow, DefaultActionDeny:
+		allowByDefault = cfg.DefaultAction == DefaultActionAllow
+	default:
+		return nil, fmt.Errorf("unknown default action %q", cfg.DefaultAction)
+	}
+
+	switch cfg.EvaluationOrder {
+	case "", EvaluationOrderDenyFirst, EvaluationOrderAllowFirst, EvaluationOrderMostSpecific:
+	default:
+		return nil, fmt.Errorf("unknown evaluation order %q", cfg.EvaluationOrder)
+	}
+
+	rules := newGeoIPRules(cfg)
+	if !rules.empty() && cfg.GeoIP == nil {
+		return nil, errors.New("country and continent rules require a GeoIP reader")
+	}
+
 	a := &NetworkACL{
-		AllowByDefault: cfg.AllowByDefault,
-		allowNetworks:  allowNetworks,
-		denyNetworks:   denyNetworks,
+		AllowByDefault: allowByDefault,
+		order:          cfg.EvaluationOrder,
+		allowFunc:      cfg.AllowFunc,
+		allowRules:     allowRules,
+		denyRules:      denyRules,
+		timeWindows:    timeWindows,
+		now:            time.Now,
+		hosts:          newHosts(cfg.AllowedHosts, cfg.HostResolver),
+		providers:      providers,
+		denyProviders:  denyProviders,
+		geoIP:          cfg.GeoIP,
+		geoIPRules:     rules,
 	}
+	a.rules.Store(newNetworkRules(allowNetworks, denyNetworks))
 
 	return a, err
 }
 
+// newHosts creates a HostSet for the given hostnames, or returns nil if there are none.
+func newHosts(hosts []string, resolver HostResolver) *HostSet {
+	if len(hosts) == 0 {
+		return nil
+	}
+
+	var opts []HostSetOption
+	if resolver != nil {
+		opts = append(opts, WithHostResolver(resolver))
+	}
+	return NewHostSet(hosts, opts...)
+}
+
+// RefreshHosts resolves the AllowedHosts. Until the first refresh, no address is allowed
+// by them. It returns the joined resolution errors, if any.
+func (a *NetworkACL) RefreshHosts(ctx context.Context) error {
+	if a.hosts == nil {
+		return nil
+	}
+	return a.hosts.Refresh(ctx)
+}
+
+// RunHosts resolves the AllowedHosts immediately and then every interval until ctx is
+// cancelled. It returns at once if none are configured.
+func (a *NetworkACL) RunHosts(ctx context.Context, interval time.Duration) {
+	if a.hosts == nil {
+		return
+	}
+	a.hosts.Run(ctx, interval)
+}
+
+// newProviders creates a MultiProvider for the given provider references and ASNs, or
+// returns nil if there are none. The providers share fetchers through pool, or through a
+// new pool if it is nil.
+func newProviders(refs []string, asns []uint32, resolver prefixlist.ASNResolver, pool *prefixlist.FetcherPool) (*prefixlist.MultiProvider, error) {
+	if len(refs) == 0 && len(asns) == 0 {
+		return nil, nil
+	}
+	if pool == nil {
+		pool = prefixlist.NewFetcherPool()
+	}
+
+	providers := make([]prefixlist.Provider, 0, len(refs)+len(asns))
+	for _, ref := range refs {
+		cfg, err := prefixlist.ParseProviderRef(ref)
+		if err != nil {
+			return nil, err
+		}
+		cfg.FetcherPool = pool
+		provider, err := prefixlist.NewProviderFromConfig(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("provider %q: %w", ref, err)
+		}
+		providers = append(providers, provider)
+	}
+	for _, asn := range asns {
+		providers = append(providers, prefixlist.NewASNProvider(asn, resolver))
+	}
+
+	return prefixlist.NewMultiProvider(providers, zerolog.Nop()), nil
+}
+
+// RefreshProviders fetches the prefixes of the AllowedProviders, AllowedASNs and
+// DeniedASNs. Until the first refresh, no address is allowed or denied by them. A
+// provider or ASN that fails to refresh keeps the prefixes of its last successful
+// refresh, so a resolver outage never lets a denied ASN through. For each of the allow
+// and deny sides, an error is returned only if every provider fails.
+func (a *NetworkACL) RefreshProviders(ctx context.Context) error {
+	var errs []error
+	for _, providers := range a.providerSets() {
+		if _, err := providers.Prefixes(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// RunProviders refreshes the provider and ASN prefixes immediately and then every
+// interval until ctx is cancelled. It returns at once if none are configured.
+func (a *NetworkACL) RunProviders(ctx context.Context, interval time.Duration) {
+	sets := a.providerSets()
+	if len(sets) == 0 {
+		return
+	}
+
+	for _, providers := range sets {
+		providers.Start(ctx, interval)
+	}
+	<-ctx.Done()
+	for _, providers := range sets {
+		providers.Stop()
+	}
+}
+
+func (a *NetworkACL) providerSets() []*prefixlist.MultiProvider {
+	var sets []*prefixlist.MultiProvider
+	for _, providers := range []*prefixlist.MultiProvider{a.providers, a.denyProviders} {
+		if providers != nil {
+			sets = append(sets, providers)
+		}
+	}
+	return sets
+}
+
 // AllowFromString parses a network string and adds it to the allow list.
 func (a *NetworkACL) AllowFromString(n string) error {
 	tcpNet, err := parseTCPNet(n)
@@ -50,7 +325,10 @@ func (a *NetworkACL) AllowFromString(n string) error {
 
 // Allow adds a network to the allow list.
 func (a *NetworkACL) Allow(n *net.IPNet) {
-	a.allowNetworks = append(a.allowNetworks, n)
+	a.update(func(r networkRules) (networkRules, error) {
+		r.allow = append(slices.Clip(r.allow), n)
+		return r, nil
+	})
 }
 
 // DenyFromString parses a network string and adds it to the deny list.
@@ -64,55 +342,449 @@ func (a *NetworkACL) DenyFromString(n string) error {
 }
 
 // Deny adds a network to the deny list.
-func (a *NetworkACL) Deny(net *net.IPNet) {
-	a.denyNetworks = append(a.denyNetworks, net)
+func (a *NetworkACL) Deny(n *net.IPNet) {
+	a.update(func(r networkRules) (networkRules, error) {
+		r.deny = append(slices.Clip(r.deny), n)
+		return r, nil
+	})
+}
+
+// AllowCIDR validates cidr and adds it to the allow list if it is not already present.
+// A bare IP address is treated as a single-host network.
+func (a *NetworkACL) AllowCIDR(cidr string) error {
+	n, err := parseTCPNet(cidr)
+	if err != nil {
+		return fmt.Errorf("invalid network %q: %w", cidr, err)
+	}
+
+	return a.update(func(r networkRules) (networkRules, error) {
+		r.allow = addNetwork(r.allow, n)
+		return r, nil
+	})
+}
+
+// DenyCIDR validates cidr and adds it to the deny list if it is not already present.
+// A bare IP address is treated as a single-host network.
+func (a *NetworkACL) DenyCIDR(cidr string) error {
+	n, err := parseTCPNet(cidr)
+	if err != nil {
+		return fmt.Errorf("invalid network %q: %w", cidr, err)
+	}
+
+	return a.update(func(r networkRules) (networkRules, error) {
+		r.deny = addNetwork(r.deny, n)
+		return r, nil
+	})
+}
+
+// AddAllowedNet adds prefix to the allow list if it is not already present.
+func (a *NetworkACL) AddAllowedNet(prefix netip.Prefix) error {
+	if !prefix.IsValid() {
+		return fmt.Errorf("invalid network %q", prefix)
+	}
+	return a.AllowCIDR(prefix.String())
+}
+
+// AddDeniedNet adds prefix to the deny list if it is not already present.
+func (a *NetworkACL) AddDeniedNet(prefix netip.Prefix) error {
+	if !prefix.IsValid() {
+		return fmt.Errorf("invalid network %q", prefix)
+	}
+	return a.DenyCIDR(prefix.String())
+}
+
+// RemoveAllowCIDR removes cidr from the allow list. It returns ErrNetworkNotFound if
+// the network is not in the list.
+func (a *NetworkACL) RemoveAllowCIDR(cidr string) error {
+	n, err := parseTCPNet(cidr)
+	if err != nil {
+		return fmt.Errorf("invalid network %q: %w", cidr, err)
+	}
+
+	return a.update(func(r networkRules) (networkRules, error) {
+		networks, removed := removeNetwork(r.allow, n)
+		if !removed {
+			return r, fmt.Errorf("%s: %w", n, ErrNetworkNotFound)
+		}
+		r.allow = networks
+		return r, nil
+	})
+}
+
+// RemoveDenyCIDR removes cidr from the deny list. It returns ErrNetworkNotFound if
+// the network is not in the list.
+func (a *NetworkACL) RemoveDenyCIDR(cidr string) error {
+	n, err := parseTCPNet(cidr)
+	if err != nil {
+		return fmt.Errorf("invalid network %q: %w", cidr, err)
+	}
+
+	return a.update(func(r networkRules) (networkRules, error) {
+		networks, removed := removeNetwork(r.deny, n)
+		if !removed {
+			return r, fmt.Errorf("%s: %w", n, ErrNetworkNotFound)
+		}
+		r.deny = networks
+		return r, nil
+	})
+}
+
+// RemoveNet removes prefix from both the allow and deny lists. It returns
+// ErrNetworkNotFound if the network is in neither.
+func (a *NetworkACL) RemoveNet(prefix netip.Prefix) error {
+	if !prefix.IsValid() {
+		return fmt.Errorf("invalid network %q", prefix)
+	}
+	n, err := parseTCPNet(prefix.String())
+	if err != nil {
+		return fmt.Errorf("invalid network %q: %w", prefix, err)
+	}
+
+	return a.update(func(r networkRules) (networkRules, error) {
+		allow, allowRemoved := removeNetwork(r.allow, n)
+		deny, denyRemoved := removeNetwork(r.deny, n)
+		if !allowRemoved && !denyRemoved {
+			return r, fmt.Errorf("%s: %w", n, ErrNetworkNotFound)
+		}
+		return networkRules{allow: allow, deny: deny}, nil
+	})
+}
+
+// addDenied adds n to the deny list and reports whether it was not already present.
+func (a *NetworkACL) addDenied(n *net.IPNet) bool {
+	var added bool
+	_ = a.update(func(r networkRules) (networkRules, error) {
+		deny := addNetwork(r.deny, n)
+		added = len(deny) != len(r.deny)
+		r.deny = deny
+		return r, nil
+	})
+	return added
+}
+
+// removeDenied removes n from the deny list, if present.
+func (a *NetworkACL) removeDenied(n *net.IPNet) {
+	_ = a.update(func(r networkRules) (networkRules, error) {
+		r.deny, _ = removeNetwork(r.deny, n)
+		return r, nil
+	})
+}
+
+// ListAllowed returns the networks in the allow list in CIDR notation.
+func (a *NetworkACL) ListAllowed() []string {
+	return networkStrings(a.loadRules().allow)
+}
+
+// ListDenied returns the networks in the deny list in CIDR notation.
+func (a *NetworkACL) ListDenied() []string {
+	return networkStrings(a.loadRules().deny)
+}
+
+// Snapshot returns a consistent copy of the allow and deny lists, e.g. for an admin API
+// to report the rules in force.
+func (a *NetworkACL) Snapshot() NetworkACLSnapshot {
+	r := a.loadRules()
+	return NetworkACLSnapshot{
+		AllowByDefault: a.AllowByDefault,
+		AllowedNets:    networkStrings(r.allow),
+		DeniedNets:     networkStrings(r.deny),
+	}
 }
 
-// AuthoriseConn checks if the provided connection is authorised.
+// loadRules returns the current rules, which are empty for a zero NetworkACL.
+func (a *NetworkACL) loadRules() *networkRules {
+	if r := a.rules.Load(); r != nil {
+		return r
+	}
+	return &networkRules{}
+}
+
+// update applies fn to a copy of the current rules and stores the result, unless fn
+// returns an error. fn must not modify the slices it is given in place.
+func (a *NetworkACL) update(fn func(networkRules) (networkRules, error)) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	r, err := fn(*a.loadRules())
+	if err != nil {
+		return err
+	}
+	a.rules.Store(newNetworkRules(r.allow, r.deny))
+	return nil
+}
+
+func sameNetwork(a, b *net.IPNet) bool {
+	return a.String() == b.String()
+}
+
+// addNetwork returns a new slice with n appended, or networks itself if it already
+// contains n, leaving the original untouched.
+func addNetwork(networks []*net.IPNet, n *net.IPNet) []*net.IPNet {
+	if slices.ContainsFunc(networks, func(existing *net.IPNet) bool { return sameNetwork(existing, n) }) {
+		return networks
+	}
+	return append(slices.Clip(networks), n)
+}
+
+// removeNetwork returns a new slice without n, leaving the original untouched.
+func removeNetwork(networks []*net.IPNet, n *net.IPNet) ([]*net.IPNet, bool) {
+	result := make([]*net.IPNet, 0, len(networks))
+	for _, existing := range networks {
+		if !sameNetwork(existing, n) {
+			result = append(result, existing)
+		}
+	}
+	return result, len(result) != len(networks)
+}
+
+func networkStrings(networks []*net.IPNet) []string {
+	result := make([]string, len(networks))
+	for i, n := range networks {
+		result[i] = n.String()
+	}
+	return result
+}
+
+// AuthoriseConn checks if the provided connection is authorised. Rules are matched
+// against its metadata, as returned by ConnMetadataFromConn.
 func (a *NetworkACL) AuthoriseConn(c net.Conn) (bool, error) {
-	return a.AuthoriseFromString(c.RemoteAddr().String())
+	allowed, _, err := a.AuthoriseConnWithReason(c)
+	return allowed, err
+}
+
+// AuthoriseConnWithReason is like AuthoriseConn but also returns the reason for the
+// decision, one of the Reason constants.
+func (a *NetworkACL) AuthoriseConnWithReason(c net.Conn) (bool, string, error) {
+	md, err := ConnMetadataFromConn(c)
+	if err != nil {
+		return false, "", err
+	}
+	allowed, reason := a.authorise(md)
+	return allowed, reason, nil
+}
+
+// AuthoriseMetadata checks if a connection with the given metadata is authorised, e.g.
+// from an HTTP handler that knows the server name of the request.
+func (a *NetworkACL) AuthoriseMetadata(md ConnMetadata) bool {
+	allowed, _ := a.AuthoriseMetadataWithReason(md)
+	return allowed
+}
+
+// AuthoriseMetadataWithReason is like AuthoriseMetadata but also returns the reason for
+// the decision, one of the Reason constants.
+func (a *NetworkACL) AuthoriseMetadataWithReason(md ConnMetadata) (bool, string) {
+	return a.authorise(md)
+}
+
+// AuthoriseIP checks if addr is authorised. Any zone is ignored and an IPv4-mapped IPv6
+// address is treated as the IPv4 address it maps.
+func (a *NetworkACL) AuthoriseIP(addr netip.Addr) bool {
+	allowed, _ := a.AuthoriseIPWithReason(addr)
+	return allowed
+}
+
+// AuthoriseIPWithReason is like AuthoriseIP but also returns the reason for the
+// decision, one of the Reason constants.
+func (a *NetworkACL) AuthoriseIPWithReason(addr netip.Addr) (bool, string) {
+	return a.AuthoriseWithReason(&net.TCPAddr{IP: net.IP(addr.WithZone("").AsSlice())})
+}
+
+// AuthoriseAddr checks if addr, such as a connection's RemoteAddr, is authorised.
+// *net.TCPAddr, *net.UDPAddr and *net.IPAddr are used directly; other address types are
+// parsed from their String form as by AuthoriseFromString.
+func (a *NetworkACL) AuthoriseAddr(addr net.Addr) (bool, error) {
+	allowed, _, err := a.AuthoriseAddrWithReason(addr)
+	return allowed, err
+}
+
+// AuthoriseAddrWithReason is like AuthoriseAddr but also returns the reason for the
+// decision, one of the Reason constants.
+func (a *NetworkACL) AuthoriseAddrWithReason(addr net.Addr) (bool, string, error) {
+	var tcpAddr *net.TCPAddr
+	switch addr := addr.(type) {
+	case *net.TCPAddr:
+		tcpAddr = addr
+	case *net.UDPAddr:
+		tcpAddr = &net.TCPAddr{IP: addr.IP, Port: addr.Port, Zone: addr.Zone}
+	case *net.IPAddr:
+		tcpAddr = &net.TCPAddr{IP: addr.IP, Zone: addr.Zone}
+	case nil:
+		return false, "", errors.New("nil address")
+	default:
+		return a.AuthoriseFromStringWithReason(addr.String())
+	}
+
+	allowed, reason := a.AuthoriseWithReason(tcpAddr)
+	return allowed, reason, nil
 }
 
 // AuthoriseFromString checks if the provided address string is authorised.
+// The address may be an IP address or host:port. IPv6 zone identifiers, bracketed
+// ("[fe80::1%eth0]:1234") or not ("fe80::1%eth0:1234"), are accepted but ignored for
+// matching: CIDR membership depends only on the address, so a link-local peer matches
+// "fe80::/10" whichever interface it arrived on.
 func (a *NetworkACL) AuthoriseFromString(addr string) (bool, error) {
+	allowed, _, err := a.AuthoriseFromStringWithReason(addr)
+	return allowed, err
+}
+
+// AuthoriseFromStringWithReason is like AuthoriseFromString but also returns the reason
+// for the decision, one of the Reason constants.
+func (a *NetworkACL) AuthoriseFromStringWithReason(addr string) (bool, string, error) {
+	if ip, ok := parseIPWithOptionalPort(addr); ok {
+		allowed, reason := a.AuthoriseWithReason(&net.TCPAddr{IP: ip})
+		return allowed, reason, nil
+	}
+
 	tcpAddr, err := net.ResolveTCPAddr("tcp", addr)
 	if err != nil {
-		return false, err
+		return false, "", err
+	}
+	allowed, reason := a.AuthoriseWithReason(tcpAddr)
+	return allowed, reason, nil
+}
+
+// parseIPWithOptionalPort parses an IP address, with or without a port, discarding any
+// IPv6 zone identifier.
+func parseIPWithOptionalPort(s string) (net.IP, bool) {
+	if addrPort, err := netip.ParseAddrPort(s); err == nil {
+		return net.IP(addrPort.Addr().WithZone("").AsSlice()), true
+	}
+	// ParseAddr also accepts an unbracketed zoned address with a port, such as
+	// "fe80::1%eth0:1234", treating ":1234" as part of the zone, which is discarded.
+	if addr, err := netip.ParseAddr(s); err == nil {
+		return net.IP(addr.WithZone("").AsSlice()), true
 	}
-	return a.Authorise(tcpAddr), nil
+
+	return nil, false
 }
 
 // Authorise checks if the provided TCP address is authorised.
-// If both allow and deny lists are present, allow is checked first.
-// If an IP is in the allow list but also matches a deny rule, authorisation is denied.
-// This allows denying subsets of allowed CIDR ranges.
+//
+// If an AllowFunc is configured it is consulted first, and its decision (if any) is
+// final. Otherwise every deny check runs before any allow check:
+//
+//  1. the deny list, after reconciling an address in both lists by EvaluationOrder, so
+//     that by default a deny network inside an allowed range is denied;
+//  2. the DeniedRules, denying time windows and the DeniedASNs;
+//  3. the allow list, the AllowedRules, allowing time windows, the AllowedHosts, and the
+//     prefixes of the AllowedProviders and AllowedASNs;
+//  4. country and continent rules, so a CIDR, provider or ASN rule always takes
+//     precedence over a location rule;
+//  5. the default action.
+//
+// Rules are matched knowing only the address and port of addr; use AuthoriseConn or
+// AuthoriseMetadata to match rules on the local port or server name. IPv4-mapped IPv6
+// addresses are matched as IPv4, against IPv4 rules.
 func (a *NetworkACL) Authorise(addr *net.TCPAddr) bool {
-	inAllow := containsAddress(a.allowNetworks, addr.IP)
-	inDeny := containsAddress(a.denyNetworks, addr.IP)
+	allowed, _ := a.AuthoriseWithReason(addr)
+	return allowed
+}
 
-	if inAllow && !inDeny {
-		return true
+// AuthoriseWithReason is like Authorise but also returns the reason for the decision,
+// one of the Reason constants, e.g. for logging or auditing.
+func (a *NetworkACL) AuthoriseWithReason(addr *net.TCPAddr) (bool, string) {
+	return a.authorise(ConnMetadata{RemoteAddr: addr.AddrPort()})
+}
+
+// authorise decides on a connection described by md.
+func (a *NetworkACL) authorise(md ConnMetadata) (bool, string) {
+	return a.decide(normalizeIP(net.IP(md.RemoteAddr.Addr().AsSlice())), md)
+}
+
+func (a *NetworkACL) decide(ip net.IP, md ConnMetadata) (bool, string) {
+	if a.allowFunc != nil {
+		if allow, decided := a.allowFunc(ip); decided {
+			return allow, ReasonAllowFunc
+		}
+	}
+
+	rules := a.loadRules()
+	inAllow, inDeny := rules.match(ip, a.order)
+	if inDeny {
+		return false, ReasonDenyList
+	}
+
+	if matchRules(a.denyRules, md) {
+		return false, ReasonDenyRule
+	}
+
+	var windowAllow, windowDeny bool
+	if len(a.timeWindows) > 0 {
+		windowAllow, windowDeny = matchTimeWindows(a.timeWindows, a.now(), ip, md)
+	}
+	if windowDeny {
+		return false, ReasonDenyTimeWindow
+	}
+
+	if providersContain(a.denyProviders, ip) {
+		return false, ReasonDenyProvider
 	}
 
-	// if in both allow and deny, deny
 	if inAllow {
-		return false
+		return true, ReasonAllowList
 	}
 
-	if inDeny {
-		return false
+	if matchRules(a.allowRules, md) {
+		return true, ReasonAllowRule
+	}
+
+	if windowAllow {
+		return true, ReasonAllowTimeWindow
 	}
 
-	return a.AllowByDefault
+	if a.hosts != nil && a.hosts.Contains(ip) {
+		return true, ReasonAllowHost
+	}
+
+	if providersContain(a.providers, ip) {
+		return true, ReasonAllowProvider
+	}
+
+	if allowed, reason, matched := a.authoriseGeoIP(ip); matched {
+		return allowed, reason
+	}
+
+	if a.AllowByDefault {
+		return true, ReasonDefaultAllow
+	}
+	return false, ReasonDefaultDeny
 }
 
-func containsAddress(netList []*net.IPNet, ip net.IP) bool {
-	for _, n := range netList {
-		if n.Contains(ip) {
-			return true
-		}
+func (a *NetworkACL) authoriseGeoIP(ip net.IP) (allowed bool, reason string, matched bool) {
+	if a.geoIP == nil || a.geoIPRules.empty() {
+		return false, "", false
+	}
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return false, "", false
 	}
-	return false
+	// Addresses that cannot be located match no location rule
+	location, err := a.geoIP.Lookup(addr.Unmap())
+	if err != nil {
+		return false, "", false
+	}
+	return a.geoIPRules.decide(location)
+}
+
+func providersContain(providers *prefixlist.MultiProvider, ip net.IP) bool {
+	if providers == nil {
+		return false
+	}
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return false
+	}
+	return providers.Contains(addr.Unmap())
+}
+
+// normalizeIP returns the 4-byte form of IPv4 and IPv4-mapped IPv6 addresses, so that
+// they match IPv4 rules and not IPv6 ones.
+func normalizeIP(ip net.IP) net.IP {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4
+	}
+	return ip
 }
 
 func parseTCPNet(n string) (*net.IPNet, error) {
@@ -139,5 +811,11 @@ func parseTCPNet(n string) (*net.IPNet, error) {
 		return nil, err
 	}
 
+	// IPv4-mapped IPv6 networks such as ::ffff:10.0.0.0/104 become the IPv4 network they
+	// map, since addresses are matched in their IPv4 form
+	if ones, bits := ipNet.Mask.Size(); bits == 8*net.IPv6len && ones >= 96 && ipNet.IP.To4() != nil {
+		ipNet = &net.IPNet{IP: ipNet.IP.To4(), Mask: net.CIDRMask(ones-96, 8*net.IPv4len)}
+	}
+
 	return ipNet, nil
 }
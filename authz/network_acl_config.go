@@ -1,8 +1,286 @@
 package authz
 
+import (
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+
+	"github.com/dioad/net/authz/prefixlist"
+)
+
+// AllowFunc is a custom authorisation hook evaluated before the CIDR rules.
+// If decided is true, allow is the final decision. If decided is false, the
+// normal allow/deny list evaluation proceeds.
+type AllowFunc func(ip net.IP) (allow bool, decided bool)
+
+// EvaluationOrder determines how a NetworkACL decides on an address that is in both
+// its allow and deny lists.
+type EvaluationOrder string
+
+const (
+	// EvaluationOrderDenyFirst denies an address in both lists. It is the default.
+	EvaluationOrderDenyFirst EvaluationOrder = "deny-first"
+	// EvaluationOrderAllowFirst allows an address in both lists.
+	EvaluationOrderAllowFirst EvaluationOrder = "allow-first"
+	// EvaluationOrderMostSpecific follows the list with the longest matching network, so
+	// a denied /32 inside an allowed /8 is denied and an allowed /32 inside a denied /8
+	// is allowed. A network in both lists is denied.
+	EvaluationOrderMostSpecific EvaluationOrder = "most-specific"
+)
+
+// DefaultAction is what a NetworkACL does with an address that no rule matches.
+type DefaultAction string
+
+const (
+	// DefaultActionAllow allows addresses that no rule matches.
+	DefaultActionAllow DefaultAction = "allow"
+	// DefaultActionDeny denies addresses that no rule matches.
+	DefaultActionDeny DefaultAction = "deny"
+)
+
 // NetworkACLConfig describes the configuration for network-based access control.
+//
+// AllowedNets and DeniedNets hold networks in CIDR notation, single addresses, or the
+// names of built-in network sets such as "private" (see NamedNetworks).
 type NetworkACLConfig struct {
 	AllowedNets    []string `json:"allow,omitzero" mapstructure:"allow"`
 	DeniedNets     []string `json:"deny,omitzero" mapstructure:"deny"`
 	AllowByDefault bool     `json:"allow_by_default" mapstructure:"allow-by-default"`
+
+	// DefaultAction decides addresses that no rule matches. It takes precedence over
+	// AllowByDefault when set, which lets an overlay passed to MergeConfigs deny by default
+	// on top of a base that allows by default. If empty, AllowByDefault decides.
+	DefaultAction DefaultAction `json:"default_action,omitzero" mapstructure:"default-action"`
+
+	// EvaluationOrder decides between AllowedNets and DeniedNets for an address in both.
+	// It only reconciles the two lists: an address they allow can still be denied by
+	// DeniedRules or DeniedASNs. The default is EvaluationOrderDenyFirst.
+	EvaluationOrder EvaluationOrder `json:"evaluation_order,omitzero" mapstructure:"evaluation-order"`
+
+	// AllowedProviders optionally allows the prefixes published by prefixlist providers,
+	// given as references such as "github?service=hooks" or "cloudflare" (see
+	// prefixlist.ParseProviderRef). The deny list still applies. Prefixes are only known
+	// once NetworkACL.RefreshProviders or NetworkACL.RunProviders has fetched them.
+	AllowedProviders []string `json:"allow_providers,omitzero" mapstructure:"allow-providers"`
+
+	// FetcherPool optionally shares the fetchers of AllowedProviders with other ACLs and
+	// providers created with the same pool. If nil, only the providers of this ACL share
+	// fetchers. It cannot be set from configuration files.
+	FetcherPool *prefixlist.FetcherPool `json:"-" mapstructure:"-"`
+
+	// AllowedRules and DeniedRules optionally allow or deny connections matching rule
+	// expressions, which can combine conditions on the client address, the ports and
+	// the TLS server name, e.g. "ip in 10.0.0.0/8 && port != 22" (see Rule). They are
+	// consulted just after AllowedNets and DeniedNets, and a denied rule overrides any
+	// allow rule.
+	AllowedRules []string `json:"allow_rules,omitzero" mapstructure:"allow-rules"`
+	DeniedRules  []string `json:"deny_rules,omitzero" mapstructure:"deny-rules"`
+
+	// TimeWindows optionally allow or deny networks and rules only while their schedule
+	// is active, e.g. to allow a partner's network during office hours. They are checked
+	// when a connection is authorised, just after AllowedRules and DeniedRules.
+	TimeWindows []TimeWindowConfig `json:"time_windows,omitzero" mapstructure:"time-windows"`
+
+	// AllowedHosts optionally allows the addresses that hostnames resolve to, for peers
+	// with dynamic addresses but stable DNS names. The deny list still applies. Addresses
+	// are only known once NetworkACL.RefreshHosts or NetworkACL.RunHosts has resolved
+	// them, and are kept or dropped on resolution failures as described on HostSet.
+	AllowedHosts []string `json:"allow_hosts,omitzero" mapstructure:"allow-hosts"`
+
+	// HostResolver resolves AllowedHosts. If nil, net.DefaultResolver is used. It cannot
+	// be set from configuration files.
+	HostResolver HostResolver `json:"-" mapstructure:"-"`
+
+	// AllowedASNs and DeniedASNs optionally allow or deny the prefixes announced by
+	// autonomous systems, resolved by ASNResolver. Like AllowedProviders, their prefixes
+	// are only known once refreshed, and a denied ASN prefix overrides any allow rule.
+	AllowedASNs []uint32 `json:"allow_asns,omitzero" mapstructure:"allow-asns"`
+	DeniedASNs  []uint32 `json:"deny_asns,omitzero" mapstructure:"deny-asns"`
+
+	// ASNResolver resolves AllowedASNs and DeniedASNs. If nil, RIPEstat is used (see
+	// prefixlist.NewASNProvider). It cannot be set from configuration files.
+	ASNResolver prefixlist.ASNResolver `json:"-" mapstructure:"-"`
+
+	// AllowedCountries, DeniedCountries, AllowedContinents and DeniedContinents
+	// optionally match addresses by location, looked up with GeoIP. Countries are ISO
+	// 3166-1 alpha-2 codes such as "GB" and continents are two-letter codes such as
+	// "EU", matched case-insensitively. Location rules only apply to addresses that no
+	// CIDR, provider or ASN rule matched, and a denied location wins over an allowed one.
+	AllowedCountries  []string `json:"allow_countries,omitzero" mapstructure:"allow-countries"`
+	DeniedCountries   []string `json:"deny_countries,omitzero" mapstructure:"deny-countries"`
+	AllowedContinents []string `json:"allow_continents,omitzero" mapstructure:"allow-continents"`
+	DeniedContinents  []string `json:"deny_continents,omitzero" mapstructure:"deny-continents"`
+
+	// GeoIP looks up locations for the country and continent rules, and is required
+	// when any are set. Use a GeoIPDatabase to reload the database while in use. It
+	// cannot be set from configuration files.
+	GeoIP GeoIPReader `json:"-" mapstructure:"-"`
+
+	// AllowFunc optionally runs before the allow and deny lists. When it reports a
+	// decision, that decision wins even over an explicit deny entry, so it can be
+	// used for policies that cannot be expressed as CIDR sets (time of day,
+	// reputation lookups). It cannot be set from configuration files.
+	AllowFunc AllowFunc `json:"-" mapstructure:"-"`
+}
+
+// TimeWindowConfig describes networks and rules that apply only at the times given by a
+// cron expression (see Schedule), such as "* 9-16 * * Mon-Fri" for weekday office hours
+// in UTC. AllowedNets and DeniedNets accept the same entries as in NetworkACLConfig, and
+// AllowedRules and DeniedRules the same rule expressions.
+type TimeWindowConfig struct {
+	Schedule     string   `json:"schedule" mapstructure:"schedule"`
+	AllowedNets  []string `json:"allow,omitzero" mapstructure:"allow"`
+	DeniedNets   []string `json:"deny,omitzero" mapstructure:"deny"`
+	AllowedRules []string `json:"allow_rules,omitzero" mapstructure:"allow-rules"`
+	DeniedRules  []string `json:"deny_rules,omitzero" mapstructure:"deny-rules"`
+}
+
+// MergeConfigs layers overlay on top of base and returns the combined configuration.
+//
+// The allow and deny lists are the union of both configs, with base entries first and
+// duplicates (including equivalent forms such as "10.0.0.1" and "10.0.0.1/32") removed.
+// The rule lists, AllowedHosts, AllowedProviders, the ASN lists and the country and
+// continent lists are the unions of both configs' entries, with duplicates removed.
+// TimeWindows holds base's windows followed by overlay's.
+//
+// Overlay's default action replaces base's when overlay sets one, either with
+// DefaultAction or by setting AllowByDefault, so an overlay can deny by default on top
+// of a base that allows by default. The merged config then carries overlay's action in
+// DefaultAction. Overlay's EvaluationOrder replaces base's when it is set, and overlay's
+// AllowFunc, FetcherPool, HostResolver, ASNResolver and GeoIP replace base's when they
+// are non-nil.
+//
+// The merged networks, rules and time windows are validated as NewNetworkACL would, so
+// an error is returned if either config contains an invalid one. Hosts, providers and
+// country and continent codes are merged as given and only checked by NewNetworkACL, if
+// at all.
+func MergeConfigs(base, overlay NetworkACLConfig) (NetworkACLConfig, error) {
+	allowed, err := mergeNets(base.AllowedNets, overlay.AllowedNets)
+	if err != nil {
+		return NetworkACLConfig{}, fmt.Errorf("failed to merge allowed networks: %w", err)
+	}
+
+	denied, err := mergeNets(base.DeniedNets, overlay.DeniedNets)
+	if err != nil {
+		return NetworkACLConfig{}, fmt.Errorf("failed to merge denied networks: %w", err)
+	}
+
+	merged := NetworkACLConfig{
+		AllowedNets:       allowed,
+		DeniedNets:        denied,
+		AllowByDefault:    base.AllowByDefault,
+		DefaultAction:     base.DefaultAction,
+		EvaluationOrder:   base.EvaluationOrder,
+		AllowedRules:      mergeUnique(base.AllowedRules, overlay.AllowedRules),
+		DeniedRules:       mergeUnique(base.DeniedRules, overlay.DeniedRules),
+		TimeWindows:       slices.Concat(base.TimeWindows, overlay.TimeWindows),
+		AllowedHosts:      mergeUnique(base.AllowedHosts, overlay.AllowedHosts),
+		AllowedProviders:  mergeUnique(base.AllowedProviders, overlay.AllowedProviders),
+		AllowedASNs:       mergeUnique(base.AllowedASNs, overlay.AllowedASNs),
+		DeniedASNs:        mergeUnique(base.DeniedASNs, overlay.DeniedASNs),
+		AllowedCountries:  mergeUnique(base.AllowedCountries, overlay.AllowedCountries),
+		DeniedCountries:   mergeUnique(base.DeniedCountries, overlay.DeniedCountries),
+		AllowedContinents: mergeUnique(base.AllowedContinents, overlay.AllowedContinents),
+		DeniedContinents:  mergeUnique(base.DeniedContinents, overlay.DeniedContinents),
+		AllowFunc:         base.AllowFunc,
+		FetcherPool:       base.FetcherPool,
+		HostResolver:      base.HostResolver,
+		ASNResolver:       base.ASNResolver,
+		GeoIP:             base.GeoIP,
+	}
+	if action := overlay.defaultAction(); action != "" {
+		merged.DefaultAction = action
+		merged.AllowByDefault = action == DefaultActionAllow
+	}
+	if overlay.EvaluationOrder != "" {
+		merged.EvaluationOrder = overlay.EvaluationOrder
+	}
+	if overlay.AllowFunc != nil {
+		merged.AllowFunc = overlay.AllowFunc
+	}
+	if overlay.FetcherPool != nil {
+		merged.FetcherPool = overlay.FetcherPool
+	}
+	if overlay.HostResolver != nil {
+		merged.HostResolver = overlay.HostResolver
+	}
+	if overlay.ASNResolver != nil {
+		merged.ASNResolver = overlay.ASNResolver
+	}
+	if overlay.GeoIP != nil {
+		merged.GeoIP = overlay.GeoIP
+	}
+
+	if err := merged.validateRules(); err != nil {
+		return NetworkACLConfig{}, err
+	}
+
+	return merged, nil
+}
+
+// validateRules parses the rules and time windows of c, as NewNetworkACL does.
+func (c NetworkACLConfig) validateRules() error {
+	for _, expr := range slices.Concat(c.AllowedRules, c.DeniedRules) {
+		if _, err := ParseRule(expr); err != nil {
+			return fmt.Errorf("failed to merge rules: %w", err)
+		}
+	}
+	for _, window := range c.TimeWindows {
+		if _, err := newTimeWindow(window); err != nil {
+			return fmt.Errorf("failed to merge time windows: %w", err)
+		}
+	}
+	return nil
+}
+
+// defaultAction returns the default action the config sets, or "" if it sets none.
+// AllowByDefault only sets one when true, as false cannot be told apart from unset.
+func (c NetworkACLConfig) defaultAction() DefaultAction {
+	if c.DefaultAction != "" {
+		return c.DefaultAction
+	}
+	if c.AllowByDefault {
+		return DefaultActionAllow
+	}
+	return ""
+}
+
+func mergeNets(lists ...[]string) ([]string, error) {
+	var result []string
+	seen := make(map[string]bool)
+
+	for _, list := range lists {
+		for _, n := range list {
+			// names of built-in network sets are kept as names
+			key := strings.ToLower(n)
+			if _, ok := namedNetworks[key]; !ok {
+				ipNet, err := parseTCPNet(n)
+				if err != nil {
+					return nil, fmt.Errorf("invalid network %q: %w", n, err)
+				}
+				key = ipNet.String()
+			}
+
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			result = append(result, n)
+		}
+	}
+
+	return result, nil
+}
+
+func mergeUnique[T comparable](lists ...[]T) []T {
+	var result []T
+	for _, list := range lists {
+		for _, v := range list {
+			if !slices.Contains(result, v) {
+				result = append(result, v)
+			}
+		}
+	}
+	return result
 }
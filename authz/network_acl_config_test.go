@@ -0,0 +1,205 @@
+package authz
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dioad/net/authz/prefixlist"
+)
+
+func TestMergeConfigs(t *testing.T) {
+	base := NetworkACLConfig{
+		AllowedNets: []string{"10.0.0.0/8", "192.168.1.1"},
+		DeniedNets:  []string{"10.1.0.0/16"},
+	}
+	overlay := NetworkACLConfig{
+		AllowedNets: []string{"192.168.1.1/32", "172.16.0.0/12"},
+		DeniedNets:  []string{"10.1.0.0/16", "10.2.0.0/16"},
+	}
+
+	merged, err := MergeConfigs(base, overlay)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"10.0.0.0/8", "192.168.1.1", "172.16.0.0/12"}, merged.AllowedNets)
+	assert.Equal(t, []string{"10.1.0.0/16", "10.2.0.0/16"}, merged.DeniedNets)
+	assert.False(t, merged.AllowByDefault)
+	assert.Nil(t, merged.AllowFunc)
+
+	// Inputs must not be modified
+	assert.Equal(t, []string{"10.0.0.0/8", "192.168.1.1"}, base.AllowedNets)
+}
+
+func TestMergeConfigs_AllowByDefaultAndAllowFunc(t *testing.T) {
+	baseFunc := func(ip net.IP) (bool, bool) { return false, true }
+	overlayFunc := func(ip net.IP) (bool, bool) { return true, true }
+
+	tests := []struct {
+		name          string
+		base          NetworkACLConfig
+		overlay       NetworkACLConfig
+		wantDefault   bool
+		wantFuncAllow *bool
+	}{
+		{name: "neither set", wantDefault: false},
+		{name: "base allow by default", base: NetworkACLConfig{AllowByDefault: true}, wantDefault: true},
+		{name: "overlay allow by default", overlay: NetworkACLConfig{AllowByDefault: true}, wantDefault: true},
+		{name: "overlay deny by default", base: NetworkACLConfig{AllowByDefault: true}, overlay: NetworkACLConfig{DefaultAction: DefaultActionDeny}, wantDefault: false},
+		{name: "overlay allow action", base: NetworkACLConfig{DefaultAction: DefaultActionDeny}, overlay: NetworkACLConfig{DefaultAction: DefaultActionAllow}, wantDefault: true},
+		{name: "overlay unset keeps base", base: NetworkACLConfig{DefaultAction: DefaultActionAllow}, wantDefault: true},
+		{name: "base func kept", base: NetworkACLConfig{AllowFunc: baseFunc}, wantFuncAllow: new(false)},
+		{name: "overlay func wins", base: NetworkACLConfig{AllowFunc: baseFunc}, overlay: NetworkACLConfig{AllowFunc: overlayFunc}, wantFuncAllow: new(true)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged, err := MergeConfigs(tt.base, tt.overlay)
+			require.NoError(t, err)
+			acl, err := NewNetworkACL(merged)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantDefault, acl.AllowByDefault)
+
+			if tt.wantFuncAllow == nil {
+				assert.Nil(t, merged.AllowFunc)
+				return
+			}
+			require.NotNil(t, merged.AllowFunc)
+			allow, _ := merged.AllowFunc(net.ParseIP("127.0.0.1"))
+			assert.Equal(t, *tt.wantFuncAllow, allow)
+		})
+	}
+}
+
+func TestMergeConfigs_DenyByDefaultOverlay(t *testing.T) {
+	base := NetworkACLConfig{AllowByDefault: true, DeniedNets: []string{"203.0.113.0/24"}}
+	overlay := NetworkACLConfig{DefaultAction: DefaultActionDeny, AllowedNets: []string{"10.0.0.0/8"}}
+
+	merged, err := MergeConfigs(base, overlay)
+	require.NoError(t, err)
+	assert.False(t, merged.AllowByDefault)
+	assert.Equal(t, DefaultActionDeny, merged.DefaultAction)
+
+	acl, err := NewNetworkACL(merged)
+	require.NoError(t, err)
+
+	allowed, reason := acl.AuthoriseIPWithReason(netip.MustParseAddr("198.51.100.1"))
+	assert.False(t, allowed)
+	assert.Equal(t, ReasonDefaultDeny, reason)
+	assert.True(t, acl.AuthoriseIP(netip.MustParseAddr("10.1.2.3")))
+	assert.False(t, acl.AuthoriseIP(netip.MustParseAddr("203.0.113.1")))
+}
+
+func TestNewNetworkACL_DefaultAction(t *testing.T) {
+	acl, err := NewNetworkACL(NetworkACLConfig{AllowByDefault: true, DefaultAction: DefaultActionDeny})
+	require.NoError(t, err)
+	assert.False(t, acl.AllowByDefault)
+
+	acl, err = NewNetworkACL(NetworkACLConfig{DefaultAction: DefaultActionAllow})
+	require.NoError(t, err)
+	assert.True(t, acl.AllowByDefault)
+
+	_, err = NewNetworkACL(NetworkACLConfig{DefaultAction: "maybe"})
+	assert.ErrorContains(t, err, "unknown default action")
+}
+
+func TestMergeConfigs_InvalidNetwork(t *testing.T) {
+	_, err := MergeConfigs(
+		NetworkACLConfig{AllowedNets: []string{"10.0.0.0/8"}},
+		NetworkACLConfig{DeniedNets: []string{"not-a-network"}},
+	)
+	assert.Error(t, err)
+}
+
+func TestMergeConfigs_InvalidEntries(t *testing.T) {
+	tests := []struct {
+		name    string
+		overlay NetworkACLConfig
+		wantErr string
+	}{
+		{name: "allowed rule", overlay: NetworkACLConfig{AllowedRules: []string{"port == not-a-port"}}, wantErr: "failed to merge rules"},
+		{name: "denied rule", overlay: NetworkACLConfig{DeniedRules: []string{"ip in"}}, wantErr: "failed to merge rules"},
+		{name: "time window schedule", overlay: NetworkACLConfig{TimeWindows: []TimeWindowConfig{{Schedule: "not a schedule"}}}, wantErr: "failed to merge time windows"},
+		{name: "time window network", overlay: NetworkACLConfig{TimeWindows: []TimeWindowConfig{{Schedule: "* * * * *", AllowedNets: []string{"nope"}}}}, wantErr: "failed to merge time windows"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := MergeConfigs(NetworkACLConfig{AllowedRules: []string{"port != 22"}}, tt.overlay)
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestMergeConfigs_AllowedProviders(t *testing.T) {
+	merged, err := MergeConfigs(
+		NetworkACLConfig{AllowedProviders: []string{"github?service=hooks", "cloudflare"}},
+		NetworkACLConfig{AllowedProviders: []string{"cloudflare", "fastly"}},
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"github?service=hooks", "cloudflare", "fastly"}, merged.AllowedProviders)
+}
+
+func TestMergeConfigs_ASNs(t *testing.T) {
+	resolver := prefixlist.ASNResolverFunc(func(context.Context, uint32) ([]netip.Prefix, error) { return nil, nil })
+
+	merged, err := MergeConfigs(
+		NetworkACLConfig{AllowedASNs: []uint32{13335, 64500}, DeniedASNs: []uint32{64501}},
+		NetworkACLConfig{AllowedASNs: []uint32{64500, 64502}, ASNResolver: resolver},
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, []uint32{13335, 64500, 64502}, merged.AllowedASNs)
+	assert.Equal(t, []uint32{64501}, merged.DeniedASNs)
+	assert.NotNil(t, merged.ASNResolver)
+}
+
+func TestMergeConfigs_AllowedHosts(t *testing.T) {
+	resolver := &fakeHostResolver{}
+	merged, err := MergeConfigs(
+		NetworkACLConfig{AllowedHosts: []string{"vpn.example.com"}, HostResolver: resolver},
+		NetworkACLConfig{AllowedHosts: []string{"vpn.example.com", "peer.example.com"}},
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"vpn.example.com", "peer.example.com"}, merged.AllowedHosts)
+	assert.Same(t, resolver, merged.HostResolver)
+}
+
+func TestMergeConfigs_FetcherPool(t *testing.T) {
+	base, overlay := prefixlist.NewFetcherPool(), prefixlist.NewFetcherPool()
+
+	merged, err := MergeConfigs(NetworkACLConfig{FetcherPool: base}, NetworkACLConfig{})
+	require.NoError(t, err)
+	assert.Same(t, base, merged.FetcherPool)
+
+	merged, err = MergeConfigs(NetworkACLConfig{FetcherPool: base}, NetworkACLConfig{FetcherPool: overlay})
+	require.NoError(t, err)
+	assert.Same(t, overlay, merged.FetcherPool)
+}
+
+func TestMergeConfigs_EvaluationOrder(t *testing.T) {
+	merged, err := MergeConfigs(NetworkACLConfig{EvaluationOrder: EvaluationOrderMostSpecific}, NetworkACLConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, EvaluationOrderMostSpecific, merged.EvaluationOrder)
+
+	merged, err = MergeConfigs(
+		NetworkACLConfig{EvaluationOrder: EvaluationOrderMostSpecific},
+		NetworkACLConfig{EvaluationOrder: EvaluationOrderAllowFirst},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, EvaluationOrderAllowFirst, merged.EvaluationOrder)
+}
+
+func TestMergeConfigs_TimeWindows(t *testing.T) {
+	base := NetworkACLConfig{TimeWindows: []TimeWindowConfig{{Schedule: "* 9-16 * * 1-5", AllowedNets: []string{"203.0.113.0/24"}}}}
+	overlay := NetworkACLConfig{TimeWindows: []TimeWindowConfig{{Schedule: "* 0-5 * * *", DeniedNets: []string{"10.0.0.0/8"}}}}
+
+	merged, err := MergeConfigs(base, overlay)
+	require.NoError(t, err)
+	assert.Equal(t, []TimeWindowConfig{base.TimeWindows[0], overlay.TimeWindows[0]}, merged.TimeWindows)
+}
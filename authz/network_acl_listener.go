@@ -1,44 +1,425 @@
 package authz
 
 import (
+	"errors"
+	"fmt"
 	"net"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/rs/zerolog"
+
+	net2 "github.com/dioad/net"
+)
+
+// ReasonAuthoriseError means the Authoriser, or NetworkACL, returned an error for the
+// connection, e.g. because its address could not be parsed, and the Listener denied it.
+const ReasonAuthoriseError = "authorise error"
+
+// ErrDenied is matched, via errors.Is, by the *DeniedError that Accept returns for a
+// denied connection under DenyPolicyError.
+var ErrDenied = errors.New("access denied")
+
+// DeniedError is returned by Listener.Accept for a connection the NetworkACL denied when
+// DenyPolicy is DenyPolicyError. The connection has already been closed.
+//
+// It reports itself as a temporary net.Error so that accept loops that retry temporary
+// errors, such as http.Server's, keep serving. Note that http.Server also backs off
+// briefly after each one.
+type DeniedError struct {
+	RemoteAddr net.Addr
+	// Reason is the NetworkACL's reason for the decision, one of the Reason constants.
+	Reason string
+}
+
+func (e *DeniedError) Error() string {
+	return fmt.Sprintf("%v from %v: %s", ErrDenied, e.RemoteAddr, e.Reason)
+}
+
+// Is reports whether target is ErrDenied.
+func (e *DeniedError) Is(target error) bool { return target == ErrDenied }
+
+// Timeout is part of net.Error and always returns false.
+func (e *DeniedError) Timeout() bool { return false }
+
+// Temporary is part of net.Error and always returns true.
+func (e *DeniedError) Temporary() bool { return true }
+
+// DenyPolicy determines what Listener.Accept does after closing a denied connection.
+type DenyPolicy int
+
+const (
+	// DenyPolicyContinue keeps accepting until an authorised connection arrives, so
+	// denied connections are never seen by the caller. It is the default.
+	DenyPolicyContinue DenyPolicy = iota
+	// DenyPolicyError returns a *DeniedError, matching ErrDenied, for each denied
+	// connection.
+	DenyPolicyError
 )
 
+// Authoriser decides whether a connection is allowed, and why. NetworkACL and
+// OPAAuthoriser implement it.
+type Authoriser interface {
+	AuthoriseConnWithReason(c net.Conn) (allowed bool, reason string, err error)
+}
+
 // Listener is a network listener that enforces a NetworkACL on all incoming connections.
 type Listener struct {
 	NetworkACL *NetworkACL
+	// Authoriser, if set, decides on each connection instead of NetworkACL, e.g. an
+	// OPAAuthoriser that delegates to a central policy. A connection either returns an
+	// error for is denied with ReasonAuthoriseError.
+	Authoriser Authoriser
 	Listener   net.Listener
 	Logger     zerolog.Logger
+
+	// TCPOptions, if set, are applied to each authorised connection before it is returned.
+	TCPOptions *net2.TCPConnOptions
+
+	// MaxTotalConns, if greater than zero, caps the number of accepted connections
+	// that may be open at once. Once the cap is reached Accept blocks until a
+	// connection is closed, or rejects new connections if RejectOverLimit is set.
+	MaxTotalConns int
+	// RejectOverLimit closes connections accepted past MaxTotalConns instead of
+	// pausing Accept.
+	RejectOverLimit bool
+
+	// AuditOnly logs connections the NetworkACL would deny, and counts them in WouldDeny,
+	// but returns them as authorised. Use it to validate a new policy against real traffic
+	// before enforcing it.
+	AuditOnly bool
+
+	// AuthoriseConcurrency, if greater than zero, authorises up to that many connections
+	// at once in background goroutines, and Accept returns them in the order they are
+	// authorised. Set it when the Authoriser can be slow, such as an OPAAuthoriser
+	// querying a sidecar: by default each connection is authorised inside Accept, so
+	// one slow decision delays every connection queued behind it.
+	AuthoriseConcurrency int
+
+	// DenyPolicy controls what Accept does after a connection is denied. Denied
+	// connections are always closed and Accept never returns a nil connection with a
+	// nil error.
+	DenyPolicy DenyPolicy
+	// RejectHandler, if set, is called with each denied connection and the reason it was
+	// denied, e.g. to write a banner, before the connection is closed. It runs in its
+	// own goroutine so that a slow client cannot stall Accept, and should set a write
+	// deadline on the connection.
+	RejectHandler func(c net.Conn, reason string)
+
+	// DecisionObserver, if set, is notified of the decision for every connection,
+	// including connections let through by AuditOnly, whether it was made by the
+	// NetworkACL or by the Authoriser.
+	DecisionObserver DecisionObserver
+	// AuditLog, if set, records the decision for every connection, like
+	// DecisionObserver.
+	AuditLog *AuditLog
+
+	// Name labels the listener's metrics, to tell apart Listeners sharing Metrics.
+	Name string
+	// Metrics, if set, counts decisions and tracks the number of open authorised
+	// connections. Register them with a Prometheus registerer to collect them.
+	Metrics *ListenerMetrics
+
+	wouldDeny   atomic.Uint64
+	limiterOnce sync.Once
+	limiter     *net2.ConnLimiter
+	closed      atomic.Bool
+
+	authoriseOnce sync.Once
+	authorised    chan acceptResult
+	acceptStopped chan struct{}
+	acceptErr     error
+	stopInit      sync.Once
+	stopOnce      sync.Once
+	stop          chan struct{}
+}
+
+// acceptResult is a connection, or error, ready to be returned by Accept.
+type acceptResult struct {
+	conn net.Conn
+	err  error
+}
+
+// IsClosedErr reports whether err, as returned by Listener.Accept, means the listener
+// has been closed. It is equivalent to errors.Is(err, net.ErrClosed).
+func IsClosedErr(err error) bool {
+	return net2.IsClosedErr(err)
+}
+
+func (l *Listener) connLimiter() *net2.ConnLimiter {
+	l.limiterOnce.Do(func() {
+		if l.MaxTotalConns > 0 {
+			l.limiter = net2.NewConnLimiter(l.MaxTotalConns)
+		}
+	})
+	return l.limiter
+}
+
+// WouldDeny returns the number of connections that were let through in AuditOnly mode
+// but would otherwise have been denied.
+func (l *Listener) WouldDeny() uint64 {
+	return l.wouldDeny.Load()
+}
+
+// ActiveConns returns the number of accepted connections currently open.
+// It is only tracked when MaxTotalConns is set and otherwise returns 0.
+func (l *Listener) ActiveConns() int {
+	if limiter := l.connLimiter(); limiter != nil {
+		return limiter.Active()
+	}
+	return 0
 }
 
 // Accept waits for and returns the next connection to the listener.
-// It checks each connection against the NetworkACL and closes it if not authorised.
+// It checks each connection against the NetworkACL and closes it if not authorised,
+// then continues or returns an error as set by DenyPolicy. Connections are authorised
+// inside Accept unless AuthoriseConcurrency is set.
+// Once the listener is closed, Accept returns an error matching net.ErrClosed.
 func (l *Listener) Accept() (net.Conn, error) {
+	if l.AuthoriseConcurrency > 0 {
+		return l.acceptAuthorised()
+	}
+
+	for {
+		c, err := l.accept()
+		if c != nil || err != nil {
+			return c, err
+		}
+	}
+}
+
+// accept accepts and authorises a single connection. It returns a nil connection and nil
+// error for a connection that was denied and should be skipped.
+func (l *Listener) accept() (net.Conn, error) {
+	c, err := l.acceptConn()
+	if c == nil || err != nil {
+		return c, err
+	}
+	return l.authorise(c)
+}
+
+// acceptAuthorised returns the next connection authorised by authoriseLoop.
+func (l *Listener) acceptAuthorised() (net.Conn, error) {
+	l.authoriseOnce.Do(func() {
+		l.authorised = make(chan acceptResult)
+		l.acceptStopped = make(chan struct{})
+		go l.authoriseLoop()
+	})
+
+	select {
+	case r := <-l.authorised:
+		return r.conn, r.err
+	case <-l.acceptStopped:
+		return nil, l.acceptErr
+	}
+}
+
+// authoriseLoop accepts connections and authorises up to AuthoriseConcurrency of them at
+// once, handing each result to Accept. It stops once the listener is closed.
+func (l *Listener) authoriseLoop() {
+	defer close(l.acceptStopped)
+
+	workers := make(chan struct{}, l.AuthoriseConcurrency)
+	for {
+		c, err := l.acceptConn()
+		if err != nil {
+			if IsClosedErr(err) {
+				l.acceptErr = err
+				return
+			}
+			l.deliver(nil, err)
+			continue
+		}
+		if c == nil {
+			continue
+		}
+
+		workers <- struct{}{}
+		go func() {
+			defer func() { <-workers }()
+			c, err := l.authorise(c)
+			if c != nil || err != nil {
+				l.deliver(c, err)
+			}
+		}()
+	}
+}
+
+// deliver waits for Accept to take c or err, closing c instead if the listener is closed.
+func (l *Listener) deliver(c net.Conn, err error) {
+	select {
+	case l.authorised <- acceptResult{conn: c, err: err}:
+	case <-l.stopping():
+		if c != nil {
+			_ = c.Close()
+		}
+	}
+}
+
+// stopping returns a channel that is closed when the listener is closed.
+func (l *Listener) stopping() chan struct{} {
+	l.stopInit.Do(func() {
+		l.stop = make(chan struct{})
+	})
+	return l.stop
+}
+
+// acceptConn accepts a single connection from the underlying listener, enforcing
+// MaxTotalConns. It returns a nil connection and nil error for a connection that was
+// rejected over the limit.
+func (l *Listener) acceptConn() (net.Conn, error) {
+	limiter := l.connLimiter()
+	if limiter != nil && !l.RejectOverLimit {
+		if !limiter.Acquire() {
+			return nil, net.ErrClosed
+		}
+	}
+
 	c, err := l.Listener.Accept()
 	if err != nil {
+		if limiter != nil && !l.RejectOverLimit {
+			limiter.Release()
+		}
+		if l.closed.Load() {
+			err = net2.WrapClosedErr(err)
+		}
 		return nil, err
 	}
 
-	authorised, err := l.NetworkACL.AuthoriseConn(c)
+	if limiter != nil && l.RejectOverLimit && !limiter.TryAcquire() {
+		l.Logger.Warn().Stringer("remoteAddr", c.RemoteAddr()).Msg("connection limit reached")
+		err = c.Close()
+		if err != nil {
+			l.Logger.Error().Err(err).Msg("closeConnError")
+		}
+		return nil, nil
+	}
+
+	return c, nil
+}
+
+// authorise decides on an accepted connection. It returns a nil connection and nil error
+// for a connection that was denied and should be skipped. A connection the authoriser
+// returns an error for is denied with ReasonAuthoriseError.
+func (l *Listener) authorise(c net.Conn) (net.Conn, error) {
+	limiter := l.connLimiter()
+
+	start := time.Now()
+	authorised, reason, err := l.authoriser().AuthoriseConnWithReason(c)
 	if err != nil {
-		return nil, err
+		l.Logger.Error().Err(err).Stringer("remoteAddr", c.RemoteAddr()).Msg("failed to authorise connection")
+		authorised, reason = false, ReasonAuthoriseError
+	}
+
+	if l.DecisionObserver != nil || l.AuditLog != nil {
+		d := Decision{
+			RemoteAddr: c.RemoteAddr(),
+			LocalAddr:  c.LocalAddr(),
+			Allowed:    authorised,
+			Reason:     reason,
+			Time:       start,
+			Duration:   time.Since(start),
+			AuditOnly:  !authorised && l.AuditOnly,
+		}
+		if l.DecisionObserver != nil {
+			l.DecisionObserver.ObserveDecision(d)
+		}
+		if l.AuditLog != nil {
+			l.AuditLog.ObserveDecision(d)
+		}
+	}
+
+	if l.Metrics != nil {
+		result := "allowed"
+		switch {
+		case !authorised && l.AuditOnly:
+			result = "would_deny"
+		case !authorised:
+			result = "denied"
+		}
+		l.Metrics.recordDecision(l.Name, result, reason)
+	}
+
+	if !authorised && l.AuditOnly {
+		l.wouldDeny.Add(1)
+		l.Logger.Warn().
+			Stringer("remoteAddr", c.RemoteAddr()).
+			Str("reason", reason).
+			Msg("access would be denied (audit only)")
+		authorised = true
 	}
 
 	if !authorised {
-		l.Logger.Warn().Stringer("remoteAddr", c.RemoteAddr()).Msg("access denied")
-		err = c.Close()
+		if limiter != nil {
+			limiter.Release()
+		}
+		l.Logger.Warn().Stringer("remoteAddr", c.RemoteAddr()).Str("reason", reason).Msg("access denied")
+		l.reject(c, reason)
+
+		if l.DenyPolicy == DenyPolicyError {
+			return nil, &DeniedError{RemoteAddr: c.RemoteAddr(), Reason: reason}
+		}
+		return nil, nil
+	}
+
+	if l.TCPOptions != nil {
+		c, err = l.TCPOptions.Apply(c)
 		if err != nil {
-			l.Logger.Error().Err(err).Msg("closeConnError")
+			l.Logger.Warn().Err(err).Stringer("remoteAddr", c.RemoteAddr()).Msg("failed to apply TCP options")
 		}
 	}
 
+	if limiter != nil {
+		c = limiter.Track(c)
+	}
+
+	if l.Metrics != nil {
+		open := l.Metrics.OpenConnections.WithLabelValues(l.Name)
+		open.Inc()
+		// Close may be called from several goroutines at once, so only decrement once
+		var once sync.Once
+		c = net2.NewConnWithCloser(c, func(net.Conn) { once.Do(open.Dec) })
+	}
+
 	return c, nil
 }
 
-// Close closes the listener.
+func (l *Listener) authoriser() Authoriser {
+	if l.Authoriser != nil {
+		return l.Authoriser
+	}
+	return l.NetworkACL
+}
+
+// reject closes a denied connection, first passing it to RejectHandler if set.
+func (l *Listener) reject(c net.Conn, reason string) {
+	closeConn := func() {
+		if err := c.Close(); err != nil {
+			l.Logger.Error().Err(err).Msg("closeConnError")
+		}
+	}
+
+	if l.RejectHandler == nil {
+		closeConn()
+		return
+	}
+
+	go func() {
+		defer closeConn()
+		l.RejectHandler(c, reason)
+	}()
+}
+
+// Close closes the listener, unblocking any Accept waiting on MaxTotalConns.
 func (l *Listener) Close() error {
+	l.closed.Store(true)
+	l.stopOnce.Do(func() {
+		close(l.stopping())
+	})
+	if limiter := l.connLimiter(); limiter != nil {
+		limiter.Close()
+	}
 	return l.Listener.Close()
 }
 
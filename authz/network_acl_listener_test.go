@@ -0,0 +1,289 @@
+package authz
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// shutdownListener returns a plain error, not matching net.ErrClosed, once closed.
+type shutdownListener struct {
+	net.Listener
+	closed chan struct{}
+}
+
+func (l *shutdownListener) Accept() (net.Conn, error) {
+	<-l.closed
+	return nil, errors.New("listener shut down")
+}
+
+func (l *shutdownListener) Close() error {
+	close(l.closed)
+	return nil
+}
+
+func TestListenerAcceptAfterClose(t *testing.T) {
+	tests := []struct {
+		name          string
+		maxTotalConns int
+		ln            func(t *testing.T) net.Listener
+	}{
+		{
+			name: "tcp listener",
+			ln: func(t *testing.T) net.Listener {
+				ln, err := net.Listen("tcp", "127.0.0.1:0")
+				require.NoError(t, err)
+				return ln
+			},
+		},
+		{
+			name: "listener returning plain error",
+			ln: func(t *testing.T) net.Listener {
+				return &shutdownListener{closed: make(chan struct{})}
+			},
+		},
+		{
+			name:          "closed while waiting on MaxTotalConns",
+			maxTotalConns: 1,
+			ln: func(t *testing.T) net.Listener {
+				return &shutdownListener{closed: make(chan struct{})}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &Listener{
+				NetworkACL:    &NetworkACL{},
+				Listener:      tt.ln(t),
+				Logger:        zerolog.Nop(),
+				MaxTotalConns: tt.maxTotalConns,
+			}
+			require.NoError(t, l.Close())
+
+			_, err := l.Accept()
+			assert.True(t, IsClosedErr(err), "got %v", err)
+		})
+	}
+}
+
+func TestListenerAuditOnly(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	acl, err := NewNetworkACL(NetworkACLConfig{DeniedNets: []string{"127.0.0.0/8"}})
+	require.NoError(t, err)
+
+	l := &Listener{NetworkACL: acl, Listener: ln, Logger: zerolog.Nop(), AuditOnly: true}
+	defer l.Close()
+
+	client, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	defer client.Close()
+
+	conn, err := l.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// The connection is still usable rather than closed
+	go func() { _, _ = client.Write([]byte("x")) }()
+	buf := make([]byte, 1)
+	_, err = conn.Read(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), l.WouldDeny())
+}
+
+func TestListenerDenyPolicy(t *testing.T) {
+	acl, err := NewNetworkACL(NetworkACLConfig{DeniedNets: []string{"127.0.0.0/8"}})
+	require.NoError(t, err)
+
+	t.Run("continue", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+
+		l := &Listener{NetworkACL: acl, Listener: ln, Logger: zerolog.Nop()}
+
+		client, err := net.Dial("tcp", ln.Addr().String())
+		require.NoError(t, err)
+		defer client.Close()
+
+		errCh := make(chan error, 1)
+		go func() {
+			conn, err := l.Accept()
+			if err == nil {
+				err = fmt.Errorf("unexpected connection from %v", conn.RemoteAddr())
+			}
+			errCh <- err
+		}()
+
+		// The denied connection is closed and Accept keeps waiting until the listener closes
+		_, err = client.Read(make([]byte, 1))
+		assert.ErrorIs(t, err, io.EOF)
+
+		require.NoError(t, l.Close())
+		assert.True(t, IsClosedErr(<-errCh))
+	})
+
+	t.Run("error", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+
+		l := &Listener{NetworkACL: acl, Listener: ln, Logger: zerolog.Nop(), DenyPolicy: DenyPolicyError}
+		defer l.Close()
+
+		client, err := net.Dial("tcp", ln.Addr().String())
+		require.NoError(t, err)
+		defer client.Close()
+
+		conn, err := l.Accept()
+		assert.Nil(t, conn)
+		assert.ErrorIs(t, err, ErrDenied)
+
+		var deniedErr *DeniedError
+		require.ErrorAs(t, err, &deniedErr)
+		assert.Equal(t, ReasonDenyList, deniedErr.Reason)
+
+		var netErr net.Error
+		require.ErrorAs(t, err, &netErr)
+		assert.True(t, netErr.Temporary(), "http.Server only retries temporary errors")
+	})
+
+	t.Run("reject handler", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+
+		l := &Listener{
+			NetworkACL: acl,
+			Listener:   ln,
+			Logger:     zerolog.Nop(),
+			DenyPolicy: DenyPolicyError,
+			RejectHandler: func(c net.Conn, reason string) {
+				_, _ = io.WriteString(c, "denied: "+reason+"\n")
+			},
+		}
+		defer l.Close()
+
+		client, err := net.Dial("tcp", ln.Addr().String())
+		require.NoError(t, err)
+		defer client.Close()
+
+		_, err = l.Accept()
+		assert.ErrorIs(t, err, ErrDenied)
+
+		banner, err := io.ReadAll(client)
+		require.NoError(t, err)
+		assert.Equal(t, "denied: "+ReasonDenyList+"\n", string(banner))
+	})
+}
+
+func TestListenerAuthoriserError(t *testing.T) {
+	authoriser := fixedAuthoriser{err: errors.New("authoriser unavailable")}
+
+	t.Run("continue", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+
+		l := &Listener{Authoriser: authoriser, Listener: ln, Logger: zerolog.Nop()}
+
+		client, err := net.Dial("tcp", ln.Addr().String())
+		require.NoError(t, err)
+		defer client.Close()
+
+		errCh := make(chan error, 1)
+		go func() {
+			conn, err := l.Accept()
+			if err == nil {
+				err = fmt.Errorf("unexpected connection from %v", conn.RemoteAddr())
+			}
+			errCh <- err
+		}()
+
+		// The connection is closed and Accept keeps waiting until the listener closes
+		_, err = client.Read(make([]byte, 1))
+		assert.ErrorIs(t, err, io.EOF)
+
+		require.NoError(t, l.Close())
+		assert.True(t, IsClosedErr(<-errCh))
+	})
+
+	t.Run("error", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+
+		l := &Listener{Authoriser: authoriser, Listener: ln, Logger: zerolog.Nop(), DenyPolicy: DenyPolicyError}
+		defer l.Close()
+
+		client, err := net.Dial("tcp", ln.Addr().String())
+		require.NoError(t, err)
+		defer client.Close()
+
+		conn, err := l.Accept()
+		assert.Nil(t, conn)
+
+		var deniedErr *DeniedError
+		require.ErrorAs(t, err, &deniedErr)
+		assert.Equal(t, ReasonAuthoriseError, deniedErr.Reason)
+
+		_, err = client.Read(make([]byte, 1))
+		assert.ErrorIs(t, err, io.EOF)
+	})
+}
+
+func TestListenerRejectOverLimit(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	acl, err := NewNetworkACL(NetworkACLConfig{AllowByDefault: true})
+	require.NoError(t, err)
+
+	l := &Listener{
+		NetworkACL:      acl,
+		Listener:        ln,
+		Logger:          zerolog.Nop(),
+		MaxTotalConns:   1,
+		RejectOverLimit: true,
+	}
+	defer l.Close()
+
+	first, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	defer first.Close()
+
+	conn, err := l.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	over, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	defer over.Close()
+
+	connCh := make(chan net.Conn, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			conn = nil
+		}
+		connCh <- conn
+	}()
+
+	// The connection over the limit is closed rather than returned
+	_, err = over.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, io.EOF)
+
+	// Accept keeps waiting and returns the next connection within the limit
+	require.NoError(t, conn.Close())
+	next, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	defer next.Close()
+
+	accepted := <-connCh
+	require.NotNil(t, accepted)
+	defer accepted.Close()
+	assert.Equal(t, next.LocalAddr().String(), accepted.RemoteAddr().String())
+}
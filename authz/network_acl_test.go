@@ -1,10 +1,20 @@
 package authz
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net"
+	"net/netip"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/dioad/net/authz/prefixlist"
 )
 
 func TestParseNetWithDefault(t *testing.T) {
@@ -35,24 +45,24 @@ func TestContains(t *testing.T) {
 	if err != nil {
 		t.Fatalf("failed to parse cidr")
 	}
-	list := []*net.IPNet{
+	list := newPrefixTrie([]*net.IPNet{
 		cidrOne,
 		cidrTwo,
-	}
+	})
 
 	addrOne := net.ParseIP("127.0.0.123")
 
-	gotOne := containsAddress(list, addrOne)
+	gotOne := list.contains(addrOne)
 	require.Equal(t, gotOne, true)
 
 	addrTwo := net.ParseIP("10.0.0.1")
 
-	gotTwo := containsAddress(list, addrTwo)
+	gotTwo := list.contains(addrTwo)
 	require.Equal(t, gotTwo, true)
 
 	addrThree := net.ParseIP("192.164.12.45")
 
-	gotThree := containsAddress(list, addrThree)
+	gotThree := list.contains(addrThree)
 	require.Equal(t, gotThree, false)
 }
 
@@ -213,3 +223,659 @@ func TestAuthoriserIPv6SingleAddress(t *testing.T) {
 
 	require.False(t, got)
 }
+
+func TestAuthoriserAllowFunc(t *testing.T) {
+	reputation := map[string]bool{
+		"10.0.0.5":    false, // known bad inside an allowed range
+		"192.168.1.1": true,  // known good inside a denied range
+	}
+
+	c := NetworkACLConfig{
+		AllowedNets: []string{"10.0.0.0/8"},
+		DeniedNets:  []string{"192.168.0.0/16"},
+		AllowFunc: func(ip net.IP) (bool, bool) {
+			allow, ok := reputation[ip.String()]
+			return allow, ok
+		},
+	}
+
+	a, err := NewNetworkACL(c)
+	require.NoError(t, err)
+
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"10.0.0.5:1234", false},    // AllowFunc denies despite allow list
+		{"192.168.1.1:1234", true},  // AllowFunc allows despite deny list
+		{"10.0.0.6:1234", true},     // undecided, falls through to allow list
+		{"192.168.1.2:1234", false}, // undecided, falls through to deny list
+		{"172.16.0.1:1234", false},  // undecided, default deny
+	}
+
+	for _, tt := range tests {
+		got, err := a.AuthoriseFromString(tt.addr)
+		require.NoError(t, err)
+		require.Equal(t, tt.want, got, tt.addr)
+	}
+}
+
+func TestNetworkACLEvaluationOrder(t *testing.T) {
+	cfg := NetworkACLConfig{
+		AllowedNets: []string{"10.0.0.0/8", "192.168.1.1", "172.16.0.0/12"},
+		DeniedNets:  []string{"10.0.0.1", "192.168.0.0/16", "172.16.0.0/12"},
+		DeniedRules: []string{"ip == 10.2.0.1"},
+	}
+
+	tests := []struct {
+		order EvaluationOrder
+		want  map[string]bool
+	}{
+		{
+			order: "",
+			want:  map[string]bool{"10.0.0.1": false, "10.0.0.2": true, "192.168.1.1": false, "172.16.0.1": false, "10.2.0.1": false},
+		},
+		{
+			order: EvaluationOrderDenyFirst,
+			want:  map[string]bool{"10.0.0.1": false, "10.0.0.2": true, "192.168.1.1": false, "172.16.0.1": false, "10.2.0.1": false},
+		},
+		{
+			order: EvaluationOrderAllowFirst,
+			want:  map[string]bool{"10.0.0.1": true, "10.0.0.2": true, "192.168.1.1": true, "172.16.0.1": true, "10.2.0.1": false},
+		},
+		{
+			order: EvaluationOrderMostSpecific,
+			want:  map[string]bool{"10.0.0.1": false, "10.0.0.2": true, "192.168.1.1": true, "192.168.1.2": false, "172.16.0.1": false, "10.2.0.1": false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.order), func(t *testing.T) {
+			cfg := cfg
+			cfg.EvaluationOrder = tt.order
+			acl, err := NewNetworkACL(cfg)
+			require.NoError(t, err)
+
+			for ip, want := range tt.want {
+				assert.Equal(t, want, acl.AuthoriseIP(netip.MustParseAddr(ip)), ip)
+			}
+		})
+	}
+
+	_, err := NewNetworkACL(NetworkACLConfig{EvaluationOrder: "first-match"})
+	assert.Error(t, err)
+}
+
+func TestNetworkACLMutation(t *testing.T) {
+	acl, err := NewNetworkACL(NetworkACLConfig{})
+	require.NoError(t, err)
+
+	require.NoError(t, acl.AllowCIDR("10.0.0.0/8"))
+	require.NoError(t, acl.AllowCIDR("10.0.0.0/8"))
+	require.NoError(t, acl.DenyCIDR("10.0.0.5"))
+	require.Error(t, acl.AllowCIDR("not-a-network"))
+
+	require.Equal(t, []string{"10.0.0.0/8"}, acl.ListAllowed())
+	require.Equal(t, []string{"10.0.0.5/32"}, acl.ListDenied())
+
+	require.True(t, acl.Authorise(&net.TCPAddr{IP: net.ParseIP("10.1.2.3")}))
+	require.False(t, acl.Authorise(&net.TCPAddr{IP: net.ParseIP("10.0.0.5")}))
+
+	require.NoError(t, acl.RemoveDenyCIDR("10.0.0.5/32"))
+	require.True(t, acl.Authorise(&net.TCPAddr{IP: net.ParseIP("10.0.0.5")}))
+
+	require.NoError(t, acl.RemoveAllowCIDR("10.0.0.0/8"))
+	require.False(t, acl.Authorise(&net.TCPAddr{IP: net.ParseIP("10.1.2.3")}))
+
+	require.ErrorIs(t, acl.RemoveAllowCIDR("10.0.0.0/8"), ErrNetworkNotFound)
+	require.Empty(t, acl.ListAllowed())
+}
+
+func TestNetworkACLPrefixMutation(t *testing.T) {
+	acl, err := NewNetworkACL(NetworkACLConfig{DeniedNets: []string{"10.0.0.5"}})
+	require.NoError(t, err)
+
+	before := acl.Snapshot()
+
+	require.NoError(t, acl.AddAllowedNet(netip.MustParsePrefix("10.0.0.0/8")))
+	require.NoError(t, acl.AddDeniedNet(netip.MustParsePrefix("2001:db8::/32")))
+	require.NoError(t, acl.AddAllowedNet(netip.MustParsePrefix("2001:db8::/32")))
+	require.Error(t, acl.AddAllowedNet(netip.Prefix{}))
+
+	assert.Equal(t, NetworkACLSnapshot{DeniedNets: []string{"10.0.0.5/32"}, AllowedNets: []string{}}, before)
+	assert.Equal(t, NetworkACLSnapshot{
+		AllowedNets: []string{"10.0.0.0/8", "2001:db8::/32"},
+		DeniedNets:  []string{"10.0.0.5/32", "2001:db8::/32"},
+	}, acl.Snapshot())
+	assert.False(t, acl.AuthoriseIP(netip.MustParseAddr("2001:db8::1")))
+
+	require.NoError(t, acl.RemoveNet(netip.MustParsePrefix("2001:db8::/32")))
+	assert.Equal(t, []string{"10.0.0.0/8"}, acl.ListAllowed())
+	assert.Equal(t, []string{"10.0.0.5/32"}, acl.ListDenied())
+	require.ErrorIs(t, acl.RemoveNet(netip.MustParsePrefix("2001:db8::/32")), ErrNetworkNotFound)
+
+	require.NoError(t, acl.RemoveNet(netip.MustParsePrefix("10.0.0.5/32")))
+	assert.True(t, acl.AuthoriseIP(netip.MustParseAddr("10.0.0.5")))
+}
+
+func TestNetworkACLZeroValueMutation(t *testing.T) {
+	acl := &NetworkACL{}
+	assert.False(t, acl.AuthoriseIP(netip.MustParseAddr("10.0.0.1")))
+	assert.Empty(t, acl.Snapshot().AllowedNets)
+
+	require.NoError(t, acl.AddAllowedNet(netip.MustParsePrefix("10.0.0.0/8")))
+	assert.True(t, acl.AuthoriseIP(netip.MustParseAddr("10.0.0.1")))
+}
+
+func TestNetworkACLConcurrentMutation(t *testing.T) {
+	acl, err := NewNetworkACL(NetworkACLConfig{})
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(2)
+		cidr := fmt.Sprintf("192.168.%d.0/24", i)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				_ = acl.AllowCIDR(cidr)
+				_ = acl.RemoveAllowCIDR(cidr)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				acl.Authorise(&net.TCPAddr{IP: net.ParseIP("192.168.1.1")})
+				_ = acl.ListAllowed()
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestAuthoriseFromStringIPv6Zone(t *testing.T) {
+	acl, err := NewNetworkACL(NetworkACLConfig{
+		AllowedNets: []string{"fe80::/10", "10.0.0.0/8"},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{addr: "[fe80::1%eth0]:1234", want: true},
+		{addr: "fe80::1%eth0:1234", want: true},
+		{addr: "fe80::1%eth0", want: true},
+		{addr: "[fe80::1]:1234", want: true},
+		{addr: "[2001:db8::1%eth0]:1234", want: false},
+		{addr: "10.1.2.3:80", want: true},
+		{addr: "10.1.2.3", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			got, err := acl.AuthoriseFromString(tt.addr)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err = acl.AuthoriseFromString("fe80::zz%eth0:1234")
+	require.Error(t, err)
+}
+
+func TestAuthoriseIPv4Mapped(t *testing.T) {
+	acl, err := NewNetworkACL(NetworkACLConfig{
+		AllowedNets: []string{"10.0.0.0/8", "::ffff:192.168.0.0/112", "2001:db8::/32"},
+		DeniedNets:  []string{"10.1.0.0/16"},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{addr: "::ffff:10.0.0.1", want: true},
+		{addr: "[::ffff:10.0.0.1]:443", want: true},
+		{addr: "[::ffff:10.1.0.1]:443", want: false},
+		{addr: "192.168.1.1", want: true},
+		{addr: "::ffff:192.168.1.1", want: true},
+		{addr: "[2001:db8::1%eth0]:443", want: true},
+		{addr: "::ffff:172.16.0.1", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			got, err := acl.AuthoriseFromString(tt.addr)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestAuthoriseIP(t *testing.T) {
+	acl, err := NewNetworkACL(NetworkACLConfig{
+		AllowedNets: []string{"10.0.0.0/8", "fe80::/10"},
+	})
+	require.NoError(t, err)
+
+	assert.True(t, acl.AuthoriseIP(netip.MustParseAddr("10.0.0.1")))
+	assert.True(t, acl.AuthoriseIP(netip.MustParseAddr("::ffff:10.0.0.1")))
+	assert.True(t, acl.AuthoriseIP(netip.MustParseAddr("fe80::1%eth0")))
+	assert.False(t, acl.AuthoriseIP(netip.MustParseAddr("192.0.2.1")))
+	assert.False(t, acl.AuthoriseIP(netip.Addr{}))
+
+	allowed, reason := acl.AuthoriseIPWithReason(netip.MustParseAddr("::ffff:10.0.0.1"))
+	assert.True(t, allowed)
+	assert.Equal(t, ReasonAllowList, reason)
+}
+
+func TestAuthoriseAddr(t *testing.T) {
+	acl, err := NewNetworkACL(NetworkACLConfig{
+		AllowedNets: []string{"10.0.0.0/8", "fe80::/10"},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		addr net.Addr
+		want bool
+	}{
+		{name: "tcp", addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 80}, want: true},
+		{name: "tcp mapped", addr: &net.TCPAddr{IP: net.ParseIP("::ffff:10.0.0.1"), Port: 80}, want: true},
+		{name: "udp", addr: &net.UDPAddr{IP: net.ParseIP("fe80::1"), Port: 53, Zone: "eth0"}, want: true},
+		{name: "ip", addr: &net.IPAddr{IP: net.ParseIP("192.0.2.1")}, want: false},
+		{name: "other", addr: &net.UnixAddr{Name: "10.0.0.1:80", Net: "unix"}, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := acl.AuthoriseAddr(tt.addr)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err = acl.AuthoriseAddr(nil)
+	require.Error(t, err)
+}
+
+func TestAuthoriseWithReason(t *testing.T) {
+	acl, err := NewNetworkACL(NetworkACLConfig{
+		AllowedNets: []string{"10.0.0.0/8"},
+		DeniedNets:  []string{"10.1.0.0/16", "192.0.2.0/24"},
+		AllowFunc: func(ip net.IP) (bool, bool) {
+			return true, ip.Equal(net.ParseIP("198.51.100.1"))
+		},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		addr        string
+		allowByDflt bool
+		wantAllowed bool
+		wantReason  string
+	}{
+		{addr: "10.0.0.1", wantAllowed: true, wantReason: ReasonAllowList},
+		{addr: "10.1.0.1", wantAllowed: false, wantReason: ReasonDenyList},
+		{addr: "192.0.2.1", wantAllowed: false, wantReason: ReasonDenyList},
+		{addr: "198.51.100.1", wantAllowed: true, wantReason: ReasonAllowFunc},
+		{addr: "203.0.113.1", wantAllowed: false, wantReason: ReasonDefaultDeny},
+		{addr: "203.0.113.1", allowByDflt: true, wantAllowed: true, wantReason: ReasonDefaultAllow},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr+"/"+tt.wantReason, func(t *testing.T) {
+			acl.AllowByDefault = tt.allowByDflt
+			allowed, reason := acl.AuthoriseWithReason(&net.TCPAddr{IP: net.ParseIP(tt.addr)})
+			assert.Equal(t, tt.wantAllowed, allowed)
+			assert.Equal(t, tt.wantReason, reason)
+
+			allowed, reason, err := acl.AuthoriseFromStringWithReason(net.JoinHostPort(tt.addr, "1234"))
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantAllowed, allowed)
+			assert.Equal(t, tt.wantReason, reason)
+		})
+	}
+}
+
+// staticPrefixProvider is a prefixlist.Provider with a fixed prefix, registered as
+// "static-test" so that it can be referenced from AllowedProviders.
+type staticPrefixProvider struct {
+	prefix netip.Prefix
+}
+
+func (p *staticPrefixProvider) Name() string { return "static-test" }
+
+func (p *staticPrefixProvider) Prefixes(context.Context) ([]netip.Prefix, error) {
+	return []netip.Prefix{p.prefix}, nil
+}
+
+func (p *staticPrefixProvider) Contains(addr netip.Addr) bool { return p.prefix.Contains(addr) }
+
+func init() {
+	prefixlist.RegisterProvider("static-test", func(cfg prefixlist.ProviderConfig) (prefixlist.Provider, error) {
+		prefix, err := netip.ParsePrefix(cfg.Filter["prefix"])
+		if err != nil {
+			return nil, err
+		}
+		return &staticPrefixProvider{prefix: prefix}, nil
+	})
+}
+
+func TestNetworkACLAllowedProviders(t *testing.T) {
+	acl, err := NewNetworkACL(NetworkACLConfig{
+		AllowedNets:      []string{"10.0.0.0/8"},
+		DeniedNets:       []string{"203.0.113.128/25"},
+		AllowedProviders: []string{"static-test?prefix=203.0.113.0/24"},
+	})
+	require.NoError(t, err)
+
+	// Provider prefixes are unknown until refreshed
+	allowed, reason := acl.AuthoriseWithReason(&net.TCPAddr{IP: net.ParseIP("203.0.113.1")})
+	assert.False(t, allowed)
+	assert.Equal(t, ReasonDefaultDeny, reason)
+
+	require.NoError(t, acl.RefreshProviders(context.Background()))
+
+	tests := []struct {
+		addr        string
+		wantAllowed bool
+		wantReason  string
+	}{
+		{addr: "10.0.0.1", wantAllowed: true, wantReason: ReasonAllowList},
+		{addr: "203.0.113.1", wantAllowed: true, wantReason: ReasonAllowProvider},
+		{addr: "::ffff:203.0.113.1", wantAllowed: true, wantReason: ReasonAllowProvider},
+		{addr: "203.0.113.200", wantAllowed: false, wantReason: ReasonDenyList},
+		{addr: "198.51.100.1", wantAllowed: false, wantReason: ReasonDefaultDeny},
+	}
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			allowed, reason := acl.AuthoriseWithReason(&net.TCPAddr{IP: net.ParseIP(tt.addr)})
+			assert.Equal(t, tt.wantAllowed, allowed)
+			assert.Equal(t, tt.wantReason, reason)
+		})
+	}
+}
+
+func TestNetworkACLRunProviders(t *testing.T) {
+	acl, err := NewNetworkACL(NetworkACLConfig{AllowedProviders: []string{"static-test?prefix=203.0.113.0/24"}})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		acl.RunProviders(ctx, time.Hour)
+		close(done)
+	}()
+
+	require.Eventually(t, func() bool {
+		return acl.Authorise(&net.TCPAddr{IP: net.ParseIP("203.0.113.1")})
+	}, time.Second, 5*time.Millisecond)
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("RunProviders did not return after context cancellation")
+	}
+}
+
+func TestNetworkACLAllowedProvidersInvalid(t *testing.T) {
+	_, err := NewNetworkACL(NetworkACLConfig{AllowedProviders: []string{"no-such-provider"}})
+	assert.Error(t, err)
+
+	_, err = NewNetworkACL(NetworkACLConfig{AllowedProviders: []string{"?prefix=10.0.0.0/8"}})
+	assert.Error(t, err)
+
+	acl, err := NewNetworkACL(NetworkACLConfig{})
+	require.NoError(t, err)
+	assert.NoError(t, acl.RefreshProviders(context.Background()))
+	acl.RunProviders(context.Background(), time.Hour) // returns at once without providers
+}
+
+func TestNetworkACLRules(t *testing.T) {
+	acl, err := NewNetworkACL(NetworkACLConfig{
+		AllowedNets:  []string{"192.168.0.0/16"},
+		AllowedRules: []string{"ip in 10.0.0.0/8 && port != 22", "sni == public.example.com"},
+		DeniedRules:  []string{"ip == 192.168.1.1 && port == 22"},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		md         ConnMetadata
+		wantAllow  bool
+		wantReason string
+	}{
+		{
+			name:       "allowed rule",
+			md:         ConnMetadata{RemoteAddr: netip.MustParseAddrPort("10.0.0.1:5000"), LocalAddr: netip.MustParseAddrPort("10.0.0.2:443")},
+			wantAllow:  true,
+			wantReason: ReasonAllowRule,
+		},
+		{
+			name:       "port excluded by rule",
+			md:         ConnMetadata{RemoteAddr: netip.MustParseAddrPort("10.0.0.1:5000"), LocalAddr: netip.MustParseAddrPort("10.0.0.2:22")},
+			wantReason: ReasonDefaultDeny,
+		},
+		{
+			name:       "server name",
+			md:         ConnMetadata{RemoteAddr: netip.MustParseAddrPort("198.51.100.1:5000"), ServerName: "public.example.com"},
+			wantAllow:  true,
+			wantReason: ReasonAllowRule,
+		},
+		{
+			name:       "denied rule overrides allow list",
+			md:         ConnMetadata{RemoteAddr: netip.MustParseAddrPort("192.168.1.1:5000"), LocalAddr: netip.MustParseAddrPort("10.0.0.2:22")},
+			wantReason: ReasonDenyRule,
+		},
+		{
+			name:       "allow list",
+			md:         ConnMetadata{RemoteAddr: netip.MustParseAddrPort("192.168.1.1:5000"), LocalAddr: netip.MustParseAddrPort("10.0.0.2:443")},
+			wantAllow:  true,
+			wantReason: ReasonAllowList,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allowed, reason := acl.AuthoriseMetadataWithReason(tt.md)
+			assert.Equal(t, tt.wantAllow, allowed)
+			assert.Equal(t, tt.wantReason, reason)
+		})
+	}
+
+	// without a local port, "port != 22" is not known to hold
+	assert.False(t, acl.AuthoriseIP(netip.MustParseAddr("10.0.0.1")))
+
+	_, err = NewNetworkACL(NetworkACLConfig{AllowedRules: []string{"ip in"}})
+	assert.Error(t, err)
+}
+
+func TestNetworkACLRulesConn(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	port := ln.Addr().(*net.TCPAddr).Port
+	acl, err := NewNetworkACL(NetworkACLConfig{
+		AllowedRules: []string{fmt.Sprintf("ip == 127.0.0.1 && port == %d", port)},
+	})
+	require.NoError(t, err)
+
+	client, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	defer client.Close()
+
+	server, err := ln.Accept()
+	require.NoError(t, err)
+	defer server.Close()
+
+	allowed, reason, err := acl.AuthoriseConnWithReason(server)
+	require.NoError(t, err)
+	assert.True(t, allowed)
+	assert.Equal(t, ReasonAllowRule, reason)
+
+	// from the client's side the local port is the ephemeral one
+	allowed, err = acl.AuthoriseConn(client)
+	require.NoError(t, err)
+	assert.False(t, allowed)
+}
+
+func TestNetworkACLTimeWindows(t *testing.T) {
+	acl, err := NewNetworkACL(NetworkACLConfig{
+		AllowedNets: []string{"10.0.0.0/8"},
+		TimeWindows: []TimeWindowConfig{
+			{Schedule: "* 9-16 * * Mon-Fri", AllowedNets: []string{"203.0.113.0/24"}},
+			{Schedule: "* 0-5 * * *", DeniedRules: []string{"ip in 10.1.0.0/16 && port == 22"}},
+		},
+	})
+	require.NoError(t, err)
+
+	// 2026-03-02 is a Monday
+	monday := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	partner := ConnMetadata{RemoteAddr: netip.MustParseAddrPort("203.0.113.5:5000"), LocalAddr: netip.MustParseAddrPort("10.0.0.1:443")}
+	admin := ConnMetadata{RemoteAddr: netip.MustParseAddrPort("10.1.0.5:5000"), LocalAddr: netip.MustParseAddrPort("10.0.0.1:22")}
+
+	tests := []struct {
+		name       string
+		now        time.Time
+		md         ConnMetadata
+		wantAllow  bool
+		wantReason string
+	}{
+		{name: "partner in office hours", now: monday.Add(10 * time.Hour), md: partner, wantAllow: true, wantReason: ReasonAllowTimeWindow},
+		{name: "partner out of hours", now: monday.Add(18 * time.Hour), md: partner, wantReason: ReasonDefaultDeny},
+		{name: "partner at weekend", now: monday.AddDate(0, 0, -1).Add(10 * time.Hour), md: partner, wantReason: ReasonDefaultDeny},
+		{name: "ssh during maintenance", now: monday.Add(3 * time.Hour), md: admin, wantReason: ReasonDenyTimeWindow},
+		{name: "ssh after maintenance", now: monday.Add(6 * time.Hour), md: admin, wantAllow: true, wantReason: ReasonAllowList},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			acl.now = func() time.Time { return tt.now }
+			allowed, reason := acl.AuthoriseMetadataWithReason(tt.md)
+			assert.Equal(t, tt.wantAllow, allowed)
+			assert.Equal(t, tt.wantReason, reason)
+		})
+	}
+
+	_, err = NewNetworkACL(NetworkACLConfig{TimeWindows: []TimeWindowConfig{{Schedule: "9-17"}}})
+	assert.Error(t, err)
+	_, err = NewNetworkACL(NetworkACLConfig{TimeWindows: []TimeWindowConfig{{Schedule: "* * * * *", AllowedNets: []string{"bogus"}}}})
+	assert.Error(t, err)
+}
+
+func TestNetworkACLAllowedHosts(t *testing.T) {
+	resolver := &fakeHostResolver{addrs: []netip.Addr{netip.MustParseAddr("192.0.2.10")}}
+	acl, err := NewNetworkACL(NetworkACLConfig{
+		AllowedHosts: []string{"vpn.example.com"},
+		HostResolver: resolver,
+		DeniedNets:   []string{"192.0.2.20"},
+	})
+	require.NoError(t, err)
+
+	// nothing is allowed until the hosts are resolved
+	assert.False(t, acl.AuthoriseIP(netip.MustParseAddr("192.0.2.10")))
+
+	require.NoError(t, acl.RefreshHosts(context.Background()))
+	allowed, reason := acl.AuthoriseIPWithReason(netip.MustParseAddr("192.0.2.10"))
+	assert.True(t, allowed)
+	assert.Equal(t, ReasonAllowHost, reason)
+
+	// the host moves: the new address is allowed and the old one no longer is, while the
+	// deny list still wins
+	resolver.addrs = []netip.Addr{netip.MustParseAddr("192.0.2.20"), netip.MustParseAddr("192.0.2.30")}
+	require.NoError(t, acl.RefreshHosts(context.Background()))
+	assert.False(t, acl.AuthoriseIP(netip.MustParseAddr("192.0.2.10")))
+	assert.True(t, acl.AuthoriseIP(netip.MustParseAddr("192.0.2.30")))
+	allowed, reason = acl.AuthoriseIPWithReason(netip.MustParseAddr("192.0.2.20"))
+	assert.False(t, allowed)
+	assert.Equal(t, ReasonDenyList, reason)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	acl.RunHosts(ctx, time.Hour) // returns once the context is cancelled
+
+	acl, err = NewNetworkACL(NetworkACLConfig{})
+	require.NoError(t, err)
+	assert.NoError(t, acl.RefreshHosts(context.Background()))
+	acl.RunHosts(context.Background(), time.Hour) // returns at once without hosts
+}
+
+func TestNetworkACLASNs(t *testing.T) {
+	announced := map[uint32][]netip.Prefix{
+		64500: {netip.MustParsePrefix("192.0.2.0/24"), netip.MustParsePrefix("2001:db8::/32")},
+		64501: {netip.MustParsePrefix("192.0.2.128/25")},
+	}
+	resolver := prefixlist.ASNResolverFunc(func(ctx context.Context, asn uint32) ([]netip.Prefix, error) {
+		prefixes, ok := announced[asn]
+		if !ok {
+			return nil, fmt.Errorf("unknown AS%d", asn)
+		}
+		return prefixes, nil
+	})
+
+	acl, err := NewNetworkACL(NetworkACLConfig{
+		AllowedNets: []string{"198.51.100.0/24"},
+		AllowedASNs: []uint32{64500},
+		DeniedASNs:  []uint32{64501},
+		ASNResolver: resolver,
+	})
+	require.NoError(t, err)
+	require.NoError(t, acl.RefreshProviders(context.Background()))
+
+	tests := []struct {
+		addr        string
+		wantAllowed bool
+		wantReason  string
+	}{
+		{addr: "192.0.2.1", wantAllowed: true, wantReason: ReasonAllowProvider},
+		{addr: "2001:db8::1", wantAllowed: true, wantReason: ReasonAllowProvider},
+		{addr: "192.0.2.200", wantAllowed: false, wantReason: ReasonDenyProvider},
+		{addr: "198.51.100.1", wantAllowed: true, wantReason: ReasonAllowList},
+		{addr: "203.0.113.1", wantAllowed: false, wantReason: ReasonDefaultDeny},
+	}
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			allowed, reason := acl.AuthoriseWithReason(&net.TCPAddr{IP: net.ParseIP(tt.addr)})
+			assert.Equal(t, tt.wantAllowed, allowed)
+			assert.Equal(t, tt.wantReason, reason)
+		})
+	}
+
+	failing, err := NewNetworkACL(NetworkACLConfig{DeniedASNs: []uint32{64999}, ASNResolver: resolver})
+	require.NoError(t, err)
+	assert.Error(t, failing.RefreshProviders(context.Background()))
+}
+
+func TestNetworkACLDeniedASNsSurviveResolverFailure(t *testing.T) {
+	var failing atomic.Bool
+	resolver := prefixlist.ASNResolverFunc(func(ctx context.Context, asn uint32) ([]netip.Prefix, error) {
+		if failing.Load() {
+			return nil, errors.New("resolver unavailable")
+		}
+		return []netip.Prefix{netip.MustParsePrefix("192.0.2.0/24")}, nil
+	})
+
+	acl, err := NewNetworkACL(NetworkACLConfig{
+		AllowByDefault: true,
+		DeniedASNs:     []uint32{64501},
+		ASNResolver:    resolver,
+	})
+	require.NoError(t, err)
+	require.NoError(t, acl.RefreshProviders(context.Background()))
+
+	addr := &net.TCPAddr{IP: net.ParseIP("192.0.2.1")}
+	allowed, reason := acl.AuthoriseWithReason(addr)
+	assert.False(t, allowed)
+	assert.Equal(t, ReasonDenyProvider, reason)
+
+	// A failed refresh keeps the prefixes of the last successful one
+	failing.Store(true)
+	assert.Error(t, acl.RefreshProviders(context.Background()))
+	allowed, reason = acl.AuthoriseWithReason(addr)
+	assert.False(t, allowed)
+	assert.Equal(t, ReasonDenyProvider, reason)
+}
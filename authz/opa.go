@@ -0,0 +1,292 @@
+package authz
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// Reasons reported by OPAAuthoriser for decisions that were not made by its fast path.
+const (
+	// ReasonOPA means the OPA policy made the decision and did not report a reason.
+	ReasonOPA = "opa"
+	// ReasonOPAError means OPA could not be queried, or returned an undefined or invalid
+	// result, and the failure decision applied.
+	ReasonOPAError = "opa error"
+)
+
+// DefaultOPATimeout is how long an OPAAuthoriser waits for OPA to answer a query.
+const DefaultOPATimeout = 1 * time.Second
+
+// DefaultOPACacheTTL is how long an OPAAuthoriser reuses a decision from OPA.
+const DefaultOPACacheTTL = 1 * time.Minute
+
+// maxOPACacheEntries bounds the decision cache; it is cleared when full.
+const maxOPACacheEntries = 10000
+
+// OPAOption configures an OPAAuthoriser.
+type OPAOption func(*OPAAuthoriser)
+
+// WithOPAFastPath sets a NetworkACL that is consulted before OPA. Its decision is used
+// whenever one of its rules matched, so OPA is only queried for connections that would
+// otherwise fall to the ACL's default.
+func WithOPAFastPath(acl *NetworkACL) OPAOption {
+	return func(a *OPAAuthoriser) {
+		a.fastPath = acl
+	}
+}
+
+// WithOPAHTTPClient sets the HTTP client used to query OPA. The default is
+// http.DefaultClient; queries are bounded by the timeout set with WithOPATimeout.
+func WithOPAHTTPClient(client *http.Client) OPAOption {
+	return func(a *OPAAuthoriser) {
+		a.client = client
+	}
+}
+
+// WithOPATimeout sets how long to wait for OPA to answer a query. The default is
+// DefaultOPATimeout.
+func WithOPATimeout(d time.Duration) OPAOption {
+	return func(a *OPAAuthoriser) {
+		a.timeout = d
+	}
+}
+
+// WithOPACacheTTL sets how long a decision from OPA is reused for connections with the
+// same client address, local port and server name. Zero disables caching. The default
+// is DefaultOPACacheTTL.
+func WithOPACacheTTL(d time.Duration) OPAOption {
+	return func(a *OPAAuthoriser) {
+		a.cacheTTL = d
+	}
+}
+
+// WithOPAFailOpen allows connections when OPA cannot be queried or returns an invalid
+// result. By default they are denied.
+func WithOPAFailOpen() OPAOption {
+	return func(a *OPAAuthoriser) {
+		a.failOpen = true
+	}
+}
+
+// WithOPALogger sets the logger used to report failed queries.
+func WithOPALogger(logger zerolog.Logger) OPAOption {
+	return func(a *OPAAuthoriser) {
+		a.logger = logger
+	}
+}
+
+// OPAAuthoriser is an Authoriser that delegates decisions to an Open Policy Agent
+// instance, such as a sidecar, through its REST data API, so that policy can be changed
+// centrally without redeploying services.
+//
+// For each connection it queries the policy decision at its URL, for example
+// "http://localhost:8181/v1/data/netauthz/allow", with the input
+//
+//	{"remote_addr": "10.0.0.1", "remote_port": 50000, "local_addr": "10.0.0.2",
+//	 "local_port": 443, "server_name": "example.com"}
+//
+// The decision may be a boolean, or an object with an "allow" boolean and an optional
+// "reason" string. Fields that are not known, such as the server name before the TLS
+// handshake, are omitted.
+//
+// Decisions are cached briefly and an optional NetworkACL fast path answers connections
+// that its rules match without a query. Failures are logged and fail closed, unless
+// WithOPAFailOpen is set; they are never returned as errors, so that a listener keeps
+// accepting while OPA is unavailable.
+//
+// A query blocks its caller for up to the OPA timeout. A Listener authorises inside
+// Accept by default, so a slow OPA holds up every connection waiting to be accepted;
+// set Listener.AuthoriseConcurrency to query for several connections at once.
+type OPAAuthoriser struct {
+	url      string
+	client   *http.Client
+	timeout  time.Duration
+	cacheTTL time.Duration
+	failOpen bool
+	fastPath *NetworkACL
+	logger   zerolog.Logger
+	now      func() time.Time
+
+	mu    sync.Mutex
+	cache map[opaCacheKey]opaCacheEntry
+}
+
+type opaCacheKey struct {
+	remoteAddr string
+	localPort  uint16
+	serverName string
+}
+
+type opaCacheEntry struct {
+	allowed bool
+	reason  string
+	expires time.Time
+}
+
+// NewOPAAuthoriser creates an OPAAuthoriser that queries the policy decision at url.
+func NewOPAAuthoriser(url string, opts ...OPAOption) *OPAAuthoriser {
+	a := &OPAAuthoriser{
+		url:      url,
+		client:   http.DefaultClient,
+		timeout:  DefaultOPATimeout,
+		cacheTTL: DefaultOPACacheTTL,
+		logger:   zerolog.Nop(),
+		now:      time.Now,
+		cache:    make(map[opaCacheKey]opaCacheEntry),
+	}
+
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	return a
+}
+
+// AuthoriseConnWithReason decides on c with the fast path, the cache or OPA, in that
+// order. It only returns an error if the remote address of c is not an IP address.
+func (a *OPAAuthoriser) AuthoriseConnWithReason(c net.Conn) (bool, string, error) {
+	md, err := ConnMetadataFromConn(c)
+	if err != nil {
+		return false, "", err
+	}
+
+	if a.fastPath != nil {
+		allowed, reason := a.fastPath.AuthoriseMetadataWithReason(md)
+		if reason != ReasonDefaultAllow && reason != ReasonDefaultDeny {
+			return allowed, reason, nil
+		}
+	}
+
+	allowed, reason := a.AuthoriseMetadataWithReason(context.Background(), md)
+	return allowed, reason, nil
+}
+
+// AuthoriseMetadataWithReason decides on a connection with the given metadata using the
+// cache or OPA, without the fast path.
+func (a *OPAAuthoriser) AuthoriseMetadataWithReason(ctx context.Context, md ConnMetadata) (bool, string) {
+	key := opaCacheKey{
+		remoteAddr: md.RemoteAddr.Addr().Unmap().WithZone("").String(),
+		localPort:  md.LocalAddr.Port(),
+		serverName: md.ServerName,
+	}
+	if entry, ok := a.cached(key); ok {
+		return entry.allowed, entry.reason
+	}
+
+	allowed, reason, err := a.query(ctx, md)
+	if err != nil {
+		a.logger.Warn().Err(err).Str("remoteAddr", key.remoteAddr).Msg("OPA query failed")
+		return a.failOpen, ReasonOPAError
+	}
+
+	a.store(key, allowed, reason)
+	return allowed, reason
+}
+
+func (a *OPAAuthoriser) cached(key opaCacheKey) (opaCacheEntry, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	entry, ok := a.cache[key]
+	if !ok || !a.now().Before(entry.expires) {
+		return opaCacheEntry{}, false
+	}
+	return entry, true
+}
+
+func (a *OPAAuthoriser) store(key opaCacheKey, allowed bool, reason string) {
+	if a.cacheTTL <= 0 {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if len(a.cache) >= maxOPACacheEntries {
+		clear(a.cache)
+	}
+	a.cache[key] = opaCacheEntry{allowed: allowed, reason: reason, expires: a.now().Add(a.cacheTTL)}
+}
+
+type opaInput struct {
+	RemoteAddr string `json:"remote_addr"`
+	RemotePort uint16 `json:"remote_port,omitempty"`
+	LocalAddr  string `json:"local_addr,omitempty"`
+	LocalPort  uint16 `json:"local_port,omitempty"`
+	ServerName string `json:"server_name,omitempty"`
+}
+
+type opaDecision struct {
+	Allow  *bool  `json:"allow"`
+	Reason string `json:"reason"`
+}
+
+func (a *OPAAuthoriser) query(ctx context.Context, md ConnMetadata) (bool, string, error) {
+	input := opaInput{
+		RemoteAddr: md.RemoteAddr.Addr().Unmap().WithZone("").String(),
+		RemotePort: md.RemoteAddr.Port(),
+		ServerName: md.ServerName,
+	}
+	if md.LocalAddr.IsValid() {
+		input.LocalAddr = md.LocalAddr.Addr().Unmap().WithZone("").String()
+		input.LocalPort = md.LocalAddr.Port()
+	}
+
+	body, err := json.Marshal(map[string]opaInput{"input": input})
+	if err != nil {
+		return false, "", err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.url, bytes.NewReader(body))
+	if err != nil {
+		return false, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return false, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, "", fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	var result struct {
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return false, "", fmt.Errorf("failed to decode response: %w", err)
+	}
+	if len(result.Result) == 0 {
+		return false, "", errors.New("policy decision is undefined")
+	}
+
+	var allowed bool
+	if err := json.Unmarshal(result.Result, &allowed); err == nil {
+		return allowed, ReasonOPA, nil
+	}
+
+	var decision opaDecision
+	if err := json.Unmarshal(result.Result, &decision); err != nil || decision.Allow == nil {
+		return false, "", fmt.Errorf("invalid policy decision: %s", result.Result)
+	}
+	reason := decision.Reason
+	if reason == "" {
+		reason = ReasonOPA
+	}
+	return *decision.Allow, reason, nil
+}
@@ -0,0 +1,297 @@
+package authz
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// addrConn is a net.Conn with fixed addresses.
+type addrConn struct {
+	net.Conn
+	remote, local net.Addr
+}
+
+func (c *addrConn) RemoteAddr() net.Addr { return c.remote }
+func (c *addrConn) LocalAddr() net.Addr  { return c.local }
+
+func newAddrConn(remote, local string) *addrConn {
+	remoteAddr, _ := net.ResolveTCPAddr("tcp", remote)
+	localAddr, _ := net.ResolveTCPAddr("tcp", local)
+	return &addrConn{remote: remoteAddr, local: localAddr}
+}
+
+// newOPAServer starts a fake OPA that answers every query with result, recording the
+// last input and counting queries.
+func newOPAServer(t *testing.T, status int, result string) (*httptest.Server, *atomic.Int32, *atomic.Pointer[opaInput]) {
+	t.Helper()
+	var queries atomic.Int32
+	var input atomic.Pointer[opaInput]
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries.Add(1)
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/v1/data/netauthz/allow", r.URL.Path)
+
+		var body struct {
+			Input opaInput `json:"input"`
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		input.Store(&body.Input)
+
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(result))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &queries, &input
+}
+
+func TestOPAAuthoriserDecisions(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		result      string
+		failOpen    bool
+		wantAllowed bool
+		wantReason  string
+	}{
+		{name: "boolean allow", status: http.StatusOK, result: `{"result": true}`, wantAllowed: true, wantReason: ReasonOPA},
+		{name: "boolean deny", status: http.StatusOK, result: `{"result": false}`, wantReason: ReasonOPA},
+		{name: "object", status: http.StatusOK, result: `{"result": {"allow": true, "reason": "partner"}}`, wantAllowed: true, wantReason: "partner"},
+		{name: "object without reason", status: http.StatusOK, result: `{"result": {"allow": false}}`, wantReason: ReasonOPA},
+		{name: "undefined", status: http.StatusOK, result: `{}`, wantReason: ReasonOPAError},
+		{name: "invalid", status: http.StatusOK, result: `{"result": "yes"}`, wantReason: ReasonOPAError},
+		{name: "object without allow", status: http.StatusOK, result: `{"result": {"reason": "x"}}`, wantReason: ReasonOPAError},
+		{name: "server error", status: http.StatusInternalServerError, result: `{}`, wantReason: ReasonOPAError},
+		{name: "server error fail open", status: http.StatusInternalServerError, result: `{}`, failOpen: true, wantAllowed: true, wantReason: ReasonOPAError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, _, input := newOPAServer(t, tt.status, tt.result)
+			opts := []OPAOption{}
+			if tt.failOpen {
+				opts = append(opts, WithOPAFailOpen())
+			}
+			a := NewOPAAuthoriser(srv.URL+"/v1/data/netauthz/allow", opts...)
+
+			allowed, reason, err := a.AuthoriseConnWithReason(newAddrConn("[::ffff:10.0.0.1]:50000", "10.0.0.2:443"))
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantAllowed, allowed)
+			assert.Equal(t, tt.wantReason, reason)
+			assert.Equal(t, opaInput{RemoteAddr: "10.0.0.1", RemotePort: 50000, LocalAddr: "10.0.0.2", LocalPort: 443}, *input.Load())
+		})
+	}
+}
+
+func TestOPAAuthoriserUnreachable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+
+	a := NewOPAAuthoriser(srv.URL, WithOPATimeout(time.Second))
+	allowed, reason, err := a.AuthoriseConnWithReason(newAddrConn("10.0.0.1:50000", "10.0.0.2:443"))
+	require.NoError(t, err)
+	assert.False(t, allowed)
+	assert.Equal(t, ReasonOPAError, reason)
+}
+
+func TestOPAAuthoriserCache(t *testing.T) {
+	srv, queries, _ := newOPAServer(t, http.StatusOK, `{"result": true}`)
+	a := NewOPAAuthoriser(srv.URL+"/v1/data/netauthz/allow", WithOPACacheTTL(time.Minute))
+	now := time.Now()
+	a.now = func() time.Time { return now }
+
+	for range 3 {
+		allowed, _, err := a.AuthoriseConnWithReason(newAddrConn("10.0.0.1:50000", "10.0.0.2:443"))
+		require.NoError(t, err)
+		assert.True(t, allowed)
+	}
+	assert.Equal(t, int32(1), queries.Load())
+
+	// a different local port is a different decision
+	_, _, err := a.AuthoriseConnWithReason(newAddrConn("10.0.0.1:50001", "10.0.0.2:22"))
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), queries.Load())
+
+	now = now.Add(time.Minute)
+	_, _, err = a.AuthoriseConnWithReason(newAddrConn("10.0.0.1:50000", "10.0.0.2:443"))
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), queries.Load())
+}
+
+func TestOPAAuthoriserErrorsNotCached(t *testing.T) {
+	srv, queries, _ := newOPAServer(t, http.StatusServiceUnavailable, `{}`)
+	a := NewOPAAuthoriser(srv.URL + "/v1/data/netauthz/allow")
+
+	for range 2 {
+		_, _, err := a.AuthoriseConnWithReason(newAddrConn("10.0.0.1:50000", "10.0.0.2:443"))
+		require.NoError(t, err)
+	}
+	assert.Equal(t, int32(2), queries.Load())
+}
+
+func TestOPAAuthoriserFastPath(t *testing.T) {
+	srv, queries, _ := newOPAServer(t, http.StatusOK, `{"result": true}`)
+	acl, err := NewNetworkACL(NetworkACLConfig{
+		AllowedNets: []string{"10.0.0.0/8"},
+		DeniedNets:  []string{"192.0.2.0/24"},
+	})
+	require.NoError(t, err)
+	a := NewOPAAuthoriser(srv.URL+"/v1/data/netauthz/allow", WithOPAFastPath(acl))
+
+	allowed, reason, err := a.AuthoriseConnWithReason(newAddrConn("10.0.0.1:50000", "10.0.0.2:443"))
+	require.NoError(t, err)
+	assert.True(t, allowed)
+	assert.Equal(t, ReasonAllowList, reason)
+
+	allowed, reason, err = a.AuthoriseConnWithReason(newAddrConn("192.0.2.1:50000", "10.0.0.2:443"))
+	require.NoError(t, err)
+	assert.False(t, allowed)
+	assert.Equal(t, ReasonDenyList, reason)
+	assert.Equal(t, int32(0), queries.Load())
+
+	// no rule matches, so OPA decides
+	allowed, reason, err = a.AuthoriseConnWithReason(newAddrConn("198.51.100.1:50000", "10.0.0.2:443"))
+	require.NoError(t, err)
+	assert.True(t, allowed)
+	assert.Equal(t, ReasonOPA, reason)
+	assert.Equal(t, int32(1), queries.Load())
+}
+
+func TestListenerAuthoriser(t *testing.T) {
+	srv, queries, _ := newOPAServer(t, http.StatusOK, `{"result": {"allow": false, "reason": "not today"}}`)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	l := &Listener{
+		Listener:   ln,
+		Logger:     zerolog.Nop(),
+		Authoriser: NewOPAAuthoriser(srv.URL + "/v1/data/netauthz/allow"),
+		DenyPolicy: DenyPolicyError,
+	}
+	defer l.Close()
+
+	client, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	defer client.Close()
+
+	_, err = l.Accept()
+	var denied *DeniedError
+	require.ErrorAs(t, err, &denied)
+	assert.Equal(t, "not today", denied.Reason)
+	assert.Equal(t, int32(1), queries.Load())
+}
+
+func TestListenerAuthoriseConcurrency(t *testing.T) {
+	// OPA hangs on the query for the first connection until released
+	release := make(chan struct{})
+	var slowPort atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Input opaInput `json:"input"`
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		if int32(body.Input.RemotePort) == slowPort.Load() {
+			<-release
+		}
+		_, _ = w.Write([]byte(`{"result": true}`))
+	}))
+	t.Cleanup(srv.Close)
+	defer close(release)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	l := &Listener{
+		Listener: ln,
+		Logger:   zerolog.Nop(),
+		Authoriser: NewOPAAuthoriser(srv.URL+"/v1/data/netauthz/allow",
+			WithOPATimeout(10*time.Second),
+			WithOPACacheTTL(0),
+		),
+		AuthoriseConcurrency: 4,
+	}
+	defer l.Close()
+
+	slow, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	defer slow.Close()
+	slowPort.Store(int32(slow.LocalAddr().(*net.TCPAddr).Port))
+
+	accepted := make(chan net.Conn)
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				close(accepted)
+				return
+			}
+			accepted <- c
+		}
+	}()
+
+	// Concurrent dials are accepted while the first connection is still being decided
+	var fast []net.Conn
+	for range 3 {
+		c, err := net.Dial("tcp", ln.Addr().String())
+		require.NoError(t, err)
+		defer c.Close()
+		fast = append(fast, c)
+	}
+
+	for range fast {
+		select {
+		case c := <-accepted:
+			assert.NotEqual(t, slow.LocalAddr().String(), c.RemoteAddr().String())
+			_ = c.Close()
+		case <-time.After(5 * time.Second):
+			t.Fatal("connection stalled behind a slow OPA query")
+		}
+	}
+
+	release <- struct{}{}
+	select {
+	case c := <-accepted:
+		assert.Equal(t, slow.LocalAddr().String(), c.RemoteAddr().String())
+		_ = c.Close()
+	case <-time.After(5 * time.Second):
+		t.Fatal("slow connection was not accepted")
+	}
+}
+
+func TestListenerAuthoriseConcurrencyClose(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	l := &Listener{
+		NetworkACL:           &NetworkACL{AllowByDefault: true},
+		Listener:             ln,
+		Logger:               zerolog.Nop(),
+		AuthoriseConcurrency: 2,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := l.Accept()
+		errCh <- err
+	}()
+
+	require.NoError(t, l.Close())
+	select {
+	case err := <-errCh:
+		assert.True(t, IsClosedErr(err), "got %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Accept did not return after Close")
+	}
+
+	_, err = l.Accept()
+	assert.True(t, IsClosedErr(err), "got %v", err)
+}
@@ -0,0 +1,181 @@
+package authz
+
+import (
+	"net"
+	"net/netip"
+)
+
+// prefixTrie is an immutable set of networks that answers whether an address is in any
+// of them in time proportional to the address length, however many networks it holds.
+// It is a path-compressed binary trie, with separate roots for IPv4 and IPv6.
+type prefixTrie struct {
+	v4 *prefixTrieNode
+	v6 *prefixTrieNode
+}
+
+type prefixTrieNode struct {
+	// prefix is the masked prefix shared by every network below this node.
+	prefix netip.Prefix
+	// terminal is set if prefix itself is in the set.
+	terminal bool
+	children [2]*prefixTrieNode
+}
+
+// newPrefixTrie creates a prefixTrie of networks. IPv4 networks, including IPv4-mapped
+// IPv6 ones, match IPv4 addresses; networks with non-canonical masks are ignored.
+func newPrefixTrie(networks []*net.IPNet) *prefixTrie {
+	t := &prefixTrie{}
+	for _, n := range networks {
+		prefix, ok := ipNetPrefix(n)
+		if !ok {
+			continue
+		}
+		if prefix.Addr().Is4() {
+			insertPrefix(&t.v4, prefix)
+		} else {
+			insertPrefix(&t.v6, prefix)
+		}
+	}
+	return t
+}
+
+// contains reports whether ip is in any network of the trie. A nil trie is empty.
+func (t *prefixTrie) contains(ip net.IP) bool {
+	if t == nil {
+		return false
+	}
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return false
+	}
+	addr = addr.Unmap()
+
+	node := t.v6
+	if addr.Is4() {
+		node = t.v4
+	}
+	for node != nil {
+		if !node.prefix.Contains(addr) {
+			return false
+		}
+		if node.terminal {
+			return true
+		}
+		bits := node.prefix.Bits()
+		if bits == addr.BitLen() {
+			return false
+		}
+		node = node.children[addrBit(addr, bits)]
+	}
+	return false
+}
+
+// longestMatch returns the length of the longest network in the trie that contains ip,
+// and whether there is one.
+func (t *prefixTrie) longestMatch(ip net.IP) (int, bool) {
+	if t == nil {
+		return 0, false
+	}
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return 0, false
+	}
+	addr = addr.Unmap()
+
+	node := t.v6
+	if addr.Is4() {
+		node = t.v4
+	}
+	longest, found := 0, false
+	for node != nil && node.prefix.Contains(addr) {
+		bits := node.prefix.Bits()
+		if node.terminal {
+			longest, found = bits, true
+		}
+		if bits == addr.BitLen() {
+			break
+		}
+		node = node.children[addrBit(addr, bits)]
+	}
+	return longest, found
+}
+
+func insertPrefix(slot **prefixTrieNode, prefix netip.Prefix) {
+	for {
+		node := *slot
+		if node == nil {
+			*slot = &prefixTrieNode{prefix: prefix, terminal: true}
+			return
+		}
+
+		common := commonPrefixBits(node.prefix, prefix)
+		switch {
+		case common == node.prefix.Bits() && common == prefix.Bits():
+			node.terminal = true
+			return
+		case common == node.prefix.Bits():
+			// node is a supernet of prefix
+			slot = &node.children[addrBit(prefix.Addr(), common)]
+			continue
+		}
+
+		// prefix is a supernet of node, or they diverge; either way a new node for their
+		// common prefix replaces node
+		split := &prefixTrieNode{prefix: netip.PrefixFrom(prefix.Addr(), common).Masked()}
+		split.children[addrBit(node.prefix.Addr(), common)] = node
+		if common == prefix.Bits() {
+			split.terminal = true
+		} else {
+			split.children[addrBit(prefix.Addr(), common)] = &prefixTrieNode{prefix: prefix, terminal: true}
+		}
+		*slot = split
+		return
+	}
+}
+
+// commonPrefixBits returns the number of leading bits that a and b have in common, up to
+// the shorter of their lengths.
+func commonPrefixBits(a, b netip.Prefix) int {
+	n := min(a.Bits(), b.Bits())
+	for i := range n {
+		if addrBit(a.Addr(), i) != addrBit(b.Addr(), i) {
+			return i
+		}
+	}
+	return n
+}
+
+// addrBit returns bit i of addr, counting from the most significant bit.
+func addrBit(addr netip.Addr, i int) int {
+	if addr.Is4() {
+		b := addr.As4()
+		return int(b[i/8]>>(7-i%8)) & 1
+	}
+	b := addr.As16()
+	return int(b[i/8]>>(7-i%8)) & 1
+}
+
+// ipNetPrefix returns n as a masked netip.Prefix, with IPv4 and IPv4-mapped IPv6
+// networks in their IPv4 form.
+func ipNetPrefix(n *net.IPNet) (netip.Prefix, bool) {
+	ones, bits := n.Mask.Size()
+	if bits == 0 {
+		return netip.Prefix{}, false
+	}
+
+	ip := n.IP
+	if ip4 := ip.To4(); ip4 != nil {
+		switch {
+		case bits == 8*net.IPv4len:
+			ip = ip4
+		case bits == 8*net.IPv6len && ones >= 96:
+			ip, ones, bits = ip4, ones-96, 8*net.IPv4len
+		}
+	}
+
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok || addr.BitLen() != bits {
+		return netip.Prefix{}, false
+	}
+	return netip.PrefixFrom(addr, ones).Masked(), true
+}
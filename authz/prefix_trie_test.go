@@ -0,0 +1,229 @@
+package authz
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustParseTCPNets(t testing.TB, networks ...string) []*net.IPNet {
+	t.Helper()
+	result := make([]*net.IPNet, len(networks))
+	for i, n := range networks {
+		ipNet, err := parseTCPNet(n)
+		require.NoError(t, err)
+		result[i] = ipNet
+	}
+	return result
+}
+
+func TestPrefixTrie(t *testing.T) {
+	trie := newPrefixTrie(mustParseTCPNets(t,
+		"10.0.0.0/8",
+		"10.1.0.0/16", // inside 10.0.0.0/8
+		"192.168.1.0/24",
+		"192.168.2.0/24", // sibling of 192.168.1.0/24
+		"192.168.0.0/16", // supernet of both, inserted after them
+		"203.0.113.7",
+		"::ffff:198.51.100.0/120",
+		"2001:db8::/32",
+		"2001:db8:1::/48",
+		"fe80::1",
+	))
+
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{ip: "10.200.0.1", want: true},
+		{ip: "10.1.2.3", want: true},
+		{ip: "11.0.0.1", want: false},
+		{ip: "192.168.1.1", want: true},
+		{ip: "192.168.3.1", want: true},
+		{ip: "192.169.0.1", want: false},
+		{ip: "203.0.113.7", want: true},
+		{ip: "203.0.113.8", want: false},
+		{ip: "198.51.100.9", want: true},
+		{ip: "::ffff:198.51.100.9", want: true},
+		{ip: "::ffff:10.0.0.1", want: true},
+		{ip: "2001:db8:ffff::1", want: true},
+		{ip: "2001:db9::1", want: false},
+		{ip: "fe80::1", want: true},
+		{ip: "fe80::2", want: false},
+		{ip: "::10.0.0.1", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			assert.Equal(t, tt.want, trie.contains(net.ParseIP(tt.ip)))
+		})
+	}
+
+	assert.False(t, trie.contains(nil))
+	assert.False(t, (*prefixTrie)(nil).contains(net.ParseIP("10.0.0.1")))
+}
+
+func TestPrefixTrieDefaultRoutes(t *testing.T) {
+	trie := newPrefixTrie(mustParseTCPNets(t, "0.0.0.0/0"))
+	assert.True(t, trie.contains(net.ParseIP("192.0.2.1")))
+	assert.False(t, trie.contains(net.ParseIP("2001:db8::1")))
+
+	trie = newPrefixTrie(mustParseTCPNets(t, "::/0"))
+	assert.True(t, trie.contains(net.ParseIP("2001:db8::1")))
+	assert.False(t, trie.contains(net.ParseIP("192.0.2.1")))
+}
+
+func TestPrefixTrieMatchesLinearScan(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	networks := randomNetworks(rng, 2000)
+	trie := newPrefixTrie(networks)
+
+	for range 20000 {
+		ip := randomIP(rng)
+		want := false
+		for _, n := range networks {
+			if n.Contains(ip) {
+				want = true
+				break
+			}
+		}
+		require.Equal(t, want, trie.contains(ip), ip.String())
+	}
+}
+
+func TestPrefixTrieLongestMatch(t *testing.T) {
+	trie := newPrefixTrie(mustParseTCPNets(t, "10.0.0.0/8", "10.1.0.0/16", "10.1.2.3", "2001:db8::/32"))
+
+	tests := []struct {
+		ip       string
+		wantBits int
+		wantOK   bool
+	}{
+		{ip: "10.0.0.1", wantBits: 8, wantOK: true},
+		{ip: "10.1.0.1", wantBits: 16, wantOK: true},
+		{ip: "10.1.2.3", wantBits: 32, wantOK: true},
+		{ip: "2001:db8::1", wantBits: 32, wantOK: true},
+		{ip: "192.0.2.1", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			bits, ok := trie.longestMatch(net.ParseIP(tt.ip))
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.wantBits, bits)
+		})
+	}
+
+	_, ok := (*prefixTrie)(nil).longestMatch(net.ParseIP("10.0.0.1"))
+	assert.False(t, ok)
+}
+
+func TestIPNetPrefix(t *testing.T) {
+	tests := []struct {
+		n    *net.IPNet
+		want string
+	}{
+		{n: &net.IPNet{IP: net.ParseIP("10.1.2.3"), Mask: net.CIDRMask(8, 32)}, want: "10.0.0.0/8"},
+		{n: &net.IPNet{IP: net.ParseIP("10.1.2.3").To16(), Mask: net.CIDRMask(112, 128)}, want: "10.1.0.0/16"},
+		{n: &net.IPNet{IP: net.ParseIP("2001:db8::1"), Mask: net.CIDRMask(32, 128)}, want: "2001:db8::/32"},
+		{n: &net.IPNet{IP: net.ParseIP("2001:db8::1"), Mask: net.CIDRMask(8, 32)}, want: ""},
+		{n: &net.IPNet{IP: net.ParseIP("10.0.0.1"), Mask: net.IPv4Mask(255, 0, 255, 0)}, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.n.String(), func(t *testing.T) {
+			got, ok := ipNetPrefix(tt.n)
+			if tt.want == "" {
+				assert.False(t, ok)
+				return
+			}
+			require.True(t, ok)
+			assert.Equal(t, netip.MustParsePrefix(tt.want), got)
+		})
+	}
+}
+
+// randomNetworks returns n random IPv4 and IPv6 networks, skewed towards long prefixes
+// as in provider lists.
+func randomNetworks(rng *rand.Rand, n int) []*net.IPNet {
+	networks := make([]*net.IPNet, n)
+	for i := range networks {
+		ip := randomIP(rng)
+		bits := 8 * len(ip)
+		ones := bits - rng.IntN(bits/2)
+		if rng.IntN(50) == 0 {
+			ones = rng.IntN(bits / 2)
+		}
+		mask := net.CIDRMask(ones, bits)
+		networks[i] = &net.IPNet{IP: ip.Mask(mask), Mask: mask}
+	}
+	return networks
+}
+
+// randomIP returns a random IPv4 or IPv6 address, drawn from a small range of each so
+// that addresses fall into the networks of randomNetworks.
+func randomIP(rng *rand.Rand) net.IP {
+	if rng.IntN(2) == 0 {
+		return net.IPv4(10, byte(rng.IntN(4)), byte(rng.IntN(256)), byte(rng.IntN(256))).To4()
+	}
+	ip := make(net.IP, net.IPv6len)
+	copy(ip, net.ParseIP("2001:db8::"))
+	ip[4] = byte(rng.IntN(4))
+	for i := 5; i < net.IPv6len; i++ {
+		ip[i] = byte(rng.IntN(256))
+	}
+	return ip
+}
+
+func BenchmarkNetworkACLAuthorise(b *testing.B) {
+	for _, size := range []int{10, 1000, 50000} {
+		b.Run(fmt.Sprintf("prefixes=%d", size), func(b *testing.B) {
+			rng := rand.New(rand.NewPCG(1, 2))
+			var allowed []string
+			for _, n := range randomNetworks(rng, size) {
+				allowed = append(allowed, n.String())
+			}
+			acl, err := NewNetworkACL(NetworkACLConfig{AllowedNets: allowed})
+			require.NoError(b, err)
+
+			addrs := make([]*net.TCPAddr, 1024)
+			for i := range addrs {
+				addrs[i] = &net.TCPAddr{IP: randomIP(rng)}
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				acl.Authorise(addrs[i%len(addrs)])
+			}
+		})
+	}
+}
+
+func BenchmarkLinearScan(b *testing.B) {
+	for _, size := range []int{10, 1000, 50000} {
+		b.Run(fmt.Sprintf("prefixes=%d", size), func(b *testing.B) {
+			rng := rand.New(rand.NewPCG(1, 2))
+			networks := randomNetworks(rng, size)
+
+			addrs := make([]net.IP, 1024)
+			for i := range addrs {
+				addrs[i] = randomIP(rng)
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				ip := addrs[i%len(addrs)]
+				for _, n := range networks {
+					if n.Contains(ip) {
+						break
+					}
+				}
+			}
+		})
+	}
+}
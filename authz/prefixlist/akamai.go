@@ -0,0 +1,80 @@
+package prefixlist
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+func init() {
+	RegisterProvider("akamai", func(cfg ProviderConfig) (Provider, error) {
+		// Akamai: "url" key locating the account's CIDR list (required)
+		url := cfg.Filter["url"]
+		if url == "" {
+			return nil, errors.New("akamai provider requires a url filter")
+		}
+		return NewAkamaiProvider(url), nil
+	})
+}
+
+// AkamaiProvider fetches the CIDRs of the Akamai servers that connect to an origin.
+//
+// Akamai does not publish a single public list: Site Shield maps and the Origin IP ACL
+// list are specific to an account. The provider reads one from a URL, either as a plain
+// text list of CIDRs, such as the Origin IP ACL list, or as a Site Shield map as returned
+// by the Site Shield API, in which case both the current and proposed CIDRs are allowed
+// so that traffic keeps flowing while a map change is pending acknowledgement.
+type AkamaiProvider struct {
+	*HTTPTextProvider
+}
+
+type akamaiSiteShieldMap struct {
+	CurrentCIDRs  []string `json:"currentCidrs"`
+	ProposedCIDRs []string `json:"proposedCidrs"`
+}
+
+// NewAkamaiProvider creates a new Akamai prefix list provider
+// url: location of the account's CIDR list or Site Shield map
+func NewAkamaiProvider(url string) *AkamaiProvider {
+	p := &AkamaiProvider{
+		HTTPTextProvider: &HTTPTextProvider{cachedProvider[[]string]{
+			name:      "akamai",
+			transform: parsePrefixes,
+		}},
+	}
+
+	p.fetcher = NewCachingFetcherWithFunc[[]string](
+		url,
+		CacheConfig{
+			StaticExpiry: 24 * time.Hour,
+			ReturnStale:  true,
+		},
+		func(ctx context.Context, url string) ([]string, error) {
+			return p.fetcher.requestConfig().fetchAkamaiCIDRs(ctx, url)
+		},
+	)
+
+	return p
+}
+
+// fetchAkamaiCIDRs fetches url and returns the CIDRs of a Site Shield map, if it is one,
+// or the lines of a plain text list otherwise
+func (c CacheConfig) fetchAkamaiCIDRs(ctx context.Context, url string) ([]string, error) {
+	body, err := c.fetchBody(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '{' {
+		var siteShieldMap akamaiSiteShieldMap
+		if err := json.Unmarshal(trimmed, &siteShieldMap); err != nil {
+			return nil, fmt.Errorf("unmarshal site shield map: %w", err)
+		}
+		return append(siteShieldMap.CurrentCIDRs, siteShieldMap.ProposedCIDRs...), nil
+	}
+
+	return parseTextLines(bytes.NewReader(body))
+}
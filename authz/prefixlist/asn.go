@@ -0,0 +1,163 @@
+package prefixlist
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+func init() {
+	RegisterProvider("asn", func(cfg ProviderConfig) (Provider, error) {
+		// ASN: "asn" key (e.g., "13335" or "AS13335")
+		asn, err := ParseASN(cfg.Filter["asn"])
+		if err != nil {
+			return nil, err
+		}
+		return NewASNProvider(asn, nil), nil
+	})
+}
+
+// ASNResolver resolves the prefixes announced by an autonomous system, for example from
+// a BGP RIB dump or an HTTP API such as RIPEstat.
+type ASNResolver interface {
+	ASNPrefixes(ctx context.Context, asn uint32) ([]netip.Prefix, error)
+}
+
+// ASNResolverFunc adapts a function to an ASNResolver.
+type ASNResolverFunc func(ctx context.Context, asn uint32) ([]netip.Prefix, error)
+
+// ASNPrefixes calls f(ctx, asn).
+func (f ASNResolverFunc) ASNPrefixes(ctx context.Context, asn uint32) ([]netip.Prefix, error) {
+	return f(ctx, asn)
+}
+
+// ParseASN parses an autonomous system number, with or without an "AS" prefix.
+func ParseASN(s string) (uint32, error) {
+	s = strings.TrimSpace(s)
+	if len(s) > 2 && strings.EqualFold(s[:2], "AS") {
+		s = s[2:]
+	}
+
+	asn, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid ASN %q: %w", s, err)
+	}
+	return uint32(asn), nil
+}
+
+// ASNProvider provides the prefixes announced by an autonomous system
+type ASNProvider struct {
+	asn      uint32
+	resolver ASNResolver
+
+	mu       sync.RWMutex
+	prefixes []netip.Prefix
+}
+
+// NewASNProvider creates a provider for the prefixes announced by asn. If resolver is
+// nil, a RIPEStatASNResolver caching results for an hour is used.
+func NewASNProvider(asn uint32, resolver ASNResolver) *ASNProvider {
+	if resolver == nil {
+		resolver = NewRIPEStatASNResolver(CacheConfig{
+			StaticExpiry: 1 * time.Hour,
+			ReturnStale:  true,
+		})
+	}
+	return &ASNProvider{
+		asn:      asn,
+		resolver: resolver,
+	}
+}
+
+func (p *ASNProvider) Name() string {
+	return fmt.Sprintf("asn-%d", p.asn)
+}
+
+// Prefixes resolves the announced prefixes. On failure the previously resolved
+// prefixes are kept for Contains.
+func (p *ASNProvider) Prefixes(ctx context.Context) ([]netip.Prefix, error) {
+	prefixes, err := p.resolver.ASNPrefixes(ctx, p.asn)
+	if err != nil {
+		return nil, fmt.Errorf("AS%d: %w", p.asn, err)
+	}
+
+	p.mu.Lock()
+	p.prefixes = prefixes
+	p.mu.Unlock()
+
+	return prefixes, nil
+}
+
+// Contains checks addr against the prefixes from the last successful Prefixes call
+func (p *ASNProvider) Contains(addr netip.Addr) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for _, prefix := range p.prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// RIPEStatAnnouncedPrefixesURL is the RIPEstat announced-prefixes endpoint used by
+// RIPEStatASNResolver, formatted with the ASN.
+const RIPEStatAnnouncedPrefixesURL = "https://stat.ripe.net/data/announced-prefixes/data.json?resource=AS%d"
+
+// RIPEStatASNResolver resolves announced prefixes using the RIPEstat announced-prefixes
+// API, caching each ASN's response according to its CacheConfig.
+type RIPEStatASNResolver struct {
+	config    CacheConfig
+	urlFormat string
+
+	mu       sync.Mutex
+	fetchers map[uint32]*CachingFetcher[ripeStatAnnouncedPrefixes]
+}
+
+type ripeStatAnnouncedPrefixes struct {
+	Data struct {
+		Prefixes []struct {
+			Prefix string `json:"prefix"`
+		} `json:"prefixes"`
+	} `json:"data"`
+}
+
+// NewRIPEStatASNResolver creates a resolver that queries RIPEstat.
+func NewRIPEStatASNResolver(config CacheConfig) *RIPEStatASNResolver {
+	return &RIPEStatASNResolver{
+		config:    config,
+		urlFormat: RIPEStatAnnouncedPrefixesURL,
+		fetchers:  make(map[uint32]*CachingFetcher[ripeStatAnnouncedPrefixes]),
+	}
+}
+
+// ASNPrefixes returns the prefixes RIPEstat reports as announced by asn.
+func (r *RIPEStatASNResolver) ASNPrefixes(ctx context.Context, asn uint32) ([]netip.Prefix, error) {
+	r.mu.Lock()
+	fetcher, ok := r.fetchers[asn]
+	if !ok {
+		fetcher = NewCachingFetcher[ripeStatAnnouncedPrefixes](fmt.Sprintf(r.urlFormat, asn), r.config)
+		r.fetchers[asn] = fetcher
+	}
+	r.mu.Unlock()
+
+	data, _, err := fetcher.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	prefixes := make([]netip.Prefix, 0, len(data.Data.Prefixes))
+	for _, p := range data.Data.Prefixes {
+		prefix, err := netip.ParsePrefix(p.Prefix)
+		if err != nil {
+			return nil, fmt.Errorf("invalid prefix %q: %w", p.Prefix, err)
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	return prefixes, nil
+}
@@ -0,0 +1,86 @@
+package prefixlist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseASN(t *testing.T) {
+	for _, s := range []string{"13335", "AS13335", "as13335", " AS13335 "} {
+		asn, err := ParseASN(s)
+		require.NoError(t, err, s)
+		assert.Equal(t, uint32(13335), asn, s)
+	}
+
+	for _, s := range []string{"", "AS", "ASX", "-1", "4294967296"} {
+		_, err := ParseASN(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestASNProvider(t *testing.T) {
+	fail := false
+	resolver := ASNResolverFunc(func(ctx context.Context, asn uint32) ([]netip.Prefix, error) {
+		if fail {
+			return nil, errors.New("resolver unavailable")
+		}
+		assert.Equal(t, uint32(64500), asn)
+		return []netip.Prefix{netip.MustParsePrefix("192.0.2.0/24")}, nil
+	})
+
+	p := NewASNProvider(64500, resolver)
+	assert.Equal(t, "asn-64500", p.Name())
+	assert.False(t, p.Contains(netip.MustParseAddr("192.0.2.1")), "unresolved provider should contain nothing")
+
+	prefixes, err := p.Prefixes(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("192.0.2.0/24")}, prefixes)
+	assert.True(t, p.Contains(netip.MustParseAddr("192.0.2.1")))
+
+	fail = true
+	_, err = p.Prefixes(context.Background())
+	assert.Error(t, err)
+	assert.True(t, p.Contains(netip.MustParseAddr("192.0.2.1")), "failed refresh should keep previous prefixes")
+}
+
+func TestRIPEStatASNResolver(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("resource") != "AS64500" {
+			http.Error(w, "unknown resource", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"data":{"prefixes":[{"prefix":"192.0.2.0/24"},{"prefix":"2001:db8::/32"}]}}`)
+	}))
+	defer server.Close()
+
+	r := NewRIPEStatASNResolver(CacheConfig{})
+	r.urlFormat = server.URL + "/?resource=AS%d"
+
+	prefixes, err := r.ASNPrefixes(context.Background(), 64500)
+	require.NoError(t, err)
+	assert.Equal(t, []netip.Prefix{
+		netip.MustParsePrefix("192.0.2.0/24"),
+		netip.MustParsePrefix("2001:db8::/32"),
+	}, prefixes)
+
+	_, err = r.ASNPrefixes(context.Background(), 64501)
+	assert.Error(t, err)
+}
+
+func TestNewProviderFromConfig_ASN(t *testing.T) {
+	p, err := NewProviderFromConfig(ProviderConfig{Name: "asn", Enabled: true, Filter: map[string]string{"asn": "AS13335"}})
+	require.NoError(t, err)
+	assert.Equal(t, "asn-13335", p.Name())
+
+	_, err = NewProviderFromConfig(ProviderConfig{Name: "asn", Enabled: true})
+	assert.Error(t, err)
+}
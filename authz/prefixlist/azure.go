@@ -0,0 +1,132 @@
+package prefixlist
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"regexp"
+	"strings"
+	"time"
+)
+
+func init() {
+	RegisterProvider("azure", func(cfg ProviderConfig) (Provider, error) {
+		// Azure: support "service" and "region" keys
+		service := cfg.Filter["service"]
+		region := cfg.Filter["region"]
+		return NewAzureProvider(service, region), nil
+	})
+}
+
+// AzureServiceTagsDownloadURL is the Microsoft download page for the Azure public cloud
+// Service Tags. The JSON file it links to is renamed weekly, so AzureProvider finds the
+// current file from this page.
+const AzureServiceTagsDownloadURL = "https://www.microsoft.com/en-us/download/details.aspx?id=56519"
+
+// azureServiceTagsFileRegexp matches the link to the Service Tags JSON file on the
+// download page.
+var azureServiceTagsFileRegexp = regexp.MustCompile(`https?://[^"'\s<>]+/ServiceTags_Public_\d+\.json`)
+
+// AzureProvider fetches IP ranges from the Azure Service Tags
+type AzureProvider struct {
+	*HTTPJSONProvider[azureServiceTags]
+	service string // optional filter for a service tag (e.g., "AzureCloud", "Storage")
+	region  string // optional filter for a region (e.g., "westeurope")
+}
+
+type azureServiceTags struct {
+	Values []struct {
+		Name       string `json:"name"`
+		Properties struct {
+			Region          string   `json:"region"`
+			AddressPrefixes []string `json:"addressPrefixes"`
+		} `json:"properties"`
+	} `json:"values"`
+}
+
+// NewAzureProvider creates a new Azure prefix list provider
+// service: optional service tag to filter by, without a region suffix (e.g., "AzureCloud")
+// region: optional region to filter by (e.g., "westeurope")
+func NewAzureProvider(service, region string) *AzureProvider {
+	name := "azure"
+	if service != "" {
+		name += "-" + service
+	}
+	if region != "" {
+		name += "-" + region
+	}
+
+	p := &AzureProvider{
+		service: service,
+		region:  region,
+	}
+
+	p.HTTPJSONProvider = &HTTPJSONProvider[azureServiceTags]{cachedProvider[azureServiceTags]{
+		name:      name,
+		transform: p.transformAzureServiceTags,
+	}}
+	p.fetcher = NewCachingFetcherWithFunc[azureServiceTags](
+		AzureServiceTagsDownloadURL,
+		CacheConfig{
+			StaticExpiry: 24 * time.Hour,
+			ReturnStale:  true,
+		},
+		func(ctx context.Context, url string) (azureServiceTags, error) {
+			return p.fetcher.requestConfig().fetchAzureServiceTags(ctx, url)
+		},
+	)
+
+	return p
+}
+
+func (p *AzureProvider) transformAzureServiceTags(data azureServiceTags) ([]netip.Prefix, error) {
+	var cidrs []string
+	for _, tag := range data.Values {
+		if p.matchesFilter(tag.Name, tag.Properties.Region) {
+			cidrs = append(cidrs, tag.Properties.AddressPrefixes...)
+		}
+	}
+
+	return parseCIDRs(cidrs)
+}
+
+// matchesFilter reports whether a service tag matches the filters. The service is the
+// tag name without its region suffix, so "AzureCloud" matches both "AzureCloud" and
+// "AzureCloud.westeurope".
+func (p *AzureProvider) matchesFilter(name, region string) bool {
+	service, _, _ := strings.Cut(name, ".")
+	if p.service != "" && !strings.EqualFold(service, p.service) {
+		return false
+	}
+	if p.region != "" && !strings.EqualFold(region, p.region) {
+		return false
+	}
+	return true
+}
+
+// fetchAzureServiceTags finds the current Service Tags file linked from the download
+// page at pageURL and fetches it.
+func (c CacheConfig) fetchAzureServiceTags(ctx context.Context, pageURL string) (azureServiceTags, error) {
+	var tags azureServiceTags
+
+	page, err := c.fetchBody(ctx, pageURL)
+	if err != nil {
+		return tags, fmt.Errorf("fetch download page: %w", err)
+	}
+
+	fileURL := azureServiceTagsFileRegexp.Find(page)
+	if fileURL == nil {
+		return tags, fmt.Errorf("no service tags file linked from %s", pageURL)
+	}
+
+	body, err := c.fetchBody(ctx, string(fileURL))
+	if err != nil {
+		return tags, fmt.Errorf("fetch service tags: %w", err)
+	}
+
+	if err := json.Unmarshal(body, &tags); err != nil {
+		return tags, fmt.Errorf("unmarshal json: %w", err)
+	}
+	return tags, nil
+}
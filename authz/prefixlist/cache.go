@@ -5,7 +5,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"maps"
+	"math/rand/v2"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -19,7 +22,78 @@ type CacheConfig struct {
 	// ReturnStale controls whether stale data should be returned while refreshing
 	// If true, returns stale data immediately and refreshes in background
 	// If false, blocks until fresh data is fetched
+	// A stale-while-revalidate directive from the origin bounds how long past its
+	// expiry data is returned this way; a stale-if-error directive likewise bounds
+	// how long it is returned when a refresh fails.
 	ReturnStale bool
+
+	// MaxStale bounds how long past its expiry cached data may still be returned,
+	// both by ReturnStale and when a refresh fails. Once data is older than its
+	// expiry plus MaxStale, Get blocks on a fresh fetch and returns the fetch error
+	// with a zero value if it fails. Zero means stale data is returned indefinitely,
+	// unless the origin's stale-while-revalidate or stale-if-error directives bound it.
+	MaxStale time.Duration
+
+	// Jitter randomly shortens each computed cache lifetime by up to this fraction
+	// (0.0-1.0) so that fetchers sharing an upstream do not all expire and refetch
+	// at the same moment. For example, 0.1 spreads expiry over the final 10% of the
+	// lifetime. Jitter never extends a lifetime beyond what the upstream allows.
+	// Zero disables jitter.
+	Jitter float64
+
+	// Client is the HTTP client used for requests. If nil, a client with a
+	// 30 second timeout is used.
+	Client *http.Client
+
+	// UserAgent is sent with every request. If empty, DefaultUserAgent is used.
+	UserAgent string
+
+	// Headers are additional headers sent with every request
+	Headers http.Header
+
+	// ObjectClients are the clients used to fetch object store URLs, by URL scheme, such
+	// as "s3" for s3://bucket/key. Schemes are case-insensitive. URLs with other schemes
+	// are fetched with Client.
+	ObjectClients map[string]ObjectClient
+
+	// Dir, if set, is a directory in which the last fetched data and its expiry are
+	// persisted. The data is loaded before the first fetch, so that prefix lists keep
+	// working when the process restarts while the upstream is unreachable. Data loaded
+	// past its expiry is treated as stale, subject to ReturnStale and MaxStale.
+	Dir string
+}
+
+// DefaultUserAgent is the User-Agent sent when CacheConfig.UserAgent is empty
+const DefaultUserAgent = "dioad-net/prefixlist"
+
+// newRequest creates a GET request carrying the configured User-Agent and headers
+func (c CacheConfig) newRequest(ctx context.Context, url string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	for name, values := range c.Headers {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+
+	userAgent := c.UserAgent
+	if userAgent == "" {
+		userAgent = DefaultUserAgent
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	return req, nil
+}
+
+// httpClient returns the configured client or a default one
+func (c CacheConfig) httpClient() *http.Client {
+	if c.Client != nil {
+		return c.Client
+	}
+	return &http.Client{Timeout: 30 * time.Second}
 }
 
 // FetchFunc is a custom function type for fetching data from an HTTP endpoint
@@ -37,6 +111,20 @@ const (
 	CacheResultStale
 )
 
+// String returns the name of the result, as used in metric labels
+func (r CacheResult) String() string {
+	switch r {
+	case CacheResultFresh:
+		return "fresh"
+	case CacheResultCached:
+		return "cached"
+	case CacheResultStale:
+		return "stale"
+	default:
+		return "unknown"
+	}
+}
+
 // FetchResult contains the fetched data and metadata about the fetch
 type FetchResult[T any] struct {
 	Data   T
@@ -48,16 +136,24 @@ type FetchResult[T any] struct {
 type CachingFetcher[T any] struct {
 	url         string
 	config      CacheConfig
-	fetchFunc   FetchFunc[T] // custom fetch function, defaults to JSON fetching
+	fetchFunc   FetchFunc[T]            // custom fetch function, defaults to JSON fetching
+	decodeFunc  func([]byte) (T, error) // decodes the response body, defaults to JSON unmarshaling
+	kind        string                  // how the body is decoded, for FetcherPool; empty if not shareable
 	lastHeaders http.Header
 
-	mu          sync.RWMutex
-	cachedData  *T
-	cachedAt    time.Time
-	expiresAt   time.Time
-	lastError   error
-	refreshing  bool
-	refreshCond *sync.Cond
+	mu            sync.RWMutex
+	cachedData    *T
+	cachedHeaders http.Header // headers from the fetch that produced cachedData
+	cachedAt      time.Time
+	expiresAt     time.Time
+	lastError     error
+	refreshing    bool
+	refreshCond   *sync.Cond
+	diskLoaded    bool // whether the disk cache has been loaded
+	metrics       *Metrics
+	metricsName   string      // provider label for metrics
+	lastResult    CacheResult // result of the last Get that served data
+	served        bool        // whether any Get has served data
 }
 
 // NewCachingFetcher creates a new caching fetcher for the specified URL and type.
@@ -67,11 +163,21 @@ func NewCachingFetcher[T any](url string, config CacheConfig) *CachingFetcher[T]
 		url:       url,
 		config:    config,
 		fetchFunc: nil,
+		kind:      fetcherKindJSON,
 	}
 	f.refreshCond = sync.NewCond(&f.mu)
 	return f
 }
 
+// newDecodingCachingFetcher creates a caching fetcher that decodes the response body
+// with decode, which is identified by kind for sharing through a FetcherPool
+func newDecodingCachingFetcher[T any](url string, config CacheConfig, kind string, decode func([]byte) (T, error)) *CachingFetcher[T] {
+	f := NewCachingFetcher[T](url, config)
+	f.decodeFunc = decode
+	f.kind = kind
+	return f
+}
+
 // NewCachingFetcherWithFunc creates a new caching fetcher with a custom fetch function.
 // If fetchFunc is nil, it defaults to JSON unmarshaling. This allows for custom
 // parsing of the HTTP response (e.g., plain text lines).
@@ -85,26 +191,73 @@ func NewCachingFetcherWithFunc[T any](url string, config CacheConfig, fetchFunc
 	return f
 }
 
+// NewBytesCachingFetcher creates a caching fetcher that stores the raw response body
+// rather than decoding it. Response headers are captured as with NewCachingFetcher,
+// so it can back a handler created by NewCachingProxyHandler.
+func NewBytesCachingFetcher(url string, config CacheConfig) *CachingFetcher[[]byte] {
+	return newDecodingCachingFetcher(url, config, fetcherKindBytes, func(body []byte) ([]byte, error) {
+		return body, nil
+	})
+}
+
 // Get fetches data from the URL with caching.
 // It returns the data, cache result status (Fresh, Cached, or Stale), and any error encountered.
 // If ReturnStale is enabled, it may return stale data immediately and start a background refresh.
 func (f *CachingFetcher[T]) Get(ctx context.Context) (T, CacheResult, error) {
+	data, _, result, err := f.GetWithHeaders(ctx)
+	return data, result, err
+}
+
+// GetWithHeaders behaves like Get but also returns the HTTP response headers captured
+// from the fetch that produced the returned data. The headers are a copy and may be
+// modified freely by the caller. Headers are nil if no data has been fetched or if a
+// custom fetch function that does not expose headers is in use.
+func (f *CachingFetcher[T]) GetWithHeaders(ctx context.Context) (T, http.Header, CacheResult, error) {
+	data, headers, result, err := f.getWithHeaders(ctx)
+
+	// Only count results that served data
+	if err == nil || result == CacheResultStale {
+		f.mu.Lock()
+		f.lastResult = result
+		f.served = true
+		f.mu.Unlock()
+
+		if metrics, name := f.metricsConfig(); metrics != nil {
+			metrics.recordCacheResult(name, result)
+		}
+	}
+
+	return data, headers, result, err
+}
+
+func (f *CachingFetcher[T]) getWithHeaders(ctx context.Context) (T, http.Header, CacheResult, error) {
 	f.mu.Lock()
+	f.loadDiskCacheLocked()
 
 	// Check if we have valid cached data
 	if f.cachedData != nil && time.Now().Before(f.expiresAt) {
 		data := *f.cachedData
+		headers := f.cachedHeaders.Clone()
 		f.mu.Unlock()
-		return data, CacheResultCached, nil
+		return data, headers, CacheResultCached, nil
 	}
 
 	// Data is expired or doesn't exist
 	staleData := f.cachedData
+	staleHeaders := f.cachedHeaders
+
+	// Data too far past its expiry must not be served
+	if staleData != nil && !f.withinMaxStaleLocked(time.Now()) {
+		staleData = nil
+		staleHeaders = nil
+	}
 
-	// If return stale is enabled and we have stale data
-	if f.config.ReturnStale && staleData != nil {
+	// If return stale is enabled and we have stale data the origin allows serving while
+	// revalidating
+	if f.config.ReturnStale && staleData != nil && f.withinStaleDirectiveLocked("stale-while-revalidate", time.Now()) {
 		// Return stale data immediately
 		data := *staleData
+		headers := staleHeaders.Clone()
 
 		// Start background refresh if not already refreshing
 		if !f.refreshing {
@@ -113,7 +266,7 @@ func (f *CachingFetcher[T]) Get(ctx context.Context) (T, CacheResult, error) {
 		}
 
 		f.mu.Unlock()
-		return data, CacheResultStale, nil
+		return data, headers, CacheResultStale, nil
 	}
 
 	// Need to fetch now (blocking)
@@ -121,15 +274,16 @@ func (f *CachingFetcher[T]) Get(ctx context.Context) (T, CacheResult, error) {
 	if f.refreshing {
 		f.refreshCond.Wait()
 		// After wait, check if we now have data
-		if f.cachedData != nil {
+		if f.cachedData != nil && (f.lastError == nil || f.withinStaleIfErrorLocked(time.Now())) {
 			data := *f.cachedData
+			headers := f.cachedHeaders.Clone()
 			err := f.lastError
 			result := CacheResultFresh
 			if err != nil {
 				result = CacheResultStale
 			}
 			f.mu.Unlock()
-			return data, result, err
+			return data, headers, result, err
 		}
 	}
 
@@ -145,32 +299,105 @@ func (f *CachingFetcher[T]) Get(ctx context.Context) (T, CacheResult, error) {
 	f.lastError = err
 
 	if err != nil {
-		// If fetch failed and we have stale data, return it
-		if staleData != nil {
+		// If fetch failed and we have stale data the origin allows serving on error, return it
+		if staleData != nil && f.withinStaleDirectiveLocked("stale-if-error", time.Now()) {
 			result := *staleData
+			headers := staleHeaders.Clone()
 			f.mu.Unlock()
 			f.refreshCond.Broadcast()
-			return result, CacheResultStale, err
+			return result, headers, CacheResultStale, err
 		}
 		// No stale data, return zero value
 		var zero T
 		f.mu.Unlock()
 		f.refreshCond.Broadcast()
-		return zero, CacheResultFresh, err
+		return zero, nil, CacheResultFresh, err
 	}
 
 	// Success - cache the data
 	f.cachedData = &data
+	f.cachedHeaders = f.lastHeaders
 	f.cachedAt = time.Now()
 	f.expiresAt = f.calculateExpiry(f.lastHeaders)
+	f.saveDiskCacheLocked()
+	headers := f.cachedHeaders.Clone()
 
 	f.mu.Unlock()
 	f.refreshCond.Broadcast()
-	return data, CacheResultFresh, nil
+	return data, headers, CacheResultFresh, nil
+}
+
+// withinMaxStaleLocked reports whether cached data may still be served at now.
+// The caller must hold f.mu.
+func (f *CachingFetcher[T]) withinMaxStaleLocked(now time.Time) bool {
+	if f.config.MaxStale <= 0 {
+		return true
+	}
+	return now.Before(f.expiresAt.Add(f.config.MaxStale))
+}
+
+// withinStaleIfErrorLocked reports whether cached data may be served at now because a
+// fetch failed. The caller must hold f.mu.
+func (f *CachingFetcher[T]) withinStaleIfErrorLocked(now time.Time) bool {
+	return f.withinMaxStaleLocked(now) && f.withinStaleDirectiveLocked("stale-if-error", now)
+}
+
+// withinStaleDirectiveLocked reports whether cached data is within the period past its
+// expiry allowed by the stale-while-revalidate or stale-if-error directive of the
+// response that produced it (RFC 5861). Without the directive, the period is unbounded.
+// The caller must hold f.mu.
+func (f *CachingFetcher[T]) withinStaleDirectiveLocked(directive string, now time.Time) bool {
+	limit, ok := staleDirective(f.cachedHeaders, directive)
+	if !ok {
+		return true
+	}
+	return now.Before(f.expiresAt.Add(limit))
+}
+
+// staleDirective returns the number of seconds given by a Cache-Control directive such
+// as stale-while-revalidate=60, and whether it is present
+func staleDirective(headers http.Header, directive string) (time.Duration, bool) {
+	for _, d := range strings.Split(headers.Get("Cache-Control"), ",") {
+		value, ok := strings.CutPrefix(strings.TrimSpace(d), directive+"=")
+		if !ok {
+			continue
+		}
+		seconds, err := strconv.ParseInt(strings.Trim(value, "\""), 10, 64)
+		if err != nil || seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	return 0, false
+}
+
+// Refresh fetches the data and replaces the cached copy whether or not it has expired,
+// so that callers can refresh ahead of expiry instead of blocking Get. If a fetch is
+// already in progress, Refresh waits for it and returns its error. On failure the
+// cached data is kept.
+func (f *CachingFetcher[T]) Refresh(ctx context.Context) error {
+	f.mu.Lock()
+	f.loadDiskCacheLocked()
+	if f.refreshing {
+		f.refreshCond.Wait()
+		err := f.lastError
+		f.mu.Unlock()
+		return err
+	}
+	f.refreshing = true
+	f.mu.Unlock()
+
+	return f.refresh(ctx)
 }
 
 // backgroundRefresh performs a refresh in the background
 func (f *CachingFetcher[T]) backgroundRefresh(ctx context.Context) {
+	_ = f.refresh(ctx)
+}
+
+// refresh fetches the data and caches it if the fetch succeeds. The caller must have
+// set f.refreshing.
+func (f *CachingFetcher[T]) refresh(ctx context.Context) error {
 	data, err := f.doFetch(ctx)
 
 	f.mu.Lock()
@@ -181,37 +408,75 @@ func (f *CachingFetcher[T]) backgroundRefresh(ctx context.Context) {
 
 	if err == nil {
 		f.cachedData = &data
+		f.cachedHeaders = f.lastHeaders
 		f.cachedAt = time.Now()
 		f.expiresAt = f.calculateExpiry(f.lastHeaders)
+		f.saveDiskCacheLocked()
 	}
 
 	f.refreshCond.Broadcast()
+	return err
 }
 
 // doFetch performs the actual fetch, using custom function if provided
-func (f *CachingFetcher[T]) doFetch(ctx context.Context) (T, error) {
+func (f *CachingFetcher[T]) doFetch(ctx context.Context) (data T, err error) {
+	if metrics, name := f.metricsConfig(); metrics != nil {
+		start := time.Now()
+		defer func() {
+			metrics.recordFetch(name, time.Since(start), err)
+		}()
+	}
+
 	if f.fetchFunc != nil {
 		return f.fetchFunc(ctx, f.url)
 	}
-	return f.fetchJSON(ctx)
+	return f.fetchHTTP(ctx)
 }
 
-// fetchJSON performs the actual HTTP request and JSON unmarshaling
-func (f *CachingFetcher[T]) fetchJSON(ctx context.Context) (T, error) {
+// fetchHTTP performs the actual HTTP request and decodes the body,
+// using JSON unmarshaling unless a decode function is set
+func (f *CachingFetcher[T]) fetchHTTP(ctx context.Context) (T, error) {
 	var result T
 
-	req, err := http.NewRequestWithContext(ctx, "GET", f.url, nil)
+	config := f.requestConfig()
+	req, err := config.newRequest(ctx, f.url)
 	if err != nil {
-		return result, fmt.Errorf("create request: %w", err)
+		return result, err
+	}
+
+	// Revalidate cached data using the validators of the response that produced it
+	cachedData, cachedHeaders := f.cachedForRevalidation()
+	if cachedData != nil {
+		if etag := cachedHeaders.Get("ETag"); etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if lastModified := cachedHeaders.Get("Last-Modified"); lastModified != "" {
+			req.Header.Set("If-Modified-Since", lastModified)
+		}
 	}
 
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := config.clientFor(req).Do(req)
 	if err != nil {
 		return result, fmt.Errorf("http request: %w", err)
 	}
 	defer resp.Body.Close()
 
+	// Not Modified extends the life of the cached data, with the stored headers
+	// updated from the response (RFC 9111 section 4.3.4)
+	if resp.StatusCode == http.StatusNotModified && cachedData != nil {
+		headers := cachedHeaders.Clone()
+		if headers == nil {
+			headers = make(http.Header, len(resp.Header))
+		}
+		for name, values := range resp.Header {
+			if name != "Content-Length" {
+				headers[name] = values
+			}
+		}
+		f.lastHeaders = headers
+		return *cachedData, nil
+	}
+
 	// Capture response headers for cache expiry calculation
 	f.lastHeaders = resp.Header
 
@@ -224,6 +489,10 @@ func (f *CachingFetcher[T]) fetchJSON(ctx context.Context) (T, error) {
 		return result, fmt.Errorf("read response: %w", err)
 	}
 
+	if f.decodeFunc != nil {
+		return f.decodeFunc(body)
+	}
+
 	if err := json.Unmarshal(body, &result); err != nil {
 		return result, fmt.Errorf("unmarshal json: %w", err)
 	}
@@ -231,10 +500,23 @@ func (f *CachingFetcher[T]) fetchJSON(ctx context.Context) (T, error) {
 	return result, nil
 }
 
-// calculateExpiry determines when the cached data expires based on HTTP cache headers
+// cachedForRevalidation returns the cached data and the headers of the response that
+// produced it, or nil if nothing is cached
+func (f *CachingFetcher[T]) cachedForRevalidation() (*T, http.Header) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.cachedData, f.cachedHeaders
+}
+
+// calculateExpiry determines when the cached data expires based on HTTP cache headers,
+// applying any configured jitter
 func (f *CachingFetcher[T]) calculateExpiry(headers http.Header) time.Time {
 	now := time.Now()
+	return f.applyJitter(now, f.baseExpiry(headers, now))
+}
 
+// baseExpiry determines when the cached data expires based on HTTP cache headers
+func (f *CachingFetcher[T]) baseExpiry(headers http.Header, now time.Time) time.Time {
 	if headers != nil {
 		// Check Cache-Control header first (takes precedence)
 		if cacheControl := headers.Get("Cache-Control"); cacheControl != "" {
@@ -256,6 +538,19 @@ func (f *CachingFetcher[T]) calculateExpiry(headers http.Header) time.Time {
 	return now.Add(1 * time.Hour)
 }
 
+// applyJitter shortens the lifetime between now and expiry by a random fraction
+// of up to config.Jitter
+func (f *CachingFetcher[T]) applyJitter(now, expiry time.Time) time.Time {
+	jitter := min(f.config.Jitter, 1)
+	lifetime := expiry.Sub(now)
+	if jitter <= 0 || lifetime <= 0 {
+		return expiry
+	}
+
+	reduction := time.Duration(rand.Float64() * jitter * float64(lifetime))
+	return expiry.Add(-reduction)
+}
+
 // parseCacheControl extracts max-age from Cache-Control header and handles caching directives
 func (f *CachingFetcher[T]) parseCacheControl(cacheControl string, now time.Time) time.Time {
 	// Parse comma-separated directives
@@ -347,6 +642,62 @@ func (f *CachingFetcher[T]) parseExpires(expiresStr string, now time.Time) time.
 	return now.Add(1 * time.Hour)
 }
 
+// setJitter updates the jitter fraction applied to subsequent expiry calculations
+func (f *CachingFetcher[T]) setJitter(jitter float64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.config.Jitter = jitter
+}
+
+// setRequestOptions updates the User-Agent and extra headers sent with subsequent requests
+func (f *CachingFetcher[T]) setRequestOptions(userAgent string, headers http.Header) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if userAgent != "" {
+		f.config.UserAgent = userAgent
+	}
+	if len(headers) > 0 {
+		f.config.Headers = headers.Clone()
+	}
+}
+
+// setObjectClients updates the clients used to fetch object store URLs
+func (f *CachingFetcher[T]) setObjectClients(clients map[string]ObjectClient) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.config.ObjectClients = maps.Clone(clients)
+}
+
+// setDir updates the directory in which fetched data is persisted. It must be called
+// before the first Get to load previously persisted data.
+func (f *CachingFetcher[T]) setDir(dir string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.config.Dir = dir
+}
+
+// setMetrics sets the metrics recorded for fetches and cache results, labelled with name
+func (f *CachingFetcher[T]) setMetrics(metrics *Metrics, name string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.metrics = metrics
+	f.metricsName = name
+}
+
+// metricsConfig returns the metrics and their provider label, or nil if none are set
+func (f *CachingFetcher[T]) metricsConfig() (*Metrics, string) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.metrics, f.metricsName
+}
+
+// requestConfig returns a snapshot of the configuration used to make requests
+func (f *CachingFetcher[T]) requestConfig() CacheConfig {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.config
+}
+
 // GetCachedData returns the currently cached data without performing a fetch.
 // It returns nil if no data is currently cached.
 func (f *CachingFetcher[T]) GetCachedData() *T {
@@ -362,3 +713,11 @@ func (f *CachingFetcher[T]) GetCacheInfo() (cachedAt, expiresAt time.Time, hasDa
 	defer f.mu.RUnlock()
 	return f.cachedAt, f.expiresAt, f.cachedData != nil
 }
+
+// lastCacheResult returns the result of the last Get or GetWithHeaders that served data,
+// and false if none has
+func (f *CachingFetcher[T]) lastCacheResult() (CacheResult, bool) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.lastResult, f.served
+}
@@ -239,6 +239,37 @@ func TestCachingFetcher_GetCachedData(t *testing.T) {
 	assert.Equal(t, 42, cached.Count)
 }
 
+func TestCachingFetcher_GetWithHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Upstream", "origin")
+		data := testData{Message: "hello", Count: 42}
+		json.NewEncoder(w).Encode(data)
+	}))
+	defer server.Close()
+
+	fetcher := NewCachingFetcher[testData](server.URL, CacheConfig{
+		StaticExpiry: 1 * time.Hour,
+	})
+
+	ctx := context.Background()
+
+	data, headers, result, err := fetcher.GetWithHeaders(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, CacheResultFresh, result)
+	assert.Equal(t, 42, data.Count)
+	assert.Equal(t, "application/json", headers.Get("Content-Type"))
+	assert.Equal(t, "origin", headers.Get("X-Upstream"))
+
+	// Mutating the returned headers must not affect the cached copy
+	headers.Set("X-Upstream", "mutated")
+
+	_, headers2, result2, err := fetcher.GetWithHeaders(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, CacheResultCached, result2)
+	assert.Equal(t, "origin", headers2.Get("X-Upstream"))
+}
+
 func TestCachingFetcher_GetCacheInfo(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		data := testData{Message: "hello", Count: 42}
@@ -493,6 +524,32 @@ func TestCachingFetcher_CalculateExpiry(t *testing.T) {
 	}
 }
 
+func TestCachingFetcher_Jitter(t *testing.T) {
+	lifetime := 1 * time.Hour
+	fetcher := &CachingFetcher[testData]{
+		config: CacheConfig{
+			StaticExpiry: lifetime,
+			Jitter:       0.5,
+		},
+	}
+
+	seen := make(map[int64]bool)
+	for range 20 {
+		before := time.Now()
+		result := fetcher.calculateExpiry(nil)
+
+		// Jitter only ever shortens the lifetime, by at most half
+		assert.False(t, result.After(before.Add(lifetime).Add(time.Second)))
+		assert.False(t, result.Before(before.Add(lifetime/2)))
+		seen[result.UnixNano()] = true
+	}
+	assert.Greater(t, len(seen), 1, "expected jitter to vary expiry times")
+
+	// no-store expiry is never pushed into the past
+	now := time.Now()
+	assert.Equal(t, now, fetcher.applyJitter(now, now))
+}
+
 // TestCachingFetcher_HTTPCacheHeaders tests end-to-end behavior with HTTP cache headers
 func TestCachingFetcher_HTTPCacheHeaders(t *testing.T) {
 	t.Run("respects Cache-Control max-age", func(t *testing.T) {
@@ -755,3 +812,312 @@ func TestCachingFetcher_CacheControl_MaxAgeWithNoStore(t *testing.T) {
 	assert.Equal(t, 2, data2.Count)
 	assert.Equal(t, int32(2), callCount.Load())
 }
+
+func TestCachingFetcher_RequestOptions(t *testing.T) {
+	var gotHeaders atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHeaders.Store(r.Header.Clone())
+		if r.URL.Path == "/text" {
+			w.Write([]byte("192.0.2.0/24\n"))
+			return
+		}
+		json.NewEncoder(w).Encode(testData{Message: "hello"})
+	}))
+	defer server.Close()
+
+	t.Run("default user agent", func(t *testing.T) {
+		fetcher := NewCachingFetcher[testData](server.URL, CacheConfig{StaticExpiry: time.Hour})
+		_, _, err := fetcher.Get(context.Background())
+		require.NoError(t, err)
+
+		headers := gotHeaders.Load().(http.Header)
+		assert.Equal(t, DefaultUserAgent, headers.Get("User-Agent"))
+	})
+
+	t.Run("custom user agent, headers and client", func(t *testing.T) {
+		fetcher := NewCachingFetcher[testData](server.URL, CacheConfig{
+			StaticExpiry: time.Hour,
+			Client:       server.Client(),
+			UserAgent:    "my-service/1.0",
+			Headers:      http.Header{"X-Request-Source": []string{"test"}},
+		})
+		_, _, err := fetcher.Get(context.Background())
+		require.NoError(t, err)
+
+		headers := gotHeaders.Load().(http.Header)
+		assert.Equal(t, "my-service/1.0", headers.Get("User-Agent"))
+		assert.Equal(t, "test", headers.Get("X-Request-Source"))
+	})
+
+	t.Run("text provider", func(t *testing.T) {
+		provider := NewHTTPTextProvider("text", server.URL+"/text", CacheConfig{StaticExpiry: time.Hour})
+		provider.setRequestOptions("text-agent", http.Header{"X-Token": []string{"abc"}})
+
+		prefixes, err := provider.Prefixes(context.Background())
+		require.NoError(t, err)
+		assert.Len(t, prefixes, 1)
+
+		headers := gotHeaders.Load().(http.Header)
+		assert.Equal(t, "text-agent", headers.Get("User-Agent"))
+		assert.Equal(t, "abc", headers.Get("X-Token"))
+	})
+}
+
+func TestCachingFetcher_MaxStale(t *testing.T) {
+	var failing atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(testData{Message: "hello", Count: 1})
+	}))
+	defer server.Close()
+
+	for _, returnStale := range []bool{true, false} {
+		t.Run(map[bool]string{true: "return stale", false: "stale on error"}[returnStale], func(t *testing.T) {
+			failing.Store(false)
+			fetcher := NewCachingFetcher[testData](server.URL, CacheConfig{
+				StaticExpiry: 50 * time.Millisecond,
+				ReturnStale:  returnStale,
+				MaxStale:     200 * time.Millisecond,
+			})
+
+			data, result, err := fetcher.Get(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, CacheResultFresh, result)
+			assert.Equal(t, "hello", data.Message)
+
+			failing.Store(true)
+
+			// Expired but within MaxStale: stale data is still served
+			time.Sleep(100 * time.Millisecond)
+			data, result, _ = fetcher.Get(context.Background())
+			assert.Equal(t, CacheResultStale, result)
+			assert.Equal(t, "hello", data.Message)
+
+			// Past expiry + MaxStale: the fetch error is returned with no data
+			time.Sleep(200 * time.Millisecond)
+			data, _, err = fetcher.Get(context.Background())
+			assert.Error(t, err)
+			assert.Equal(t, testData{}, data)
+
+			// Recovery resumes normal service
+			failing.Store(false)
+			data, result, err = fetcher.Get(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, CacheResultFresh, result)
+			assert.Equal(t, "hello", data.Message)
+		})
+	}
+}
+
+func TestCachingFetcher_ConditionalRequests(t *testing.T) {
+	tests := []struct {
+		name      string
+		validator string
+		value     string
+		condition string
+	}{
+		{
+			name:      "etag",
+			validator: "ETag",
+			value:     `"v1"`,
+			condition: "If-None-Match",
+		},
+		{
+			name:      "last modified",
+			validator: "Last-Modified",
+			value:     "Mon, 05 Jan 2026 20:21:06 GMT",
+			condition: "If-Modified-Since",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fullResponses := atomic.Int32{}
+			notModified := atomic.Int32{}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set(tt.validator, tt.value)
+				if r.Header.Get(tt.condition) == tt.value {
+					notModified.Add(1)
+					w.Header().Set("Cache-Control", "max-age=3600")
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+				fullResponses.Add(1)
+				w.Header().Set("Cache-Control", "max-age=0")
+				w.Header().Set("X-Source", "full")
+				json.NewEncoder(w).Encode(testData{Message: "hello", Count: 1})
+			}))
+			defer server.Close()
+
+			fetcher := NewCachingFetcher[testData](server.URL, CacheConfig{})
+			ctx := context.Background()
+
+			data, _, result, err := fetcher.GetWithHeaders(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, CacheResultFresh, result)
+			assert.Equal(t, "hello", data.Message)
+
+			// The expired data is revalidated, and Not Modified extends it with the new max-age
+			data, headers, result, err := fetcher.GetWithHeaders(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, CacheResultFresh, result)
+			assert.Equal(t, testData{Message: "hello", Count: 1}, data)
+			assert.Equal(t, "full", headers.Get("X-Source"))
+			assert.Equal(t, "max-age=3600", headers.Get("Cache-Control"))
+			assert.Equal(t, int32(1), fullResponses.Load())
+			assert.Equal(t, int32(1), notModified.Load())
+
+			_, expiresAt, _ := fetcher.GetCacheInfo()
+			assert.WithinDuration(t, time.Now().Add(time.Hour), expiresAt, time.Minute)
+
+			data, result, err = fetcher.Get(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, CacheResultCached, result)
+			assert.Equal(t, "hello", data.Message)
+		})
+	}
+}
+
+func TestCachingFetcher_NotModifiedWithoutCache(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Empty(t, r.Header.Get("If-None-Match"))
+		w.WriteHeader(http.StatusNotModified)
+	}))
+	defer server.Close()
+
+	fetcher := NewCachingFetcher[testData](server.URL, CacheConfig{})
+	_, _, err := fetcher.Get(context.Background())
+	assert.Error(t, err)
+}
+
+func TestCachingFetcher_Refresh(t *testing.T) {
+	callCount := atomic.Int32{}
+	failing := atomic.Bool{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(testData{Message: "hello", Count: int(callCount.Add(1))})
+	}))
+	defer server.Close()
+
+	fetcher := NewCachingFetcher[testData](server.URL, CacheConfig{StaticExpiry: time.Hour})
+	ctx := context.Background()
+
+	_, _, err := fetcher.Get(ctx)
+	require.NoError(t, err)
+
+	// Refresh fetches although the cached data is fresh
+	require.NoError(t, fetcher.Refresh(ctx))
+	data, result, err := fetcher.Get(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, CacheResultCached, result)
+	assert.Equal(t, 2, data.Count)
+
+	// A failed refresh keeps the cached data
+	failing.Store(true)
+	assert.Error(t, fetcher.Refresh(ctx))
+	data, result, err = fetcher.Get(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, CacheResultCached, result)
+	assert.Equal(t, 2, data.Count)
+}
+
+func TestCachingFetcher_StaleDirectives(t *testing.T) {
+	tests := []struct {
+		name         string
+		cacheControl string
+		returnStale  bool
+		failing      bool
+		wantResult   CacheResult
+		wantCount    int
+		wantErr      bool
+	}{
+		{
+			name:         "within stale-while-revalidate",
+			cacheControl: "max-age=60, stale-while-revalidate=3600",
+			returnStale:  true,
+			wantResult:   CacheResultStale,
+			wantCount:    1,
+		},
+		{
+			name:         "past stale-while-revalidate",
+			cacheControl: "max-age=60, stale-while-revalidate=1",
+			returnStale:  true,
+			wantResult:   CacheResultFresh,
+			wantCount:    2,
+		},
+		{
+			name:         "within stale-if-error",
+			cacheControl: "max-age=60, stale-if-error=3600",
+			failing:      true,
+			wantResult:   CacheResultStale,
+			wantCount:    1,
+			wantErr:      true,
+		},
+		{
+			name:         "past stale-if-error",
+			cacheControl: "max-age=60, stale-if-error=1",
+			failing:      true,
+			wantResult:   CacheResultFresh,
+			wantCount:    0,
+			wantErr:      true,
+		},
+		{
+			name:         "past stale-while-revalidate within stale-if-error",
+			cacheControl: `max-age=60, stale-while-revalidate=1, stale-if-error="3600"`,
+			returnStale:  true,
+			failing:      true,
+			wantResult:   CacheResultStale,
+			wantCount:    1,
+			wantErr:      true,
+		},
+		{
+			name:         "no directives",
+			cacheControl: "max-age=60",
+			returnStale:  true,
+			failing:      true,
+			wantResult:   CacheResultStale,
+			wantCount:    1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			callCount := atomic.Int32{}
+			failing := atomic.Bool{}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if failing.Load() {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				w.Header().Set("Cache-Control", tt.cacheControl)
+				json.NewEncoder(w).Encode(testData{Message: "hello", Count: int(callCount.Add(1))})
+			}))
+			defer server.Close()
+
+			fetcher := NewCachingFetcher[testData](server.URL, CacheConfig{ReturnStale: tt.returnStale})
+			_, _, err := fetcher.Get(context.Background())
+			require.NoError(t, err)
+
+			// Expire the data ten seconds ago
+			fetcher.mu.Lock()
+			fetcher.expiresAt = time.Now().Add(-10 * time.Second)
+			fetcher.mu.Unlock()
+			failing.Store(tt.failing)
+
+			data, result, err := fetcher.Get(context.Background())
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantResult, result)
+			assert.Equal(t, tt.wantCount, data.Count)
+		})
+	}
+}
@@ -1,5 +1,11 @@
 package prefixlist
 
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
 // Config represents the configuration for prefix list providers
 type Config struct {
 	// Providers lists the enabled providers
@@ -8,7 +14,7 @@ type Config struct {
 
 // ProviderConfig represents configuration for a single provider
 type ProviderConfig struct {
-	// Name is the provider name (github, cloudflare, google, atlassian, gitlab, aws)
+	// Name is the provider name (github, cloudflare, google, atlassian, gitlab, aws, azure, oci, digitalocean, akamai, custom)
 	Name string `mapstructure:"name" yaml:"name"`
 
 	// Enabled controls whether this provider is active
@@ -18,8 +24,70 @@ type ProviderConfig struct {
 	// Examples:
 	//   GitHub: {"service": "hooks"} or {"service": "actions"}
 	//   AWS: {"service": "EC2", "region": "us-east-1"}
+	//   Azure: {"service": "AzureCloud", "region": "westeurope"}
+	//   OCI: {"region": "us-ashburn-1", "tag": "OSN"}
+	//   DigitalOcean: {"country": "NL,DE"}
+	//   Akamai: {"url": "https://example.com/akamai-origin-ip-acl.txt"}
+	//   Custom: {"name": "corp-vpn", "url": "https://example.com/prefixes.json", "path": "prefixes.#.cidr"}
+	//   Custom: {"url": "https://example.com/ips.csv", "format": "csv", "column": "cidr"}
+	//   Custom: {"url": "s3://bucket/ips.txt", "format": "text"} (with an ObjectClient set for s3)
 	//   Google: {"scope": "us-central1", "service": "Google Cloud"}
 	//   Atlassian: {"region": "global", "product": "jira"}
 	//   Cloudflare: {"version": "ipv6"}
 	Filter map[string]string `mapstructure:"filter" yaml:"filter,omitempty"`
+
+	// CacheJitter optionally randomizes the provider's cache expiry by up to this
+	// fraction (0.0-1.0) of the cache lifetime. See CacheConfig.Jitter.
+	CacheJitter float64 `mapstructure:"cache_jitter" yaml:"cache_jitter,omitempty"`
+
+	// CacheDir optionally persists the provider's fetched data in this directory so that
+	// it survives restarts. See CacheConfig.Dir.
+	CacheDir string `mapstructure:"cache_dir" yaml:"cache_dir,omitempty"`
+
+	// UserAgent optionally overrides the User-Agent sent when fetching prefixes.
+	// See CacheConfig.UserAgent.
+	UserAgent string `mapstructure:"user_agent" yaml:"user_agent,omitempty"`
+
+	// Headers optionally adds headers to every request made when fetching prefixes
+	Headers map[string]string `mapstructure:"headers" yaml:"headers,omitempty"`
+
+	// ObjectClients optionally sets the clients used to fetch object store URLs, by URL
+	// scheme. It cannot be loaded from a file and is set in code. See
+	// CacheConfig.ObjectClients.
+	ObjectClients map[string]ObjectClient `mapstructure:"-" yaml:"-"`
+
+	// FetcherPool optionally shares the provider's fetcher with other providers created
+	// with the same pool that fetch the same URL with the same settings. If nil, the
+	// provider's fetcher is not shared, except with the other providers of a
+	// NewMultiProviderFromConfig call. It cannot be loaded from a file and is set in code.
+	FetcherPool *FetcherPool `mapstructure:"-" yaml:"-"`
+}
+
+// ParseProviderRef parses a provider reference of the form "name" or
+// "name?key=value&key=value" into an enabled ProviderConfig, e.g. "github?service=hooks"
+// or "google?scope=us-central1,europe-west1". Query values become the Filter.
+func ParseProviderRef(ref string) (ProviderConfig, error) {
+	name, query, _ := strings.Cut(ref, "?")
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return ProviderConfig{}, fmt.Errorf("provider reference %q has no name", ref)
+	}
+
+	cfg := ProviderConfig{Name: name, Enabled: true}
+	if query == "" {
+		return cfg, nil
+	}
+
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return ProviderConfig{}, fmt.Errorf("invalid filter in provider reference %q: %w", ref, err)
+	}
+	cfg.Filter = make(map[string]string, len(values))
+	for key, v := range values {
+		if len(v) > 1 {
+			return ProviderConfig{}, fmt.Errorf("filter %q repeated in provider reference %q", key, ref)
+		}
+		cfg.Filter[key] = v[0]
+	}
+	return cfg, nil
 }
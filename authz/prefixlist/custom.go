@@ -0,0 +1,186 @@
+package prefixlist
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	RegisterProvider("custom", func(cfg ProviderConfig) (Provider, error) {
+		// Custom: "url" key (required), "format" key ("json", the default, "text" or
+		// "csv"), "path" key locating the prefixes in a JSON document, "column" key
+		// selecting the CSV column and "name" key distinguishing several custom providers
+		url := cfg.Filter["url"]
+		if url == "" {
+			return nil, errors.New("custom provider requires a url filter")
+		}
+		name := cfg.Filter["name"]
+		switch format := strings.ToLower(cfg.Filter["format"]); format {
+		case "", "json":
+			return NewCustomProvider(name, url, cfg.Filter["path"]), nil
+		case "text":
+			return NewCustomTextProvider(name, url), nil
+		case "csv":
+			column := cfg.Filter["column"]
+			if column == "" {
+				column = "0"
+			}
+			return NewCustomCSVProvider(name, url, column), nil
+		default:
+			return nil, fmt.Errorf("unknown custom provider format: %s", format)
+		}
+	})
+}
+
+// CustomProvider fetches IP ranges from any HTTP endpoint returning JSON, using a path
+// to locate the prefixes in the document.
+//
+// The path uses a subset of the gjson syntax: keys separated by dots, array indices,
+// and '#' to select every element of an array. A dot that is part of a key is escaped
+// with a backslash. For example, for a document such as
+//
+//	{"prefixes": [{"ip_prefix": "192.0.2.0/24"}, {"ip_prefix": "198.51.100.0/24"}]}
+//
+// the path "prefixes.#.ip_prefix" selects both prefixes. The path must select strings
+// or arrays of strings; an empty path selects the document itself, which is then
+// expected to be an array of strings.
+type CustomProvider struct {
+	*HTTPJSONProvider[any]
+	path []string
+}
+
+// NewCustomProvider creates a new prefix list provider for a JSON document
+// name: optional name to distinguish custom providers (e.g., "corp-vpn")
+// url: the HTTP endpoint to fetch from
+// path: location of the prefixes in the document (e.g., "prefixes.#.ip_prefix")
+func NewCustomProvider(name, url, path string) *CustomProvider {
+	p := &CustomProvider{
+		path: splitJSONPath(path),
+	}
+
+	p.HTTPJSONProvider = NewHTTPJSONProvider[any](
+		customProviderName(name),
+		url,
+		customCacheConfig,
+		p.transformCustom,
+	)
+
+	return p
+}
+
+// NewCustomTextProvider creates a new prefix list provider for a plain text list of
+// CIDRs or addresses, one per line
+// name: optional name to distinguish custom providers (e.g., "stripe-webhooks")
+// url: the HTTP endpoint to fetch from
+func NewCustomTextProvider(name, url string) *HTTPTextProvider {
+	return NewHTTPTextProvider(customProviderName(name), url, customCacheConfig)
+}
+
+// NewCustomCSVProvider creates a new prefix list provider for a CSV list
+// name: optional name to distinguish custom providers (e.g., "zendesk")
+// url: the HTTP endpoint to fetch from
+// column: 0-based index or header name of the column holding the prefixes (see CSVColumn)
+func NewCustomCSVProvider(name, url, column string) *HTTPCSVProvider {
+	return NewHTTPCSVProvider(customProviderName(name), url, customCacheConfig, CSVColumn(column))
+}
+
+var customCacheConfig = CacheConfig{
+	StaticExpiry: 24 * time.Hour,
+	ReturnStale:  true,
+}
+
+func customProviderName(name string) string {
+	if name == "" {
+		return "custom"
+	}
+	return "custom-" + name
+}
+
+func (p *CustomProvider) transformCustom(data any) ([]netip.Prefix, error) {
+	cidrs, err := selectJSONPath(data, p.path)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseCIDRs(cidrs)
+}
+
+// splitJSONPath splits a path into its components, honouring escaped dots
+func splitJSONPath(path string) []string {
+	if path == "" {
+		return nil
+	}
+
+	var components []string
+	var component strings.Builder
+	for i := 0; i < len(path); i++ {
+		switch {
+		case path[i] == '\\' && i+1 < len(path):
+			i++
+			component.WriteByte(path[i])
+		case path[i] == '.':
+			components = append(components, component.String())
+			component.Reset()
+		default:
+			component.WriteByte(path[i])
+		}
+	}
+	return append(components, component.String())
+}
+
+// selectJSONPath returns the strings selected by path in a decoded JSON value. Keys and
+// indices that are missing select nothing.
+func selectJSONPath(value any, path []string) ([]string, error) {
+	if len(path) == 0 {
+		switch v := value.(type) {
+		case nil:
+			return nil, nil
+		case string:
+			return []string{v}, nil
+		case []any:
+			var result []string
+			for _, element := range v {
+				s, ok := element.(string)
+				if !ok {
+					return nil, fmt.Errorf("expected string, got %T", element)
+				}
+				result = append(result, s)
+			}
+			return result, nil
+		default:
+			return nil, fmt.Errorf("expected string or array of strings, got %T", value)
+		}
+	}
+
+	component, rest := path[0], path[1:]
+	switch v := value.(type) {
+	case map[string]any:
+		return selectJSONPath(v[component], rest)
+	case []any:
+		if component == "#" {
+			var result []string
+			for _, element := range v {
+				selected, err := selectJSONPath(element, rest)
+				if err != nil {
+					return nil, err
+				}
+				result = append(result, selected...)
+			}
+			return result, nil
+		}
+		index, err := strconv.Atoi(component)
+		if err != nil {
+			return nil, fmt.Errorf("path component %q: expected index or '#' for array", component)
+		}
+		if index < 0 || index >= len(v) {
+			return nil, nil
+		}
+		return selectJSONPath(v[index], rest)
+	default:
+		return nil, nil
+	}
+}
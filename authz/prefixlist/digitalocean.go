@@ -0,0 +1,61 @@
+package prefixlist
+
+import (
+	"net/netip"
+	"strings"
+	"time"
+)
+
+func init() {
+	RegisterProvider("digitalocean", func(cfg ProviderConfig) (Provider, error) {
+		// DigitalOcean: support "country" key (comma-separated ISO 3166-1 alpha-2 codes)
+		countries := parseCommaSeparated(cfg.Filter["country"])
+		return NewDigitalOceanProvider(countries), nil
+	})
+}
+
+// DigitalOceanProvider fetches IP ranges from DigitalOcean's geofeed
+type DigitalOceanProvider struct {
+	*HTTPCSVProvider
+	countries []string // optional filter for countries (e.g., "NL", "US")
+}
+
+// NewDigitalOceanProvider creates a new DigitalOcean prefix list provider
+// countries: optional list of ISO 3166-1 alpha-2 country codes to filter by (e.g., ["NL", "DE"])
+func NewDigitalOceanProvider(countries []string) *DigitalOceanProvider {
+	name := "digitalocean"
+	if len(countries) > 0 {
+		name += "-" + strings.Join(countries, ",")
+	}
+
+	p := &DigitalOceanProvider{
+		countries: countries,
+	}
+
+	p.HTTPCSVProvider = NewHTTPCSVProvider(
+		name,
+		"https://www.digitalocean.com/geo/google.csv",
+		CacheConfig{
+			StaticExpiry: 24 * time.Hour,
+			ReturnStale:  true,
+		},
+		p.transformDigitalOceanRanges,
+	)
+
+	return p
+}
+
+// transformDigitalOceanRanges extracts the prefixes from geofeed records, which hold
+// the prefix, country, region, city and postal code (RFC 8805)
+func (p *DigitalOceanProvider) transformDigitalOceanRanges(records [][]string) ([]netip.Prefix, error) {
+	var cidrs []string
+	for _, record := range records {
+		// Apply country filter if specified
+		if len(p.countries) > 0 && (len(record) < 2 || !contains(p.countries, record[1])) {
+			continue
+		}
+		cidrs = append(cidrs, record[0])
+	}
+
+	return parseCIDRs(cidrs)
+}
@@ -0,0 +1,125 @@
+package prefixlist
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// diskCacheEntry is the on-disk form of a CachingFetcher's cached data
+type diskCacheEntry struct {
+	URL       string          `json:"url"`
+	CachedAt  time.Time       `json:"cached_at"`
+	ExpiresAt time.Time       `json:"expires_at"`
+	Headers   http.Header     `json:"headers,omitempty"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// diskCachePath returns the file in which the fetcher persists its data, or "" if no
+// cache directory is configured. The name is derived from the URL and the cached type,
+// so fetchers decoding the same URL differently do not share a file.
+func (f *CachingFetcher[T]) diskCachePath() string {
+	if f.config.Dir == "" {
+		return ""
+	}
+
+	var zero T
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%T\x00%s", zero, f.url)))
+	return filepath.Join(f.config.Dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// loadDiskCacheLocked populates the cache from disk the first time it is called, unless
+// data has already been fetched. A missing or unreadable file leaves the cache empty.
+// The caller must hold f.mu.
+func (f *CachingFetcher[T]) loadDiskCacheLocked() {
+	if f.diskLoaded {
+		return
+	}
+	f.diskLoaded = true
+
+	path := f.diskCachePath()
+	if path == "" || f.cachedData != nil {
+		return
+	}
+
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	var entry diskCacheEntry
+	if err := json.Unmarshal(body, &entry); err != nil || entry.URL != f.url {
+		return
+	}
+
+	var data T
+	if err := json.Unmarshal(entry.Data, &data); err != nil {
+		return
+	}
+
+	f.cachedData = &data
+	f.cachedHeaders = entry.Headers
+	f.cachedAt = entry.CachedAt
+	f.expiresAt = entry.ExpiresAt
+}
+
+// saveDiskCacheLocked persists the cached data, replacing the file atomically so that a
+// crash never leaves a partial entry. Persistence is best effort: errors are ignored, as
+// the in-memory cache remains valid. The caller must hold f.mu.
+func (f *CachingFetcher[T]) saveDiskCacheLocked() {
+	path := f.diskCachePath()
+	if path == "" || f.cachedData == nil || noStore(f.cachedHeaders) {
+		return
+	}
+
+	data, err := json.Marshal(*f.cachedData)
+	if err != nil {
+		return
+	}
+
+	body, err := json.Marshal(diskCacheEntry{
+		URL:       f.url,
+		CachedAt:  f.cachedAt,
+		ExpiresAt: f.expiresAt,
+		Headers:   f.cachedHeaders,
+		Data:      data,
+	})
+	if err != nil {
+		return
+	}
+
+	if err := os.MkdirAll(f.config.Dir, 0o700); err != nil {
+		return
+	}
+
+	tmp, err := os.CreateTemp(f.config.Dir, ".tmp-*")
+	if err != nil {
+		return
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(body); err != nil {
+		tmp.Close()
+		return
+	}
+	if err := tmp.Close(); err != nil {
+		return
+	}
+	_ = os.Rename(tmp.Name(), path)
+}
+
+// noStore reports whether the response headers forbid storing the response
+func noStore(headers http.Header) bool {
+	for _, directive := range strings.Split(headers.Get("Cache-Control"), ",") {
+		if strings.TrimSpace(directive) == "no-store" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package prefixlist
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCachingFetcher_DiskCache(t *testing.T) {
+	dir := t.TempDir()
+	available := atomic.Bool{}
+	available.Store(true)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !available.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("X-Source", "upstream")
+		json.NewEncoder(w).Encode(testData{Message: "persisted", Count: 1})
+	}))
+	defer server.Close()
+
+	config := CacheConfig{
+		StaticExpiry: time.Hour,
+		Dir:          dir,
+	}
+
+	fetcher := NewCachingFetcher[testData](server.URL, config)
+	data, result, err := fetcher.Get(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, CacheResultFresh, result)
+	assert.Equal(t, "persisted", data.Message)
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	// A new fetcher, as after a restart, loads the data without reaching the upstream
+	available.Store(false)
+	restarted := NewCachingFetcher[testData](server.URL, config)
+	data, headers, result, err := restarted.GetWithHeaders(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, CacheResultCached, result)
+	assert.Equal(t, testData{Message: "persisted", Count: 1}, data)
+	assert.Equal(t, "upstream", headers.Get("X-Source"))
+
+	_, expiresAt, hasData := restarted.GetCacheInfo()
+	assert.True(t, hasData)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), expiresAt, time.Minute)
+
+	// Fetchers of another type for the same URL do not share the file
+	other := NewCachingFetcher[[]string](server.URL, config)
+	_, _, err = other.Get(context.Background())
+	assert.Error(t, err)
+}
+
+func TestCachingFetcher_DiskCacheExpired(t *testing.T) {
+	dir := t.TempDir()
+	available := atomic.Bool{}
+	available.Store(true)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !available.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`["192.0.2.0/24"]`))
+	}))
+	defer server.Close()
+
+	config := CacheConfig{
+		StaticExpiry: 10 * time.Millisecond,
+		Dir:          dir,
+	}
+
+	fetcher := NewCachingFetcher[[]string](server.URL, config)
+	_, _, err := fetcher.Get(context.Background())
+	require.NoError(t, err)
+	time.Sleep(20 * time.Millisecond)
+
+	// Expired data is returned as stale while the upstream is unreachable
+	available.Store(false)
+	restarted := NewCachingFetcher[[]string](server.URL, config)
+	data, result, err := restarted.Get(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, CacheResultStale, result)
+	assert.Equal(t, []string{"192.0.2.0/24"}, data)
+
+	// Unless it is too far past its expiry
+	config.MaxStale = time.Millisecond
+	restarted = NewCachingFetcher[[]string](server.URL, config)
+	data, _, err = restarted.Get(context.Background())
+	assert.Error(t, err)
+	assert.Nil(t, data)
+}
+
+func TestCachingFetcher_DiskCacheNoStore(t *testing.T) {
+	dir := t.TempDir()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		w.Write([]byte(`["192.0.2.0/24"]`))
+	}))
+	defer server.Close()
+
+	fetcher := NewCachingFetcher[[]string](server.URL, CacheConfig{Dir: dir})
+	_, _, err := fetcher.Get(context.Background())
+	require.NoError(t, err)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
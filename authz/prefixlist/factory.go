@@ -2,6 +2,9 @@ package prefixlist
 
 import (
 	"fmt"
+	"maps"
+	"net/http"
+	"slices"
 	"strings"
 	"sync"
 
@@ -35,6 +38,12 @@ var (
 //	    })
 //	}
 //
+// Applications register their own providers the same way, in an init function or
+// before loading configuration, and then configure them by name through Config and
+// NewMultiProviderFromConfig like the built-in ones. The factory applies the common
+// ProviderConfig options, such as CacheDir and Headers, to providers that embed one of
+// the HTTP providers. Registering an existing name replaces its constructor.
+//
 // This registration-based approach reduces cyclomatic complexity by eliminating
 // the need for a large switch statement in the factory function.
 func RegisterProvider(name string, constructor ProviderConstructor) {
@@ -43,6 +52,17 @@ func RegisterProvider(name string, constructor ProviderConstructor) {
 	providerRegistry[strings.ToLower(name)] = constructor
 }
 
+// RegisteredProviders returns the names of the registered providers, sorted, including
+// the built-in providers and any registered by the application.
+func RegisteredProviders() []string {
+	providerRegistryMu.RLock()
+	defer providerRegistryMu.RUnlock()
+
+	names := slices.Collect(maps.Keys(providerRegistry))
+	slices.Sort(names)
+	return names
+}
+
 // NewProviderFromConfig creates a provider instance from configuration.
 // It looks up the provider by name in the registry and invokes its constructor.
 // The provider must be registered via RegisterProvider before it can be instantiated.
@@ -58,17 +78,93 @@ func NewProviderFromConfig(cfg ProviderConfig) (Provider, error) {
 	providerRegistryMu.RUnlock()
 
 	if !ok {
-		return nil, fmt.Errorf("unknown provider: %s", cfg.Name)
+		return nil, fmt.Errorf("unknown provider: %s (registered: %s)", cfg.Name, strings.Join(RegisteredProviders(), ", "))
 	}
 
-	return constructor(cfg)
+	provider, err := constructor(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.CacheJitter > 0 {
+		if c, ok := provider.(cacheJitterSetter); ok {
+			c.setCacheJitter(cfg.CacheJitter)
+		}
+	}
+
+	if cfg.CacheDir != "" {
+		if c, ok := provider.(cacheDirSetter); ok {
+			c.setCacheDir(cfg.CacheDir)
+		}
+	}
+
+	if cfg.UserAgent != "" || len(cfg.Headers) > 0 {
+		if r, ok := provider.(requestOptionsSetter); ok {
+			headers := make(http.Header, len(cfg.Headers))
+			for name, value := range cfg.Headers {
+				headers.Set(name, value)
+			}
+			r.setRequestOptions(cfg.UserAgent, headers)
+		}
+	}
+
+	if len(cfg.ObjectClients) > 0 {
+		if o, ok := provider.(objectClientsSetter); ok {
+			o.setObjectClients(cfg.ObjectClients)
+		}
+	}
+
+	// Share the fetcher once it is configured, so only identically configured providers
+	// share one
+	if cfg.FetcherPool != nil {
+		if f, ok := provider.(fetcherSharer); ok {
+			f.shareFetcher(cfg.FetcherPool)
+		}
+	}
+
+	return provider, nil
+}
+
+// fetcherSharer is implemented by providers backed by a CachingFetcher
+// so that NewProviderFromConfig can share it through ProviderConfig.FetcherPool.
+type fetcherSharer interface {
+	shareFetcher(pool *FetcherPool)
+}
+
+// requestOptionsSetter is implemented by providers backed by a CachingFetcher
+// so that ProviderConfig.UserAgent and Headers can be applied after construction.
+type requestOptionsSetter interface {
+	setRequestOptions(userAgent string, headers http.Header)
+}
+
+// objectClientsSetter is implemented by providers backed by a CachingFetcher
+// so that ProviderConfig.ObjectClients can be applied after construction.
+type objectClientsSetter interface {
+	setObjectClients(clients map[string]ObjectClient)
+}
+
+// cacheJitterSetter is implemented by providers backed by a CachingFetcher
+// so that ProviderConfig.CacheJitter can be applied after construction.
+type cacheJitterSetter interface {
+	setCacheJitter(jitter float64)
+}
+
+// cacheDirSetter is implemented by providers backed by a CachingFetcher
+// so that ProviderConfig.CacheDir can be applied after construction.
+type cacheDirSetter interface {
+	setCacheDir(dir string)
 }
 
-// NewMultiProviderFromConfig creates a MultiProvider from configuration
+// NewMultiProviderFromConfig creates a MultiProvider from configuration. Providers
+// without a FetcherPool share their fetchers through a pool created for this call.
 func NewMultiProviderFromConfig(cfg Config, logger zerolog.Logger) (*MultiProvider, error) {
 	var providers []Provider
 
+	pool := NewFetcherPool()
 	for _, providerCfg := range cfg.Providers {
+		if providerCfg.FetcherPool == nil {
+			providerCfg.FetcherPool = pool
+		}
 		provider, err := NewProviderFromConfig(providerCfg)
 		if err != nil {
 			logger.Warn().Err(err).Str("provider", providerCfg.Name).Msg("failed to create provider")
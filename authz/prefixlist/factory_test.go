@@ -1,6 +1,7 @@
 package prefixlist
 
 import (
+	"slices"
 	"testing"
 
 	"github.com/rs/zerolog"
@@ -99,6 +100,36 @@ func TestNewProviderFromConfig(t *testing.T) {
 			wantName: "aws-EC2-us-east-1",
 			wantErr:  false,
 		},
+		{
+			name: "azure with filter map",
+			config: ProviderConfig{
+				Name:    "azure",
+				Enabled: true,
+				Filter:  map[string]string{"service": "AzureCloud", "region": "westeurope"},
+			},
+			wantName: "azure-AzureCloud-westeurope",
+			wantErr:  false,
+		},
+		{
+			name: "oci with filter",
+			config: ProviderConfig{
+				Name:    "oci",
+				Enabled: true,
+				Filter:  map[string]string{"region": "uk-london-1", "tag": "OCI,OSN"},
+			},
+			wantName: "oci-OCI,OSN-uk-london-1",
+			wantErr:  false,
+		},
+		{
+			name: "digitalocean with filter",
+			config: ProviderConfig{
+				Name:    "digitalocean",
+				Enabled: true,
+				Filter:  map[string]string{"country": "NL,DE"},
+			},
+			wantName: "digitalocean-NL,DE",
+			wantErr:  false,
+		},
 		{
 			name: "google with filter",
 			config: ProviderConfig{
@@ -128,6 +159,71 @@ func TestNewProviderFromConfig(t *testing.T) {
 			wantName: "fastly",
 			wantErr:  false,
 		},
+		{
+			name: "akamai with url",
+			config: ProviderConfig{
+				Name:    "akamai",
+				Enabled: true,
+				Filter:  map[string]string{"url": "https://example.com/akamai.txt"},
+			},
+			wantName: "akamai",
+			wantErr:  false,
+		},
+		{
+			name: "akamai without url",
+			config: ProviderConfig{
+				Name:    "akamai",
+				Enabled: true,
+			},
+			wantErr: true,
+		},
+		{
+			name: "custom with url and path",
+			config: ProviderConfig{
+				Name:    "custom",
+				Enabled: true,
+				Filter:  map[string]string{"name": "corp-vpn", "url": "https://example.com/prefixes.json", "path": "prefixes.#.cidr"},
+			},
+			wantName: "custom-corp-vpn",
+			wantErr:  false,
+		},
+		{
+			name: "custom text format",
+			config: ProviderConfig{
+				Name:    "custom",
+				Enabled: true,
+				Filter:  map[string]string{"name": "stripe", "url": "https://stripe.com/files/ips/ips_webhooks.txt", "format": "text"},
+			},
+			wantName: "custom-stripe",
+			wantErr:  false,
+		},
+		{
+			name: "custom csv format",
+			config: ProviderConfig{
+				Name:    "custom",
+				Enabled: true,
+				Filter:  map[string]string{"url": "https://example.com/ips.csv", "format": "csv", "column": "cidr"},
+			},
+			wantName: "custom",
+			wantErr:  false,
+		},
+		{
+			name: "custom unknown format",
+			config: ProviderConfig{
+				Name:    "custom",
+				Enabled: true,
+				Filter:  map[string]string{"url": "https://example.com/ips.xml", "format": "xml"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "custom without url",
+			config: ProviderConfig{
+				Name:    "custom",
+				Enabled: true,
+			},
+			wantErr: true,
+		},
 		{
 			name: "hetzner",
 			config: ProviderConfig{
@@ -168,6 +264,48 @@ func TestNewProviderFromConfig(t *testing.T) {
 	}
 }
 
+func TestNewProviderFromConfig_CacheJitter(t *testing.T) {
+	provider, err := NewProviderFromConfig(ProviderConfig{
+		Name:        "github",
+		Enabled:     true,
+		CacheJitter: 0.25,
+	})
+	require.NoError(t, err)
+
+	gh, ok := provider.(*GitHubProvider)
+	require.True(t, ok)
+	assert.Equal(t, 0.25, gh.fetcher.config.Jitter)
+}
+
+func TestNewProviderFromConfig_CacheDir(t *testing.T) {
+	dir := t.TempDir()
+	provider, err := NewProviderFromConfig(ProviderConfig{
+		Name:     "github",
+		Enabled:  true,
+		CacheDir: dir,
+	})
+	require.NoError(t, err)
+
+	gh, ok := provider.(*GitHubProvider)
+	require.True(t, ok)
+	assert.Equal(t, dir, gh.fetcher.config.Dir)
+}
+
+func TestNewProviderFromConfig_RequestOptions(t *testing.T) {
+	provider, err := NewProviderFromConfig(ProviderConfig{
+		Name:      "github",
+		Enabled:   true,
+		UserAgent: "my-service/1.0",
+		Headers:   map[string]string{"x-contact": "ops@example.com"},
+	})
+	require.NoError(t, err)
+
+	gh, ok := provider.(*GitHubProvider)
+	require.True(t, ok)
+	assert.Equal(t, "my-service/1.0", gh.fetcher.config.UserAgent)
+	assert.Equal(t, "ops@example.com", gh.fetcher.config.Headers.Get("X-Contact"))
+}
+
 func TestNewMultiProviderFromConfig(t *testing.T) {
 	logger := zerolog.Nop()
 
@@ -221,3 +359,87 @@ func TestNewMultiProviderFromConfig(t *testing.T) {
 		assert.Contains(t, err.Error(), "no valid providers")
 	})
 }
+
+func TestParseProviderRef(t *testing.T) {
+	tests := []struct {
+		ref     string
+		want    ProviderConfig
+		wantErr bool
+	}{
+		{ref: "cloudflare", want: ProviderConfig{Name: "cloudflare", Enabled: true}},
+		{ref: "github?service=hooks", want: ProviderConfig{Name: "github", Enabled: true, Filter: map[string]string{"service": "hooks"}}},
+		{
+			ref:  "google?scope=us-central1,europe-west1&service=Google%20Cloud",
+			want: ProviderConfig{Name: "google", Enabled: true, Filter: map[string]string{"scope": "us-central1,europe-west1", "service": "Google Cloud"}},
+		},
+		{ref: "", wantErr: true},
+		{ref: "?service=hooks", wantErr: true},
+		{ref: "github?service=hooks&service=actions", wantErr: true},
+		{ref: "github?service=%zz", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			got, err := ParseProviderRef(tt.ref)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+
+			_, err = NewProviderFromConfig(got)
+			assert.NoError(t, err)
+		})
+	}
+}
+
+// acmeProvider is a third-party provider registered by an application
+type acmeProvider struct {
+	*HTTPTextProvider
+}
+
+func TestRegisterProvider(t *testing.T) {
+	RegisterProvider("Acme", func(cfg ProviderConfig) (Provider, error) {
+		return &acmeProvider{
+			HTTPTextProvider: NewHTTPTextProvider("acme-"+cfg.Filter["region"], "https://acme.example.com/ips.txt", CacheConfig{}),
+		}, nil
+	})
+	t.Cleanup(func() {
+		providerRegistryMu.Lock()
+		defer providerRegistryMu.Unlock()
+		delete(providerRegistry, "acme")
+	})
+
+	assert.Contains(t, RegisteredProviders(), "acme")
+	assert.True(t, slices.IsSorted(RegisteredProviders()))
+
+	multiProvider, err := NewMultiProviderFromConfig(Config{
+		Providers: []ProviderConfig{
+			{
+				Name:    "ACME",
+				Enabled: true,
+				Filter:  map[string]string{"region": "eu"},
+				Headers: map[string]string{"X-Contact": "ops@example.com"},
+			},
+			{
+				Name:    "gitlab",
+				Enabled: true,
+			},
+		},
+	}, zerolog.Nop())
+	require.NoError(t, err)
+	require.Len(t, multiProvider.providers, 2)
+
+	acme, ok := multiProvider.providers[0].(*acmeProvider)
+	require.True(t, ok)
+	assert.Equal(t, "acme-eu", acme.Name())
+	assert.Equal(t, "ops@example.com", acme.fetcher.config.Headers.Get("X-Contact"))
+}
+
+func TestNewProviderFromConfig_UnknownProvider(t *testing.T) {
+	_, err := NewProviderFromConfig(ProviderConfig{Name: "unknown", Enabled: true})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown provider: unknown")
+	assert.Contains(t, err.Error(), "github")
+}
@@ -0,0 +1,137 @@
+package prefixlist
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"weak"
+)
+
+// Kinds of fetcher that can be shared through a FetcherPool, by how they decode the
+// response body. Fetchers with a custom fetch function cannot be shared.
+const (
+	fetcherKindJSON  = "json"
+	fetcherKindBytes = "bytes"
+	fetcherKindText  = "text"
+	fetcherKindCSV   = "csv"
+)
+
+// FetcherPool shares CachingFetchers between consumers of the same upstream URL, such as
+// several providers filtering the same AWS ranges or several ACLs built from the same
+// configuration, so that they make one request and keep one cache entry. Concurrent Gets
+// on a shared fetcher wait for a single fetch. Providers created from configuration use
+// the pool in ProviderConfig.FetcherPool.
+//
+// Fetchers are only shared when they decode the URL into the same type in the same way
+// and have the same CacheConfig, including the ObjectClient for the URL's scheme, so
+// sharing never changes what a consumer fetches or how long it is cached. Metrics of a
+// shared fetcher are labelled with the provider that set them last. A pool does not keep
+// fetchers alive: once no consumer holds a fetcher it is removed from the pool.
+type FetcherPool struct {
+	mu       sync.Mutex
+	fetchers map[fetcherPoolKey]any // weak.Pointer[CachingFetcher[T]] for the key's type
+}
+
+// fetcherPoolKey identifies fetchers that can be shared
+type fetcherPoolKey struct {
+	typ          reflect.Type
+	kind         string
+	url          string
+	staticExpiry time.Duration
+	returnStale  bool
+	maxStale     time.Duration
+	jitter       float64
+	client       *http.Client
+	userAgent    string
+	headers      string
+	dir          string
+	objectClient ObjectClient
+}
+
+// NewFetcherPool creates an empty FetcherPool.
+func NewFetcherPool() *FetcherPool {
+	return &FetcherPool{fetchers: make(map[fetcherPoolKey]any)}
+}
+
+// PooledCachingFetcher returns the fetcher in pool for url, type T and config, creating
+// it with NewCachingFetcher if there is none.
+func PooledCachingFetcher[T any](pool *FetcherPool, url string, config CacheConfig) *CachingFetcher[T] {
+	return shareFetcher(pool, NewCachingFetcher[T](url, config))
+}
+
+// shareFetcher returns the fetcher in pool equivalent to f, adding f if there is none.
+// Fetchers with a custom fetch function, or a nil pool, are returned unchanged.
+func shareFetcher[T any](pool *FetcherPool, f *CachingFetcher[T]) *CachingFetcher[T] {
+	if pool == nil || f.kind == "" {
+		return f
+	}
+	key := f.poolKey()
+	if key.objectClient != nil && !reflect.TypeOf(key.objectClient).Comparable() {
+		return f
+	}
+
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	if existing, ok := pool.fetchers[key].(weak.Pointer[CachingFetcher[T]]); ok {
+		if shared := existing.Value(); shared != nil {
+			return shared
+		}
+	}
+
+	ptr := weak.Make(f)
+	pool.fetchers[key] = ptr
+	runtime.AddCleanup(f, pool.remove, fetcherPoolEntry{key: key, ptr: ptr})
+	return f
+}
+
+// fetcherPoolEntry identifies a pool entry to remove once its fetcher is unreachable
+type fetcherPoolEntry struct {
+	key fetcherPoolKey
+	ptr any
+}
+
+// remove removes an entry unless it has since been replaced
+func (pool *FetcherPool) remove(entry fetcherPoolEntry) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	if pool.fetchers[entry.key] == entry.ptr {
+		delete(pool.fetchers, entry.key)
+	}
+}
+
+// poolKey returns the key identifying fetchers that can share f's cache
+func (f *CachingFetcher[T]) poolKey() fetcherPoolKey {
+	config := f.requestConfig()
+
+	var objectClient ObjectClient
+	if u, err := url.Parse(f.url); err == nil {
+		objectClient, _ = config.objectClient(u.Scheme)
+	}
+
+	var headers []string
+	for name, values := range config.Headers {
+		headers = append(headers, name+": "+strings.Join(values, "\x00"))
+	}
+	slices.Sort(headers)
+
+	return fetcherPoolKey{
+		typ:          reflect.TypeFor[T](),
+		kind:         f.kind,
+		url:          f.url,
+		staticExpiry: config.StaticExpiry,
+		returnStale:  config.ReturnStale,
+		maxStale:     config.MaxStale,
+		jitter:       config.Jitter,
+		client:       config.Client,
+		userAgent:    config.UserAgent,
+		headers:      strings.Join(headers, "\n"),
+		dir:          config.Dir,
+		objectClient: objectClient,
+	}
+}
@@ -0,0 +1,108 @@
+package prefixlist
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetcherPool(t *testing.T) {
+	pool := NewFetcherPool()
+	config := CacheConfig{StaticExpiry: time.Hour}
+
+	a := PooledCachingFetcher[testData](pool, "https://example.com/a.json", config)
+	assert.Same(t, a, PooledCachingFetcher[testData](pool, "https://example.com/a.json", config))
+
+	assert.NotSame(t, a, PooledCachingFetcher[testData](pool, "https://example.com/b.json", config), "different URL")
+	assert.NotSame(t, a, PooledCachingFetcher[testData](pool, "https://example.com/a.json", CacheConfig{StaticExpiry: time.Minute}), "different config")
+	assert.NotSame(t, a, PooledCachingFetcher[testData](pool, "https://example.com/a.json", CacheConfig{
+		StaticExpiry: time.Hour,
+		Headers:      http.Header{"Authorization": []string{"Bearer token"}},
+	}), "different headers")
+
+	// A fetcher decoding into another type has its own entry
+	other := PooledCachingFetcher[map[string]any](pool, "https://example.com/a.json", config)
+	assert.Same(t, other, PooledCachingFetcher[map[string]any](pool, "https://example.com/a.json", config))
+	assert.Same(t, a, PooledCachingFetcher[testData](pool, "https://example.com/a.json", config))
+
+	// Fetchers with a custom fetch function are never shared
+	custom := NewCachingFetcherWithFunc[testData]("https://example.com/a.json", config, nil)
+	assert.Same(t, custom, shareFetcher(pool, custom))
+	assert.Same(t, custom, shareFetcher(nil, custom))
+}
+
+func TestFetcherPoolSingleRequest(t *testing.T) {
+	requests := atomic.Int32{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		time.Sleep(50 * time.Millisecond)
+		_, _ = w.Write([]byte(`{"message": "shared", "count": 1}`))
+	}))
+	defer server.Close()
+
+	pool := NewFetcherPool()
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Go(func() {
+			fetcher := PooledCachingFetcher[testData](pool, server.URL, CacheConfig{StaticExpiry: time.Hour})
+			data, _, err := fetcher.Get(context.Background())
+			assert.NoError(t, err)
+			assert.Equal(t, "shared", data.Message)
+		})
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), requests.Load())
+}
+
+func TestFetcherPoolReleasesUnusedFetchers(t *testing.T) {
+	pool := NewFetcherPool()
+	PooledCachingFetcher[testData](pool, "https://example.com/a.json", CacheConfig{})
+
+	require.Eventually(t, func() bool {
+		runtime.GC()
+		pool.mu.Lock()
+		defer pool.mu.Unlock()
+		return len(pool.fetchers) == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestNewProviderFromConfig_SharesFetchers(t *testing.T) {
+	pool := NewFetcherPool()
+	ec2, err := NewProviderFromConfig(ProviderConfig{Name: "aws", Enabled: true, Filter: map[string]string{"service": "EC2"}, FetcherPool: pool})
+	require.NoError(t, err)
+	s3, err := NewProviderFromConfig(ProviderConfig{Name: "aws", Enabled: true, Filter: map[string]string{"service": "S3"}, FetcherPool: pool})
+	require.NoError(t, err)
+	jittered, err := NewProviderFromConfig(ProviderConfig{Name: "aws", Enabled: true, CacheJitter: 0.1, FetcherPool: pool})
+	require.NoError(t, err)
+	unpooled, err := NewProviderFromConfig(ProviderConfig{Name: "aws", Enabled: true})
+	require.NoError(t, err)
+
+	assert.Same(t, ec2.(*AWSProvider).fetcher, s3.(*AWSProvider).fetcher)
+	assert.NotSame(t, ec2.(*AWSProvider).fetcher, jittered.(*AWSProvider).fetcher)
+	assert.NotSame(t, ec2.(*AWSProvider).fetcher, unpooled.(*AWSProvider).fetcher)
+}
+
+func TestNewMultiProviderFromConfig_SharesFetchers(t *testing.T) {
+	cfg := Config{Providers: []ProviderConfig{
+		{Name: "aws", Enabled: true, Filter: map[string]string{"service": "EC2"}},
+		{Name: "aws", Enabled: true, Filter: map[string]string{"service": "S3"}},
+	}}
+
+	first, err := NewMultiProviderFromConfig(cfg, zerolog.Nop())
+	require.NoError(t, err)
+	second, err := NewMultiProviderFromConfig(cfg, zerolog.Nop())
+	require.NoError(t, err)
+
+	assert.Same(t, first.providers[0].(*AWSProvider).fetcher, first.providers[1].(*AWSProvider).fetcher)
+	assert.NotSame(t, first.providers[0].(*AWSProvider).fetcher, second.providers[0].(*AWSProvider).fetcher)
+}
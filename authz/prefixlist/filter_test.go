@@ -1,9 +1,11 @@
 package prefixlist
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestGoogleProviderWithFilters(t *testing.T) {
@@ -100,6 +102,145 @@ func TestAtlassianProviderWithFilters(t *testing.T) {
 	}
 }
 
+func TestOCIProviderWithFilters(t *testing.T) {
+	var data ociIPRanges
+	require.NoError(t, json.Unmarshal([]byte(`{"last_updated_timestamp": "2026-01-05T20:21:06.221347", "regions": [
+		{"region": "uk-london-1", "cidrs": [
+			{"cidr": "132.145.0.0/16", "tags": ["OCI"]},
+			{"cidr": "134.70.64.0/22", "tags": ["OBJECT_STORAGE", "OSN"]}
+		]},
+		{"region": "us-ashburn-1", "cidrs": [
+			{"cidr": "129.213.0.0/16", "tags": ["OCI"]},
+			{"cidr": "134.70.24.0/21", "tags": ["OSN"]},
+			{"cidr": "192.29.0.0/16"}
+		]}
+	]}`), &data))
+
+	tests := []struct {
+		name         string
+		regions      []string
+		tags         []string
+		expectedName string
+		expected     []string
+	}{
+		{
+			name:         "no filters",
+			expectedName: "oci",
+			expected:     []string{"132.145.0.0/16", "134.70.64.0/22", "129.213.0.0/16", "134.70.24.0/21", "192.29.0.0/16"},
+		},
+		{
+			name:         "with region",
+			regions:      []string{"UK-London-1"},
+			expectedName: "oci-UK-London-1",
+			expected:     []string{"132.145.0.0/16", "134.70.64.0/22"},
+		},
+		{
+			name:         "with tag",
+			tags:         []string{"osn"},
+			expectedName: "oci-osn",
+			expected:     []string{"134.70.64.0/22", "134.70.24.0/21"},
+		},
+		{
+			name:         "with region and tags",
+			regions:      []string{"us-ashburn-1"},
+			tags:         []string{"OCI", "OBJECT_STORAGE"},
+			expectedName: "oci-OCI,OBJECT_STORAGE-us-ashburn-1",
+			expected:     []string{"129.213.0.0/16"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := NewOCIProvider(tt.regions, tt.tags)
+			assert.Equal(t, tt.expectedName, provider.Name())
+
+			prefixes, err := provider.transformOCIRanges(data)
+			require.NoError(t, err)
+			var got []string
+			for _, prefix := range prefixes {
+				got = append(got, prefix.String())
+			}
+			assert.Equal(t, tt.expected, got)
+		})
+	}
+}
+
+func TestCustomProviderPaths(t *testing.T) {
+	var data any
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"prefixes": [
+			{"ip_prefix": "192.0.2.0/24", "service": "vpn"},
+			{"ip_prefix": "198.51.100.0/24", "service": "ci"}
+		],
+		"groups": {"egress.v6": ["2001:db8::/32"], "count": 1},
+		"ipv4": ["203.0.113.0/24"]
+	}`), &data))
+
+	tests := []struct {
+		name     string
+		path     string
+		expected []string
+		wantErr  bool
+	}{
+		{
+			name:     "every element",
+			path:     "prefixes.#.ip_prefix",
+			expected: []string{"192.0.2.0/24", "198.51.100.0/24"},
+		},
+		{
+			name:     "index",
+			path:     "prefixes.1.ip_prefix",
+			expected: []string{"198.51.100.0/24"},
+		},
+		{
+			name:     "array of strings",
+			path:     "ipv4",
+			expected: []string{"203.0.113.0/24"},
+		},
+		{
+			name:     "escaped dot",
+			path:     `groups.egress\.v6`,
+			expected: []string{"2001:db8::/32"},
+		},
+		{
+			name: "missing key",
+			path: "ipv6",
+		},
+		{
+			name:    "not a string",
+			path:    "groups.count",
+			wantErr: true,
+		},
+		{
+			name:    "not an index",
+			path:    "ipv4.first",
+			wantErr: true,
+		},
+		{
+			name:    "empty path on object",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := NewCustomProvider("", "https://example.com/prefixes.json", tt.path)
+
+			prefixes, err := provider.transformCustom(data)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			var got []string
+			for _, prefix := range prefixes {
+				got = append(got, prefix.String())
+			}
+			assert.Equal(t, tt.expected, got)
+		})
+	}
+}
+
 func TestContains(t *testing.T) {
 	tests := []struct {
 		name     string
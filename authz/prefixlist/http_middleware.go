@@ -0,0 +1,116 @@
+package prefixlist
+
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/rs/zerolog"
+
+	"github.com/dioad/net/http/clientip"
+	diojson "github.com/dioad/net/http/json"
+)
+
+const (
+	// ModeAllow only admits requests whose client IP is in the provider's prefixes.
+	ModeAllow = "allow"
+	// ModeDeny rejects requests whose client IP is in the provider's prefixes.
+	ModeDeny = "deny"
+)
+
+// HTTPMiddlewareOption configures the middleware returned by NewHTTPMiddleware.
+type HTTPMiddlewareOption func(*httpMiddleware)
+
+// WithTrustedProxies sets the proxies whose X-Forwarded-For (or, when absent, RFC 7239
+// Forwarded) header is trusted.
+// Without trusted proxies the client IP is always taken from the request's RemoteAddr.
+func WithTrustedProxies(prefixes ...netip.Prefix) HTTPMiddlewareOption {
+	return func(m *httpMiddleware) {
+		m.trust.TrustedProxies = append(m.trust.TrustedProxies, prefixes...)
+	}
+}
+
+// WithTrustConfig sets how the client IP is resolved from forwarded headers, using the
+// same rules as the diohttp server's ClientIP.
+func WithTrustConfig(trust clientip.TrustConfig) HTTPMiddlewareOption {
+	return func(m *httpMiddleware) {
+		m.trust = trust
+	}
+}
+
+// WithMiddlewareLogger sets the logger used to record rejected requests.
+func WithMiddlewareLogger(logger zerolog.Logger) HTTPMiddlewareOption {
+	return func(m *httpMiddleware) {
+		m.logger = logger
+	}
+}
+
+type httpMiddleware struct {
+	provider Provider
+	allow    bool
+	trust    clientip.TrustConfig
+	logger   zerolog.Logger
+}
+
+// NewHTTPMiddleware returns middleware that allows or denies requests based on
+// whether the client IP is contained in the provider's prefixes.
+//
+// In ModeAllow only matching clients are admitted; in ModeDeny matching clients are
+// rejected. Rejected requests receive a JSON 403 response. Membership is checked on
+// every request, so prefixes refreshed by the provider take effect immediately.
+func NewHTTPMiddleware(provider Provider, mode string, opts ...HTTPMiddlewareOption) (func(http.Handler) http.Handler, error) {
+	if provider == nil {
+		return nil, fmt.Errorf("provider is required")
+	}
+
+	m := &httpMiddleware{
+		provider: provider,
+		logger:   zerolog.Nop(),
+	}
+
+	switch strings.ToLower(mode) {
+	case ModeAllow:
+		m.allow = true
+	case ModeDeny:
+		m.allow = false
+	default:
+		return nil, fmt.Errorf("invalid mode %q: must be %q or %q", mode, ModeAllow, ModeDeny)
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return m.wrap, nil
+}
+
+func (m *httpMiddleware) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		addr, ok := m.clientAddr(r)
+		if !ok {
+			m.logger.Warn().
+				Str("remoteAddr", r.RemoteAddr).
+				Msg("unable to determine client IP, rejecting request")
+			diojson.NewResponse(w).ForbiddenWithMessage(http.StatusText(http.StatusForbidden))
+			return
+		}
+
+		if m.provider.Contains(addr) != m.allow {
+			m.logger.Warn().
+				Str("clientIP", addr.String()).
+				Str("provider", m.provider.Name()).
+				Msg("request rejected by prefix list")
+			diojson.NewResponse(w).ForbiddenWithMessage(http.StatusText(http.StatusForbidden))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientAddr returns the client IP for the request, resolved by clientip.ClientAddr with
+// the middleware's trust configuration.
+func (m *httpMiddleware) clientAddr(r *http.Request) (netip.Addr, bool) {
+	return clientip.ClientAddr(r, m.trust)
+}
@@ -0,0 +1,107 @@
+package prefixlist
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dioad/net/http/clientip"
+)
+
+func TestNewHTTPMiddleware(t *testing.T) {
+	provider := &mockProvider{name: "test", prefixes: []string{"192.0.2.0/24"}}
+	proxies := WithTrustedProxies(netip.MustParsePrefix("10.0.0.0/8"))
+	realIP := WithTrustConfig(clientip.TrustConfig{
+		TrustedProxies: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+		Headers:        []string{clientip.HeaderXRealIP},
+	})
+
+	tests := []struct {
+		name       string
+		mode       string
+		opts       []HTTPMiddlewareOption
+		remoteAddr string
+		xff        string
+		forwarded  string
+		realIP     string
+		wantStatus int
+	}{
+		{name: "allow mode member", mode: ModeAllow, remoteAddr: "192.0.2.10:1234", wantStatus: http.StatusOK},
+		{name: "allow mode non-member", mode: ModeAllow, remoteAddr: "198.51.100.1:1234", wantStatus: http.StatusForbidden},
+		{name: "deny mode member", mode: ModeDeny, remoteAddr: "192.0.2.10:1234", wantStatus: http.StatusForbidden},
+		{name: "deny mode non-member", mode: ModeDeny, remoteAddr: "198.51.100.1:1234", wantStatus: http.StatusOK},
+		{name: "untrusted peer ignores xff", mode: ModeAllow, remoteAddr: "198.51.100.1:1234", xff: "192.0.2.10", wantStatus: http.StatusForbidden},
+		{name: "trusted proxy uses xff", mode: ModeAllow, opts: []HTTPMiddlewareOption{proxies}, remoteAddr: "10.0.0.1:1234", xff: "192.0.2.10", wantStatus: http.StatusOK},
+		{name: "spoofed leftmost xff ignored", mode: ModeAllow, opts: []HTTPMiddlewareOption{proxies}, remoteAddr: "10.0.0.1:1234", xff: "192.0.2.10, 198.51.100.1", wantStatus: http.StatusForbidden},
+		{name: "chained trusted proxies", mode: ModeAllow, opts: []HTTPMiddlewareOption{proxies}, remoteAddr: "10.0.0.1:1234", xff: "192.0.2.10, 10.0.0.2", wantStatus: http.StatusOK},
+		{name: "invalid xff uses last trusted hop", mode: ModeAllow, opts: []HTTPMiddlewareOption{proxies}, remoteAddr: "10.0.0.1:1234", xff: "not-an-ip", wantStatus: http.StatusForbidden},
+		{name: "trusted proxy uses forwarded", mode: ModeAllow, opts: []HTTPMiddlewareOption{proxies}, remoteAddr: "10.0.0.1:1234", forwarded: `for="192.0.2.10:8080";proto=https`, wantStatus: http.StatusOK},
+		{name: "forwarded quoted ipv6", mode: ModeDeny, opts: []HTTPMiddlewareOption{proxies}, remoteAddr: "10.0.0.1:1234", forwarded: `for="[2001:db8::1]:443"`, wantStatus: http.StatusOK},
+		{name: "forwarded element without for uses last trusted hop", mode: ModeAllow, opts: []HTTPMiddlewareOption{proxies}, remoteAddr: "10.0.0.1:1234", forwarded: "for=192.0.2.10, proto=https", wantStatus: http.StatusForbidden},
+		{name: "xff preferred over forwarded", mode: ModeAllow, opts: []HTTPMiddlewareOption{proxies}, remoteAddr: "10.0.0.1:1234", xff: "198.51.100.1", forwarded: "for=192.0.2.10", wantStatus: http.StatusForbidden},
+		{name: "trust config headers", mode: ModeAllow, opts: []HTTPMiddlewareOption{realIP}, remoteAddr: "10.0.0.1:1234", xff: "198.51.100.1", realIP: "192.0.2.10", wantStatus: http.StatusOK},
+		{name: "ipv4 mapped ipv6", mode: ModeAllow, remoteAddr: "[::ffff:192.0.2.10]:1234", wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mw, err := NewHTTPMiddleware(provider, tt.mode, tt.opts...)
+			require.NoError(t, err)
+
+			handler := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.xff != "" {
+				req.Header.Set("X-Forwarded-For", tt.xff)
+			}
+			if tt.forwarded != "" {
+				req.Header.Set("Forwarded", tt.forwarded)
+			}
+			if tt.realIP != "" {
+				req.Header.Set("X-Real-IP", tt.realIP)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantStatus == http.StatusForbidden {
+				assert.Contains(t, rec.Header().Get("Content-Type"), "application/json")
+			}
+		})
+	}
+}
+
+func TestNewHTTPMiddleware_Refresh(t *testing.T) {
+	provider := &mockProvider{name: "test", prefixes: []string{"192.0.2.0/24"}}
+	mw, err := NewHTTPMiddleware(provider, ModeAllow)
+	require.NoError(t, err)
+
+	handler := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	serve := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "198.51.100.1:1234"
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusForbidden, serve())
+
+	provider.prefixes = append(provider.prefixes, "198.51.100.0/24")
+	assert.Equal(t, http.StatusOK, serve())
+}
+
+func TestNewHTTPMiddleware_Errors(t *testing.T) {
+	_, err := NewHTTPMiddleware(nil, ModeAllow)
+	assert.Error(t, err)
+
+	_, err = NewHTTPMiddleware(&mockProvider{name: "test"}, "maybe")
+	assert.Error(t, err)
+}
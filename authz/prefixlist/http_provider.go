@@ -1,39 +1,30 @@
 package prefixlist
 
 import (
+	"bytes"
 	"context"
+	"net/http"
 	"net/netip"
+	"time"
 )
 
 // TransformFunc is a function that transforms fetched data into a list of prefixes
 type TransformFunc[T any] func(T) ([]netip.Prefix, error)
 
-// HTTPJSONProvider is a generic provider that fetches JSON data and transforms it into prefixes
-type HTTPJSONProvider[T any] struct {
+// cachedProvider holds the name, fetcher and transform shared by the HTTP providers, and
+// implements Provider and the optional setters that NewProviderFromConfig and
+// MultiProvider apply to providers backed by a CachingFetcher
+type cachedProvider[T any] struct {
 	name      string
 	fetcher   *CachingFetcher[T]
 	transform TransformFunc[T]
 }
 
-// NewHTTPJSONProvider creates a new HTTP JSON-based provider
-// Parameters:
-//   - name: the name of the provider (e.g., "github", "aws")
-//   - url: the HTTP endpoint to fetch from
-//   - config: caching configuration
-//   - transform: function to transform the JSON response into prefixes
-func NewHTTPJSONProvider[T any](name, url string, config CacheConfig, transform TransformFunc[T]) *HTTPJSONProvider[T] {
-	return &HTTPJSONProvider[T]{
-		name:      name,
-		fetcher:   NewCachingFetcher[T](url, config),
-		transform: transform,
-	}
-}
-
-func (p *HTTPJSONProvider[T]) Name() string {
+func (p *cachedProvider[T]) Name() string {
 	return p.name
 }
 
-func (p *HTTPJSONProvider[T]) Prefixes(ctx context.Context) ([]netip.Prefix, error) {
+func (p *cachedProvider[T]) Prefixes(ctx context.Context) ([]netip.Prefix, error) {
 	data, _, err := p.fetcher.Get(ctx)
 	if err != nil {
 		return nil, err
@@ -42,7 +33,7 @@ func (p *HTTPJSONProvider[T]) Prefixes(ctx context.Context) ([]netip.Prefix, err
 	return p.transform(data)
 }
 
-func (p *HTTPJSONProvider[T]) Contains(addr netip.Addr) bool {
+func (p *cachedProvider[T]) Contains(addr netip.Addr) bool {
 	prefixes, err := p.Prefixes(context.Background())
 	if err != nil {
 		return false
@@ -55,47 +46,93 @@ func (p *HTTPJSONProvider[T]) Contains(addr netip.Addr) bool {
 	return false
 }
 
+func (p *cachedProvider[T]) setCacheJitter(jitter float64) {
+	p.fetcher.setJitter(jitter)
+}
+
+func (p *cachedProvider[T]) refreshCache(ctx context.Context) error {
+	return p.fetcher.Refresh(ctx)
+}
+
+func (p *cachedProvider[T]) cacheExpiry() (time.Time, bool) {
+	_, expiresAt, hasData := p.fetcher.GetCacheInfo()
+	return expiresAt, hasData
+}
+
+func (p *cachedProvider[T]) lastCacheResult() (CacheResult, bool) {
+	return p.fetcher.lastCacheResult()
+}
+
+func (p *cachedProvider[T]) shareFetcher(pool *FetcherPool) {
+	p.fetcher = shareFetcher(pool, p.fetcher)
+}
+
+func (p *cachedProvider[T]) setMetrics(metrics *Metrics) {
+	p.fetcher.setMetrics(metrics, p.name)
+}
+
+func (p *cachedProvider[T]) setCacheDir(dir string) {
+	p.fetcher.setDir(dir)
+}
+
+func (p *cachedProvider[T]) setRequestOptions(userAgent string, headers http.Header) {
+	p.fetcher.setRequestOptions(userAgent, headers)
+}
+
+func (p *cachedProvider[T]) setObjectClients(clients map[string]ObjectClient) {
+	p.fetcher.setObjectClients(clients)
+}
+
+// HTTPJSONProvider is a generic provider that fetches JSON data and transforms it into prefixes
+type HTTPJSONProvider[T any] struct {
+	cachedProvider[T]
+}
+
+// NewHTTPJSONProvider creates a new HTTP JSON-based provider
+// Parameters:
+//   - name: the name of the provider (e.g., "github", "aws")
+//   - url: the HTTP endpoint to fetch from
+//   - config: caching configuration
+//   - transform: function to transform the JSON response into prefixes
+func NewHTTPJSONProvider[T any](name, url string, config CacheConfig, transform TransformFunc[T]) *HTTPJSONProvider[T] {
+	return &HTTPJSONProvider[T]{cachedProvider[T]{
+		name:      name,
+		fetcher:   NewCachingFetcher[T](url, config),
+		transform: transform,
+	}}
+}
+
 // HTTPTextProvider is a provider for HTTP endpoints that return plain text lists of prefixes
 type HTTPTextProvider struct {
-	name    string
-	fetcher *CachingFetcher[[]string]
+	cachedProvider[[]string]
 }
 
 // NewHTTPTextProvider creates a new HTTP text-based provider
-// The endpoint is expected to return a plain text list of CIDR ranges (one per line)
+// The endpoint is expected to return a plain text list of CIDR ranges or addresses (one per line)
 func NewHTTPTextProvider(name, url string, config CacheConfig) *HTTPTextProvider {
-	return &HTTPTextProvider{
+	return &HTTPTextProvider{cachedProvider[[]string]{
 		name: name,
-		fetcher: NewCachingFetcherWithFunc[[]string](
-			url,
-			config,
-			FetchTextLines,
-		),
-	}
-}
-
-func (p *HTTPTextProvider) Name() string {
-	return p.name
+		fetcher: newDecodingCachingFetcher(url, config, fetcherKindText, func(body []byte) ([]string, error) {
+			return parseTextLines(bytes.NewReader(body))
+		}),
+		transform: parsePrefixes,
+	}}
 }
 
-func (p *HTTPTextProvider) Prefixes(ctx context.Context) ([]netip.Prefix, error) {
-	cidrs, _, err := p.fetcher.Get(ctx)
-	if err != nil {
-		return nil, err
-	}
-
-	return parseCIDRs(cidrs)
+// HTTPCSVProvider is a provider for HTTP endpoints that return CSV files, such as
+// geofeeds, with a transform function that extracts the prefixes from the records
+type HTTPCSVProvider struct {
+	cachedProvider[[][]string]
 }
 
-func (p *HTTPTextProvider) Contains(addr netip.Addr) bool {
-	prefixes, err := p.Prefixes(context.Background())
-	if err != nil {
-		return false
-	}
-	for _, prefix := range prefixes {
-		if prefix.Contains(addr) {
-			return true
-		}
-	}
-	return false
+// NewHTTPCSVProvider creates a new HTTP CSV-based provider
+// The endpoint is expected to return CSV records, which transform turns into prefixes
+func NewHTTPCSVProvider(name, url string, config CacheConfig, transform TransformFunc[[][]string]) *HTTPCSVProvider {
+	return &HTTPCSVProvider{cachedProvider[[][]string]{
+		name: name,
+		fetcher: newDecodingCachingFetcher(url, config, fetcherKindCSV, func(body []byte) ([][]string, error) {
+			return parseCSVRecords(bytes.NewReader(body))
+		}),
+		transform: transform,
+	}}
 }
@@ -3,8 +3,11 @@ package prefixlist
 import (
 	"net"
 	"net/netip"
+	"sync/atomic"
 
 	"github.com/rs/zerolog"
+
+	net2 "github.com/dioad/net"
 )
 
 // Listener wraps a net.Listener and filters connections based on prefix lists
@@ -12,6 +15,7 @@ type Listener struct {
 	listener net.Listener
 	provider Provider
 	logger   zerolog.Logger
+	closed   atomic.Bool
 }
 
 // NewListener creates a new prefix list filtering listener
@@ -23,11 +27,15 @@ func NewListener(listener net.Listener, provider Provider, logger zerolog.Logger
 	}
 }
 
-// Accept waits for and returns the next connection, filtering based on prefix lists
+// Accept waits for and returns the next connection, filtering based on prefix lists.
+// Once the listener is closed, Accept returns an error matching net.ErrClosed.
 func (l *Listener) Accept() (net.Conn, error) {
 	for {
 		conn, err := l.listener.Accept()
 		if err != nil {
+			if l.closed.Load() {
+				err = net2.WrapClosedErr(err)
+			}
 			return nil, err
 		}
 
@@ -70,6 +78,7 @@ func (l *Listener) Accept() (net.Conn, error) {
 
 // Close closes the underlying listener
 func (l *Listener) Close() error {
+	l.closed.Store(true)
 	return l.listener.Close()
 }
 
@@ -0,0 +1,91 @@
+package prefixlist
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics are Prometheus metrics for prefix list fetches and caches, labelled by
+// provider. Attach them to the providers of a MultiProvider with MultiProvider.SetMetrics.
+type Metrics struct {
+	// FetchDuration observes the duration of fetches from a provider's upstream.
+	FetchDuration *prometheus.HistogramVec
+	// FetchErrors counts failed fetches by provider.
+	FetchErrors *prometheus.CounterVec
+	// CacheResults counts cached data served by provider and result: "fresh" for data
+	// just fetched, "cached" for unexpired data and "stale" for expired data.
+	CacheResults *prometheus.CounterVec
+	// Prefixes is the number of prefixes fetched from each provider.
+	Prefixes *prometheus.GaugeVec
+	// CacheExpiry is the number of seconds until each provider's cached data expires, as
+	// of the last refresh. It is negative once the data has expired.
+	CacheExpiry *prometheus.GaugeVec
+}
+
+// NewMetrics creates a set of prefix list metrics. They are not collected until they are
+// registered with Register.
+func NewMetrics() *Metrics {
+	return &Metrics{
+		FetchDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "dioad_net_prefixlist_fetch_duration_seconds",
+				Help:    "Histogram of prefix list fetch durations by provider.",
+				Buckets: prometheus.ExponentialBuckets(0.05, 2, 10),
+			},
+			[]string{"provider"},
+		),
+		FetchErrors: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "dioad_net_prefixlist_fetch_errors_total",
+				Help: "Counter of failed prefix list fetches by provider.",
+			},
+			[]string{"provider"},
+		),
+		CacheResults: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "dioad_net_prefixlist_cache_results_total",
+				Help: "Counter of cached prefix list data served, by provider and result.",
+			},
+			[]string{"provider", "result"},
+		),
+		Prefixes: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "dioad_net_prefixlist_prefixes",
+				Help: "Gauge of prefixes fetched from each provider.",
+			},
+			[]string{"provider"},
+		),
+		CacheExpiry: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "dioad_net_prefixlist_cache_expiry_seconds",
+				Help: "Gauge of seconds until each provider's cached prefixes expire, as of the last refresh.",
+			},
+			[]string{"provider"},
+		),
+	}
+}
+
+// Register registers the metrics with r. Metrics registered with
+// prometheus.DefaultRegisterer are served by the /metrics endpoint of a diohttp.Server,
+// alongside its own.
+func (m *Metrics) Register(r prometheus.Registerer) {
+	r.MustRegister(
+		m.FetchDuration,
+		m.FetchErrors,
+		m.CacheResults,
+		m.Prefixes,
+		m.CacheExpiry,
+	)
+}
+
+func (m *Metrics) recordFetch(provider string, duration time.Duration, err error) {
+	m.FetchDuration.WithLabelValues(provider).Observe(duration.Seconds())
+	if err != nil {
+		m.FetchErrors.WithLabelValues(provider).Inc()
+	}
+}
+
+func (m *Metrics) recordCacheResult(provider string, result CacheResult) {
+	m.CacheResults.WithLabelValues(provider, result.String()).Inc()
+}
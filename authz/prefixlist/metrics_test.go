@@ -0,0 +1,62 @@
+package prefixlist
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	failing := atomic.Bool{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Cache-Control", "max-age=3600")
+		_, _ = w.Write([]byte(`{"addresses": ["192.0.2.0/24", "198.51.100.0/24"]}`))
+	}))
+	defer server.Close()
+
+	fastly := NewFastlyProvider()
+	fastly.fetcher.url = server.URL
+	static := &mockProvider{name: "static", prefixes: []string{"10.0.0.0/8"}}
+
+	metrics := NewMetrics()
+	metrics.Register(prometheus.NewRegistry())
+
+	m := NewMultiProvider([]Provider{fastly, static}, zerolog.Nop())
+	m.SetMetrics(metrics)
+
+	_, err := m.Prefixes(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics.FetchDuration))
+	assert.Equal(t, 0.0, testutil.ToFloat64(metrics.FetchErrors.WithLabelValues("fastly")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.CacheResults.WithLabelValues("fastly", "fresh")))
+	assert.Equal(t, 2.0, testutil.ToFloat64(metrics.Prefixes.WithLabelValues("fastly")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.Prefixes.WithLabelValues("static")))
+	assert.InDelta(t, 3600, testutil.ToFloat64(metrics.CacheExpiry.WithLabelValues("fastly")), 60)
+
+	_, err = m.Prefixes(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.CacheResults.WithLabelValues("fastly", "cached")))
+
+	failing.Store(true)
+	assert.Error(t, fastly.fetcher.Refresh(context.Background()))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.FetchErrors.WithLabelValues("fastly")))
+}
+
+func TestCacheResultString(t *testing.T) {
+	assert.Equal(t, "fresh", CacheResultFresh.String())
+	assert.Equal(t, "cached", CacheResultCached.String())
+	assert.Equal(t, "stale", CacheResultStale.String())
+	assert.Equal(t, "unknown", CacheResult(-1).String())
+}
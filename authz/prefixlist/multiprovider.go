@@ -3,25 +3,103 @@ package prefixlist
 import (
 	"context"
 	"fmt"
+	"math/rand/v2"
 	"net/netip"
+	"slices"
 	"sync"
+	"time"
 
 	"github.com/rs/zerolog"
 )
 
+// DefaultRefreshInterval is the interval used by MultiProvider.Start when none is given.
+const DefaultRefreshInterval = 5 * time.Minute
+
+// DefaultRefreshLead is how long before a provider's cache expires MultiProvider.StartScheduled
+// refreshes it when no lead is given.
+const DefaultRefreshLead = time.Minute
+
+// cacheRefresher is implemented by providers backed by a CachingFetcher
+// so that StartScheduled can refresh them ahead of their cache expiry.
+type cacheRefresher interface {
+	refreshCache(ctx context.Context) error
+	cacheExpiry() (time.Time, bool)
+}
+
+// ChangeFunc is called when a refresh changes the prefixes of a provider, with the
+// prefixes added and removed since its previous successful fetch, each sorted. The first
+// successful fetch of a provider reports all its prefixes as added.
+type ChangeFunc func(provider string, added, removed []netip.Prefix)
+
+// cacheResultReporter is implemented by providers backed by a CachingFetcher
+// so that MultiProvider.Status can report how their prefixes were last served.
+type cacheResultReporter interface {
+	lastCacheResult() (CacheResult, bool)
+}
+
+// ProviderStatus is the health and freshness of the prefixes of one provider of a
+// MultiProvider, as of its last fetch.
+type ProviderStatus struct {
+	// Name is the name of the provider.
+	Name string `json:"name"`
+	// LastFetch is when prefixes were last fetched from the provider, successfully or
+	// not. It is zero until the first fetch.
+	LastFetch time.Time `json:"last_fetch,omitzero"`
+	// LastSuccess is when prefixes were last fetched from the provider successfully.
+	LastSuccess time.Time `json:"last_success,omitzero"`
+	// LastError is the error of the last fetch, or empty if it succeeded.
+	LastError string `json:"last_error,omitempty"`
+	// CacheResult is how the provider's cache last served its prefixes: "fresh",
+	// "cached" or "stale". It is empty for providers not backed by a cache.
+	CacheResult string `json:"cache_result,omitempty"`
+	// Prefixes is the number of prefixes of the last successful fetch.
+	Prefixes int `json:"prefixes"`
+	// ExpiresAt is when the provider's cached prefixes expire. It is zero for providers
+	// not backed by a cache and until data is cached.
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+	// Stale reports whether the prefixes in use may be out of date: the last fetch
+	// failed, the cache served stale data or the cached data has expired.
+	Stale bool `json:"stale"`
+}
+
+// providerState records the outcome of fetches from a provider
+type providerState struct {
+	lastFetch   time.Time
+	lastSuccess time.Time
+	lastError   error
+	prefixes    []netip.Prefix // from the last successful fetch
+}
+
+// metricsSetter is implemented by providers backed by a CachingFetcher
+// so that MultiProvider.SetMetrics can instrument their fetches.
+type metricsSetter interface {
+	setMetrics(metrics *Metrics)
+}
+
 // MultiProvider wraps multiple providers and implements the Provider interface
 type MultiProvider struct {
 	providers []Provider
-	prefixes  []netip.Prefix
+	prefixes  *prefixSet
 	mu        sync.RWMutex
 	logger    zerolog.Logger
+
+	metrics *Metrics
+	states  []providerState // by provider index, nil until fetched
+
+	changeMu     sync.Mutex
+	onChange     []ChangeFunc
+	lastPrefixes []map[netip.Prefix]struct{} // by provider index, nil until fetched
+
+	lifecycleMu sync.Mutex
+	cancel      context.CancelFunc
+	wg          sync.WaitGroup
 }
 
 // NewMultiProvider creates a new multi-provider that wraps multiple providers
 func NewMultiProvider(providers []Provider, logger zerolog.Logger) *MultiProvider {
 	return &MultiProvider{
 		providers: providers,
-		prefixes:  []netip.Prefix{},
+		prefixes:  newPrefixSetBuilder().build(),
 		logger:    logger,
 	}
 }
@@ -37,53 +115,218 @@ func (m *MultiProvider) Name() string {
 	return fmt.Sprintf("multiprovider-%d-providers", len(m.providers))
 }
 
-// Prefixes fetches prefixes from all wrapped providers
+// Prefixes fetches prefixes from all wrapped providers and caches them, deduplicated,
+// for Contains and Match. A provider whose fetch fails keeps contributing the prefixes
+// of its last successful fetch, so a transient failure neither drops allowed prefixes
+// nor lets denied ones through. An error is returned if a fetch fails and no other
+// provider returns any prefixes; the cached prefixes are updated regardless.
 func (m *MultiProvider) Prefixes(ctx context.Context) ([]netip.Prefix, error) {
-	var allPrefixes []netip.Prefix
+	builder := newPrefixSetBuilder()
 	var fetchErrors []error
+	fetched := 0
 
-	for _, provider := range m.providers {
+	for i, provider := range m.providers {
 		prefixes, err := provider.Prefixes(ctx)
+		retained := m.recordProviderState(i, prefixes, err)
 		if err != nil {
 			m.logger.Error().
 				Err(err).
 				Str("provider", provider.Name()).
+				Int("retained", len(retained)).
 				Msg("failed to fetch prefixes")
 			fetchErrors = append(fetchErrors, fmt.Errorf("%s: %w", provider.Name(), err))
+			builder.add(provider.Name(), retained)
 			continue
 		}
+		fetched += len(prefixes)
 
 		m.logger.Debug().
 			Str("provider", provider.Name()).
 			Int("count", len(prefixes)).
 			Msg("fetched prefixes")
 
-		allPrefixes = append(allPrefixes, prefixes...)
+		builder.add(provider.Name(), prefixes)
+		m.notifyChange(i, provider.Name(), prefixes)
+		m.recordProviderMetrics(provider, len(prefixes))
 	}
 
 	// Cache the result
+	set := builder.build()
 	m.mu.Lock()
-	m.prefixes = allPrefixes
+	m.prefixes = set
 	m.mu.Unlock()
 
-	if len(fetchErrors) > 0 && len(allPrefixes) == 0 {
+	if len(fetchErrors) > 0 && fetched == 0 {
 		return nil, fmt.Errorf("all providers failed: %v", fetchErrors)
 	}
 
-	return allPrefixes, nil
+	return slices.Clone(set.prefixes), nil
 }
 
-// Contains checks if an IP address is in any of the cached prefix lists
-func (m *MultiProvider) Contains(addr netip.Addr) bool {
+// SetMetrics records metrics for the wrapped providers: prefix counts and cache expiry
+// for all of them, and fetch and cache metrics for those backed by a cache. It should be
+// called before the providers are first used.
+func (m *MultiProvider) SetMetrics(metrics *Metrics) {
+	m.mu.Lock()
+	m.metrics = metrics
+	m.mu.Unlock()
+
+	for _, provider := range m.providers {
+		if s, ok := provider.(metricsSetter); ok {
+			s.setMetrics(metrics)
+		}
+	}
+}
+
+// recordProviderState records the outcome of a fetch from the provider at index i and
+// returns the prefixes of its last successful fetch
+func (m *MultiProvider) recordProviderState(i int, prefixes []netip.Prefix, err error) []netip.Prefix {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.states == nil {
+		m.states = make([]providerState, len(m.providers))
+	}
+	state := &m.states[i]
+	state.lastFetch = time.Now()
+	state.lastError = err
+	if err == nil {
+		state.lastSuccess = state.lastFetch
+		state.prefixes = prefixes
+	}
+	return state.prefixes
+}
+
+// Status returns the health and freshness of each wrapped provider, in order, as of its
+// last fetch by Prefixes or the refresh loops. Serve it with a StatusResource to show
+// whether the prefix lists are stale on a diohttp.Server's /status endpoint.
+func (m *MultiProvider) Status() []ProviderStatus {
 	m.mu.RLock()
-	defer m.mu.RUnlock()
+	states := slices.Clone(m.states)
+	m.mu.RUnlock()
 
-	for _, prefix := range m.prefixes {
-		if prefix.Contains(addr) {
-			return true
+	now := time.Now()
+	statuses := make([]ProviderStatus, len(m.providers))
+	for i, provider := range m.providers {
+		status := ProviderStatus{Name: provider.Name()}
+		if i < len(states) {
+			state := states[i]
+			status.LastFetch = state.lastFetch
+			status.LastSuccess = state.lastSuccess
+			status.Prefixes = len(state.prefixes)
+			if state.lastError != nil {
+				status.LastError = state.lastError.Error()
+				status.Stale = true
+			}
 		}
+		if r, ok := provider.(cacheResultReporter); ok {
+			if result, ok := r.lastCacheResult(); ok {
+				status.CacheResult = result.String()
+				status.Stale = status.Stale || result == CacheResultStale
+			}
+		}
+		if r, ok := provider.(cacheRefresher); ok {
+			if expiresAt, ok := r.cacheExpiry(); ok {
+				status.ExpiresAt = expiresAt
+				status.Stale = status.Stale || !now.Before(expiresAt)
+			}
+		}
+		statuses[i] = status
 	}
-	return false
+	return statuses
+}
+
+// recordProviderMetrics records the prefix count and cache expiry of a provider
+func (m *MultiProvider) recordProviderMetrics(provider Provider, count int) {
+	m.mu.RLock()
+	metrics := m.metrics
+	m.mu.RUnlock()
+	if metrics == nil {
+		return
+	}
+
+	metrics.Prefixes.WithLabelValues(provider.Name()).Set(float64(count))
+	if r, ok := provider.(cacheRefresher); ok {
+		if expiresAt, ok := r.cacheExpiry(); ok {
+			metrics.CacheExpiry.WithLabelValues(provider.Name()).Set(time.Until(expiresAt).Seconds())
+		}
+	}
+}
+
+// OnChange registers fn to be called by Prefixes, and so by the refresh loops, whenever
+// the prefixes fetched from a provider differ from its previous successful fetch, e.g. to
+// sync firewall rules or alert on unexpectedly large changes. A failed fetch is not a
+// change. Callbacks are called synchronously and in order of registration, one change at
+// a time, so they must not call Prefixes.
+func (m *MultiProvider) OnChange(fn ChangeFunc) {
+	m.changeMu.Lock()
+	defer m.changeMu.Unlock()
+	m.onChange = append(m.onChange, fn)
+}
+
+// notifyChange records the prefixes fetched from the provider at index i and calls the
+// OnChange callbacks if they differ from the previous fetch
+func (m *MultiProvider) notifyChange(i int, name string, prefixes []netip.Prefix) {
+	current := make(map[netip.Prefix]struct{}, len(prefixes))
+	for _, prefix := range prefixes {
+		if prefix.IsValid() {
+			current[unmapPrefix(prefix)] = struct{}{}
+		}
+	}
+
+	m.changeMu.Lock()
+	defer m.changeMu.Unlock()
+
+	if m.lastPrefixes == nil {
+		m.lastPrefixes = make([]map[netip.Prefix]struct{}, len(m.providers))
+	}
+	previous := m.lastPrefixes[i]
+	m.lastPrefixes[i] = current
+
+	var added, removed []netip.Prefix
+	for prefix := range current {
+		if _, ok := previous[prefix]; !ok {
+			added = append(added, prefix)
+		}
+	}
+	for prefix := range previous {
+		if _, ok := current[prefix]; !ok {
+			removed = append(removed, prefix)
+		}
+	}
+	if len(added) == 0 && len(removed) == 0 {
+		return
+	}
+	slices.SortFunc(added, netip.Prefix.Compare)
+	slices.SortFunc(removed, netip.Prefix.Compare)
+
+	m.logger.Info().
+		Str("provider", name).
+		Int("added", len(added)).
+		Int("removed", len(removed)).
+		Msg("prefixes changed")
+
+	for _, fn := range m.onChange {
+		fn(name, added, removed)
+	}
+}
+
+// Contains checks if an IP address is in any of the cached prefix lists
+func (m *MultiProvider) Contains(addr netip.Addr) bool {
+	matched, _, _ := m.Match(addr)
+	return matched
+}
+
+// Match reports whether an IP address is in any of the cached prefix lists and, if so,
+// the name of the provider that supplied the longest prefix containing it, and that
+// prefix. A prefix supplied by several providers is attributed to the first of them.
+func (m *MultiProvider) Match(addr netip.Addr) (bool, string, netip.Prefix) {
+	m.mu.RLock()
+	set := m.prefixes
+	m.mu.RUnlock()
+
+	prefix, provider, ok := set.lookup(addr)
+	return ok, provider, prefix
 }
 
 // GetPrefixes returns a copy of all current prefixes
@@ -91,7 +334,162 @@ func (m *MultiProvider) GetPrefixes() []netip.Prefix {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	result := make([]netip.Prefix, len(m.prefixes))
-	copy(result, m.prefixes)
-	return result
+	return slices.Clone(m.prefixes.prefixes)
+}
+
+// Start refreshes the cached prefixes in a background goroutine, once immediately and
+// then every interval (DefaultRefreshInterval if interval is zero or less), until ctx is
+// cancelled or Stop is called. Calling Start or StartScheduled again before Stop is a no-op.
+func (m *MultiProvider) Start(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultRefreshInterval
+	}
+
+	m.lifecycleMu.Lock()
+	defer m.lifecycleMu.Unlock()
+
+	if m.cancel != nil {
+		return
+	}
+
+	ctx, m.cancel = context.WithCancel(ctx)
+	m.wg.Add(1)
+	go m.refreshLoop(ctx, interval)
+}
+
+// StartScheduled refreshes the cached prefixes in a background goroutine like Start, but
+// refreshes each provider backed by a cache at a random moment within lead (DefaultRefreshLead
+// if lead is zero or less) before its cache expires, so that lookups never block on a
+// fetch. A failed refresh is retried after lead. Other providers are re-read every
+// DefaultRefreshInterval. Calling StartScheduled or Start again before Stop is a no-op.
+func (m *MultiProvider) StartScheduled(ctx context.Context, lead time.Duration) {
+	if lead <= 0 {
+		lead = DefaultRefreshLead
+	}
+
+	m.lifecycleMu.Lock()
+	defer m.lifecycleMu.Unlock()
+
+	if m.cancel != nil {
+		return
+	}
+
+	ctx, m.cancel = context.WithCancel(ctx)
+	m.wg.Add(1)
+	go m.scheduledRefreshLoop(ctx, lead)
+}
+
+// Stop stops the refresh loop started by Start or StartScheduled and waits for it to exit.
+// It is safe to call Stop multiple times, before Start, or after ctx is cancelled.
+// The MultiProvider can be started again after Stop returns.
+func (m *MultiProvider) Stop() {
+	m.lifecycleMu.Lock()
+	defer m.lifecycleMu.Unlock()
+
+	if m.cancel == nil {
+		return
+	}
+	m.cancel()
+	m.wg.Wait()
+	m.cancel = nil
+}
+
+func (m *MultiProvider) refreshLoop(ctx context.Context, interval time.Duration) {
+	defer m.wg.Done()
+
+	// Errors are logged per provider by Prefixes
+	_, _ = m.Prefixes(ctx)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_, _ = m.Prefixes(ctx)
+		}
+	}
+}
+
+func (m *MultiProvider) scheduledRefreshLoop(ctx context.Context, lead time.Duration) {
+	defer m.wg.Done()
+
+	// Errors are logged per provider by Prefixes
+	_, _ = m.Prefixes(ctx)
+
+	var refreshers []cacheRefresher
+	var names []string
+	for _, provider := range m.providers {
+		if r, ok := provider.(cacheRefresher); ok {
+			refreshers = append(refreshers, r)
+			names = append(names, provider.Name())
+		}
+	}
+
+	now := time.Now()
+	next := make([]time.Time, len(refreshers))
+	for i, r := range refreshers {
+		next[i] = nextScheduledRefresh(r, lead, now)
+	}
+	nextReread := now.Add(DefaultRefreshInterval)
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		due := nextReread
+		for _, t := range next {
+			if t.Before(due) {
+				due = t
+			}
+		}
+		timer.Reset(time.Until(due))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		now = time.Now()
+		for i, r := range refreshers {
+			if next[i].After(now) {
+				continue
+			}
+			if err := r.refreshCache(ctx); err != nil {
+				m.logger.Error().
+					Err(err).
+					Str("provider", names[i]).
+					Msg("failed to refresh prefixes")
+				next[i] = now.Add(lead)
+				continue
+			}
+			next[i] = nextScheduledRefresh(r, lead, now)
+		}
+		if !nextReread.After(now) {
+			nextReread = now.Add(DefaultRefreshInterval)
+		}
+
+		_, _ = m.Prefixes(ctx)
+	}
+}
+
+// nextScheduledRefresh returns a random moment within lead before r's cache expires, but
+// no sooner than half of lead from now so that data expiring immediately, such as
+// responses with Cache-Control: no-store, is not refetched continuously
+func nextScheduledRefresh(r cacheRefresher, lead time.Duration, now time.Time) time.Time {
+	earliest := now.Add(lead / 2)
+
+	expiresAt, ok := r.cacheExpiry()
+	if !ok {
+		return earliest
+	}
+
+	at := expiresAt.Add(-time.Duration(rand.Float64() * float64(lead)))
+	if at.Before(earliest) {
+		return earliest
+	}
+	return at
 }
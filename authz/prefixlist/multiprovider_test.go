@@ -0,0 +1,283 @@
+package prefixlist
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingProvider counts calls to Prefixes
+type countingProvider struct {
+	mockProvider
+	calls atomic.Int32
+}
+
+func (c *countingProvider) Prefixes(ctx context.Context) ([]netip.Prefix, error) {
+	c.calls.Add(1)
+	return c.mockProvider.Prefixes(ctx)
+}
+
+func TestMultiProviderStartStop(t *testing.T) {
+	provider := &countingProvider{mockProvider: mockProvider{name: "test", prefixes: []string{"10.0.0.0/8"}}}
+	m := NewMultiProvider([]Provider{provider}, zerolog.Nop())
+
+	m.Start(context.Background(), 10*time.Millisecond)
+	m.Start(context.Background(), 10*time.Millisecond) // no-op while running
+
+	require.Eventually(t, func() bool { return provider.calls.Load() >= 3 }, time.Second, 5*time.Millisecond)
+	assert.True(t, m.Contains(netip.MustParseAddr("10.1.2.3")))
+
+	m.Stop()
+	stopped := provider.calls.Load()
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, stopped, provider.calls.Load(), "refresh continued after Stop")
+
+	m.Stop() // safe to call again
+}
+
+func TestMultiProviderStartContextCancel(t *testing.T) {
+	provider := &countingProvider{mockProvider: mockProvider{name: "test", prefixes: []string{"10.0.0.0/8"}}}
+	m := NewMultiProvider([]Provider{provider}, zerolog.Nop())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.Start(ctx, 10*time.Millisecond)
+	require.Eventually(t, func() bool { return provider.calls.Load() >= 1 }, time.Second, 5*time.Millisecond)
+
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		m.Stop() // waits for the refresh goroutine to exit
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("refresh goroutine did not exit after context cancellation")
+	}
+
+	stopped := provider.calls.Load()
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, stopped, provider.calls.Load(), "refresh continued after context cancellation")
+}
+
+func TestMultiProviderStopWithoutStart(t *testing.T) {
+	m := NewMultiProvider(nil, zerolog.Nop())
+	m.Stop()
+}
+
+func TestMultiProviderStartScheduled(t *testing.T) {
+	fetches := atomic.Int32{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		w.Header().Set("Cache-Control", "max-age=1")
+		_, _ = w.Write([]byte(`{"addresses": ["192.0.2.0/24"]}`))
+	}))
+	defer server.Close()
+
+	cached := NewFastlyProvider()
+	cached.fetcher.url = server.URL
+	static := &countingProvider{mockProvider: mockProvider{name: "static", prefixes: []string{"10.0.0.0/8"}}}
+	m := NewMultiProvider([]Provider{cached, static}, zerolog.Nop())
+
+	m.StartScheduled(context.Background(), 500*time.Millisecond)
+	m.StartScheduled(context.Background(), 500*time.Millisecond) // no-op while running
+	defer m.Stop()
+
+	require.Eventually(t, func() bool { return m.Contains(netip.MustParseAddr("192.0.2.1")) }, time.Second, 5*time.Millisecond)
+	assert.True(t, m.Contains(netip.MustParseAddr("10.1.2.3")))
+
+	// The provider is refreshed before its one second lifetime ends, so its cache never expires
+	require.Eventually(t, func() bool { return fetches.Load() >= 3 }, 3*time.Second, 10*time.Millisecond)
+	expiry, ok := cached.cacheExpiry()
+	require.True(t, ok)
+	assert.True(t, expiry.After(time.Now()))
+}
+
+func TestNextScheduledRefresh(t *testing.T) {
+	now := time.Now()
+	lead := time.Minute
+
+	provider := NewFastlyProvider()
+	assert.Equal(t, now.Add(lead/2), nextScheduledRefresh(provider, lead, now), "no cached data")
+
+	provider.fetcher.cachedData = &fastlyIPRanges{}
+	provider.fetcher.expiresAt = now.Add(time.Hour)
+	for range 10 {
+		at := nextScheduledRefresh(provider, lead, now)
+		assert.False(t, at.Before(now.Add(time.Hour-lead)))
+		assert.False(t, at.After(now.Add(time.Hour)))
+	}
+
+	provider.fetcher.expiresAt = now
+	assert.Equal(t, now.Add(lead/2), nextScheduledRefresh(provider, lead, now), "expires immediately")
+}
+
+func TestMultiProviderMatch(t *testing.T) {
+	m := NewMultiProvider([]Provider{
+		&mockProvider{name: "broad", prefixes: []string{"10.0.0.0/8", "192.0.2.0/24"}},
+		&mockProvider{name: "narrow", prefixes: []string{"10.1.0.0/16", "192.0.2.0/24"}},
+		&mockProvider{name: "broken", fetchErr: assert.AnError},
+	}, zerolog.Nop())
+
+	matched, provider, prefix := m.Match(netip.MustParseAddr("10.1.2.3"))
+	assert.False(t, matched, "nothing matches before Prefixes")
+	assert.Empty(t, provider)
+	assert.False(t, prefix.IsValid())
+
+	prefixes, err := m.Prefixes(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, prefixes, 3, "duplicates are removed")
+
+	matched, provider, prefix = m.Match(netip.MustParseAddr("10.1.2.3"))
+	assert.True(t, matched)
+	assert.Equal(t, "narrow", provider)
+	assert.Equal(t, netip.MustParsePrefix("10.1.0.0/16"), prefix)
+
+	matched, provider, prefix = m.Match(netip.MustParseAddr("192.0.2.1"))
+	assert.True(t, matched)
+	assert.Equal(t, "broad", provider)
+	assert.Equal(t, netip.MustParsePrefix("192.0.2.0/24"), prefix)
+
+	matched, _, _ = m.Match(netip.MustParseAddr("203.0.113.1"))
+	assert.False(t, matched)
+	assert.True(t, m.Contains(netip.MustParseAddr("10.200.0.1")))
+	assert.Equal(t, prefixes, m.GetPrefixes())
+}
+
+func TestMultiProviderRetainsPrefixesOnFailure(t *testing.T) {
+	flaky := &mockProvider{name: "flaky", prefixes: []string{"192.0.2.0/24"}}
+	empty := &mockProvider{name: "empty"}
+	m := NewMultiProvider([]Provider{flaky, empty}, zerolog.Nop())
+
+	_, err := m.Prefixes(context.Background())
+	require.NoError(t, err)
+	assert.True(t, m.Contains(netip.MustParseAddr("192.0.2.1")))
+
+	flaky.fetchErr = assert.AnError
+	_, err = m.Prefixes(context.Background())
+	assert.Error(t, err, "no provider returned prefixes")
+	assert.True(t, m.Contains(netip.MustParseAddr("192.0.2.1")), "last successful fetch is kept")
+	assert.Equal(t, 1, m.Status()[0].Prefixes)
+
+	flaky.fetchErr = nil
+	flaky.prefixes = []string{"198.51.100.0/24"}
+	_, err = m.Prefixes(context.Background())
+	require.NoError(t, err)
+	assert.False(t, m.Contains(netip.MustParseAddr("192.0.2.1")))
+	assert.True(t, m.Contains(netip.MustParseAddr("198.51.100.1")))
+}
+
+func TestMultiProviderOnChange(t *testing.T) {
+	aws := &mockProvider{name: "aws", prefixes: []string{"10.0.0.0/8", "192.0.2.0/24"}}
+	github := &mockProvider{name: "github", prefixes: []string{"2001:db8::/32"}}
+	m := NewMultiProvider([]Provider{aws, github}, zerolog.Nop())
+
+	type change struct {
+		provider       string
+		added, removed []netip.Prefix
+	}
+	var changes []change
+	m.OnChange(func(provider string, added, removed []netip.Prefix) {
+		changes = append(changes, change{provider, added, removed})
+	})
+
+	// The first fetch adds every prefix
+	_, err := m.Prefixes(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []change{
+		{provider: "aws", added: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("192.0.2.0/24")}},
+		{provider: "github", added: []netip.Prefix{netip.MustParsePrefix("2001:db8::/32")}},
+	}, changes)
+
+	// Unchanged prefixes are not reported
+	changes = nil
+	_, err = m.Prefixes(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+
+	aws.prefixes = []string{"192.0.2.0/24", "198.51.100.0/24", "172.16.0.0/12"}
+	github.fetchErr = assert.AnError
+	_, err = m.Prefixes(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []change{
+		{
+			provider: "aws",
+			added:    []netip.Prefix{netip.MustParsePrefix("172.16.0.0/12"), netip.MustParsePrefix("198.51.100.0/24")},
+			removed:  []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+		},
+	}, changes, "a failed fetch is not a change")
+
+	// After recovering, the provider is compared with its last successful fetch
+	changes = nil
+	github.fetchErr = nil
+	_, err = m.Prefixes(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+}
+
+func TestMultiProviderStatus(t *testing.T) {
+	failing := atomic.Bool{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Cache-Control", "max-age=3600")
+		_, _ = w.Write([]byte(`{"addresses": ["192.0.2.0/24", "198.51.100.0/24"]}`))
+	}))
+	defer server.Close()
+
+	fastly := NewFastlyProvider()
+	fastly.fetcher.url = server.URL
+	broken := &mockProvider{name: "broken", fetchErr: assert.AnError}
+
+	m := NewMultiProvider([]Provider{fastly, broken}, zerolog.Nop())
+
+	statuses := m.Status()
+	require.Len(t, statuses, 2)
+	assert.Equal(t, ProviderStatus{Name: "fastly"}, statuses[0])
+	assert.Equal(t, ProviderStatus{Name: "broken"}, statuses[1])
+
+	_, err := m.Prefixes(context.Background())
+	require.NoError(t, err)
+
+	statuses = m.Status()
+	assert.Equal(t, "fastly", statuses[0].Name)
+	assert.False(t, statuses[0].LastFetch.IsZero())
+	assert.Equal(t, statuses[0].LastFetch, statuses[0].LastSuccess)
+	assert.Empty(t, statuses[0].LastError)
+	assert.Equal(t, "fresh", statuses[0].CacheResult)
+	assert.Equal(t, 2, statuses[0].Prefixes)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), statuses[0].ExpiresAt, time.Minute)
+	assert.False(t, statuses[0].Stale)
+
+	assert.Equal(t, "broken", statuses[1].Name)
+	assert.False(t, statuses[1].LastFetch.IsZero())
+	assert.True(t, statuses[1].LastSuccess.IsZero())
+	assert.Equal(t, assert.AnError.Error(), statuses[1].LastError)
+	assert.Empty(t, statuses[1].CacheResult)
+	assert.True(t, statuses[1].ExpiresAt.IsZero())
+	assert.True(t, statuses[1].Stale)
+
+	// Expire the cache so the next fetch fails and serves stale data
+	failing.Store(true)
+	fastly.fetcher.mu.Lock()
+	fastly.fetcher.expiresAt = time.Now().Add(-time.Second)
+	fastly.fetcher.mu.Unlock()
+
+	_, _ = m.Prefixes(context.Background())
+
+	statuses = m.Status()
+	assert.Equal(t, "stale", statuses[0].CacheResult)
+	assert.Equal(t, 2, statuses[0].Prefixes)
+	assert.True(t, statuses[0].Stale)
+}
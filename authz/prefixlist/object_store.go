@@ -0,0 +1,142 @@
+package prefixlist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrObjectNotModified is returned by ObjectClient.GetObject when the object matches the
+// ETag or modification time of the request, so the cached data is still current.
+var ErrObjectNotModified = errors.New("object not modified")
+
+// ObjectClient reads objects from an object store such as Amazon S3 or Google Cloud
+// Storage, typically by wrapping the store's SDK client. Setting one in
+// CacheConfig.ObjectClients, or ProviderConfig.ObjectClients, lets CachingFetchers, and so
+// providers such as the custom provider, fetch URLs such as s3://bucket/key with the same
+// caching and stale semantics as HTTP.
+type ObjectClient interface {
+	// GetObject returns the object identified by req. It returns ErrObjectNotModified if
+	// the object matches the request's IfNoneMatch or has not been modified since its
+	// IfModifiedSince, where set.
+	GetObject(ctx context.Context, req ObjectRequest) (*Object, error)
+}
+
+// ObjectRequest identifies an object to fetch with an ObjectClient
+type ObjectRequest struct {
+	// Bucket is the host of the object URL
+	Bucket string
+	// Key is the path of the object URL, without the leading slash
+	Key string
+	// IfNoneMatch is the ETag of the cached object, if any
+	IfNoneMatch string
+	// IfModifiedSince is the modification time of the cached object, if known
+	IfModifiedSince time.Time
+}
+
+// Object is an object fetched with an ObjectClient. Its metadata is used like the
+// headers of an HTTP response: CacheControl determines how long it is cached, and ETag
+// and LastModified are used to revalidate it once expired.
+type Object struct {
+	// Body is the content of the object. It is closed once read.
+	Body io.ReadCloser
+	// ETag is the entity tag of the object
+	ETag string
+	// LastModified is when the object was last modified
+	LastModified time.Time
+	// CacheControl is the Cache-Control metadata of the object, if any. Without it, the
+	// fetcher's StaticExpiry applies.
+	CacheControl string
+	// ContentType is the Content-Type metadata of the object, if any
+	ContentType string
+}
+
+// objectClient returns the object client configured for scheme, if any. Schemes are
+// case-insensitive.
+func (c CacheConfig) objectClient(scheme string) (ObjectClient, bool) {
+	for name, client := range c.ObjectClients {
+		if client != nil && strings.EqualFold(name, scheme) {
+			return client, true
+		}
+	}
+	return nil, false
+}
+
+// objectTransport is an http.RoundTripper that fetches object URLs with an ObjectClient,
+// presenting objects as HTTP responses so that they are cached and revalidated like any
+// other response
+type objectTransport struct {
+	client ObjectClient
+}
+
+func (t *objectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	objectReq := ObjectRequest{
+		Bucket:      req.URL.Host,
+		Key:         strings.TrimPrefix(req.URL.Path, "/"),
+		IfNoneMatch: req.Header.Get("If-None-Match"),
+	}
+	if value := req.Header.Get("If-Modified-Since"); value != "" {
+		if t, err := http.ParseTime(value); err == nil {
+			objectReq.IfModifiedSince = t
+		}
+	}
+	if objectReq.Bucket == "" || objectReq.Key == "" {
+		return nil, fmt.Errorf("object url %s: expected %s://bucket/key", req.URL, req.URL.Scheme)
+	}
+
+	resp := &http.Response{
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Body:       http.NoBody,
+		Request:    req,
+	}
+
+	object, err := t.client.GetObject(req.Context(), objectReq)
+	if errors.Is(err, ErrObjectNotModified) {
+		resp.StatusCode = http.StatusNotModified
+		resp.Status = "304 Not Modified"
+		return resp, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get object: %w", err)
+	}
+
+	resp.StatusCode = http.StatusOK
+	resp.Status = "200 OK"
+	resp.ContentLength = -1
+	if object.Body != nil {
+		resp.Body = object.Body
+	}
+	if object.ETag != "" {
+		resp.Header.Set("ETag", object.ETag)
+	}
+	if !object.LastModified.IsZero() {
+		resp.Header.Set("Last-Modified", object.LastModified.UTC().Format(http.TimeFormat))
+	}
+	if object.CacheControl != "" {
+		resp.Header.Set("Cache-Control", object.CacheControl)
+	}
+	if object.ContentType != "" {
+		resp.Header.Set("Content-Type", object.ContentType)
+	}
+	return resp, nil
+}
+
+// clientFor returns the client for a request: one fetching through the configured
+// ObjectClient for object store URLs, otherwise the configured HTTP client
+func (c CacheConfig) clientFor(req *http.Request) *http.Client {
+	client := c.httpClient()
+	if objectClient, ok := c.objectClient(req.URL.Scheme); ok {
+		return &http.Client{
+			Transport: &objectTransport{client: objectClient},
+			Timeout:   client.Timeout,
+		}
+	}
+	return client
+}
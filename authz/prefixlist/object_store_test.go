@@ -0,0 +1,159 @@
+package prefixlist
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/netip"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeObjectClient serves objects from memory, honouring conditional requests
+type fakeObjectClient struct {
+	mu       sync.Mutex
+	objects  map[string]string // by bucket/key
+	modified time.Time
+	requests []ObjectRequest
+}
+
+func (c *fakeObjectClient) GetObject(ctx context.Context, req ObjectRequest) (*Object, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requests = append(c.requests, req)
+
+	body, ok := c.objects[req.Bucket+"/"+req.Key]
+	if !ok {
+		return nil, errors.New("no such key")
+	}
+	if req.IfNoneMatch == `"v1"` {
+		return nil, ErrObjectNotModified
+	}
+	return &Object{
+		Body:         io.NopCloser(strings.NewReader(body)),
+		ETag:         `"v1"`,
+		LastModified: c.modified,
+		CacheControl: "max-age=60",
+		ContentType:  "text/plain",
+	}, nil
+}
+
+func TestObjectClientFetch(t *testing.T) {
+	client := &fakeObjectClient{
+		objects:  map[string]string{"acl-bucket/lists/office.txt": "192.0.2.0/24\n198.51.100.7\n"},
+		modified: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	provider, err := NewProviderFromConfig(ProviderConfig{
+		Name:          "custom",
+		Enabled:       true,
+		Filter:        map[string]string{"name": "office", "url": "s3://acl-bucket/lists/office.txt", "format": "text"},
+		ObjectClients: map[string]ObjectClient{"S3": client},
+	})
+	require.NoError(t, err)
+	p := provider.(*HTTPTextProvider)
+
+	prefixes, err := p.Prefixes(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []netip.Prefix{
+		netip.MustParsePrefix("192.0.2.0/24"),
+		netip.MustParsePrefix("198.51.100.7/32"),
+	}, prefixes)
+
+	_, headers, _, err := p.fetcher.GetWithHeaders(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, `"v1"`, headers.Get("ETag"))
+	assert.Equal(t, "Fri, 02 Jan 2026 03:04:05 GMT", headers.Get("Last-Modified"))
+	assert.Equal(t, "text/plain", headers.Get("Content-Type"))
+
+	// The object's Cache-Control metadata determines the expiry
+	_, expiresAt, _ := p.fetcher.GetCacheInfo()
+	assert.WithinDuration(t, time.Now().Add(time.Minute), expiresAt, 5*time.Second)
+
+	// Expired data is revalidated with the object's ETag and modification time
+	require.NoError(t, p.fetcher.Refresh(context.Background()))
+	prefixes, err = p.Prefixes(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, prefixes, 2)
+
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	require.Len(t, client.requests, 2)
+	assert.Equal(t, ObjectRequest{Bucket: "acl-bucket", Key: "lists/office.txt"}, client.requests[0])
+	assert.Equal(t, ObjectRequest{
+		Bucket:          "acl-bucket",
+		Key:             "lists/office.txt",
+		IfNoneMatch:     `"v1"`,
+		IfModifiedSince: client.modified,
+	}, client.requests[1])
+}
+
+func TestObjectClientErrors(t *testing.T) {
+	clients := map[string]ObjectClient{"gs": &fakeObjectClient{objects: map[string]string{}}}
+
+	tests := []struct {
+		name string
+		url  string
+		err  string
+	}{
+		{name: "missing object", url: "gs://bucket/missing.json", err: "no such key"},
+		{name: "missing key", url: "gs://bucket", err: "expected gs://bucket/key"},
+		{name: "unregistered scheme", url: "r2://bucket/list.json", err: "unsupported protocol scheme"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewCustomProvider("", tt.url, "")
+			p.setObjectClients(clients)
+			_, err := p.Prefixes(context.Background())
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.err)
+		})
+	}
+}
+
+func TestObjectClientStale(t *testing.T) {
+	client := &fakeObjectClient{objects: map[string]string{"bucket/list.json": `["192.0.2.0/24"]`}}
+	p := NewCustomProvider("", "s3://bucket/list.json", "")
+	p.setObjectClients(map[string]ObjectClient{"s3": client})
+	_, err := p.Prefixes(context.Background())
+	require.NoError(t, err)
+
+	// A failed fetch serves the cached data as stale, as for HTTP sources
+	client.mu.Lock()
+	delete(client.objects, "bucket/list.json")
+	client.mu.Unlock()
+	p.fetcher.mu.Lock()
+	p.fetcher.expiresAt = time.Now().Add(-time.Second)
+	p.fetcher.mu.Unlock()
+
+	assert.Error(t, p.fetcher.Refresh(context.Background()))
+	prefixes, err := p.Prefixes(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("192.0.2.0/24")}, prefixes)
+}
+
+func TestObjectClientFetchHelpers(t *testing.T) {
+	config := CacheConfig{ObjectClients: map[string]ObjectClient{"s3": &fakeObjectClient{
+		objects: map[string]string{"bucket/list.txt": "192.0.2.0/24\n", "bucket/list.csv": "192.0.2.0/24,GB\n"},
+	}}}
+
+	body, err := config.fetchBody(context.Background(), "s3://bucket/list.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "192.0.2.0/24\n", string(body))
+
+	lines, err := config.fetchTextLines(context.Background(), "s3://bucket/list.txt")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"192.0.2.0/24"}, lines)
+
+	records, err := config.fetchCSVRecords(context.Background(), "s3://bucket/list.csv")
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"192.0.2.0/24", "GB"}}, records)
+
+	// Without a client for the scheme, the URL is not fetched through another config's
+	_, err = CacheConfig{}.fetchTextLines(context.Background(), "s3://bucket/list.txt")
+	assert.ErrorContains(t, err, "unsupported protocol scheme")
+}
@@ -0,0 +1,83 @@
+package prefixlist
+
+import (
+	"net/netip"
+	"strings"
+	"time"
+)
+
+func init() {
+	RegisterProvider("oci", func(cfg ProviderConfig) (Provider, error) {
+		// OCI: support "region" and "tag" keys (comma-separated values)
+		regions := parseCommaSeparated(cfg.Filter["region"])
+		tags := parseCommaSeparated(cfg.Filter["tag"])
+		return NewOCIProvider(regions, tags), nil
+	})
+}
+
+// OCIProvider fetches IP ranges from Oracle Cloud Infrastructure
+type OCIProvider struct {
+	*HTTPJSONProvider[ociIPRanges]
+	regions []string // optional filter for regions (e.g., "us-ashburn-1", "uk-london-1")
+	tags    []string // optional filter for tags (e.g., "OCI", "OSN", "OBJECT_STORAGE")
+}
+
+type ociIPRanges struct {
+	Regions []struct {
+		Region string `json:"region"`
+		CIDRs  []struct {
+			CIDR string   `json:"cidr"`
+			Tags []string `json:"tags"`
+		} `json:"cidrs"`
+	} `json:"regions"`
+}
+
+// NewOCIProvider creates a new Oracle Cloud Infrastructure prefix list provider
+// regions: optional list of regions to filter by (e.g., ["us-ashburn-1"])
+// tags: optional list of tags to filter by (e.g., ["OSN"]); a range matches if it has any of them
+func NewOCIProvider(regions, tags []string) *OCIProvider {
+	name := "oci"
+	if len(tags) > 0 {
+		name += "-" + strings.Join(tags, ",")
+	}
+	if len(regions) > 0 {
+		name += "-" + strings.Join(regions, ",")
+	}
+
+	p := &OCIProvider{
+		regions: regions,
+		tags:    tags,
+	}
+
+	p.HTTPJSONProvider = NewHTTPJSONProvider[ociIPRanges](
+		name,
+		"https://docs.oracle.com/iaas/tools/public_ip_ranges.json",
+		CacheConfig{
+			StaticExpiry: 24 * time.Hour,
+			ReturnStale:  true,
+		},
+		p.transformOCIRanges,
+	)
+
+	return p
+}
+
+func (p *OCIProvider) transformOCIRanges(data ociIPRanges) ([]netip.Prefix, error) {
+	var cidrs []string
+	for _, region := range data.Regions {
+		// Apply region filter if specified
+		if len(p.regions) > 0 && !contains(p.regions, region.Region) {
+			continue
+		}
+
+		for _, cidr := range region.CIDRs {
+			// Apply tag filter if specified
+			if len(p.tags) > 0 && !containsAny(cidr.Tags, p.tags) {
+				continue
+			}
+			cidrs = append(cidrs, cidr.CIDR)
+		}
+	}
+
+	return parseCIDRs(cidrs)
+}
@@ -0,0 +1,100 @@
+package prefixlist
+
+import (
+	"net/netip"
+	"slices"
+)
+
+// prefixSet is an immutable, deduplicated set of prefixes gathered from several providers.
+// It finds the longest prefix containing an address with one map lookup per distinct
+// prefix length, however many prefixes it holds.
+type prefixSet struct {
+	prefixes []netip.Prefix // deduplicated, in the order first added
+	v4       prefixTable
+	v6       prefixTable
+}
+
+// prefixTable indexes the prefixes of one address family by prefix length
+type prefixTable struct {
+	lengths   []int                   // distinct prefix lengths, longest first
+	providers map[netip.Prefix]string // masked prefix to the name of the provider that supplied it first
+}
+
+// prefixSetBuilder accumulates prefixes for a prefixSet
+type prefixSetBuilder struct {
+	set *prefixSet
+}
+
+func newPrefixSetBuilder() *prefixSetBuilder {
+	return &prefixSetBuilder{
+		set: &prefixSet{
+			v4: prefixTable{providers: make(map[netip.Prefix]string)},
+			v6: prefixTable{providers: make(map[netip.Prefix]string)},
+		},
+	}
+}
+
+// add adds the prefixes supplied by the named provider. Prefixes are masked, IPv4-mapped
+// IPv6 prefixes are stored in their IPv4 form, and prefixes already added are skipped.
+func (b *prefixSetBuilder) add(provider string, prefixes []netip.Prefix) {
+	for _, prefix := range prefixes {
+		if !prefix.IsValid() {
+			continue
+		}
+		prefix = unmapPrefix(prefix)
+
+		table := &b.set.v6
+		if prefix.Addr().Is4() {
+			table = &b.set.v4
+		}
+		if _, ok := table.providers[prefix]; ok {
+			continue
+		}
+		table.providers[prefix] = provider
+		if !slices.Contains(table.lengths, prefix.Bits()) {
+			table.lengths = append(table.lengths, prefix.Bits())
+		}
+		b.set.prefixes = append(b.set.prefixes, prefix)
+	}
+}
+
+// build returns the set. The builder must not be used afterwards.
+func (b *prefixSetBuilder) build() *prefixSet {
+	for _, table := range []*prefixTable{&b.set.v4, &b.set.v6} {
+		slices.SortFunc(table.lengths, func(a, b int) int { return b - a })
+	}
+	return b.set
+}
+
+// lookup returns the longest prefix containing addr and the provider that supplied it.
+// A nil set is empty.
+func (s *prefixSet) lookup(addr netip.Addr) (netip.Prefix, string, bool) {
+	if s == nil || !addr.IsValid() {
+		return netip.Prefix{}, "", false
+	}
+	addr = addr.Unmap()
+
+	table := &s.v6
+	if addr.Is4() {
+		table = &s.v4
+	}
+	for _, bits := range table.lengths {
+		prefix, err := addr.Prefix(bits)
+		if err != nil {
+			continue
+		}
+		if provider, ok := table.providers[prefix]; ok {
+			return prefix, provider, true
+		}
+	}
+	return netip.Prefix{}, "", false
+}
+
+// unmapPrefix returns prefix masked, with an IPv4-mapped IPv6 prefix in its IPv4 form
+func unmapPrefix(prefix netip.Prefix) netip.Prefix {
+	addr := prefix.Addr()
+	if addr.Is4In6() && prefix.Bits() >= 96 {
+		return netip.PrefixFrom(addr.Unmap(), prefix.Bits()-96).Masked()
+	}
+	return prefix.Masked()
+}
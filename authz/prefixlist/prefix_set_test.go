@@ -0,0 +1,65 @@
+package prefixlist
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrefixSet(t *testing.T) {
+	builder := newPrefixSetBuilder()
+	builder.add("aws", []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("2001:db8::/32"),
+		netip.MustParsePrefix("192.0.2.1/24"), // not masked
+	})
+	builder.add("github", []netip.Prefix{
+		netip.MustParsePrefix("10.1.0.0/16"),
+		netip.MustParsePrefix("10.0.0.0/8"), // duplicate, attributed to aws
+		netip.MustParsePrefix("::ffff:198.51.100.0/120"),
+		{},
+	})
+	set := builder.build()
+
+	assert.Equal(t, []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("2001:db8::/32"),
+		netip.MustParsePrefix("192.0.2.0/24"),
+		netip.MustParsePrefix("10.1.0.0/16"),
+		netip.MustParsePrefix("198.51.100.0/24"),
+	}, set.prefixes)
+
+	tests := []struct {
+		addr     string
+		prefix   string
+		provider string
+	}{
+		{addr: "10.2.3.4", prefix: "10.0.0.0/8", provider: "aws"},
+		{addr: "10.1.3.4", prefix: "10.1.0.0/16", provider: "github"},
+		{addr: "192.0.2.200", prefix: "192.0.2.0/24", provider: "aws"},
+		{addr: "198.51.100.7", prefix: "198.51.100.0/24", provider: "github"},
+		{addr: "::ffff:10.1.0.1", prefix: "10.1.0.0/16", provider: "github"},
+		{addr: "2001:db8::1", prefix: "2001:db8::/32", provider: "aws"},
+		{addr: "203.0.113.1"},
+		{addr: "2001:db9::1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			prefix, provider, ok := set.lookup(netip.MustParseAddr(tt.addr))
+			if tt.prefix == "" {
+				assert.False(t, ok)
+				return
+			}
+			assert.True(t, ok)
+			assert.Equal(t, netip.MustParsePrefix(tt.prefix), prefix)
+			assert.Equal(t, tt.provider, provider)
+		})
+	}
+
+	_, _, ok := (*prefixSet)(nil).lookup(netip.MustParseAddr("10.0.0.1"))
+	assert.False(t, ok)
+	_, _, ok = set.lookup(netip.Addr{})
+	assert.False(t, ok)
+}
@@ -2,6 +2,10 @@ package prefixlist
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -46,6 +50,222 @@ func TestHetznerProvider(t *testing.T) {
 	assert.True(t, found, "Expected to find Hetzner range 5.9.0.0/16")
 }
 
+func TestAzureProvider(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/download":
+			fmt.Fprintf(w, `<a href="%s/files/ServiceTags_Public_20260105.json">Download</a>`, server.URL)
+		case "/files/ServiceTags_Public_20260105.json":
+			_, _ = w.Write([]byte(`{"changeNumber": 1, "cloud": "Public", "values": [
+				{"name": "AzureCloud", "properties": {"region": "", "addressPrefixes": ["13.64.0.0/16", "20.38.0.0/16", "2603:1020::/40"]}},
+				{"name": "AzureCloud.westeurope", "properties": {"region": "westeurope", "addressPrefixes": ["13.64.0.0/16", "2603:1020::/40"]}},
+				{"name": "AzureCloud.northeurope", "properties": {"region": "northeurope", "addressPrefixes": ["20.38.0.0/16"]}},
+				{"name": "Storage.WestEurope", "properties": {"region": "westeurope", "addressPrefixes": ["52.239.0.0/16"]}}
+			]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		service  string
+		region   string
+		expected []string
+	}{
+		{
+			name:     "no filter",
+			expected: []string{"13.64.0.0/16", "20.38.0.0/16", "2603:1020::/40", "52.239.0.0/16"},
+		},
+		{
+			name:     "service",
+			service:  "storage",
+			expected: []string{"52.239.0.0/16"},
+		},
+		{
+			name:     "service and region",
+			service:  "AzureCloud",
+			region:   "westeurope",
+			expected: []string{"13.64.0.0/16", "2603:1020::/40"},
+		},
+		{
+			name:     "region",
+			region:   "WestEurope",
+			expected: []string{"13.64.0.0/16", "2603:1020::/40", "52.239.0.0/16"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := NewAzureProvider(tt.service, tt.region)
+			provider.fetcher.url = server.URL + "/download"
+
+			prefixes, err := provider.Prefixes(context.Background())
+			require.NoError(t, err)
+
+			var got []string
+			for _, prefix := range prefixes {
+				got = append(got, prefix.String())
+			}
+			assert.Equal(t, tt.expected, got)
+		})
+	}
+
+	provider := NewAzureProvider("AzureCloud", "northeurope")
+	provider.fetcher.url = server.URL + "/download"
+	_, err := provider.Prefixes(context.Background())
+	require.NoError(t, err)
+	assert.True(t, provider.Contains(netip.MustParseAddr("20.38.1.1")))
+	assert.False(t, provider.Contains(netip.MustParseAddr("13.64.1.1")))
+
+	provider = NewAzureProvider("", "")
+	provider.fetcher.url = server.URL + "/missing"
+	_, err = provider.Prefixes(context.Background())
+	assert.Error(t, err)
+}
+
+func TestDigitalOceanProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("5.101.96.0/21,NL,NL-NH,Amsterdam,\n" +
+			"45.55.0.0/19,US,US-NY,New York,10013\n" +
+			"2a03:b0c0::/48,NL,NL-NH,Amsterdam,\n"))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name      string
+		countries []string
+		expected  []string
+	}{
+		{
+			name:     "no filter",
+			expected: []string{"5.101.96.0/21", "45.55.0.0/19", "2a03:b0c0::/48"},
+		},
+		{
+			name:      "country",
+			countries: []string{"nl"},
+			expected:  []string{"5.101.96.0/21", "2a03:b0c0::/48"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := NewDigitalOceanProvider(tt.countries)
+			provider.fetcher.url = server.URL
+
+			prefixes, err := provider.Prefixes(context.Background())
+			require.NoError(t, err)
+
+			var got []string
+			for _, prefix := range prefixes {
+				got = append(got, prefix.String())
+			}
+			assert.Equal(t, tt.expected, got)
+			assert.True(t, provider.Contains(netip.MustParseAddr("5.101.96.1")))
+		})
+	}
+}
+
+func TestAkamaiProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/origin-ip-acl.txt":
+			_, _ = w.Write([]byte("23.32.0.0/11\n# comment\n2600:1400::/24\n"))
+		case "/siteshield/v1/maps/1234":
+			_, _ = w.Write([]byte(`{"id": 1234, "currentCidrs": ["23.32.0.0/11"], "proposedCidrs": ["23.192.0.0/11"]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		path     string
+		expected []string
+	}{
+		{
+			name:     "text list",
+			path:     "/origin-ip-acl.txt",
+			expected: []string{"23.32.0.0/11", "2600:1400::/24"},
+		},
+		{
+			name:     "site shield map",
+			path:     "/siteshield/v1/maps/1234",
+			expected: []string{"23.32.0.0/11", "23.192.0.0/11"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := NewAkamaiProvider(server.URL + tt.path)
+
+			prefixes, err := provider.Prefixes(context.Background())
+			require.NoError(t, err)
+
+			var got []string
+			for _, prefix := range prefixes {
+				got = append(got, prefix.String())
+			}
+			assert.Equal(t, tt.expected, got)
+			assert.True(t, provider.Contains(netip.MustParseAddr("23.32.0.1")))
+		})
+	}
+
+	provider := NewAkamaiProvider(server.URL + "/missing")
+	_, err := provider.Prefixes(context.Background())
+	assert.Error(t, err)
+}
+
+func TestCustomProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`["192.0.2.0/24", "2001:db8::/32"]`))
+	}))
+	defer server.Close()
+
+	provider := NewCustomProvider("corp-vpn", server.URL, "")
+	prefixes, err := provider.Prefixes(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, prefixes, 2)
+	assert.True(t, provider.Contains(netip.MustParseAddr("2001:db8::1")))
+	assert.False(t, provider.Contains(netip.MustParseAddr("198.51.100.1")))
+}
+
+func TestCustomTextAndCSVProviders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ips_webhooks.txt":
+			_, _ = w.Write([]byte("3.18.12.63\n3.130.192.231\n2001:db8::/32\n"))
+		case "/ips.csv":
+			_, _ = w.Write([]byte("region,cidr\nus,192.0.2.0/24\neu,198.51.100.0/24\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	textProvider := NewCustomTextProvider("stripe-webhooks", server.URL+"/ips_webhooks.txt")
+	prefixes, err := textProvider.Prefixes(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []netip.Prefix{
+		netip.MustParsePrefix("3.18.12.63/32"),
+		netip.MustParsePrefix("3.130.192.231/32"),
+		netip.MustParsePrefix("2001:db8::/32"),
+	}, prefixes)
+	assert.Equal(t, "custom-stripe-webhooks", textProvider.Name())
+
+	csvProvider := NewCustomCSVProvider("zendesk", server.URL+"/ips.csv", "cidr")
+	prefixes, err = csvProvider.Prefixes(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []netip.Prefix{
+		netip.MustParsePrefix("192.0.2.0/24"),
+		netip.MustParsePrefix("198.51.100.0/24"),
+	}, prefixes)
+	assert.True(t, csvProvider.Contains(netip.MustParseAddr("198.51.100.1")))
+}
+
 func TestProviderNames(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -97,6 +317,46 @@ func TestProviderNames(t *testing.T) {
 			provider: NewAWSProvider("EC2", "us-east-1"),
 			expected: "aws-EC2-us-east-1",
 		},
+		{
+			name:     "azure no filter",
+			provider: NewAzureProvider("", ""),
+			expected: "azure",
+		},
+		{
+			name:     "azure with service and region",
+			provider: NewAzureProvider("AzureCloud", "westeurope"),
+			expected: "azure-AzureCloud-westeurope",
+		},
+		{
+			name:     "oci",
+			provider: NewOCIProvider(nil, nil),
+			expected: "oci",
+		},
+		{
+			name:     "akamai",
+			provider: NewAkamaiProvider("https://example.com/akamai.txt"),
+			expected: "akamai",
+		},
+		{
+			name:     "custom",
+			provider: NewCustomProvider("", "https://example.com/prefixes.json", ""),
+			expected: "custom",
+		},
+		{
+			name:     "custom with name",
+			provider: NewCustomProvider("corp-vpn", "https://example.com/prefixes.json", "prefixes"),
+			expected: "custom-corp-vpn",
+		},
+		{
+			name:     "digitalocean",
+			provider: NewDigitalOceanProvider(nil),
+			expected: "digitalocean",
+		},
+		{
+			name:     "digitalocean with countries",
+			provider: NewDigitalOceanProvider([]string{"NL", "DE"}),
+			expected: "digitalocean-NL,DE",
+		},
 		{
 			name:     "fastly",
 			provider: NewFastlyProvider(),
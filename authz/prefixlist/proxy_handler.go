@@ -0,0 +1,56 @@
+package prefixlist
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"time"
+)
+
+// proxiedHeaders are the upstream response headers passed through to clients
+var proxiedHeaders = []string{"Content-Type", "ETag", "Cache-Control", "Expires"}
+
+// NewCachingProxyHandler returns an http.Handler that serves the document cached by
+// fetcher, fetching it from upstream only when the cache requires it.
+//
+// Range, If-Range and conditional requests are answered from the cached body, so
+// partial requests receive 206 Partial Content with a Content-Range header and
+// unsatisfiable ranges receive 416 Range Not Satisfiable. If nothing has been
+// fetched and the upstream request fails, 502 Bad Gateway is returned.
+func NewCachingProxyHandler(fetcher *CachingFetcher[[]byte]) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Detach from the request's cancellation so a background refresh
+		// started by this request is not aborted when the response completes.
+		body, headers, _, err := fetcher.GetWithHeaders(context.WithoutCancel(r.Context()))
+		if err != nil && body == nil {
+			http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+			return
+		}
+
+		for _, name := range proxiedHeaders {
+			if value := headers.Get(name); value != "" {
+				w.Header().Set(name, value)
+			}
+		}
+
+		http.ServeContent(w, r, "", lastModified(fetcher, headers), bytes.NewReader(body))
+	})
+}
+
+// lastModified returns the upstream Last-Modified time, falling back to when the
+// data was cached
+func lastModified(fetcher *CachingFetcher[[]byte], headers http.Header) time.Time {
+	if value := headers.Get("Last-Modified"); value != "" {
+		if t, err := http.ParseTime(value); err == nil {
+			return t
+		}
+	}
+	cachedAt, _, _ := fetcher.GetCacheInfo()
+	return cachedAt
+}
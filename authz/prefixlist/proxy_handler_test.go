@@ -0,0 +1,99 @@
+package prefixlist
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCachingProxyHandler(t *testing.T) {
+	const document = "0123456789abcdefghij"
+
+	var upstreamCalls atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamCalls.Add(1)
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+		w.Write([]byte(document))
+	}))
+	defer upstream.Close()
+
+	fetcher := NewBytesCachingFetcher(upstream.URL, CacheConfig{StaticExpiry: time.Hour})
+	handler := NewCachingProxyHandler(fetcher)
+
+	tests := []struct {
+		name             string
+		method           string
+		rangeHeader      string
+		ifRange          string
+		wantStatus       int
+		wantBody         string
+		wantContentRange string
+	}{
+		{name: "full document", method: http.MethodGet, wantStatus: http.StatusOK, wantBody: document},
+		{name: "byte range", method: http.MethodGet, rangeHeader: "bytes=0-4", wantStatus: http.StatusPartialContent, wantBody: "01234", wantContentRange: "bytes 0-4/20"},
+		{name: "suffix range", method: http.MethodGet, rangeHeader: "bytes=-5", wantStatus: http.StatusPartialContent, wantBody: "fghij", wantContentRange: "bytes 15-19/20"},
+		{name: "open range", method: http.MethodGet, rangeHeader: "bytes=18-", wantStatus: http.StatusPartialContent, wantBody: "ij", wantContentRange: "bytes 18-19/20"},
+		{name: "unsatisfiable range", method: http.MethodGet, rangeHeader: "bytes=100-200", wantStatus: http.StatusRequestedRangeNotSatisfiable, wantContentRange: "bytes */20"},
+		{name: "if-range mismatch returns full document", method: http.MethodGet, rangeHeader: "bytes=0-4", ifRange: `"v0"`, wantStatus: http.StatusOK, wantBody: document},
+		{name: "if-range match returns range", method: http.MethodGet, rangeHeader: "bytes=0-4", ifRange: `"v1"`, wantStatus: http.StatusPartialContent, wantBody: "01234", wantContentRange: "bytes 0-4/20"},
+		{name: "method not allowed", method: http.MethodPost, wantStatus: http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/", nil)
+			if tt.rangeHeader != "" {
+				req.Header.Set("Range", tt.rangeHeader)
+			}
+			if tt.ifRange != "" {
+				req.Header.Set("If-Range", tt.ifRange)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantBody != "" {
+				assert.Equal(t, tt.wantBody, rec.Body.String())
+			}
+			assert.Equal(t, tt.wantContentRange, rec.Header().Get("Content-Range"))
+			if tt.wantStatus == http.StatusOK || tt.wantStatus == http.StatusPartialContent {
+				assert.Equal(t, "bytes", rec.Header().Get("Accept-Ranges"))
+				assert.Equal(t, `"v1"`, rec.Header().Get("ETag"))
+			}
+		})
+	}
+
+	assert.Equal(t, int32(1), upstreamCalls.Load(), "ranges should be served from the cached body")
+}
+
+func TestCachingProxyHandler_UpstreamError(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer upstream.Close()
+
+	handler := NewCachingProxyHandler(NewBytesCachingFetcher(upstream.URL, CacheConfig{StaticExpiry: time.Hour}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusBadGateway, rec.Code)
+}
+
+func TestBytesCachingFetcher(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("not json"))
+	}))
+	defer upstream.Close()
+
+	fetcher := NewBytesCachingFetcher(upstream.URL, CacheConfig{StaticExpiry: time.Hour})
+	body, _, err := fetcher.Get(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, []byte("not json"), body)
+}
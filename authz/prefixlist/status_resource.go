@@ -0,0 +1,40 @@
+package prefixlist
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// StatusResource serves the status of the providers of a MultiProvider. It implements
+// the Resource and StatusResource interfaces of diohttp, so when added to a
+// diohttp.Server with AddResource, the server's /status endpoint reports the status of
+// each provider, including whether its prefixes are stale:
+//
+//	server.AddResource("/prefixlist", prefixlist.NewStatusResource(multiProvider))
+type StatusResource struct {
+	provider *MultiProvider
+}
+
+// NewStatusResource creates a resource serving the status of provider.
+func NewStatusResource(provider *MultiProvider) *StatusResource {
+	return &StatusResource{provider: provider}
+}
+
+// Handler returns a handler serving the status of each provider as JSON.
+func (r *StatusResource) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(r.provider.Status()); err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+	})
+	return mux
+}
+
+// Status returns the status of each provider, as returned by MultiProvider.Status.
+// Stale providers are reported rather than treated as errors, as their prefixes
+// remain in use.
+func (r *StatusResource) Status() (any, error) {
+	return r.provider.Status(), nil
+}
@@ -0,0 +1,44 @@
+package prefixlist
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatusResource(t *testing.T) {
+	m := NewMultiProvider([]Provider{
+		&mockProvider{name: "static", prefixes: []string{"10.0.0.0/8"}},
+		&mockProvider{name: "broken", fetchErr: assert.AnError},
+	}, zerolog.Nop())
+	_, err := m.Prefixes(t.Context())
+	require.NoError(t, err)
+
+	resource := NewStatusResource(m)
+
+	status, err := resource.Status()
+	require.NoError(t, err)
+	assert.Equal(t, m.Status(), status)
+
+	rec := httptest.NewRecorder()
+	resource.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var body []map[string]any
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	require.Len(t, body, 2)
+	assert.Equal(t, "static", body[0]["name"])
+	assert.Equal(t, 1.0, body[0]["prefixes"])
+	assert.Equal(t, false, body[0]["stale"])
+	assert.NotContains(t, body[0], "last_error")
+	assert.NotContains(t, body[0], "expires_at")
+	assert.Equal(t, "broken", body[1]["name"])
+	assert.Equal(t, assert.AnError.Error(), body[1]["last_error"])
+	assert.Equal(t, true, body[1]["stale"])
+}
@@ -2,13 +2,17 @@ package prefixlist
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"encoding/csv"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/netip"
+	"slices"
+	"strconv"
 	"strings"
-	"time"
 )
 
 // parseCommaSeparated parses comma-separated values into a slice
@@ -54,27 +58,48 @@ func parseCIDRs(cidrs []string) ([]netip.Prefix, error) {
 	return result, nil
 }
 
+// parsePrefixes parses a list of CIDR strings or bare addresses, as found in plain text
+// and CSV lists, into netip.Prefix objects. An address becomes a single host prefix.
+func parsePrefixes(values []string) ([]netip.Prefix, error) {
+	var result []netip.Prefix
+	seen := make(map[netip.Prefix]bool)
+
+	for _, value := range values {
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(value)
+			if addrErr != nil {
+				return nil, fmt.Errorf("invalid CIDR or address %q: %w", value, err)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+
+		// Skip duplicates
+		if seen[prefix] {
+			continue
+		}
+		seen[prefix] = true
+		result = append(result, prefix)
+	}
+
+	return result, nil
+}
+
 // FetchTextLines is a fetch function that retrieves plain text lines from an HTTP endpoint.
 // It returns a slice of non-empty, non-comment lines. Lines starting with '#' are
 // treated as comments and ignored.
 func FetchTextLines(ctx context.Context, url string) ([]string, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("create request: %w", err)
-	}
+	return CacheConfig{}.fetchTextLines(ctx, url)
+}
 
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+// fetchTextLines retrieves plain text lines using the configured client, User-Agent and headers
+func (c CacheConfig) fetchTextLines(ctx context.Context, url string) ([]string, error) {
+	body, err := c.fetchBody(ctx, url)
 	if err != nil {
-		return nil, fmt.Errorf("http request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return nil, err
 	}
 
-	return parseTextLines(resp.Body)
+	return parseTextLines(bytes.NewReader(body))
 }
 
 // parseTextLines parses plain text list of items (one per line)
@@ -94,3 +119,109 @@ func parseTextLines(r io.Reader) ([]string, error) {
 
 	return lines, nil
 }
+
+// FetchCSVRecords is a fetch function that retrieves CSV records from an HTTP endpoint.
+// It returns the non-empty records, which may have differing numbers of fields. Lines
+// starting with '#' are treated as comments and ignored.
+func FetchCSVRecords(ctx context.Context, url string) ([][]string, error) {
+	return CacheConfig{}.fetchCSVRecords(ctx, url)
+}
+
+// fetchCSVRecords retrieves CSV records using the configured client, User-Agent and headers
+func (c CacheConfig) fetchCSVRecords(ctx context.Context, url string) ([][]string, error) {
+	body, err := c.fetchBody(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseCSVRecords(bytes.NewReader(body))
+}
+
+// fetchBody retrieves the body of url using the configured client, User-Agent and headers
+func (c CacheConfig) fetchBody(ctx context.Context, url string) ([]byte, error) {
+	req, err := c.newRequest(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.clientFor(req).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("http request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+	return body, nil
+}
+
+// parseCSVRecords parses CSV records, trimming the spaces around each field
+func parseCSVRecords(r io.Reader) ([][]string, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var records [][]string
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse csv: %w", err)
+		}
+
+		for i := range record {
+			record[i] = strings.TrimSpace(record[i])
+		}
+		if len(record) == 1 && record[0] == "" {
+			continue
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// CSVColumn returns a transform for HTTPCSVProvider that reads the prefixes, as CIDRs or
+// bare addresses, from one column of the records. column is either a 0-based index, or
+// the name of a column in the first record, which is then treated as a header. Empty
+// fields are skipped.
+func CSVColumn(column string) TransformFunc[[][]string] {
+	return func(records [][]string) ([]netip.Prefix, error) {
+		index, err := strconv.Atoi(column)
+		if err != nil {
+			if len(records) == 0 {
+				return nil, nil
+			}
+			index = slices.IndexFunc(records[0], func(name string) bool {
+				return strings.EqualFold(name, column)
+			})
+			if index < 0 {
+				return nil, fmt.Errorf("no column %q in csv header", column)
+			}
+			records = records[1:]
+		} else if index < 0 {
+			return nil, fmt.Errorf("invalid csv column %d", index)
+		}
+
+		var values []string
+		for i, record := range records {
+			if index >= len(record) {
+				return nil, fmt.Errorf("csv record %d has no column %d", i+1, index)
+			}
+			if record[index] != "" {
+				values = append(values, record[index])
+			}
+		}
+
+		return parsePrefixes(values)
+	}
+}
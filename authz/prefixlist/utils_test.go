@@ -1,6 +1,8 @@
 package prefixlist
 
 import (
+	"net/netip"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -104,3 +106,136 @@ func TestParseCIDRs(t *testing.T) {
 		})
 	}
 }
+
+func TestParsePrefixes(t *testing.T) {
+	got, err := parsePrefixes([]string{"192.0.2.0/24", "198.51.100.7", "2001:db8::1", "198.51.100.7/32"})
+	require.NoError(t, err)
+	assert.Equal(t, []netip.Prefix{
+		netip.MustParsePrefix("192.0.2.0/24"),
+		netip.MustParsePrefix("198.51.100.7/32"),
+		netip.MustParsePrefix("2001:db8::1/128"),
+	}, got)
+
+	_, err = parsePrefixes([]string{"not-an-address"})
+	assert.Error(t, err)
+}
+
+func TestParseCSVRecords(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    [][]string
+		wantErr bool
+	}{
+		{
+			name:  "geofeed",
+			input: "# comment\n5.101.96.0/21,NL,NL-NH,Amsterdam,\n2a03:b0c0::/48, US ,US-NY,New York,10013\n",
+			want: [][]string{
+				{"5.101.96.0/21", "NL", "NL-NH", "Amsterdam", ""},
+				{"2a03:b0c0::/48", "US", "US-NY", "New York", "10013"},
+			},
+		},
+		{
+			name:  "varying fields and blank lines",
+			input: "10.0.0.0/8\n\n192.168.0.0/16,GB\n",
+			want:  [][]string{{"10.0.0.0/8"}, {"192.168.0.0/16", "GB"}},
+		},
+		{
+			name:  "quoted fields",
+			input: `10.0.0.0/8,US,US-CA,"San Francisco, CA",94107`,
+			want:  [][]string{{"10.0.0.0/8", "US", "US-CA", "San Francisco, CA", "94107"}},
+		},
+		{
+			name:  "empty",
+			input: "",
+			want:  nil,
+		},
+		{
+			name:    "unterminated quote",
+			input:   `10.0.0.0/8,"US`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseCSVRecords(strings.NewReader(tt.input))
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCSVColumn(t *testing.T) {
+	records := [][]string{
+		{"Region", "IP Address"},
+		{"us", "192.0.2.0/24"},
+		{"eu", ""},
+		{"eu", "198.51.100.7"},
+	}
+
+	tests := []struct {
+		name    string
+		column  string
+		records [][]string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:    "header name",
+			column:  "ip address",
+			records: records,
+			want:    []string{"192.0.2.0/24", "198.51.100.7/32"},
+		},
+		{
+			name:    "index",
+			column:  "1",
+			records: records[1:],
+			want:    []string{"192.0.2.0/24", "198.51.100.7/32"},
+		},
+		{
+			name:    "index includes header",
+			column:  "1",
+			records: records,
+			wantErr: true,
+		},
+		{
+			name:    "unknown header",
+			column:  "cidr",
+			records: records,
+			wantErr: true,
+		},
+		{
+			name:    "index out of range",
+			column:  "2",
+			records: records[1:],
+			wantErr: true,
+		},
+		{
+			name:    "negative index",
+			column:  "-1",
+			records: records[1:],
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefixes, err := CSVColumn(tt.column)(tt.records)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			var got []string
+			for _, prefix := range prefixes {
+				got = append(got, prefix.String())
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
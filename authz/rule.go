@@ -0,0 +1,463 @@
+package authz
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ConnMetadata describes a connection for evaluating Rules. Fields that are not known
+// are left at their zero value.
+type ConnMetadata struct {
+	// RemoteAddr is the client's address and port.
+	RemoteAddr netip.AddrPort
+	// LocalAddr is the address and port the connection was accepted on.
+	LocalAddr netip.AddrPort
+	// ServerName is the TLS server name (SNI) requested by the client.
+	ServerName string
+}
+
+// ConnMetadataFromConn returns the metadata of c. The server name is only known once
+// the TLS handshake of a *tls.Conn (or any conn with a ConnectionState method) has
+// completed. An error is returned if the remote address is not an IP address.
+func ConnMetadataFromConn(c net.Conn) (ConnMetadata, error) {
+	remote, err := addrPortFromAddr(c.RemoteAddr())
+	if err != nil {
+		return ConnMetadata{}, err
+	}
+
+	md := ConnMetadata{RemoteAddr: remote}
+	if local, err := addrPortFromAddr(c.LocalAddr()); err == nil {
+		md.LocalAddr = local
+	}
+	if tlsConn, ok := c.(interface{ ConnectionState() tls.ConnectionState }); ok {
+		if state := tlsConn.ConnectionState(); state.HandshakeComplete {
+			md.ServerName = state.ServerName
+		}
+	}
+
+	return md, nil
+}
+
+func addrPortFromAddr(addr net.Addr) (netip.AddrPort, error) {
+	switch addr := addr.(type) {
+	case *net.TCPAddr:
+		return addr.AddrPort(), nil
+	case *net.UDPAddr:
+		return addr.AddrPort(), nil
+	case nil:
+		return netip.AddrPort{}, errors.New("nil address")
+	}
+
+	if addrPort, err := netip.ParseAddrPort(addr.String()); err == nil {
+		return addrPort, nil
+	}
+	if ip, ok := parseIPWithOptionalPort(addr.String()); ok {
+		ipAddr, _ := netip.AddrFromSlice(ip)
+		return netip.AddrPortFrom(ipAddr, 0), nil
+	}
+	return netip.AddrPort{}, fmt.Errorf("invalid IP address: %s", addr)
+}
+
+// Rule is a condition on connection metadata, parsed from an expression such as
+//
+//	ip in 10.0.0.0/8 && port != 22
+//	sni in ["*.example.com", "example.com"] || ip == 192.0.2.1
+//
+// Comparisons take the form "field op value" and combine with &&, || and !, with
+// parentheses for grouping; && binds more tightly than ||. The fields are:
+//
+//	ip           the client's address
+//	remote_port  the client's port
+//	local_ip     the address the connection was accepted on
+//	port         the port the connection was accepted on
+//	sni          the TLS server name requested by the client
+//
+// The addresses support ==, !=, in and not in, against an address, a CIDR, the name of
+// a built-in network set such as private (see NamedNetworks) or a list of these in
+// square brackets, so "ip == 10.0.0.0/8" and "ip in 10.0.0.0/8" are the same.
+// The ports additionally support <, <=, > and >=. sni supports ==, !=, in and not in
+// against quoted or bare names, compared case-insensitively, where a name such as
+// "*.example.com" matches any subdomain of example.com.
+//
+// A comparison against a field that is not known, such as sni before the TLS handshake,
+// is false, whatever the operator.
+type Rule struct {
+	expr string
+	root ruleNode
+}
+
+// ParseRule parses a rule expression.
+func ParseRule(expr string) (*Rule, error) {
+	tokens, err := tokenizeRule(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rule %q: %w", expr, err)
+	}
+
+	p := &ruleParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err == nil && p.peek().kind != ruleTokenEOF {
+		err = p.errorf("unexpected %q", p.peek().text)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid rule %q: %w", expr, err)
+	}
+
+	return &Rule{expr: expr, root: root}, nil
+}
+
+// Match reports whether md satisfies the rule.
+func (r *Rule) Match(md ConnMetadata) bool {
+	return r.root.match(md)
+}
+
+// String returns the expression the rule was parsed from.
+func (r *Rule) String() string {
+	return r.expr
+}
+
+func matchRules(rules []*Rule, md ConnMetadata) bool {
+	return slices.ContainsFunc(rules, func(r *Rule) bool { return r.Match(md) })
+}
+
+type ruleNode interface {
+	match(md ConnMetadata) bool
+}
+
+type ruleAnd struct{ left, right ruleNode }
+
+func (n ruleAnd) match(md ConnMetadata) bool { return n.left.match(md) && n.right.match(md) }
+
+type ruleOr struct{ left, right ruleNode }
+
+func (n ruleOr) match(md ConnMetadata) bool { return n.left.match(md) || n.right.match(md) }
+
+type ruleNot struct{ node ruleNode }
+
+func (n ruleNot) match(md ConnMetadata) bool { return !n.node.match(md) }
+
+type ruleComparison struct {
+	field    string
+	op       string
+	prefixes []netip.Prefix
+	ports    []uint16
+	names    []string
+}
+
+func (c *ruleComparison) match(md ConnMetadata) bool {
+	switch c.field {
+	case "ip", "local_ip":
+		addrPort := md.RemoteAddr
+		if c.field == "local_ip" {
+			addrPort = md.LocalAddr
+		}
+		addr := addrPort.Addr().Unmap().WithZone("")
+		if !addr.IsValid() {
+			return false
+		}
+		return slices.ContainsFunc(c.prefixes, func(p netip.Prefix) bool { return p.Contains(addr) }) != c.negated()
+	case "port", "remote_port":
+		addrPort := md.LocalAddr
+		if c.field == "remote_port" {
+			addrPort = md.RemoteAddr
+		}
+		if !addrPort.IsValid() {
+			return false
+		}
+		return c.matchPort(addrPort.Port())
+	default: // sni
+		if md.ServerName == "" {
+			return false
+		}
+		name := strings.ToLower(strings.TrimSuffix(md.ServerName, "."))
+		return slices.ContainsFunc(c.names, func(pattern string) bool { return matchServerName(pattern, name) }) != c.negated()
+	}
+}
+
+func (c *ruleComparison) negated() bool {
+	return c.op == "!=" || c.op == "not in"
+}
+
+func (c *ruleComparison) matchPort(port uint16) bool {
+	switch c.op {
+	case "<":
+		return port < c.ports[0]
+	case "<=":
+		return port <= c.ports[0]
+	case ">":
+		return port > c.ports[0]
+	case ">=":
+		return port >= c.ports[0]
+	default:
+		return slices.Contains(c.ports, port) != c.negated()
+	}
+}
+
+// matchServerName reports whether name matches pattern, where a pattern beginning with
+// "*." matches any subdomain of the rest of the pattern.
+func matchServerName(pattern, name string) bool {
+	if suffix, ok := strings.CutPrefix(pattern, "*"); ok && strings.HasPrefix(suffix, ".") {
+		return strings.HasSuffix(name, suffix) && len(name) > len(suffix)
+	}
+	return pattern == name
+}
+
+type ruleTokenKind int
+
+const (
+	ruleTokenEOF ruleTokenKind = iota
+	ruleTokenWord
+	ruleTokenString
+	ruleTokenOp
+)
+
+type ruleToken struct {
+	kind ruleTokenKind
+	text string
+	pos  int
+}
+
+// ruleOps are the operator and punctuation tokens, longest first.
+var ruleOps = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "(", ")", "[", "]", ","}
+
+func isRuleWordChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		strings.IndexByte("._-:/*%", c) >= 0
+}
+
+func tokenizeRule(expr string) ([]ruleToken, error) {
+	var tokens []ruleToken
+
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '"':
+			end := strings.IndexByte(expr[i+1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string at offset %d", i)
+			}
+			tokens = append(tokens, ruleToken{kind: ruleTokenString, text: expr[i+1 : i+1+end], pos: i})
+			i += end + 2
+		case isRuleWordChar(c):
+			start := i
+			for i < len(expr) && isRuleWordChar(expr[i]) {
+				i++
+			}
+			tokens = append(tokens, ruleToken{kind: ruleTokenWord, text: expr[start:i], pos: start})
+		default:
+			op, ok := findRuleOp(expr[i:])
+			if !ok {
+				return nil, fmt.Errorf("unexpected %q at offset %d", c, i)
+			}
+			tokens = append(tokens, ruleToken{kind: ruleTokenOp, text: op, pos: i})
+			i += len(op)
+		}
+	}
+
+	return append(tokens, ruleToken{kind: ruleTokenEOF, pos: len(expr)}), nil
+}
+
+func findRuleOp(s string) (string, bool) {
+	for _, op := range ruleOps {
+		if strings.HasPrefix(s, op) {
+			return op, true
+		}
+	}
+	return "", false
+}
+
+type ruleParser struct {
+	tokens []ruleToken
+	pos    int
+}
+
+func (p *ruleParser) peek() ruleToken {
+	return p.tokens[p.pos]
+}
+
+func (p *ruleParser) next() ruleToken {
+	t := p.tokens[p.pos]
+	if t.kind != ruleTokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *ruleParser) acceptOp(op string) bool {
+	if t := p.peek(); t.kind == ruleTokenOp && t.text == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *ruleParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("%s at offset %d", fmt.Sprintf(format, args...), p.peek().pos)
+}
+
+func (p *ruleParser) parseOr() (ruleNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptOp("||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = ruleOr{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *ruleParser) parseAnd() (ruleNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptOp("&&") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = ruleAnd{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *ruleParser) parseUnary() (ruleNode, error) {
+	if p.acceptOp("!") {
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return ruleNot{node: node}, nil
+	}
+
+	if p.acceptOp("(") {
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.acceptOp(")") {
+			return nil, p.errorf("expected \")\"")
+		}
+		return node, nil
+	}
+
+	return p.parseComparison()
+}
+
+func (p *ruleParser) parseComparison() (ruleNode, error) {
+	field := p.peek()
+	if field.kind != ruleTokenWord {
+		return nil, p.errorf("expected a field")
+	}
+	p.next()
+
+	c := &ruleComparison{field: strings.ToLower(field.text)}
+	switch c.field {
+	case "ip", "local_ip", "port", "remote_port", "sni":
+	default:
+		return nil, fmt.Errorf("unknown field %q at offset %d", field.text, field.pos)
+	}
+
+	opToken := p.next()
+	switch {
+	case opToken.kind == ruleTokenOp && slices.Contains([]string{"==", "!=", "<", "<=", ">", ">="}, opToken.text):
+		c.op = opToken.text
+	case opToken.kind == ruleTokenWord && opToken.text == "in":
+		c.op = "in"
+	case opToken.kind == ruleTokenWord && opToken.text == "not":
+		if t := p.next(); t.kind != ruleTokenWord || t.text != "in" {
+			return nil, fmt.Errorf("expected \"in\" after \"not\" at offset %d", t.pos)
+		}
+		c.op = "not in"
+	default:
+		return nil, fmt.Errorf("expected an operator after %q at offset %d", field.text, opToken.pos)
+	}
+
+	isPort := c.field == "port" || c.field == "remote_port"
+	switch c.op {
+	case "<", "<=", ">", ">=":
+		if !isPort {
+			return nil, fmt.Errorf("operator %q is not supported for %q", c.op, c.field)
+		}
+	}
+
+	values, err := p.parseValues(c.op == "in" || c.op == "not in")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range values {
+		switch {
+		case isPort:
+			port, err := strconv.ParseUint(v.text, 10, 16)
+			if err != nil {
+				return nil, fmt.Errorf("invalid port %q at offset %d", v.text, v.pos)
+			}
+			c.ports = append(c.ports, uint16(port))
+		case c.field == "sni":
+			c.names = append(c.names, strings.ToLower(strings.TrimSuffix(v.text, ".")))
+		default:
+			networks, ok := NamedNetwork(v.text)
+			if !ok {
+				networks = []string{v.text}
+			}
+			for _, n := range networks {
+				prefix, err := parseRulePrefix(n)
+				if err != nil {
+					return nil, fmt.Errorf("invalid network %q at offset %d: %w", v.text, v.pos, err)
+				}
+				c.prefixes = append(c.prefixes, prefix)
+			}
+		}
+	}
+
+	return c, nil
+}
+
+// parseValues parses a single value or, if list is true, a bracketed list of values.
+func (p *ruleParser) parseValues(list bool) ([]ruleToken, error) {
+	if !list || !p.acceptOp("[") {
+		v := p.next()
+		if v.kind != ruleTokenWord && v.kind != ruleTokenString {
+			return nil, fmt.Errorf("expected a value at offset %d", v.pos)
+		}
+		return []ruleToken{v}, nil
+	}
+
+	var values []ruleToken
+	for {
+		v := p.next()
+		if v.kind != ruleTokenWord && v.kind != ruleTokenString {
+			return nil, fmt.Errorf("expected a value at offset %d", v.pos)
+		}
+		values = append(values, v)
+		if p.acceptOp("]") {
+			return values, nil
+		}
+		if !p.acceptOp(",") {
+			return nil, p.errorf("expected \",\" or \"]\"")
+		}
+	}
+}
+
+// parseRulePrefix parses an address or CIDR as parseTCPNet does, as a netip.Prefix.
+func parseRulePrefix(s string) (netip.Prefix, error) {
+	n, err := parseTCPNet(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr, _ := netip.AddrFromSlice(n.IP)
+	ones, _ := n.Mask.Size()
+	return netip.PrefixFrom(addr.Unmap(), ones), nil
+}
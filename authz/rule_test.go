@@ -0,0 +1,119 @@
+package authz
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuleMatch(t *testing.T) {
+	md := ConnMetadata{
+		RemoteAddr: netip.MustParseAddrPort("10.1.2.3:50000"),
+		LocalAddr:  netip.MustParseAddrPort("192.0.2.1:443"),
+		ServerName: "api.example.com",
+	}
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{expr: "ip in 10.0.0.0/8", want: true},
+		{expr: "ip == 10.1.2.3", want: true},
+		{expr: "ip != 10.0.0.0/8", want: false},
+		{expr: "ip not in [192.168.0.0/16, 172.16.0.0/12]", want: true},
+		{expr: "ip in ::ffff:10.0.0.0/104", want: true},
+		{expr: "local_ip == 192.0.2.1", want: true},
+		{expr: "ip in 10.0.0.0/8 && port != 22", want: true},
+		{expr: "ip in 10.0.0.0/8 && port == 22", want: false},
+		{expr: "port in [80, 443]", want: true},
+		{expr: "port < 1024", want: true},
+		{expr: "remote_port >= 50000", want: true},
+		{expr: "remote_port > 50000", want: false},
+		{expr: "sni == \"API.example.com.\"", want: true},
+		{expr: "sni in [\"*.example.com\"]", want: true},
+		{expr: "sni == *.example.com", want: true},
+		{expr: "sni == \"*.api.example.com\"", want: false},
+		{expr: "sni not in [other.example.com]", want: true},
+		{expr: "port == 22 || sni == api.example.com", want: true},
+		{expr: "!(port == 22)", want: true},
+		{expr: "!port == 443", want: false},
+		{expr: "port == 22 || ip in 10.0.0.0/8 && sni == other.example.com", want: false},
+		{expr: "(port == 22 || ip in 10.0.0.0/8) && sni == api.example.com", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			rule, err := ParseRule(tt.expr)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, rule.Match(md))
+			assert.Equal(t, tt.expr, rule.String())
+		})
+	}
+}
+
+func TestRuleMatchUnknownFields(t *testing.T) {
+	md := ConnMetadata{RemoteAddr: netip.MustParseAddrPort("10.1.2.3:50000")}
+
+	for _, expr := range []string{"port != 22", "sni != example.com", "local_ip not in 10.0.0.0/8"} {
+		rule, err := ParseRule(expr)
+		require.NoError(t, err)
+		assert.False(t, rule.Match(md), expr)
+	}
+}
+
+func TestParseRuleErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"ip",
+		"ip in",
+		"host == example.com",
+		"ip ~ 10.0.0.0/8",
+		"ip < 10.0.0.1",
+		"ip in not-a-network",
+		"port == 65536",
+		"port in [80, 443",
+		"port in [80 443]",
+		"(port == 80",
+		"port == 80)",
+		"port == 80 &&",
+		"sni == \"unterminated",
+		"ip not 10.0.0.0/8",
+		"port == 80 $ port == 443",
+	}
+
+	for _, expr := range tests {
+		t.Run(expr, func(t *testing.T) {
+			_, err := ParseRule(expr)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestConnMetadataFromConn(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	client, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	defer client.Close()
+
+	server, err := ln.Accept()
+	require.NoError(t, err)
+	defer server.Close()
+
+	md, err := ConnMetadataFromConn(server)
+	require.NoError(t, err)
+	assert.Equal(t, client.LocalAddr().(*net.TCPAddr).AddrPort(), md.RemoteAddr)
+	assert.Equal(t, ln.Addr().(*net.TCPAddr).AddrPort(), md.LocalAddr)
+	assert.Empty(t, md.ServerName)
+
+	pipe, other := net.Pipe()
+	defer pipe.Close()
+	defer other.Close()
+	_, err = ConnMetadataFromConn(pipe)
+	assert.Error(t, err)
+}
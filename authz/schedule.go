@@ -0,0 +1,231 @@
+package authz
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dioad/generics"
+)
+
+// Schedule is a set of times given by a cron expression, used to limit rules to time
+// windows. A time is in the schedule if its minute, hour, day of month, month and day of
+// week all match the expression's five fields, as cron would run a job in that minute.
+// For example "* 9-16 * * Mon-Fri" is 09:00 to 16:59 on weekdays.
+//
+// Fields accept "*", numbers, ranges such as "9-16", lists such as "1,15" and steps
+// such as "*/15" or "0-30/10". Months and days of week may be given by their
+// three-letter English names, and Sunday is day 0 or 7. As in cron, if both the day of
+// month and the day of week are restricted, a time matches if either does.
+//
+// Times are matched in UTC unless the expression starts with a time zone, as in
+// "TZ=Europe/London * 9-16 * * 1-5". CRON_TZ= is accepted as a synonym for TZ=.
+type Schedule struct {
+	expr     string
+	location *time.Location
+	minute   uint64
+	hour     uint64
+	dom      uint64
+	month    uint64
+	dow      uint64
+	// domStar and dowStar are set if the day fields are unrestricted.
+	domStar bool
+	dowStar bool
+}
+
+var (
+	monthNames = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+	dayNames   = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+)
+
+// ParseSchedule parses a cron expression into a Schedule.
+func ParseSchedule(expr string) (*Schedule, error) {
+	s := &Schedule{expr: expr, location: time.UTC}
+
+	fields := strings.Fields(expr)
+	if len(fields) > 0 {
+		zone, ok := strings.CutPrefix(fields[0], "TZ=")
+		if !ok {
+			zone, ok = strings.CutPrefix(fields[0], "CRON_TZ=")
+		}
+		if ok {
+			location, err := time.LoadLocation(zone)
+			if err != nil {
+				return nil, fmt.Errorf("invalid time zone %q: %w", zone, err)
+			}
+			s.location = location
+			fields = fields[1:]
+		}
+	}
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expected 5 fields, got %d", len(fields))
+	}
+
+	var err error
+	if s.minute, err = parseScheduleField(fields[0], 0, 59, nil); err != nil {
+		return nil, fmt.Errorf("invalid minute %q: %w", fields[0], err)
+	}
+	if s.hour, err = parseScheduleField(fields[1], 0, 23, nil); err != nil {
+		return nil, fmt.Errorf("invalid hour %q: %w", fields[1], err)
+	}
+	if s.dom, err = parseScheduleField(fields[2], 1, 31, nil); err != nil {
+		return nil, fmt.Errorf("invalid day of month %q: %w", fields[2], err)
+	}
+	if s.month, err = parseScheduleField(fields[3], 1, 12, monthNames); err != nil {
+		return nil, fmt.Errorf("invalid month %q: %w", fields[3], err)
+	}
+	if s.dow, err = parseScheduleField(fields[4], 0, 7, dayNames); err != nil {
+		return nil, fmt.Errorf("invalid day of week %q: %w", fields[4], err)
+	}
+	// Sunday is both 0 and 7
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domStar = strings.HasPrefix(fields[2], "*")
+	s.dowStar = strings.HasPrefix(fields[4], "*")
+
+	return s, nil
+}
+
+// Contains reports whether t is in the schedule.
+func (s *Schedule) Contains(t time.Time) bool {
+	t = t.In(s.location)
+	if s.minute&(1<<t.Minute()) == 0 || s.hour&(1<<t.Hour()) == 0 || s.month&(1<<int(t.Month())) == 0 {
+		return false
+	}
+
+	domMatch := s.dom&(1<<t.Day()) != 0
+	dowMatch := s.dow&(1<<int(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// String returns the expression the schedule was parsed from.
+func (s *Schedule) String() string {
+	return s.expr
+}
+
+// parseScheduleField parses a comma-separated cron field into a bit set of the values
+// between lo and hi that it matches. names, if given, are accepted in place of the
+// values from lo upwards.
+func parseScheduleField(field string, lo, hi int, names []string) (uint64, error) {
+	var bits uint64
+	for part := range strings.SplitSeq(field, ",") {
+		valueRange, stepText, hasStep := strings.Cut(part, "/")
+
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepText)
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q", stepText)
+			}
+		}
+
+		var first, last int
+		switch {
+		case valueRange == "*":
+			first, last = lo, hi
+		default:
+			firstText, lastText, isRange := strings.Cut(valueRange, "-")
+			var err error
+			if first, err = parseScheduleValue(firstText, lo, hi, names); err != nil {
+				return 0, err
+			}
+			last = first
+			if isRange {
+				if last, err = parseScheduleValue(lastText, lo, hi, names); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				last = hi
+			}
+			if last < first {
+				return 0, fmt.Errorf("invalid range %q", valueRange)
+			}
+		}
+
+		for v := first; v <= last; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+func parseScheduleValue(s string, lo, hi int, names []string) (int, error) {
+	for i, name := range names {
+		if strings.EqualFold(s, name) {
+			return lo + i, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < lo || v > hi {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	return v, nil
+}
+
+// timeWindow is a TimeWindowConfig ready for matching.
+type timeWindow struct {
+	schedule   *Schedule
+	allow      *prefixTrie
+	deny       *prefixTrie
+	allowRules []*Rule
+	denyRules  []*Rule
+}
+
+func newTimeWindow(cfg TimeWindowConfig) (*timeWindow, error) {
+	schedule, err := ParseSchedule(cfg.Schedule)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: %w", cfg.Schedule, err)
+	}
+
+	allowNetworks, err := parseNets(cfg.AllowedNets)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse allowed networks: %w", err)
+	}
+
+	denyNetworks, err := parseNets(cfg.DeniedNets)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse denied networks: %w", err)
+	}
+
+	allowRules, err := generics.Map(ParseRule, cfg.AllowedRules)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse allowed rules: %w", err)
+	}
+
+	denyRules, err := generics.Map(ParseRule, cfg.DeniedRules)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse denied rules: %w", err)
+	}
+
+	return &timeWindow{
+		schedule:   schedule,
+		allow:      newPrefixTrie(allowNetworks),
+		deny:       newPrefixTrie(denyNetworks),
+		allowRules: allowRules,
+		denyRules:  denyRules,
+	}, nil
+}
+
+// matchTimeWindows reports whether a connection from ip with metadata md is allowed or
+// denied by any of windows whose schedule contains now.
+func matchTimeWindows(windows []*timeWindow, now time.Time, ip net.IP, md ConnMetadata) (inAllow, inDeny bool) {
+	for _, w := range windows {
+		if !w.schedule.Contains(now) {
+			continue
+		}
+		if w.deny.contains(ip) || matchRules(w.denyRules, md) {
+			inDeny = true
+		}
+		if w.allow.contains(ip) || matchRules(w.allowRules, md) {
+			inAllow = true
+		}
+	}
+	return inAllow, inDeny
+}
@@ -0,0 +1,75 @@
+package authz
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchedule(t *testing.T) {
+	// 2026-03-02 is a Monday
+	monday := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		expr string
+		time time.Time
+		want bool
+	}{
+		{expr: "* * * * *", time: monday, want: true},
+		{expr: "* 9-16 * * Mon-Fri", time: monday.Add(9 * time.Hour), want: true},
+		{expr: "* 9-16 * * Mon-Fri", time: monday.Add(16*time.Hour + 59*time.Minute), want: true},
+		{expr: "* 9-16 * * Mon-Fri", time: monday.Add(17 * time.Hour), want: false},
+		{expr: "* 9-16 * * Mon-Fri", time: monday.Add(-time.Hour), want: false}, // Sunday 23:00
+		{expr: "* 9-16 * * 1-5", time: monday.AddDate(0, 0, 5).Add(10 * time.Hour), want: false},
+		{expr: "*/15 * * * *", time: monday.Add(45 * time.Minute), want: true},
+		{expr: "*/15 * * * *", time: monday.Add(46 * time.Minute), want: false},
+		{expr: "0-30/10 * * * *", time: monday.Add(20 * time.Minute), want: true},
+		{expr: "0-30/10 * * * *", time: monday.Add(40 * time.Minute), want: false},
+		{expr: "5/20 * * * *", time: monday.Add(45 * time.Minute), want: true},
+		{expr: "0 0 1,15 * *", time: monday.AddDate(0, 0, 13), want: true},
+		{expr: "* * * dec,Jan *", time: monday, want: false},
+		{expr: "* * * mar *", time: monday, want: true},
+		{expr: "* * * * 7", time: monday.AddDate(0, 0, -1), want: true},
+		{expr: "* * * * sun", time: monday.AddDate(0, 0, -1), want: true},
+		// either a restricted day of month or a restricted day of week matches
+		{expr: "* * 13 * mon", time: monday, want: true},
+		{expr: "* * 2 * fri", time: monday, want: true},
+		{expr: "* * 3 * fri", time: monday, want: false},
+		{expr: "* * */2 * mon", time: monday.AddDate(0, 0, 1), want: false},
+		{expr: "TZ=America/New_York * 9-16 * * *", time: monday.Add(14 * time.Hour), want: true},
+		{expr: "CRON_TZ=America/New_York * 9-16 * * *", time: monday.Add(9 * time.Hour), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			s, err := ParseSchedule(tt.expr)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, s.Contains(tt.time), tt.time)
+			assert.Equal(t, tt.expr, s.String())
+		})
+	}
+}
+
+func TestParseScheduleErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"* 17-9 * * *",
+		"*/0 * * * *",
+		"* * * * funday",
+		"TZ=Nowhere/Special * * * * *",
+	} {
+		t.Run(expr, func(t *testing.T) {
+			_, err := ParseSchedule(expr)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package net
+
+import (
+	"errors"
+	"fmt"
+	"net"
+)
+
+// IsClosedErr reports whether err indicates that a listener or connection has been
+// closed, e.g. the error returned by Accept after Close. Use it in accept loops to
+// detect shutdown instead of matching on the error text:
+//
+//	conn, err := ln.Accept()
+//	if net2.IsClosedErr(err) {
+//		return
+//	}
+func IsClosedErr(err error) bool {
+	return errors.Is(err, net.ErrClosed)
+}
+
+// WrapClosedErr returns err wrapped so that it matches net.ErrClosed. It is used by
+// listener wrappers to report an Accept error after Close consistently, whatever error
+// the underlying listener returned. A nil err, or one that already matches
+// net.ErrClosed, is returned unchanged.
+func WrapClosedErr(err error) error {
+	if err == nil || errors.Is(err, net.ErrClosed) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", net.ErrClosed, err)
+}
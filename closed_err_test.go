@@ -0,0 +1,84 @@
+package net
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubListener returns a plain error, not matching net.ErrClosed, once closed.
+type stubListener struct {
+	closed chan struct{}
+}
+
+func newStubListener() *stubListener {
+	return &stubListener{closed: make(chan struct{})}
+}
+
+func (l *stubListener) Accept() (net.Conn, error) {
+	<-l.closed
+	return nil, errors.New("listener shut down")
+}
+
+func (l *stubListener) Close() error {
+	close(l.closed)
+	return nil
+}
+
+func (l *stubListener) Addr() net.Addr {
+	return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)}
+}
+
+func TestIsClosedErr(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "nil", err: nil, want: false},
+		{name: "other", err: errors.New("boom"), want: false},
+		{name: "ErrClosed", err: net.ErrClosed, want: true},
+		{name: "OpError", err: &net.OpError{Op: "accept", Err: net.ErrClosed}, want: true},
+		{name: "wrapped", err: fmt.Errorf("accept: %w", net.ErrClosed), want: true},
+		{name: "WrapClosedErr", err: WrapClosedErr(errors.New("boom")), want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsClosedErr(tt.err))
+		})
+	}
+}
+
+func TestWrapClosedErr(t *testing.T) {
+	assert.NoError(t, WrapClosedErr(nil))
+	assert.Equal(t, net.ErrClosed, WrapClosedErr(net.ErrClosed))
+
+	inner := errors.New("boom")
+	err := WrapClosedErr(inner)
+	assert.ErrorIs(t, err, net.ErrClosed)
+	assert.ErrorIs(t, err, inner)
+}
+
+func TestTrackingListenerAcceptAfterClose(t *testing.T) {
+	tl := NewTrackingListener(newStubListener())
+	require.NoError(t, tl.Close())
+
+	_, err := tl.Accept()
+	assert.True(t, IsClosedErr(err))
+}
+
+func TestTrackingListenerAcceptAfterCloseTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	tl := NewTrackingListener(ln)
+	require.NoError(t, tl.Close())
+
+	_, err = tl.Accept()
+	assert.True(t, IsClosedErr(err))
+}
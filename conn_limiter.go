@@ -0,0 +1,88 @@
+package net
+
+import (
+	"net"
+	"sync"
+)
+
+// ConnLimiter bounds the number of concurrently open connections.
+// Slots are acquired before a connection is handed out and released when
+// the connection returned by Track is closed.
+type ConnLimiter struct {
+	sem       chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewConnLimiter creates a ConnLimiter allowing up to max concurrent connections.
+// A max of less than 1 is treated as 1.
+func NewConnLimiter(max int) *ConnLimiter {
+	if max < 1 {
+		max = 1
+	}
+	return &ConnLimiter{
+		sem:  make(chan struct{}, max),
+		done: make(chan struct{}),
+	}
+}
+
+// Acquire blocks until a slot is available and reports true, or returns false
+// once the limiter has been closed.
+func (l *ConnLimiter) Acquire() bool {
+	select {
+	case <-l.done:
+		return false
+	default:
+	}
+
+	select {
+	case l.sem <- struct{}{}:
+		return true
+	case <-l.done:
+		return false
+	}
+}
+
+// TryAcquire acquires a slot without blocking and reports whether it succeeded.
+func (l *ConnLimiter) TryAcquire() bool {
+	select {
+	case l.sem <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// Release returns a slot acquired by Acquire or TryAcquire.
+func (l *ConnLimiter) Release() {
+	select {
+	case <-l.sem:
+	default:
+	}
+}
+
+// Track wraps c so that its slot is released exactly once when it is closed.
+func (l *ConnLimiter) Track(c net.Conn) net.Conn {
+	var once sync.Once
+	return NewConnWithCloser(c, func(net.Conn) {
+		once.Do(l.Release)
+	})
+}
+
+// Active returns the number of slots currently held.
+func (l *ConnLimiter) Active() int {
+	return len(l.sem)
+}
+
+// Max returns the maximum number of concurrent connections.
+func (l *ConnLimiter) Max() int {
+	return cap(l.sem)
+}
+
+// Close unblocks any pending Acquire calls. Held slots remain valid and
+// are still released when their connections close.
+func (l *ConnLimiter) Close() {
+	l.closeOnce.Do(func() {
+		close(l.done)
+	})
+}
@@ -0,0 +1,64 @@
+package net
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnLimiter(t *testing.T) {
+	l := NewConnLimiter(2)
+	assert.Equal(t, 2, l.Max())
+
+	require.True(t, l.TryAcquire())
+	require.True(t, l.TryAcquire())
+	assert.False(t, l.TryAcquire())
+	assert.Equal(t, 2, l.Active())
+
+	server, client := net.Pipe()
+	defer client.Close()
+
+	tracked := l.Track(server)
+	require.NoError(t, tracked.Close())
+	_ = tracked.Close()
+	assert.Equal(t, 1, l.Active(), "closing a tracked conn twice should release only one slot")
+
+	l.Release()
+	assert.Equal(t, 0, l.Active())
+}
+
+func TestConnLimiter_AcquireBlocksUntilRelease(t *testing.T) {
+	l := NewConnLimiter(1)
+	require.True(t, l.Acquire())
+
+	acquired := make(chan bool)
+	go func() {
+		acquired <- l.Acquire()
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("Acquire should block while the limiter is full")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	l.Release()
+	assert.True(t, <-acquired)
+}
+
+func TestConnLimiter_CloseUnblocksAcquire(t *testing.T) {
+	l := NewConnLimiter(1)
+	require.True(t, l.Acquire())
+
+	acquired := make(chan bool)
+	go func() {
+		acquired <- l.Acquire()
+	}()
+
+	l.Close()
+	assert.False(t, <-acquired)
+	assert.False(t, l.Acquire())
+}
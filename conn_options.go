@@ -0,0 +1,108 @@
+package net
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+// TCPConnOptions describes socket options applied to accepted connections by the
+// listener wrappers in this module (e.g. authz.Listener and ratelimit.Listener).
+//
+// The zero value disables keep-alive and re-enables Nagle's algorithm, so callers
+// should usually start from DefaultTCPConnOptions and adjust individual fields.
+type TCPConnOptions struct {
+	// KeepAlive enables TCP keep-alive probes on the connection.
+	KeepAlive bool
+	// KeepAlivePeriod is the interval between keep-alive probes. If zero, the
+	// operating system default is used.
+	KeepAlivePeriod time.Duration
+	// NoDelay disables Nagle's algorithm so small writes are sent immediately.
+	NoDelay bool
+
+	// ReadIdleTimeout, if non-zero, is applied as a read deadline before every Read,
+	// closing connections that stay idle for longer than the timeout.
+	ReadIdleTimeout time.Duration
+	// WriteIdleTimeout, if non-zero, is applied as a write deadline before every Write.
+	WriteIdleTimeout time.Duration
+}
+
+// DefaultTCPConnOptions returns options with keep-alive enabled every 30 seconds
+// and Nagle's algorithm disabled. No idle timeouts are set.
+func DefaultTCPConnOptions() TCPConnOptions {
+	return TCPConnOptions{
+		KeepAlive:       true,
+		KeepAlivePeriod: 30 * time.Second,
+		NoDelay:         true,
+	}
+}
+
+// Apply sets the socket options on c and returns the connection to use in its place.
+// Socket options are only applied when an underlying *net.TCPConn can be found;
+// other connection types are returned unchanged apart from any idle timeouts.
+func (o TCPConnOptions) Apply(c net.Conn) (net.Conn, error) {
+	if tcpConn := findTCPConn(c); tcpConn != nil {
+		if err := tcpConn.SetKeepAlive(o.KeepAlive); err != nil {
+			return c, fmt.Errorf("set keep-alive: %w", err)
+		}
+		if o.KeepAlive && o.KeepAlivePeriod > 0 {
+			if err := tcpConn.SetKeepAlivePeriod(o.KeepAlivePeriod); err != nil {
+				return c, fmt.Errorf("set keep-alive period: %w", err)
+			}
+		}
+		if err := tcpConn.SetNoDelay(o.NoDelay); err != nil {
+			return c, fmt.Errorf("set no-delay: %w", err)
+		}
+	}
+
+	if o.ReadIdleTimeout > 0 || o.WriteIdleTimeout > 0 {
+		return &idleTimeoutConn{
+			Conn:         c,
+			readTimeout:  o.ReadIdleTimeout,
+			writeTimeout: o.WriteIdleTimeout,
+		}, nil
+	}
+
+	return c, nil
+}
+
+// findTCPConn returns the *net.TCPConn underlying c, if any.
+func findTCPConn(c net.Conn) *net.TCPConn {
+	if tcpConn, ok := c.(*net.TCPConn); ok {
+		return tcpConn
+	}
+	if rawConn, ok := c.(RawConn); ok {
+		return FindTCPConn(rawConn)
+	}
+	return nil
+}
+
+// idleTimeoutConn extends the read or write deadline before each operation so that
+// a connection is closed once it has been idle for longer than the timeout.
+type idleTimeoutConn struct {
+	net.Conn
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+}
+
+func (c *idleTimeoutConn) NetConn() net.Conn {
+	return c.Conn
+}
+
+func (c *idleTimeoutConn) Read(b []byte) (int, error) {
+	if c.readTimeout > 0 {
+		if err := c.Conn.SetReadDeadline(time.Now().Add(c.readTimeout)); err != nil {
+			return 0, err
+		}
+	}
+	return c.Conn.Read(b)
+}
+
+func (c *idleTimeoutConn) Write(b []byte) (int, error) {
+	if c.writeTimeout > 0 {
+		if err := c.Conn.SetWriteDeadline(time.Now().Add(c.writeTimeout)); err != nil {
+			return 0, err
+		}
+	}
+	return c.Conn.Write(b)
+}
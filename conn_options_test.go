@@ -0,0 +1,62 @@
+package net
+
+import (
+	"errors"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestTCPConnOptionsApplyTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+
+	go func() {
+		c, err := net.Dial("tcp", ln.Addr().String())
+		if err == nil {
+			time.Sleep(200 * time.Millisecond)
+			c.Close()
+		}
+	}()
+
+	server, err := ln.Accept()
+	if err != nil {
+		t.Fatalf("accept: %v", err)
+	}
+	defer server.Close()
+
+	opts := DefaultTCPConnOptions()
+	opts.ReadIdleTimeout = 20 * time.Millisecond
+
+	c, err := opts.Apply(NewDoneConn(server))
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+
+	if FindTCPConn(c.(RawConn)) == nil {
+		t.Fatalf("expected wrapped connection to expose the underlying TCP connection")
+	}
+
+	_, err = c.Read(make([]byte, 1))
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("expected idle read timeout, got %v", err)
+	}
+}
+
+func TestTCPConnOptionsApplyNonTCP(t *testing.T) {
+	_, client := net.Pipe()
+	defer client.Close()
+
+	c, err := DefaultTCPConnOptions().Apply(client)
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+
+	if c != client {
+		t.Fatalf("expected non-TCP connection without idle timeouts to be returned unchanged")
+	}
+}
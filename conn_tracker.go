@@ -0,0 +1,104 @@
+package net
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrConnectionNotFound is returned by CloseConnection when no tracked connection has the given remote address.
+var ErrConnectionNotFound = errors.New("connection not found")
+
+// TrackingListener wraps a net.Listener and tracks the connections it accepts until they
+// are closed, so that operators can list open connections and force-close individual clients.
+// It can wrap other listener wrappers, e.g. an authz or ratelimit Listener.
+type TrackingListener struct {
+	net.Listener
+
+	mu     sync.Mutex
+	conns  map[net.Conn]struct{}
+	closed atomic.Bool
+}
+
+// NewTrackingListener creates a TrackingListener wrapping ln.
+func NewTrackingListener(ln net.Listener) *TrackingListener {
+	return &TrackingListener{
+		Listener: ln,
+		conns:    make(map[net.Conn]struct{}),
+	}
+}
+
+// Accept waits for and returns the next connection, tracking it until it is closed.
+// Once the listener is closed, Accept returns an error matching net.ErrClosed.
+func (l *TrackingListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		if l.closed.Load() {
+			err = WrapClosedErr(err)
+		}
+		return nil, err
+	}
+
+	var tracked DoneConn
+	tracked = NewConnWithCloser(c, func(net.Conn) {
+		l.untrack(tracked)
+	})
+
+	l.mu.Lock()
+	l.conns[tracked] = struct{}{}
+	l.mu.Unlock()
+
+	return tracked, nil
+}
+
+// Close closes the underlying listener. Connections already accepted are left open.
+func (l *TrackingListener) Close() error {
+	l.closed.Store(true)
+	return l.Listener.Close()
+}
+
+func (l *TrackingListener) untrack(c net.Conn) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.conns, c)
+}
+
+// ActiveConnections returns the remote addresses of the currently open connections.
+func (l *TrackingListener) ActiveConnections() []net.Addr {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	addrs := make([]net.Addr, 0, len(l.conns))
+	for c := range l.conns {
+		addrs = append(addrs, c.RemoteAddr())
+	}
+	return addrs
+}
+
+// CloseConnection closes every open connection whose remote address matches addr.
+// It returns ErrConnectionNotFound if there is no such connection.
+func (l *TrackingListener) CloseConnection(addr net.Addr) error {
+	l.mu.Lock()
+	var matched []net.Conn
+	for c := range l.conns {
+		remote := c.RemoteAddr()
+		if remote.Network() == addr.Network() && remote.String() == addr.String() {
+			matched = append(matched, c)
+		}
+	}
+	l.mu.Unlock()
+
+	if len(matched) == 0 {
+		return fmt.Errorf("%s: %w", addr, ErrConnectionNotFound)
+	}
+
+	var errs []error
+	for _, c := range matched {
+		if err := c.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
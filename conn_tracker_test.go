@@ -0,0 +1,64 @@
+package net
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrackingListener(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	tl := NewTrackingListener(ln)
+	defer tl.Close()
+
+	accepted := make(chan net.Conn, 2)
+	go func() {
+		for {
+			c, err := tl.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- c
+		}
+	}()
+
+	client1, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	defer client1.Close()
+	client2, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	defer client2.Close()
+
+	server1 := <-accepted
+	server2 := <-accepted
+
+	assert.ElementsMatch(t,
+		[]string{server1.RemoteAddr().String(), server2.RemoteAddr().String()},
+		addrStrings(tl.ActiveConnections()))
+
+	require.NoError(t, tl.CloseConnection(client1.LocalAddr()))
+	assert.Equal(t, []string{client2.LocalAddr().String()}, addrStrings(tl.ActiveConnections()))
+
+	// the kicked client sees the connection closed
+	_ = client1.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = client1.Read(make([]byte, 1))
+	assert.Error(t, err)
+
+	assert.ErrorIs(t, tl.CloseConnection(client1.LocalAddr()), ErrConnectionNotFound)
+
+	require.NoError(t, server2.Close())
+	assert.Empty(t, tl.ActiveConnections())
+}
+
+func addrStrings(addrs []net.Addr) []string {
+	s := make([]string, 0, len(addrs))
+	for _, a := range addrs {
+		s = append(s, a.String())
+	}
+	return s
+}
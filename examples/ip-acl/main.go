@@ -1,7 +1,6 @@
 package main
 
 import (
-	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -179,21 +178,20 @@ func acceptConnections(listener *authz.Listener, listenerType string, logger zer
 		conn, err := listener.Accept()
 		if err != nil {
 			// Check if listener was closed
-			if errors.Is(err, net.ErrClosed) {
+			if authz.IsClosedErr(err) {
 				return
 			}
 			logger.Error().Err(err).Str("type", listenerType).Msg("accept error")
 			continue
 		}
 
-		// Connection returned (authz.Listener closes denied connections but still returns them)
-		// The authz.Listener itself logs "access denied" for rejected connections
+		// Only authorised connections are returned; authz.Listener closes denied
+		// connections, logs "access denied" and keeps accepting
 		logger.Info().
 			Str("type", listenerType).
 			Str("remote_addr", conn.RemoteAddr().String()).
 			Msg("Connection received")
 
-		// Handle the connection (it may already be closed if denied by ACL)
 		go handleConnection(conn, logger)
 	}
 }
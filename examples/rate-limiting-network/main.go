@@ -1,7 +1,6 @@
 package main
 
 import (
-	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -9,7 +8,9 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
+	dionet "github.com/dioad/net"
 	"github.com/dioad/net/ratelimit"
 	"github.com/rs/zerolog"
 )
@@ -30,6 +31,11 @@ func main() {
 	// Wrap an existing listener with rate limiting (by source IP)
 	rlListener := ratelimit.NewListener(ln, rl, logger)
 
+	// Enable keep-alive and close connections that stay idle for 5 minutes
+	tcpOptions := dionet.DefaultTCPConnOptions()
+	tcpOptions.ReadIdleTimeout = 5 * time.Minute
+	rlListener.TCPOptions = &tcpOptions
+
 	fmt.Println("Starting TCP server with network rate limiting on :8080")
 	fmt.Println("Rate limit: 10 connections/second with burst of 20 per source IP")
 	fmt.Println("Try: nc localhost 8080 (or telnet localhost 8080)")
@@ -49,7 +55,7 @@ func main() {
 		conn, err := rlListener.Accept()
 		if err != nil {
 			// Check if we're shutting down
-			if errors.Is(err, net.ErrClosed) {
+			if ratelimit.IsClosedErr(err) {
 				break
 			}
 			log.Printf("Error accepting connection: %v\n", err)
@@ -0,0 +1,114 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/dioad/generics"
+
+	auth "github.com/dioad/auth/http"
+	"github.com/dioad/auth/oidc"
+
+	diotls "github.com/dioad/net/tls"
+)
+
+// NewAuthenticatedClient returns an *http.Client that authenticates every request using the
+// single scheme configured in cfg (basic, GitHub or HMAC via AuthConfig, OIDC via
+// OIDCConfig, or AWS Signature Version 4 via AWSSigV4Config) and applies cfg.TLSConfig. Timeouts and CheckRedirect are copied from
+// cfg.Client when it is set.
+//
+// An error is returned if more than one scheme is configured, as a request can only carry
+// one Authorization header.
+func NewAuthenticatedClient(cfg ClientConfig) (*http.Client, error) {
+	schemes := configuredAuthSchemes(cfg)
+	if len(schemes) > 1 {
+		return nil, fmt.Errorf("only one authentication scheme may be configured, got %v", schemes)
+	}
+
+	base, err := newClientTransport(cfg.TLSConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	var transport http.RoundTripper = base
+	switch {
+	case !generics.IsZeroValue(cfg.OIDCConfig):
+		source, err := oidc.NewTokenSourceFromConfig(cfg.OIDCConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create OIDC token source: %w", err)
+		}
+		transport = NewBearerTokenRoundTripper(source, base)
+	case !generics.IsZeroValue(cfg.AWSSigV4Config):
+		transport, err = NewAWSSigV4RoundTripper(cfg.AWSSigV4Config, base)
+		if err != nil {
+			return nil, err
+		}
+	case !generics.IsZeroValue(cfg.AuthConfig):
+		transport = &clientAuthRoundTripper{auth: auth.NewClientAuth(cfg.AuthConfig), base: base}
+	}
+
+	client := &http.Client{}
+	if cfg.Client != nil {
+		client.Timeout = cfg.Client.Timeout
+		client.CheckRedirect = cfg.Client.CheckRedirect
+		client.Jar = cfg.Client.Jar
+	}
+	client.Transport = transport
+
+	return client, nil
+}
+
+// configuredAuthSchemes returns the names of the authentication schemes set in cfg.
+func configuredAuthSchemes(cfg ClientConfig) []string {
+	var schemes []string
+	if !generics.IsZeroValue(cfg.AuthConfig.BasicAuthConfig) {
+		schemes = append(schemes, "basic")
+	}
+	if !generics.IsZeroValue(cfg.AuthConfig.GitHubAuthConfig) {
+		schemes = append(schemes, "github")
+	}
+	if !generics.IsZeroValue(cfg.AuthConfig.HMACAuthConfig) {
+		schemes = append(schemes, "hmac")
+	}
+	if !generics.IsZeroValue(cfg.OIDCConfig) {
+		schemes = append(schemes, "oidc")
+	}
+	if !generics.IsZeroValue(cfg.AWSSigV4Config) {
+		schemes = append(schemes, "awssigv4")
+	}
+	return schemes
+}
+
+// newClientTransport returns a clone of http.DefaultTransport using the TLS configuration
+// described by c.
+func newClientTransport(c diotls.ClientConfig) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	tlsConfig, err := diotls.NewClientTLSConfig(c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client TLS config: %w", err)
+	}
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	return transport, nil
+}
+
+// clientAuthRoundTripper adds authentication from an auth.ClientAuth to each request.
+type clientAuthRoundTripper struct {
+	auth auth.ClientAuth
+	base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper. The original request is not modified.
+func (t *clientAuthRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	authReq := req.Clone(req.Context())
+	if err := t.auth.AddAuth(authReq); err != nil {
+		if req.Body != nil {
+			_ = req.Body.Close()
+		}
+		return nil, fmt.Errorf("failed to authenticate request: %w", err)
+	}
+	return t.base.RoundTrip(authReq)
+}
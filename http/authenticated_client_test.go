@@ -0,0 +1,115 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	auth "github.com/dioad/auth/http"
+	"github.com/dioad/auth/http/basic"
+	"github.com/dioad/auth/http/hmac"
+	"github.com/dioad/auth/oidc"
+
+	diotls "github.com/dioad/net/tls"
+)
+
+func TestNewAuthenticatedClient(t *testing.T) {
+	var gotAuth string
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+	}))
+	defer srv.Close()
+
+	tlsConfig := diotls.ClientConfig{InsecureSkipVerify: true}
+
+	tests := []struct {
+		name       string
+		cfg        ClientConfig
+		wantPrefix string
+	}{
+		{
+			name:       "no auth",
+			cfg:        ClientConfig{TLSConfig: tlsConfig},
+			wantPrefix: "",
+		},
+		{
+			name: "basic",
+			cfg: ClientConfig{
+				TLSConfig:  tlsConfig,
+				AuthConfig: auth.ClientConfig{BasicAuthConfig: basic.ClientConfig{User: "user", Password: "pass"}},
+			},
+			wantPrefix: "Basic ",
+		},
+		{
+			name: "hmac",
+			cfg: ClientConfig{
+				TLSConfig: tlsConfig,
+				AuthConfig: auth.ClientConfig{HMACAuthConfig: hmac.ClientConfig{
+					CommonConfig: hmac.CommonConfig{SharedKey: strings.Repeat("k", 32)},
+					Principal:    "svc",
+				}},
+			},
+			wantPrefix: hmac.AuthScheme + " svc:",
+		},
+		{
+			name: "aws sigv4",
+			cfg: ClientConfig{
+				TLSConfig:      tlsConfig,
+				AWSSigV4Config: AWSSigV4Config{Region: "eu-west-1", Service: "execute-api", AccessKeyID: "AKID", SecretAccessKey: "secret"},
+			},
+			wantPrefix: "AWS4-HMAC-SHA256 Credential=AKID/",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotAuth = ""
+			client, err := NewAuthenticatedClient(tt.cfg)
+			require.NoError(t, err)
+
+			resp, err := client.Get(srv.URL)
+			require.NoError(t, err)
+			resp.Body.Close()
+
+			if tt.wantPrefix == "" {
+				assert.Empty(t, gotAuth)
+				return
+			}
+			assert.True(t, strings.HasPrefix(gotAuth, tt.wantPrefix), "Authorization %q should start with %q", gotAuth, tt.wantPrefix)
+		})
+	}
+}
+
+func TestNewAuthenticatedClient_MultipleSchemes(t *testing.T) {
+	_, err := NewAuthenticatedClient(ClientConfig{
+		AuthConfig: auth.ClientConfig{BasicAuthConfig: basic.ClientConfig{User: "user", Password: "pass"}},
+		OIDCConfig: oidc.ClientConfig{ClientID: "id"},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "basic")
+	assert.Contains(t, err.Error(), "oidc")
+
+	_, err = NewAuthenticatedClient(ClientConfig{
+		OIDCConfig:     oidc.ClientConfig{ClientID: "id"},
+		AWSSigV4Config: AWSSigV4Config{Region: "eu-west-1", Service: "lambda"},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "awssigv4")
+}
+
+func TestNewAuthenticatedClient_CopiesClientSettings(t *testing.T) {
+	client, err := NewAuthenticatedClient(ClientConfig{Client: &http.Client{Timeout: 3 * time.Second}})
+	require.NoError(t, err)
+	assert.Equal(t, 3*time.Second, client.Timeout)
+
+	// without TLS settings the server's self-signed certificate is rejected
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	_, err = client.Get(srv.URL)
+	require.Error(t, err)
+}
@@ -4,50 +4,161 @@ package ip
 import (
 	stdctx "context"
 	"fmt"
+	"net"
 	"net/http"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"github.com/rs/zerolog"
 
 	"github.com/dioad/net/authz"
+	diohttp "github.com/dioad/net/http"
 )
 
+// HandlerOption configures a Handler.
+type HandlerOption func(*Handler)
+
+// WithMethods restricts the ACL to requests using one of the given methods, e.g. to allow
+// reads from anywhere while limiting writes to internal networks. Requests using any other
+// method are passed through without being checked.
+func WithMethods(methods ...string) HandlerOption {
+	return func(h *Handler) {
+		for _, m := range methods {
+			h.Methods = append(h.Methods, strings.ToUpper(m))
+		}
+	}
+}
+
+// WithTrustConfig determines the client IP with diohttp.ClientIP using trust, rather than
+// from the request's RemoteAddr, so the ACL applies to the client behind trusted proxies.
+func WithTrustConfig(trust diohttp.TrustConfig) HandlerOption {
+	return func(h *Handler) {
+		h.Trust = &trust
+	}
+}
+
+// WithAuditOnly lets every request through, logging those the ACL would deny to logger
+// together with the reason, and counting them in Handler.WouldDeny. Use it to validate a
+// new policy against real traffic before enforcing it.
+func WithAuditOnly(logger zerolog.Logger) HandlerOption {
+	return func(h *Handler) {
+		h.AuditOnly = true
+		h.Logger = logger
+	}
+}
+
 // HandlerFunc creates an IP-based authorization-wrapped HTTP handler function.
-func HandlerFunc(cfg authz.NetworkACLConfig, next http.Handler) http.HandlerFunc {
-	h := NewHandler(cfg)
+func HandlerFunc(cfg authz.NetworkACLConfig, next http.Handler, opts ...HandlerOption) http.HandlerFunc {
+	h := NewHandler(cfg, opts...)
 	return h.Wrap(next).ServeHTTP
 }
 
 // NewHandler creates a new IP-based authorization handler.
-func NewHandler(cfg authz.NetworkACLConfig) *Handler {
+func NewHandler(cfg authz.NetworkACLConfig, opts ...HandlerOption) *Handler {
 	authoriser, _ := authz.NewNetworkACL(cfg)
-	return &Handler{Authoriser: authoriser}
+	h := &Handler{Authoriser: authoriser, Logger: zerolog.Nop()}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h
 }
 
 // Handler implements IP-based authorization for HTTP servers.
 type Handler struct {
 	Authoriser *authz.NetworkACL
+	// Methods, if not empty, limits the ACL to requests using these methods.
+	Methods []string
+	// Trust, if set, is used to resolve the client IP from forwarded headers.
+	Trust *diohttp.TrustConfig
+	// AuditOnly lets denied requests through, logging them to Logger instead.
+	AuditOnly bool
+	// Logger records requests let through in AuditOnly mode.
+	Logger zerolog.Logger
+
+	wouldDeny atomic.Uint64
+}
+
+// WouldDeny returns the number of requests let through in AuditOnly mode that would
+// otherwise have been denied.
+func (h *Handler) WouldDeny() uint64 {
+	return h.wouldDeny.Load()
 }
 
-// AuthRequest checks if an HTTP request is authorized based on the remote IP address.
+// AuthRequest checks if an HTTP request is authorized based on the client IP address.
+// Requests using a method the ACL is not limited to are allowed, and in AuditOnly mode
+// denied requests are logged and allowed.
 func (h *Handler) AuthRequest(r *http.Request) (stdctx.Context, error) {
-	allowed, err := h.Authoriser.AuthoriseFromString(r.RemoteAddr)
-	if err != nil {
-		return r.Context(), fmt.Errorf("failed to authorise request: %w", err)
+	if !h.appliesTo(r) {
+		return r.Context(), nil
 	}
+	return r.Context(), h.enforce(r)
+}
 
+// enforce returns an error if the request is denied. In AuditOnly mode a denied request
+// is logged and counted in WouldDeny instead, and no error is returned.
+func (h *Handler) enforce(r *http.Request) error {
+	reason, err := h.authorise(r)
+	if err != nil && h.AuditOnly {
+		h.wouldDeny.Add(1)
+		h.Logger.Warn().
+			Err(err).
+			Str("remoteAddr", r.RemoteAddr).
+			Str("reason", reason).
+			Msg("request would be denied (audit only)")
+		return nil
+	}
+	return err
+}
+
+// authorise returns the reason for the ACL's decision, and an error if the request is
+// denied or its client IP cannot be determined.
+func (h *Handler) authorise(r *http.Request) (string, error) {
+	if h.Trust == nil {
+		allowed, reason, err := h.Authoriser.AuthoriseFromStringWithReason(r.RemoteAddr)
+		if err != nil {
+			return "", fmt.Errorf("failed to authorise request: %w", err)
+		}
+
+		if !allowed {
+			return reason, fmt.Errorf("request not allowed from %s", r.RemoteAddr)
+		}
+
+		return reason, nil
+	}
+
+	ip := diohttp.ClientIP(r, *h.Trust)
+	if ip == nil {
+		return "", fmt.Errorf("failed to authorise request: unable to determine client IP from %s", r.RemoteAddr)
+	}
+
+	allowed, reason := h.Authoriser.AuthoriseWithReason(&net.TCPAddr{IP: ip})
 	if !allowed {
-		return r.Context(), fmt.Errorf("request not allowed from %s", r.RemoteAddr)
+		return reason, fmt.Errorf("request not allowed from %s", ip)
 	}
 
-	return r.Context(), nil
+	return reason, nil
+}
+
+// appliesTo reports whether the ACL is enforced for the request's method.
+func (h *Handler) appliesTo(r *http.Request) bool {
+	return len(h.Methods) == 0 || slices.Contains(h.Methods, r.Method)
 }
 
 // Wrap wraps an HTTP handler with IP-based authorization middleware.
 func (h *Handler) Wrap(handler http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ctx, err := h.AuthRequest(r)
-		if err != nil {
+		if !h.appliesTo(r) {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		if err := h.enforce(r); err != nil {
 			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
 			return
 		}
-		handler.ServeHTTP(w, r.WithContext(ctx))
+		handler.ServeHTTP(w, r)
 	})
 }
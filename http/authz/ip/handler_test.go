@@ -5,7 +5,10 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"github.com/rs/zerolog"
+
 	"github.com/dioad/net/authz"
+	diohttp "github.com/dioad/net/http"
 )
 
 func TestHandlerFunc(t *testing.T) {
@@ -109,6 +112,38 @@ func TestAuthRequest_InvalidAddress(t *testing.T) {
 	}
 }
 
+func TestAuthRequest_WithMethods(t *testing.T) {
+	cfg := authz.NetworkACLConfig{
+		AllowedNets:    []string{"10.0.0.0/8"},
+		AllowByDefault: false,
+	}
+
+	handler := NewHandler(cfg, WithMethods(http.MethodPost))
+
+	tests := []struct {
+		method     string
+		remoteAddr string
+		wantErr    bool
+	}{
+		{method: http.MethodGet, remoteAddr: "203.0.113.1:1234", wantErr: false},
+		{method: http.MethodPost, remoteAddr: "203.0.113.1:1234", wantErr: true},
+		{method: http.MethodPost, remoteAddr: "10.1.2.3:1234", wantErr: false},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "/items", nil)
+		req.RemoteAddr = tt.remoteAddr
+
+		ctx, err := handler.AuthRequest(req)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s from %s: expected error %v, got %v", tt.method, tt.remoteAddr, tt.wantErr, err)
+		}
+		if ctx == nil {
+			t.Errorf("%s from %s: expected context to be returned", tt.method, tt.remoteAddr)
+		}
+	}
+}
+
 func TestWrap_Allowed(t *testing.T) {
 	cfg := authz.NetworkACLConfig{
 		AllowedNets:    []string{"172.16.0.0/12"},
@@ -193,3 +228,117 @@ func TestWrap_AllowByDefault(t *testing.T) {
 		t.Errorf("Expected body %q, got %q", "allowed by default", w.Body.String())
 	}
 }
+
+func TestWrap_WithMethods(t *testing.T) {
+	cfg := authz.NetworkACLConfig{
+		AllowedNets:    []string{"10.0.0.0/8"},
+		AllowByDefault: false,
+	}
+
+	handler := NewHandler(cfg, WithMethods("post", http.MethodPut, http.MethodDelete)).Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		method     string
+		remoteAddr string
+		want       int
+	}{
+		{method: http.MethodGet, remoteAddr: "203.0.113.1:1234", want: http.StatusOK},
+		{method: http.MethodPost, remoteAddr: "203.0.113.1:1234", want: http.StatusForbidden},
+		{method: http.MethodDelete, remoteAddr: "203.0.113.1:1234", want: http.StatusForbidden},
+		{method: http.MethodPost, remoteAddr: "10.1.2.3:1234", want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "/items", nil)
+		req.RemoteAddr = tt.remoteAddr
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != tt.want {
+			t.Errorf("%s from %s: expected status code %d, got %d", tt.method, tt.remoteAddr, tt.want, w.Code)
+		}
+	}
+}
+
+func TestWrap_WithTrustConfig(t *testing.T) {
+	cfg := authz.NetworkACLConfig{
+		AllowedNets:    []string{"10.0.0.0/8"},
+		AllowByDefault: false,
+	}
+
+	trust, err := diohttp.NewTrustConfig("192.0.2.1")
+	if err != nil {
+		t.Fatalf("NewTrustConfig failed: %v", err)
+	}
+
+	handler := NewHandler(cfg, WithMethods(http.MethodPost), WithTrustConfig(trust)).Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		remoteAddr    string
+		xForwardedFor string
+		want          int
+	}{
+		{remoteAddr: "192.0.2.1:1234", xForwardedFor: "10.1.2.3", want: http.StatusOK},
+		{remoteAddr: "192.0.2.1:1234", xForwardedFor: "203.0.113.1", want: http.StatusForbidden},
+		// forwarded headers from an untrusted peer are ignored
+		{remoteAddr: "203.0.113.1:1234", xForwardedFor: "10.1.2.3", want: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/items", nil)
+		req.RemoteAddr = tt.remoteAddr
+		req.Header.Set("X-Forwarded-For", tt.xForwardedFor)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != tt.want {
+			t.Errorf("from %s via %s: expected status code %d, got %d", tt.xForwardedFor, tt.remoteAddr, tt.want, w.Code)
+		}
+	}
+}
+
+func TestWrap_AuditOnly(t *testing.T) {
+	cfg := authz.NetworkACLConfig{
+		AllowedNets: []string{"10.0.0.0/8"},
+		DeniedNets:  []string{"10.1.0.0/16"},
+	}
+
+	handler := NewHandler(cfg, WithAuditOnly(zerolog.Nop()))
+
+	var served int
+	wrappedHandler := handler.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served++
+	}))
+
+	for _, remoteAddr := range []string{"10.0.0.1:1234", "10.1.0.1:1234", "203.0.113.100:1234", "garbage"} {
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		wrappedHandler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: expected status code %d, got %d", remoteAddr, http.StatusOK, w.Code)
+		}
+	}
+
+	if served != 4 {
+		t.Errorf("Expected 4 requests served, got %d", served)
+	}
+	if got := handler.WouldDeny(); got != 3 {
+		t.Errorf("Expected 3 would-deny requests, got %d", got)
+	}
+
+	// AuthRequest lets denied requests through in the same way
+	req := httptest.NewRequest("GET", "/test", nil)
+	req.RemoteAddr = "10.1.0.1:1234"
+	if _, err := handler.AuthRequest(req); err != nil {
+		t.Errorf("Expected AuthRequest to allow a denied request in audit mode, got: %v", err)
+	}
+	if got := handler.WouldDeny(); got != 4 {
+		t.Errorf("Expected 4 would-deny requests, got %d", got)
+	}
+}
@@ -0,0 +1,148 @@
+package http
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAWSSigV4RoundTripper(t *testing.T) {
+	var gotAuth, gotDate, gotToken, gotBody string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		gotDate = r.Header.Get("X-Amz-Date")
+		gotToken = r.Header.Get("X-Amz-Security-Token")
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+	}))
+	defer srv.Close()
+
+	rt, err := NewAWSSigV4RoundTripper(AWSSigV4Config{
+		Region:          "eu-west-1",
+		Service:         "execute-api",
+		AccessKeyID:     "AKID",
+		SecretAccessKey: "secret",
+		SessionToken:    "token",
+	}, nil)
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader(`{"a":1}`))
+	require.NoError(t, err)
+	resp, err := (&http.Client{Transport: rt}).Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	require.NotEmpty(t, gotDate)
+	assert.True(t, strings.HasPrefix(gotAuth, "AWS4-HMAC-SHA256 Credential=AKID/"+gotDate[:8]+"/eu-west-1/execute-api/aws4_request"), gotAuth)
+	assert.Contains(t, gotAuth, "Signature=")
+	assert.Equal(t, "token", gotToken)
+	assert.Equal(t, `{"a":1}`, gotBody)
+	assert.Empty(t, req.Header.Get("Authorization"), "the original request is not modified")
+}
+
+func TestAWSSigV4RoundTripper_SignsHost(t *testing.T) {
+	const host = "api.example.com"
+
+	// verify recomputes the signature the request would have if it was sent to host
+	verify := func(r *http.Request) bool {
+		signingTime, err := time.Parse("20060102T150405Z", r.Header.Get("X-Amz-Date"))
+		if err != nil {
+			return false
+		}
+		expected, err := http.NewRequest(r.Method, "http://"+host+r.URL.RequestURI(), nil)
+		if err != nil {
+			return false
+		}
+		emptyHash := sha256.Sum256(nil)
+		creds := aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}
+		err = v4.NewSigner().SignHTTP(r.Context(), creds, expected, hex.EncodeToString(emptyHash[:]), "execute-api", "eu-west-1", signingTime)
+		return err == nil && expected.Header.Get("Authorization") == r.Header.Get("Authorization")
+	}
+
+	tests := []struct {
+		name      string
+		host      string
+		wantValid bool
+	}{
+		{name: "pinned host", host: host, wantValid: true},
+		{name: "other host", host: "other.example.com", wantValid: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotAuth string
+			var valid bool
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotAuth = r.Header.Get("Authorization")
+				r.Host = host
+				valid = verify(r)
+			}))
+			defer srv.Close()
+
+			rt, err := NewAWSSigV4RoundTripper(AWSSigV4Config{
+				Region:          "eu-west-1",
+				Service:         "execute-api",
+				AccessKeyID:     "AKID",
+				SecretAccessKey: "secret",
+			}, nil)
+			require.NoError(t, err)
+
+			req, err := http.NewRequest(http.MethodGet, srv.URL+"/items?id=1", nil)
+			require.NoError(t, err)
+			req.Host = tt.host
+			resp, err := (&http.Client{Transport: rt}).Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+
+			assert.Contains(t, gotAuth, "SignedHeaders=host;x-amz-date,")
+			assert.Equal(t, tt.wantValid, valid)
+		})
+	}
+}
+
+func TestAWSSigV4RoundTripper_CredentialsProvider(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	rt, err := NewAWSSigV4RoundTripper(AWSSigV4Config{
+		Region:  "eu-west-1",
+		Service: "lambda",
+		Credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+			return aws.Credentials{}, errors.New("no credentials")
+		}),
+	}, nil)
+	require.NoError(t, err)
+
+	_, err = (&http.Client{Transport: rt}).Get(srv.URL)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no credentials")
+}
+
+func TestNewAWSSigV4RoundTripper_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  AWSSigV4Config
+	}{
+		{name: "missing region", cfg: AWSSigV4Config{Service: "lambda", AccessKeyID: "AKID", SecretAccessKey: "secret"}},
+		{name: "missing service", cfg: AWSSigV4Config{Region: "eu-west-1", AccessKeyID: "AKID", SecretAccessKey: "secret"}},
+		{name: "missing credentials", cfg: AWSSigV4Config{Region: "eu-west-1", Service: "lambda"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewAWSSigV4RoundTripper(tt.cfg, nil)
+			assert.Error(t, err)
+		})
+	}
+}
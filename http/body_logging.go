@@ -0,0 +1,203 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	// DefaultMaxLoggedBodyBytes is the default size cap for logged request and response bodies (4KB).
+	DefaultMaxLoggedBodyBytes = 4 * 1024
+
+	redactedValue = "[REDACTED]"
+)
+
+// DefaultRedactedHeaders are the headers whose values are always redacted by body logging.
+var DefaultRedactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// BodyLoggingConfig configures request and response body logging.
+type BodyLoggingConfig struct {
+	// MaxBodyBytes caps the size of a logged body. Larger bodies are never logged, only
+	// noted as omitted. Defaults to DefaultMaxLoggedBodyBytes.
+	MaxBodyBytes int64
+	// RedactHeaders lists additional headers to redact alongside DefaultRedactedHeaders.
+	RedactHeaders []string
+	// RedactJSONKeys lists object keys, matched case-insensitively at any depth, whose
+	// values are redacted from JSON bodies.
+	RedactJSONKeys []string
+}
+
+// WithBodyLogging returns a ServerOption that logs request and response bodies at debug
+// level using the server's logger. It only takes effect when Config.EnableDebug is set.
+func WithBodyLogging(config BodyLoggingConfig) ServerOption {
+	return func(s *Server) {
+		if !s.Config.EnableDebug {
+			return
+		}
+		s.Use(func(next http.Handler) http.Handler {
+			return BodyLoggingMiddleware(s.Logger, config)(next)
+		})
+	}
+}
+
+// BodyLoggingMiddleware returns middleware that logs redacted request and response headers
+// and bodies at debug level. Bodies are only captured while debug logging is enabled, and
+// the request body is restored so that the wrapped handler can still read it.
+func BodyLoggingMiddleware(logger zerolog.Logger, config BodyLoggingConfig) Middleware {
+	if config.MaxBodyBytes <= 0 {
+		config.MaxBodyBytes = DefaultMaxLoggedBodyBytes
+	}
+
+	redactHeaders := make(map[string]bool)
+	for _, h := range append(DefaultRedactedHeaders, config.RedactHeaders...) {
+		redactHeaders[http.CanonicalHeaderKey(h)] = true
+	}
+	redactKeys := make(map[string]bool)
+	for _, k := range config.RedactJSONKeys {
+		redactKeys[strings.ToLower(k)] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !logger.Debug().Enabled() {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var requestBody []byte
+			requestTruncated := false
+			if r.Body != nil && r.Body != http.NoBody {
+				buf, err := io.ReadAll(io.LimitReader(r.Body, config.MaxBodyBytes+1))
+				requestTruncated = int64(len(buf)) > config.MaxBodyBytes
+				r.Body = struct {
+					io.Reader
+					io.Closer
+				}{io.MultiReader(bytes.NewReader(buf), r.Body), r.Body}
+				if err == nil {
+					requestBody = buf
+				}
+			}
+
+			rw := &bodyCapturingResponseWriter{ResponseWriter: w, max: config.MaxBodyBytes}
+			next.ServeHTTP(rw, r)
+
+			logger.Debug().
+				Str("method", r.Method).
+				Stringer("url", r.URL).
+				Interface("request_headers", redactHeader(r.Header, redactHeaders)).
+				Str("request_body", formatLoggedBody(requestBody, requestTruncated, r.Header, redactKeys)).
+				Int("status", rw.statusCode()).
+				Interface("response_headers", redactHeader(rw.Header(), redactHeaders)).
+				Str("response_body", formatLoggedBody(rw.body.Bytes(), rw.truncated, rw.Header(), redactKeys)).
+				Msg("http body")
+		})
+	}
+}
+
+// bodyCapturingResponseWriter records up to max bytes of the response body.
+type bodyCapturingResponseWriter struct {
+	http.ResponseWriter
+	max       int64
+	status    int
+	body      bytes.Buffer
+	truncated bool
+}
+
+func (w *bodyCapturingResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *bodyCapturingResponseWriter) Write(b []byte) (int, error) {
+	if !w.truncated {
+		if int64(w.body.Len()+len(b)) > w.max {
+			w.truncated = true
+			w.body.Reset()
+		} else {
+			w.body.Write(b)
+		}
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap allows http.ResponseController to reach the underlying ResponseWriter.
+func (w *bodyCapturingResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *bodyCapturingResponseWriter) statusCode() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+func redactHeader(header http.Header, redact map[string]bool) http.Header {
+	result := header.Clone()
+	for name := range result {
+		if redact[http.CanonicalHeaderKey(name)] {
+			result[name] = []string{redactedValue}
+		}
+	}
+	return result
+}
+
+// formatLoggedBody returns the body as it should be logged, redacting configured keys
+// from JSON bodies. Bodies that parse as JSON are redacted whatever their Content-Type so
+// that a mislabelled body cannot leak redacted values.
+func formatLoggedBody(body []byte, truncated bool, header http.Header, redactKeys map[string]bool) string {
+	if truncated {
+		return "[OMITTED: body exceeds logging size cap]"
+	}
+	if len(body) == 0 || len(redactKeys) == 0 {
+		return string(body)
+	}
+	if !isJSONContent(header.Get("Content-Type")) && !json.Valid(body) {
+		return string(body)
+	}
+
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		return "[OMITTED: invalid JSON body]"
+	}
+
+	redacted, err := json.Marshal(redactJSON(v, redactKeys))
+	if err != nil {
+		return "[OMITTED: unable to redact JSON body]"
+	}
+	return string(redacted)
+}
+
+func isJSONContent(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+func redactJSON(v any, redactKeys map[string]bool) any {
+	switch value := v.(type) {
+	case map[string]any:
+		for k, child := range value {
+			if redactKeys[strings.ToLower(k)] {
+				value[k] = redactedValue
+				continue
+			}
+			value[k] = redactJSON(child, redactKeys)
+		}
+	case []any:
+		for i, child := range value {
+			value[i] = redactJSON(child, redactKeys)
+		}
+	}
+	return v
+}
@@ -0,0 +1,93 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBodyLoggingMiddleware(t *testing.T) {
+	var logBuf bytes.Buffer
+	logger := zerolog.New(&logBuf).Level(zerolog.DebugLevel)
+
+	var handlerBody string
+	handler := BodyLoggingMiddleware(logger, BodyLoggingConfig{
+		RedactHeaders:  []string{"X-Api-Key"},
+		RedactJSONKeys: []string{"password", "token"},
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		handlerBody = string(b)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id":1,"token":"abc123"}`))
+	}))
+
+	reqBody := `{"user":"alice","nested":{"Password":"hunter2"}}`
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(reqBody))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("X-Api-Key", "key")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, reqBody, handlerBody, "handler should still read the full request body")
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	assert.Equal(t, `{"id":1,"token":"abc123"}`, rr.Body.String())
+
+	logged := logBuf.String()
+	for _, secret := range []string{"secret", "hunter2", "abc123", `"key"`} {
+		assert.NotContains(t, logged, secret)
+	}
+
+	var entry struct {
+		Status       int    `json:"status"`
+		RequestBody  string `json:"request_body"`
+		ResponseBody string `json:"response_body"`
+	}
+	require.NoError(t, json.Unmarshal(logBuf.Bytes(), &entry))
+	assert.Equal(t, http.StatusCreated, entry.Status)
+	assert.JSONEq(t, `{"user":"alice","nested":{"Password":"[REDACTED]"}}`, entry.RequestBody)
+	assert.JSONEq(t, `{"id":1,"token":"[REDACTED]"}`, entry.ResponseBody)
+}
+
+func TestBodyLoggingMiddleware_SizeCap(t *testing.T) {
+	var logBuf bytes.Buffer
+	logger := zerolog.New(&logBuf).Level(zerolog.DebugLevel)
+
+	body := strings.Repeat("x", 64)
+	var handlerBody string
+	handler := BodyLoggingMiddleware(logger, BodyLoggingConfig{MaxBodyBytes: 16})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		handlerBody = string(b)
+		_, _ = w.Write(b)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+
+	assert.Equal(t, body, handlerBody)
+	assert.NotContains(t, logBuf.String(), body[:17])
+	assert.Contains(t, logBuf.String(), "OMITTED")
+}
+
+func TestBodyLoggingMiddleware_DebugDisabled(t *testing.T) {
+	var logBuf bytes.Buffer
+	logger := zerolog.New(&logBuf).Level(zerolog.InfoLevel)
+
+	handler := BodyLoggingMiddleware(logger, BodyLoggingConfig{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(w, r.Body)
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("hello")))
+
+	assert.Equal(t, "hello", rr.Body.String())
+	assert.Empty(t, logBuf.String())
+}
@@ -0,0 +1,83 @@
+package http
+
+import (
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	diojson "github.com/dioad/net/http/json"
+)
+
+// BuildInfo identifies the build of the running program.
+// Empty fields are filled from the module and VCS information embedded by the Go toolchain.
+type BuildInfo struct {
+	// Version is the release version, e.g. "v1.2.3"
+	Version string
+	// Commit is the source revision the program was built from
+	Commit string
+}
+
+// VersionInfo is reported by the /version endpoint and in the "Build" section of /status.
+type VersionInfo struct {
+	Version       string    `json:"version"`
+	Commit        string    `json:"commit,omitzero"`
+	GoVersion     string    `json:"go_version"`
+	StartTime     time.Time `json:"start_time"`
+	UptimeSeconds int64     `json:"uptime_seconds"`
+}
+
+// SetBuildInfo sets the version and commit reported by the /status and /version endpoints.
+func (s *Server) SetBuildInfo(version, commit string) {
+	s.Config.BuildInfo = BuildInfo{Version: version, Commit: commit}
+}
+
+// VersionInfo returns the build information for the server along with its start time and uptime.
+func (s *Server) VersionInfo() VersionInfo {
+	info := VersionInfo{
+		Version:   s.Config.BuildInfo.Version,
+		Commit:    s.Config.BuildInfo.Commit,
+		GoVersion: runtime.Version(),
+		StartTime: s.startTime,
+	}
+
+	if info.Version == "" || info.Commit == "" {
+		version, commit := runtimeBuildInfo()
+		if info.Version == "" {
+			info.Version = version
+		}
+		if info.Commit == "" {
+			info.Commit = commit
+		}
+	}
+
+	if !s.startTime.IsZero() {
+		info.UptimeSeconds = int64(time.Since(s.startTime).Seconds())
+	}
+
+	return info
+}
+
+// runtimeBuildInfo returns the main module version and VCS revision embedded in the binary.
+func runtimeBuildInfo() (version string, commit string) {
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "", ""
+	}
+
+	version = bi.Main.Version
+	for _, setting := range bi.Settings {
+		if setting.Key == "vcs.revision" {
+			commit = setting.Value
+		}
+	}
+
+	return version, commit
+}
+
+// versionHandler serves the server's VersionInfo as JSON.
+func (s *Server) versionHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		diojson.NewResponse(w).OK(s.VersionInfo())
+	}
+}
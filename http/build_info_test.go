@@ -0,0 +1,93 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersionEndpoint(t *testing.T) {
+	server := NewServer(Config{
+		EnableVersion: true,
+		BuildInfo:     BuildInfo{Version: "v1.2.3", Commit: "abc123"},
+	})
+	server.startTime = time.Now().Add(-time.Minute)
+	server.initialiseServer()
+
+	req := httptest.NewRequest("GET", "/version", nil)
+	w := httptest.NewRecorder()
+	server.handler().ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var info VersionInfo
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &info))
+	assert.Equal(t, "v1.2.3", info.Version)
+	assert.Equal(t, "abc123", info.Commit)
+	assert.Equal(t, runtime.Version(), info.GoVersion)
+	assert.GreaterOrEqual(t, info.UptimeSeconds, int64(60))
+
+	// /status is not enabled
+	req = httptest.NewRequest("GET", "/status", nil)
+	w = httptest.NewRecorder()
+	server.handler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestVersionEndpointDisabled(t *testing.T) {
+	server := NewServer(Config{})
+	server.initialiseServer()
+
+	req := httptest.NewRequest("GET", "/version", nil)
+	w := httptest.NewRecorder()
+	server.handler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestStatusEndpointBuildInfo(t *testing.T) {
+	server := NewServer(Config{EnableStatus: true})
+	server.SetBuildInfo("v2.0.0", "def456")
+	server.initialiseServer()
+
+	req := httptest.NewRequest("GET", "/status", nil)
+	w := httptest.NewRecorder()
+	server.handler().ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var status struct {
+		Build VersionInfo
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	assert.Equal(t, "v2.0.0", status.Build.Version)
+	assert.Equal(t, "def456", status.Build.Commit)
+	assert.False(t, status.Build.StartTime.IsZero())
+}
+
+func TestServeKeepsStartTime(t *testing.T) {
+	server := NewServer(Config{})
+	startTime := server.VersionInfo().StartTime
+	require.False(t, startTime.IsZero())
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { _ = server.Serve(ln) }()
+	defer server.Shutdown(context.Background())
+
+	require.Eventually(t, func() bool {
+		resp, err := http.Get("http://" + ln.Addr().String() + "/")
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return true
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, startTime, server.VersionInfo().StartTime)
+}
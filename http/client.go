@@ -9,6 +9,9 @@ import (
 
 	auth "github.com/dioad/auth/http"
 	"github.com/dioad/auth/http/basic"
+	"github.com/dioad/auth/oidc"
+
+	diotls "github.com/dioad/net/tls"
 )
 
 // Client describes an HTTP client for making requests to a base URL.
@@ -22,6 +25,14 @@ type ClientConfig struct {
 	Client     *http.Client
 	UserAgent  string
 	AuthConfig auth.ClientConfig
+	// OIDCConfig authenticates requests with bearer tokens from the OIDC token source it
+	// describes. It is only used by NewAuthenticatedClient.
+	OIDCConfig oidc.ClientConfig
+	// AWSSigV4Config signs requests with AWS Signature Version 4. It is only used by
+	// NewAuthenticatedClient.
+	AWSSigV4Config AWSSigV4Config
+	// TLSConfig configures root CAs and client certificates. It is only used by NewAuthenticatedClient.
+	TLSConfig diotls.ClientConfig
 }
 
 func (c *Client) checkConfig() error {
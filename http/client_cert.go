@@ -0,0 +1,38 @@
+package http
+
+import (
+	"crypto/x509"
+	"net/http"
+
+	diojson "github.com/dioad/net/http/json"
+)
+
+// VerifiedClientCert returns the leaf of the client certificate chain verified during
+// the TLS handshake, or nil if the request was not made over TLS or no certificate was verified.
+func VerifiedClientCert(r *http.Request) *x509.Certificate {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return r.TLS.VerifiedChains[0][0]
+}
+
+// RequireClientCert returns middleware that rejects requests without a verified client
+// certificate with a 403 Forbidden response.
+//
+// TLS client authentication is negotiated once per connection during the handshake, so it
+// cannot vary by route at the TLS layer. To require mTLS on some routes only, configure the
+// listener with ClientAuthType "VerifyClientCertIfGiven" so that certificates which are
+// presented are verified but not required, then wrap the routes that need a client
+// certificate with this middleware. Certificates accepted without verification (e.g. with
+// "RequireAnyClientCert") are not considered verified and are rejected.
+func RequireClientCert() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if VerifiedClientCert(r) == nil {
+				diojson.NewResponse(w).ForbiddenWithMessage("client certificate required")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package http
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequireClientCert(t *testing.T) {
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "client"}}
+
+	tests := []struct {
+		name string
+		tls  *tls.ConnectionState
+		want int
+	}{
+		{name: "plain http", tls: nil, want: http.StatusForbidden},
+		{name: "no client cert", tls: &tls.ConnectionState{}, want: http.StatusForbidden},
+		{
+			name: "unverified client cert",
+			tls:  &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}},
+			want: http.StatusForbidden,
+		},
+		{
+			name: "verified client cert",
+			tls:  &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}, VerifiedChains: [][]*x509.Certificate{{cert}}},
+			want: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *x509.Certificate
+			handler := RequireClientCert()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = VerifiedClientCert(r)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+			req.TLS = tt.tls
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.want, rr.Code)
+			if tt.want == http.StatusOK {
+				assert.Equal(t, "client", got.Subject.CommonName)
+			}
+		})
+	}
+}
@@ -2,8 +2,11 @@ package http
 
 import (
 	"context"
+	"net"
 	"net/http"
 	"strings"
+
+	"github.com/dioad/net/http/clientip"
 )
 
 // httpContextKeyClientIP is an unexported type used as a key for storing the client IP in the context.
@@ -62,24 +65,82 @@ func GetClientIP(r *http.Request) string {
 	return addr
 }
 
+// parseForwardedHeader returns the "for" address of the first element of an RFC 7239
+// Forwarded header, without quotes, brackets or port.
 func parseForwardedHeader(f string) string {
 	if first, _, ok := strings.Cut(f, ","); ok {
 		f = first
 	}
-	// Look for for=
-	for part := range strings.SplitSeq(f, ";") {
-		part = strings.TrimSpace(part)
-		if before, after, ok := strings.Cut(part, "="); ok {
-			if strings.EqualFold(before, "for") {
-				ip := strings.TrimSpace(after)
-				// Remove quotes if present
-				ip = strings.Trim(ip, "\"")
-				// Remove brackets if present (IPv6)
-				ip = strings.TrimPrefix(ip, "[")
-				ip = strings.TrimSuffix(ip, "]")
-				return ip
+	ip := clientip.ForwardedFor(f)
+	if addr, ok := clientip.ParseHostAddr(ip); ok {
+		return addr.String()
+	}
+	return ip
+}
+
+// Forwarded headers understood by ClientIP.
+const (
+	HeaderXForwardedFor = clientip.HeaderXForwardedFor
+	HeaderForwarded     = clientip.HeaderForwarded
+	HeaderXRealIP       = clientip.HeaderXRealIP
+)
+
+// TrustConfig controls which forwarded headers, and which proxies reporting them,
+// are trusted when determining the client IP of a request. It is shared with other
+// packages, such as the prefixlist middleware, through the clientip package.
+type TrustConfig = clientip.TrustConfig
+
+// NewTrustConfig creates a TrustConfig trusting the given proxies, each an IP address or CIDR.
+func NewTrustConfig(trustedProxies ...string) (TrustConfig, error) {
+	return clientip.NewTrustConfig(trustedProxies...)
+}
+
+// ClientIP returns the IP address of the client that made the request, as resolved
+// by clientip.ClientAddr.
+//
+// Forwarded headers are only consulted when the immediate peer (RemoteAddr) is a
+// trusted proxy. The hops in the first configured header present are then walked
+// from right to left, skipping trusted proxies, and the first untrusted hop is the
+// client. If a hop cannot be parsed the last trusted hop is returned, so a client
+// cannot choose its own address by injecting garbage. If RemoteAddr cannot be
+// parsed, nil is returned.
+func ClientIP(r *http.Request, trust TrustConfig) net.IP {
+	addr, ok := clientip.ClientAddr(r, trust)
+	if !ok {
+		return nil
+	}
+	return net.IP(addr.AsSlice())
+}
+
+// ClientIPMiddleware returns a middleware that resolves the client IP using trust and
+// stores it in the request context, where ClientIPFromContext, the rate limiter's
+// ClientIPPrincipalFunc and the access loggers pick it up.
+func ClientIPMiddleware(trust TrustConfig) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := ClientIP(r, trust)
+			if ip == nil {
+				next.ServeHTTP(w, r)
+				return
 			}
-		}
+			ctx := context.WithValue(r.Context(), httpContextKeyClientIP{}, ip.String())
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// WithTrustConfig returns a ServerOption that resolves the client IP of every request
+// using trust, before any other middleware or request logging runs.
+func WithTrustConfig(trust TrustConfig) ServerOption {
+	return func(s *Server) {
+		s.trustConfig = &trust
+	}
+}
+
+// resolvedClientIP returns the client IP stored by ClientIPMiddleware, falling back to GetClientIP.
+func resolvedClientIP(r *http.Request) string {
+	if ip, ok := ClientIPFromContext(r.Context()); ok {
+		return ip
 	}
-	return ""
+	return GetClientIP(r)
 }
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestGetClientIP_XForwardedFor(t *testing.T) {
@@ -100,6 +101,16 @@ func TestGetClientIP_Forwarded(t *testing.T) {
 			header:   "For=192.0.2.60 ; Proto=https",
 			expected: "192.0.2.60",
 		},
+		{
+			name:     "Quoted IPv4 with port",
+			header:   `for="192.0.2.60:8080"`,
+			expected: "192.0.2.60",
+		},
+		{
+			name:     "Quoted IPv6 with port",
+			header:   `For="[2001:db8::1]:443"`,
+			expected: "2001:db8::1",
+		},
 	}
 
 	for _, tt := range tests {
@@ -111,3 +122,168 @@ func TestGetClientIP_Forwarded(t *testing.T) {
 		})
 	}
 }
+
+func TestClientIP(t *testing.T) {
+	trust, err := NewTrustConfig("10.0.0.0/8", "fd00::1")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		trust      TrustConfig
+		remoteAddr string
+		headers    map[string][]string
+		want       string
+	}{
+		{
+			name:       "no trust ignores headers",
+			remoteAddr: "203.0.113.1:1234",
+			headers:    map[string][]string{"X-Forwarded-For": {"192.0.2.1"}},
+			want:       "203.0.113.1",
+		},
+		{
+			name:       "untrusted peer ignores headers",
+			trust:      trust,
+			remoteAddr: "203.0.113.1:1234",
+			headers:    map[string][]string{"X-Forwarded-For": {"192.0.2.1"}},
+			want:       "203.0.113.1",
+		},
+		{
+			name:       "trusted peer uses xff",
+			trust:      trust,
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string][]string{"X-Forwarded-For": {"192.0.2.1"}},
+			want:       "192.0.2.1",
+		},
+		{
+			name:       "spoofed leftmost xff entry ignored",
+			trust:      trust,
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string][]string{"X-Forwarded-For": {"198.51.100.1, 192.0.2.1"}},
+			want:       "192.0.2.1",
+		},
+		{
+			name:       "trusted hops skipped",
+			trust:      trust,
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string][]string{"X-Forwarded-For": {"192.0.2.1, 10.0.0.3", "10.0.0.2"}},
+			want:       "192.0.2.1",
+		},
+		{
+			name:       "all hops trusted returns leftmost",
+			trust:      trust,
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string][]string{"X-Forwarded-For": {"10.0.0.3, 10.0.0.2"}},
+			want:       "10.0.0.3",
+		},
+		{
+			name:       "invalid hop returns last trusted hop",
+			trust:      trust,
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string][]string{"X-Forwarded-For": {"192.0.2.1, garbage, 10.0.0.2"}},
+			want:       "10.0.0.2",
+		},
+		{
+			name:       "forwarded header",
+			trust:      trust,
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string][]string{"Forwarded": {`for=192.0.2.60;proto=http, for="[2001:db8:cafe::17]:4711"`}},
+			want:       "2001:db8:cafe::17",
+		},
+		{
+			name:       "xff preferred over forwarded by default",
+			trust:      trust,
+			remoteAddr: "10.0.0.1:1234",
+			headers: map[string][]string{
+				"X-Forwarded-For": {"192.0.2.1"},
+				"Forwarded":       {"for=192.0.2.2"},
+			},
+			want: "192.0.2.1",
+		},
+		{
+			name:       "forwarded element without for is an invalid hop",
+			trust:      trust,
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string][]string{"Forwarded": {"for=192.0.2.60, proto=https;host=example.com, for=10.0.0.2"}},
+			want:       "10.0.0.2",
+		},
+		{
+			name:       "forwarded preferred when configured first",
+			trust:      TrustConfig{TrustedProxies: trust.TrustedProxies, Headers: []string{HeaderForwarded, HeaderXForwardedFor}},
+			remoteAddr: "10.0.0.1:1234",
+			headers: map[string][]string{
+				"X-Forwarded-For": {"192.0.2.1"},
+				"Forwarded":       {"for=192.0.2.2"},
+			},
+			want: "192.0.2.2",
+		},
+		{
+			name:       "configured headers only",
+			trust:      TrustConfig{TrustedProxies: trust.TrustedProxies, Headers: []string{HeaderXRealIP}},
+			remoteAddr: "10.0.0.1:1234",
+			headers: map[string][]string{
+				"X-Forwarded-For": {"192.0.2.1"},
+				"X-Real-IP":       {"192.0.2.3"},
+			},
+			want: "192.0.2.3",
+		},
+		{
+			name:       "trusted ipv6 proxy",
+			trust:      trust,
+			remoteAddr: "[fd00::1]:443",
+			headers:    map[string][]string{"X-Forwarded-For": {"192.0.2.1"}},
+			want:       "192.0.2.1",
+		},
+		{
+			name:       "ipv4 mapped remote addr",
+			trust:      trust,
+			remoteAddr: "[::ffff:10.0.0.1]:1234",
+			headers:    map[string][]string{"X-Forwarded-For": {"192.0.2.1"}},
+			want:       "192.0.2.1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/test", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for name, values := range tt.headers {
+				for _, v := range values {
+					req.Header.Add(name, v)
+				}
+			}
+
+			assert.Equal(t, tt.want, ClientIP(req, tt.trust).String())
+		})
+	}
+}
+
+func TestClientIP_InvalidRemoteAddr(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.RemoteAddr = "not-an-address"
+	assert.Nil(t, ClientIP(req, TrustConfig{}))
+}
+
+func TestNewTrustConfig_Invalid(t *testing.T) {
+	_, err := NewTrustConfig("10.0.0.0/8", "nope")
+	assert.Error(t, err)
+}
+
+func TestWithTrustConfig(t *testing.T) {
+	trust, err := NewTrustConfig("10.0.0.0/8")
+	require.NoError(t, err)
+
+	server := NewServer(Config{}, WithTrustConfig(trust))
+
+	var principal string
+	server.AddHandlerFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		principal, _ = ClientIPPrincipalFunc(r)
+	})
+	server.initialiseServer()
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Forwarded-For", "198.51.100.1, 192.0.2.1")
+	server.handler().ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, "192.0.2.1", principal)
+}
@@ -0,0 +1,161 @@
+// Package clientip resolves the IP address of the client behind an HTTP request,
+// believing forwarded headers only when they are reported by a trusted proxy.
+//
+// It depends only on the standard library so that packages which must not pull in
+// the rest of github.com/dioad/net/http can share the same parser.
+package clientip
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// Forwarded headers understood by ClientAddr.
+const (
+	HeaderXForwardedFor = "X-Forwarded-For"
+	HeaderForwarded     = "Forwarded"
+	HeaderXRealIP       = "X-Real-IP"
+)
+
+// TrustConfig controls which forwarded headers, and which proxies reporting them,
+// are trusted when determining the client IP of a request.
+type TrustConfig struct {
+	// TrustedProxies are the networks of proxies whose forwarded headers are believed.
+	// If empty, forwarded headers are ignored and RemoteAddr is always used.
+	TrustedProxies []netip.Prefix
+	// Headers lists the forwarded headers to consult, in order of preference.
+	// If empty, X-Forwarded-For then Forwarded are used. When a request carries more
+	// than one of them, only the first present in this order is used; the headers are
+	// never merged, as proxies that emit both record the same hops in each.
+	Headers []string
+}
+
+// NewTrustConfig creates a TrustConfig trusting the given proxies, each an IP address or CIDR.
+func NewTrustConfig(trustedProxies ...string) (TrustConfig, error) {
+	var trust TrustConfig
+	for _, p := range trustedProxies {
+		prefix, err := netip.ParsePrefix(p)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(p)
+			if addrErr != nil {
+				return TrustConfig{}, fmt.Errorf("invalid trusted proxy %q: %w", p, err)
+			}
+			prefix = netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen())
+		}
+		trust.TrustedProxies = append(trust.TrustedProxies, prefix.Masked())
+	}
+	return trust, nil
+}
+
+// IsTrusted reports whether addr is within one of the trusted proxy networks.
+func (t TrustConfig) IsTrusted(addr netip.Addr) bool {
+	for _, prefix := range t.TrustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t TrustConfig) headers() []string {
+	if len(t.Headers) > 0 {
+		return t.Headers
+	}
+	return []string{HeaderXForwardedFor, HeaderForwarded}
+}
+
+// ClientAddr returns the address of the client that made the request.
+//
+// Forwarded headers are only consulted when the immediate peer (RemoteAddr) is a
+// trusted proxy. The hops in the first configured header present are then walked
+// from right to left, skipping trusted proxies, and the first untrusted hop is the
+// client. If a hop cannot be parsed the last trusted hop is returned, so a client
+// cannot choose its own address by injecting garbage. If RemoteAddr cannot be
+// parsed, false is returned.
+func ClientAddr(r *http.Request, trust TrustConfig) (netip.Addr, bool) {
+	remote, ok := ParseHostAddr(r.RemoteAddr)
+	if !ok {
+		return netip.Addr{}, false
+	}
+
+	if !trust.IsTrusted(remote) {
+		return remote, true
+	}
+
+	for _, header := range trust.headers() {
+		hops := ForwardedHops(r.Header, header)
+		if len(hops) == 0 {
+			continue
+		}
+
+		client := remote
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop, ok := ParseHostAddr(hops[i])
+			if !ok {
+				break
+			}
+			client = hop
+			if !trust.IsTrusted(hop) {
+				break
+			}
+		}
+		return client, true
+	}
+
+	return remote, true
+}
+
+// ForwardedHops returns the addresses recorded in the named header, nearest client first.
+// A Forwarded element without a "for" parameter is kept as an empty hop, so that it is
+// treated as unparseable rather than silently shifting the remaining hops.
+func ForwardedHops(h http.Header, header string) []string {
+	isForwarded := strings.EqualFold(header, HeaderForwarded)
+
+	var hops []string
+	for _, value := range h.Values(header) {
+		for element := range strings.SplitSeq(value, ",") {
+			element = strings.TrimSpace(element)
+			if isForwarded {
+				if element == "" {
+					continue
+				}
+				hops = append(hops, ForwardedFor(element))
+				continue
+			}
+			if element != "" {
+				hops = append(hops, element)
+			}
+		}
+	}
+	return hops
+}
+
+// ForwardedFor returns the unquoted "for" parameter of a single Forwarded element, e.g.
+// "192.0.2.60", "192.0.2.60:8080" or "[2001:db8::1]:443". Parameter names are
+// case-insensitive and other parameters (proto, host, by) are ignored.
+func ForwardedFor(element string) string {
+	for part := range strings.SplitSeq(element, ";") {
+		if name, value, ok := strings.Cut(strings.TrimSpace(part), "="); ok && strings.EqualFold(strings.TrimSpace(name), "for") {
+			return strings.Trim(strings.TrimSpace(value), "\"")
+		}
+	}
+	return ""
+}
+
+// ParseHostAddr parses an IP address that may include a port or IPv6 brackets,
+// unmapping IPv4-in-IPv6 addresses.
+func ParseHostAddr(s string) (netip.Addr, bool) {
+	s = strings.TrimSpace(s)
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
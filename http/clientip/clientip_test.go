@@ -0,0 +1,134 @@
+package clientip
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientAddr(t *testing.T) {
+	trust, err := NewTrustConfig("10.0.0.0/8", "fd00::1")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		trust      TrustConfig
+		remoteAddr string
+		headers    map[string][]string
+		want       string
+	}{
+		{
+			name:       "no trust ignores headers",
+			remoteAddr: "203.0.113.1:1234",
+			headers:    map[string][]string{"X-Forwarded-For": {"192.0.2.1"}},
+			want:       "203.0.113.1",
+		},
+		{
+			name:       "trusted peer uses xff",
+			trust:      trust,
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string][]string{"X-Forwarded-For": {"192.0.2.1"}},
+			want:       "192.0.2.1",
+		},
+		{
+			name:       "trusted hops skipped",
+			trust:      trust,
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string][]string{"X-Forwarded-For": {"192.0.2.1, 10.0.0.3", "10.0.0.2"}},
+			want:       "192.0.2.1",
+		},
+		{
+			name:       "invalid hop returns last trusted hop",
+			trust:      trust,
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string][]string{"X-Forwarded-For": {"192.0.2.1, garbage, 10.0.0.2"}},
+			want:       "10.0.0.2",
+		},
+		{
+			name:       "invalid nearest hop returns remote addr",
+			trust:      trust,
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string][]string{"X-Forwarded-For": {"garbage"}},
+			want:       "10.0.0.1",
+		},
+		{
+			name:       "forwarded header",
+			trust:      trust,
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string][]string{"Forwarded": {`for=192.0.2.60;proto=http, for="[2001:db8:cafe::17]:4711"`}},
+			want:       "2001:db8:cafe::17",
+		},
+		{
+			name:       "forwarded element without for is an invalid hop",
+			trust:      trust,
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string][]string{"Forwarded": {"for=192.0.2.60, proto=https;host=example.com, for=10.0.0.2"}},
+			want:       "10.0.0.2",
+		},
+		{
+			name:       "ipv4 mapped remote addr",
+			trust:      trust,
+			remoteAddr: "[::ffff:10.0.0.1]:1234",
+			headers:    map[string][]string{"X-Forwarded-For": {"192.0.2.1"}},
+			want:       "192.0.2.1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/test", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for name, values := range tt.headers {
+				for _, v := range values {
+					req.Header.Add(name, v)
+				}
+			}
+
+			addr, ok := ClientAddr(req, tt.trust)
+			require.True(t, ok)
+			assert.Equal(t, netip.MustParseAddr(tt.want), addr)
+		})
+	}
+}
+
+func TestClientAddr_InvalidRemoteAddr(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.RemoteAddr = "not-an-address"
+	_, ok := ClientAddr(req, TrustConfig{})
+	assert.False(t, ok)
+}
+
+func TestParseHostAddr(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{in: "192.0.2.1", want: "192.0.2.1", ok: true},
+		{in: "192.0.2.1:8080", want: "192.0.2.1", ok: true},
+		{in: "[2001:db8::1]:443", want: "2001:db8::1", ok: true},
+		{in: "[2001:db8::1]", want: "2001:db8::1", ok: true},
+		{in: "::ffff:192.0.2.1", want: "192.0.2.1", ok: true},
+		{in: "garbage"},
+		{in: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			addr, ok := ParseHostAddr(tt.in)
+			assert.Equal(t, tt.ok, ok)
+			if tt.ok {
+				assert.Equal(t, netip.MustParseAddr(tt.want), addr)
+			}
+		})
+	}
+}
+
+func TestNewTrustConfig_Invalid(t *testing.T) {
+	_, err := NewTrustConfig("10.0.0.0/8", "nope")
+	assert.Error(t, err)
+}
@@ -0,0 +1,124 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+
+	diojson "github.com/dioad/net/http/json"
+)
+
+// Sentinel errors understood by DefaultErrorMapper. Handlers wrap them to choose the
+// response status, e.g. fmt.Errorf("user %s: %w", id, ErrNotFound).
+var (
+	ErrBadRequest   = errors.New("bad request")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+)
+
+// ErrorMapper maps an error returned by a HandlerFuncE handler to the response status and
+// the message sent to the client. Returning a zero status defers to DefaultErrorMapper.
+// The client message must not leak server-side detail; the full error is logged.
+type ErrorMapper func(error) (status int, clientMsg string)
+
+type httpContextKeyErrorMapper struct{}
+
+type errorMapperConfig struct {
+	mapper ErrorMapper
+	logger zerolog.Logger
+}
+
+// DefaultErrorMapper maps the sentinel errors in this package, diojson.ErrUnsupportedMediaType,
+// ErrMultipleValues, *http.MaxBytesError and context.DeadlineExceeded to their status codes,
+// and any other error to 500 Internal Server Error. Matching uses errors.Is and errors.As,
+// so wrapped errors are mapped too. The client message is the status text.
+func DefaultErrorMapper(err error) (int, string) {
+	status := http.StatusInternalServerError
+
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.Is(err, ErrBadRequest), errors.Is(err, ErrMultipleValues):
+		status = http.StatusBadRequest
+	case errors.Is(err, ErrUnauthorized):
+		status = http.StatusUnauthorized
+	case errors.Is(err, ErrForbidden):
+		status = http.StatusForbidden
+	case errors.Is(err, ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, ErrConflict):
+		status = http.StatusConflict
+	case errors.Is(err, diojson.ErrUnsupportedMediaType):
+		status = http.StatusUnsupportedMediaType
+	case errors.As(err, &maxBytesErr):
+		status = http.StatusRequestEntityTooLarge
+	case errors.Is(err, context.DeadlineExceeded):
+		status = http.StatusServiceUnavailable
+	}
+
+	return status, http.StatusText(status)
+}
+
+// HandlerFuncE adapts a handler that returns an error into an http.Handler. A non-nil
+// error is mapped to a status and client message by the ErrorMapper set with
+// WithErrorMapper (or DefaultErrorMapper), sent as a JSON error response shaped by
+// json.DefaultErrorEnvelope ({"error": clientMsg} unless changed), and logged with the
+// request details: at error level for 5xx responses and info level otherwise.
+//
+// The handler must not have written a response before returning an error.
+func HandlerFuncE(fn func(w http.ResponseWriter, r *http.Request) error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := fn(w, r)
+		if err == nil {
+			return
+		}
+
+		config, ok := r.Context().Value(httpContextKeyErrorMapper{}).(errorMapperConfig)
+		if !ok {
+			config = errorMapperConfig{logger: log.Logger}
+		}
+
+		status, clientMsg := 0, ""
+		if config.mapper != nil {
+			status, clientMsg = config.mapper(err)
+		}
+		if status == 0 {
+			status, clientMsg = DefaultErrorMapper(err)
+		}
+
+		level := zerolog.InfoLevel
+		if status >= http.StatusInternalServerError {
+			level = zerolog.ErrorLevel
+		}
+		config.logger.WithLevel(level).
+			Err(err).
+			Int("status", status).
+			Str("method", r.Method).
+			Str("url", r.URL.Redacted()).
+			Str("remoteAddr", r.RemoteAddr).
+			Msg("handler error")
+
+		diojson.NewResponse(w).ErrorWithMessages(status, clientMsg, "", nil)
+	})
+}
+
+// WithErrorMapper returns a ServerOption that makes HandlerFuncE handlers map errors with
+// mapper, falling back to DefaultErrorMapper when it returns a zero status, and log them
+// with the server's logger.
+func WithErrorMapper(mapper ErrorMapper) ServerOption {
+	return func(s *Server) {
+		s.Use(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ctx := context.WithValue(r.Context(), httpContextKeyErrorMapper{}, errorMapperConfig{
+					mapper: mapper,
+					logger: s.Logger,
+				})
+				next.ServeHTTP(w, r.WithContext(ctx))
+			})
+		})
+	}
+}
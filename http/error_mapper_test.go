@@ -0,0 +1,121 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	diojson "github.com/dioad/net/http/json"
+)
+
+func TestDefaultErrorMapper(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{name: "bad request", err: ErrBadRequest, status: http.StatusBadRequest},
+		{name: "wrapped not found", err: fmt.Errorf("user 42: %w", ErrNotFound), status: http.StatusNotFound},
+		{name: "unauthorized", err: ErrUnauthorized, status: http.StatusUnauthorized},
+		{name: "forbidden", err: ErrForbidden, status: http.StatusForbidden},
+		{name: "conflict", err: ErrConflict, status: http.StatusConflict},
+		{name: "multiple values", err: fmt.Errorf("query: %w", ErrMultipleValues), status: http.StatusBadRequest},
+		{name: "unsupported media type", err: diojson.ErrUnsupportedMediaType, status: http.StatusUnsupportedMediaType},
+		{name: "max bytes", err: fmt.Errorf("read: %w", &http.MaxBytesError{Limit: 10}), status: http.StatusRequestEntityTooLarge},
+		{name: "deadline", err: context.DeadlineExceeded, status: http.StatusServiceUnavailable},
+		{name: "unknown", err: errors.New("db connection refused"), status: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, msg := DefaultErrorMapper(tt.err)
+			assert.Equal(t, tt.status, status)
+			assert.Equal(t, http.StatusText(tt.status), msg)
+		})
+	}
+}
+
+func TestHandlerFuncE(t *testing.T) {
+	handler := HandlerFuncE(func(w http.ResponseWriter, r *http.Request) error {
+		if r.URL.Query().Get("fail") != "" {
+			return fmt.Errorf("widget %s: %w", r.URL.Query().Get("fail"), ErrNotFound)
+		}
+		_, _ = w.Write([]byte("ok"))
+		return nil
+	})
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "ok", rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?fail=7", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Contains(t, rec.Header().Get("Content-Type"), "application/json")
+
+	var body map[string]string
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, map[string]string{"error": "Not Found"}, body)
+}
+
+func TestWithErrorMapper(t *testing.T) {
+	errQuota := errors.New("quota exceeded")
+
+	var logs bytes.Buffer
+	server := NewServer(Config{},
+		WithLogger(zerolog.New(&logs)),
+		WithErrorMapper(func(err error) (int, string) {
+			if errors.Is(err, errQuota) {
+				return http.StatusTooManyRequests, "quota exceeded, try again tomorrow"
+			}
+			return 0, ""
+		}),
+	)
+
+	var handlerErr error
+	server.AddHandler("/", HandlerFuncE(func(w http.ResponseWriter, r *http.Request) error {
+		return handlerErr
+	}))
+	server.initialiseServer()
+
+	tests := []struct {
+		name    string
+		err     error
+		status  int
+		message string
+		level   string
+	}{
+		{name: "custom mapping", err: fmt.Errorf("tenant 9: %w", errQuota), status: http.StatusTooManyRequests, message: "quota exceeded, try again tomorrow", level: "info"},
+		{name: "falls back to default", err: ErrConflict, status: http.StatusConflict, message: "Conflict", level: "info"},
+		{name: "internal error detail not leaked", err: errors.New("password=hunter2 rejected by db"), status: http.StatusInternalServerError, message: "Internal Server Error", level: "error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs.Reset()
+			handlerErr = tt.err
+
+			rec := httptest.NewRecorder()
+			server.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			assert.Equal(t, tt.status, rec.Code)
+			var body map[string]string
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			assert.Equal(t, tt.message, body["error"])
+			assert.NotContains(t, rec.Body.String(), "hunter2")
+
+			assert.Contains(t, logs.String(), tt.err.Error())
+			assert.Contains(t, logs.String(), `"level":"`+tt.level+`"`)
+		})
+	}
+}
@@ -1,8 +1,12 @@
 package http
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"reflect"
+
+	"golang.org/x/net/http/httpguts"
 )
 
 const (
@@ -10,8 +14,19 @@ const (
 	HeaderMarshalTagName = "header"
 )
 
+// ErrInvalidHeaderName is returned by MarshalHeader when a generated header name is not a
+// valid HTTP field name (RFC 9110 Section 5.1), e.g. because a tag, prefix or struct name
+// contains spaces, colons or non-ASCII bytes.
+var ErrInvalidHeaderName = errors.New("invalid header name")
+
 // MarshalHeader encodes a struct into an http.Header using the provided options.
 //
+// Header names are built as Prefix-StructName-FieldName (see HTTPMarshalOptions) and
+// then canonicalized by http.Header, so "X-App" with a struct named AppConfig yields
+// "X-App-App-Config-Field" and UnmarshalHeader finds it under the same options. Every
+// name is validated before any header is written; if one is not a valid HTTP field name
+// an error wrapping ErrInvalidHeaderName is returned rather than a broken header.
+//
 // RFC 9110 Compliance:
 // For slice fields ([]string), each element is added as a separate header occurrence
 // using http.Header.Add(). This is compliant with RFC 9110 Section 5.5, which allows
@@ -35,7 +50,12 @@ func MarshalHeader(v any, opts HTTPMarshalOptions) (http.Header, error) {
 		return header, nil
 	}
 
-	err := marshalFields(v, HeaderMarshalTagName, header, opts)
+	err := validateHeaderNames(v, opts)
+	if err != nil {
+		return nil, fmt.Errorf("marshal header: %w", err)
+	}
+
+	err = marshalFields(v, HeaderMarshalTagName, header, opts)
 	if err != nil {
 		return nil, fmt.Errorf("marshal header: %w", err)
 	}
@@ -43,6 +63,25 @@ func MarshalHeader(v any, opts HTTPMarshalOptions) (http.Header, error) {
 	return header, nil
 }
 
+// validateHeaderNames checks that every header name MarshalHeader would generate for v is
+// a valid HTTP field name.
+func validateHeaderNames(v any, opts HTTPMarshalOptions) error {
+	val, typ, err := normalizeStructValue(v, false, false)
+	if err != nil {
+		return err
+	}
+
+	return walkStructFields(val, typ, HeaderMarshalTagName, opts, func(_ reflect.Value, fieldType reflect.StructField, fieldName string) error {
+		if getTagDetails(HeaderMarshalTagName, fieldType).skip {
+			return nil
+		}
+		if !httpguts.ValidHeaderFieldName(fieldName) {
+			return fmt.Errorf("fieldSet %s: %w %q", fieldType.Name, ErrInvalidHeaderName, fieldName)
+		}
+		return nil
+	})
+}
+
 // UnmarshalHeader decodes an http.Header into a struct using the provided options.
 //
 // RFC 9110 Compliance:
@@ -1,6 +1,7 @@
 package http
 
 import (
+	"errors"
 	"net/http"
 	"testing"
 
@@ -599,3 +600,109 @@ func TestRFC9110OrderPreservation(t *testing.T) {
 		t.Errorf("Order not preserved (-want +got):\n%s", diff)
 	}
 }
+
+// TestUnmarshalHeaderStrictScalarValues tests that duplicate scalar headers are rejected in strict mode
+func TestUnmarshalHeaderStrictScalarValues(t *testing.T) {
+	header := http.Header{}
+	header.Add("X-FieldOne", "value1")
+	header.Add("X-FieldOne", "conflicting")
+	header.Add("X-FieldTwo", "value2")
+	header.Add("X-FieldTwo", "value3")
+
+	// Lenient by default: the first occurrence wins
+	var lenient Example
+	if err := UnmarshalHeader(header, &lenient, HTTPMarshalOptions{Prefix: "X"}); err != nil {
+		t.Fatalf("UnmarshalHeader failed: %v", err)
+	}
+	if lenient.FieldOne != "value1" {
+		t.Errorf("FieldOne = %q, want %q", lenient.FieldOne, "value1")
+	}
+
+	var strict Example
+	err := UnmarshalHeader(header, &strict, HTTPMarshalOptions{Prefix: "X", StrictScalarValues: true})
+	if !errors.Is(err, ErrMultipleValues) {
+		t.Fatalf("UnmarshalHeader error = %v, want %v", err, ErrMultipleValues)
+	}
+
+	// Slice fields accept multiple occurrences in strict mode
+	header.Del("X-FieldOne")
+	header.Add("X-FieldOne", "value1")
+	if err := UnmarshalHeader(header, &strict, HTTPMarshalOptions{Prefix: "X", StrictScalarValues: true}); err != nil {
+		t.Fatalf("UnmarshalHeader failed: %v", err)
+	}
+	if diff := cmp.Diff([]string{"value2", "value3"}, strict.FieldTwo); diff != "" {
+		t.Errorf("FieldTwo mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestMarshalHeaderInvalidNames(t *testing.T) {
+	type Valid struct {
+		Name    string `header:"x-valid_name.v1"`
+		Skipped string `header:"-"`
+	}
+	type WithSpace struct {
+		Name string `header:"bad name"`
+	}
+	type WithColon struct {
+		Name string `header:"bad:name"`
+	}
+	type NonASCII struct {
+		Name string `header:"naïve"`
+	}
+	type ControlByte struct {
+		Name string `header:"bad\x00name"`
+	}
+
+	tests := []struct {
+		name    string
+		v       any
+		opts    HTTPMarshalOptions
+		wantErr bool
+	}{
+		{name: "valid token characters", v: Valid{Name: "v", Skipped: "s"}},
+		{name: "space in tag", v: WithSpace{Name: "v"}, wantErr: true},
+		{name: "colon in tag", v: WithColon{Name: "v"}, wantErr: true},
+		{name: "non-ascii in tag", v: NonASCII{Name: "v"}, wantErr: true},
+		{name: "control byte in tag", v: ControlByte{Name: "v"}, wantErr: true},
+		{name: "space in prefix", v: Example{FieldOne: "v"}, opts: HTTPMarshalOptions{Prefix: "X App"}, wantErr: true},
+		{name: "empty field still validated", v: WithSpace{}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header, err := MarshalHeader(tt.v, tt.opts)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidHeaderName) {
+					t.Fatalf("MarshalHeader error = %v, want %v", err, ErrInvalidHeaderName)
+				}
+				if header != nil {
+					t.Errorf("MarshalHeader returned header %v with error", header)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("MarshalHeader failed: %v", err)
+			}
+			if got := header.Get("X-Valid_name.v1"); got != "v" {
+				t.Errorf("X-Valid_name.v1 = %q, want %q", got, "v")
+			}
+			if len(header) != 1 {
+				t.Errorf("header = %v, want only the valid field", header)
+			}
+		})
+	}
+}
+
+func TestUnmarshalHeaderScalarValuesLast(t *testing.T) {
+	header := http.Header{}
+	header.Add("X-FieldOne", "value1")
+	header.Add("X-FieldOne", "override")
+
+	var ex Example
+	if err := UnmarshalHeader(header, &ex, HTTPMarshalOptions{Prefix: "X", ScalarValues: ScalarValuesLast}); err != nil {
+		t.Fatalf("UnmarshalHeader failed: %v", err)
+	}
+	if ex.FieldOne != "override" {
+		t.Errorf("FieldOne = %q, want %q", ex.FieldOne, "override")
+	}
+}
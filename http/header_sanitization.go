@@ -0,0 +1,79 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/dioad/net/http/clientip"
+)
+
+// DefaultSanitizedHeaders are the inbound headers removed by HeaderSanitizationMiddleware
+// when HeaderSanitizationConfig.Headers is empty. They are headers that proxies, or this
+// server's own middleware, set to describe trusted context.
+var DefaultSanitizedHeaders = []string{
+	HeaderXForwardedFor,
+	HeaderForwarded,
+	HeaderXRealIP,
+	"X-Forwarded-Host",
+	"X-Forwarded-Proto",
+	"X-Forwarded-Port",
+	"X-Request-ID",
+}
+
+// HeaderSanitizationConfig configures HeaderSanitizationMiddleware.
+type HeaderSanitizationConfig struct {
+	// Headers lists the inbound headers to remove from requests that do not come
+	// from a trusted proxy. If empty, DefaultSanitizedHeaders is used.
+	Headers []string
+	// Trust identifies the proxies whose headers are preserved. When used with
+	// WithHeaderSanitization and no TrustedProxies are set, the server's TrustConfig
+	// (see WithTrustConfig) is used instead.
+	Trust TrustConfig
+}
+
+func (c HeaderSanitizationConfig) headers() []string {
+	if len(c.Headers) > 0 {
+		return c.Headers
+	}
+	return DefaultSanitizedHeaders
+}
+
+// HeaderSanitizationMiddleware returns a middleware that removes the configured headers
+// from requests whose immediate peer is not a trusted proxy, so that clients cannot
+// inject headers that downstream handlers treat as trusted context. Requests from
+// trusted proxies are passed through unchanged.
+func HeaderSanitizationMiddleware(config HeaderSanitizationConfig) Middleware {
+	headers := config.headers()
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if remote, ok := clientip.ParseHostAddr(r.RemoteAddr); ok && config.Trust.IsTrusted(remote) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var stripped http.Header
+			for _, header := range headers {
+				if _, ok := r.Header[http.CanonicalHeaderKey(header)]; !ok {
+					continue
+				}
+				if stripped == nil {
+					stripped = r.Header.Clone()
+				}
+				stripped.Del(header)
+			}
+			if stripped != nil {
+				r = r.Clone(r.Context())
+				r.Header = stripped
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// WithHeaderSanitization returns a ServerOption that strips the configured inbound headers
+// from requests not sent by a trusted proxy, before any other middleware or request
+// logging runs.
+func WithHeaderSanitization(config HeaderSanitizationConfig) ServerOption {
+	return func(s *Server) {
+		s.headerSanitization = &config
+	}
+}
@@ -0,0 +1,106 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeaderSanitizationMiddleware(t *testing.T) {
+	trust, err := NewTrustConfig("10.0.0.0/8")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		config     HeaderSanitizationConfig
+		remoteAddr string
+		wantKept   []string
+		wantGone   []string
+	}{
+		{
+			name:       "untrusted peer default headers stripped",
+			config:     HeaderSanitizationConfig{Trust: trust},
+			remoteAddr: "203.0.113.1:1234",
+			wantKept:   []string{"X-Auth-Principal", "Accept"},
+			wantGone:   []string{"X-Forwarded-For", "Forwarded", "X-Real-IP", "X-Request-ID"},
+		},
+		{
+			name:       "trusted peer preserved",
+			config:     HeaderSanitizationConfig{Trust: trust},
+			remoteAddr: "10.0.0.1:1234",
+			wantKept:   []string{"X-Forwarded-For", "Forwarded", "X-Real-IP", "X-Request-ID", "X-Auth-Principal"},
+		},
+		{
+			name:       "configured headers only",
+			config:     HeaderSanitizationConfig{Trust: trust, Headers: []string{"x-auth-principal"}},
+			remoteAddr: "203.0.113.1:1234",
+			wantKept:   []string{"X-Forwarded-For", "X-Request-ID"},
+			wantGone:   []string{"X-Auth-Principal"},
+		},
+		{
+			name:       "no trust strips from every peer",
+			config:     HeaderSanitizationConfig{},
+			remoteAddr: "10.0.0.1:1234",
+			wantGone:   []string{"X-Forwarded-For", "X-Request-ID"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen http.Header
+			handler := HeaderSanitizationMiddleware(tt.config)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = r.Header
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			req.Header.Set("X-Forwarded-For", "192.0.2.1")
+			req.Header.Set("Forwarded", "for=192.0.2.1")
+			req.Header.Set("X-Real-IP", "192.0.2.1")
+			req.Header.Set("X-Request-ID", "spoofed")
+			req.Header.Set("X-Auth-Principal", "admin")
+			req.Header.Set("Accept", "application/json")
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			require.NotNil(t, seen)
+			for _, h := range tt.wantKept {
+				assert.NotEmpty(t, seen.Get(h), h)
+			}
+			for _, h := range tt.wantGone {
+				assert.Empty(t, seen.Get(h), h)
+			}
+			assert.Equal(t, "192.0.2.1", req.Header.Get("X-Forwarded-For"), "original request must not be mutated")
+		})
+	}
+}
+
+func TestWithHeaderSanitization(t *testing.T) {
+	trust, err := NewTrustConfig("10.0.0.0/8")
+	require.NoError(t, err)
+
+	server := NewServer(Config{}, WithTrustConfig(trust), WithHeaderSanitization(HeaderSanitizationConfig{}))
+
+	var requestID string
+	server.AddHandlerFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		requestID = r.Header.Get("X-Request-ID")
+	})
+	server.initialiseServer()
+
+	for _, tt := range []struct {
+		remoteAddr string
+		want       string
+	}{
+		{remoteAddr: "203.0.113.1:1234", want: ""},
+		{remoteAddr: "10.0.0.1:1234", want: "abc"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = tt.remoteAddr
+		req.Header.Set("X-Request-ID", "abc")
+		server.handler().ServeHTTP(httptest.NewRecorder(), req)
+
+		assert.Equal(t, tt.want, requestID, tt.remoteAddr)
+	}
+}
@@ -1,26 +1,48 @@
 package http
 
 import (
+	"fmt"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/rs/zerolog"
 
 	diojson "github.com/dioad/net/http/json"
 )
 
+// DefaultStatusTimeout is the default time /status waits for each resource's Status call.
+const DefaultStatusTimeout = 5 * time.Second
+
 // HealthRegistry manages the collection and aggregation of resource health and status.
 type HealthRegistry struct {
-	logger      zerolog.Logger
-	resources   map[string]Resource
-	metadataMap map[string]any
+	logger        zerolog.Logger
+	resources     map[string]Resource
+	metadataMap   map[string]any
+	versionInfo   func() VersionInfo
+	statusTimeout time.Duration
+
+	// statusMu guards statusCalls, the Status calls in progress by resource path, so
+	// that a resource whose Status hangs has at most one call outstanding
+	statusMu    sync.Mutex
+	statusCalls map[string]*statusCall
+}
+
+// statusCall is a Status call shared by every /status request made while it runs.
+type statusCall struct {
+	done   chan struct{}
+	status any
+	err    error
 }
 
 // NewHealthRegistry creates a new HealthRegistry.
 func NewHealthRegistry(logger zerolog.Logger) *HealthRegistry {
 	return &HealthRegistry{
-		logger:      logger,
-		resources:   make(map[string]Resource),
-		metadataMap: make(map[string]any),
+		logger:        logger,
+		resources:     make(map[string]Resource),
+		metadataMap:   make(map[string]any),
+		statusTimeout: DefaultStatusTimeout,
+		statusCalls:   make(map[string]*statusCall),
 	}
 }
 
@@ -100,6 +122,79 @@ func (h *HealthRegistry) aggregateReadinessHandler() http.HandlerFunc {
 	}
 }
 
+// statusResult is the outcome of a single resource's Status call.
+type statusResult struct {
+	path     string
+	status   any
+	err      error
+	duration time.Duration
+}
+
+// collectStatus calls Status on every StatusResource concurrently. A resource that does not
+// respond within the registry's status timeout is reported with a timeout error; its call is
+// left to finish in the background, and later requests wait on that call rather than
+// starting another, so a hung resource never accumulates goroutines.
+func (h *HealthRegistry) collectStatus() []statusResult {
+	results := make(chan statusResult, len(h.resources))
+	count := 0
+
+	for path, resource := range h.resources {
+		sr, ok := resource.(StatusResource)
+		if !ok {
+			continue
+		}
+		count++
+
+		go func() {
+			start := time.Now()
+			call := h.startStatusCall(path, sr)
+
+			timer := time.NewTimer(h.statusTimeout)
+			defer timer.Stop()
+
+			select {
+			case <-call.done:
+				results <- statusResult{path: path, status: call.status, err: call.err, duration: time.Since(start)}
+			case <-timer.C:
+				results <- statusResult{
+					path:     path,
+					err:      fmt.Errorf("status timed out after %s", h.statusTimeout),
+					duration: time.Since(start),
+				}
+			}
+		}()
+	}
+
+	collected := make([]statusResult, 0, count)
+	for range count {
+		collected = append(collected, <-results)
+	}
+	return collected
+}
+
+// startStatusCall returns the Status call in progress for the resource at path, starting
+// one if there is none.
+func (h *HealthRegistry) startStatusCall(path string, sr StatusResource) *statusCall {
+	h.statusMu.Lock()
+	defer h.statusMu.Unlock()
+
+	if call, ok := h.statusCalls[path]; ok {
+		return call
+	}
+
+	call := &statusCall{done: make(chan struct{})}
+	h.statusCalls[path] = call
+	go func() {
+		call.status, call.err = sr.Status()
+
+		h.statusMu.Lock()
+		delete(h.statusCalls, path)
+		h.statusMu.Unlock()
+		close(call.done)
+	}()
+	return call
+}
+
 // aggregateStatusHandler checks all StatusResource implementations
 func (h *HealthRegistry) aggregateStatusHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -108,21 +203,25 @@ func (h *HealthRegistry) aggregateStatusHandler() http.HandlerFunc {
 
 		resourceStatus := make(map[string]any)
 		resourceErrors := make(map[string]string)
-		for path, resource := range h.resources {
-			if sr, ok := resource.(StatusResource); ok {
-				status, err := sr.Status()
-				if err != nil {
-					httpStatus = http.StatusInternalServerError
-					h.logger.Error().Err(err).Str("path", path).Msg("error getting resource status")
-					resourceErrors[path] = err.Error()
-					continue
-				}
-				resourceStatus[path] = status
+		resourceDurations := make(map[string]float64)
+		for _, result := range h.collectStatus() {
+			resourceDurations[result.path] = result.duration.Seconds()
+			if result.err != nil {
+				httpStatus = http.StatusInternalServerError
+				h.logger.Error().Err(result.err).Str("path", result.path).Msg("error getting resource status")
+				resourceErrors[result.path] = result.err.Error()
+				continue
 			}
+			resourceStatus[result.path] = result.status
 		}
 		statusMap["Routes"] = resourceStatus
 		statusMap["Metadata"] = h.metadataMap
 		statusMap["Errors"] = resourceErrors
+		// Durations reports how long each resource's Status call took, in seconds
+		statusMap["Durations"] = resourceDurations
+		if h.versionInfo != nil {
+			statusMap["Build"] = h.versionInfo()
+		}
 
 		res := diojson.NewResponseWithLogger(w, r, h.logger)
 		res.Data(httpStatus, statusMap)
@@ -1,6 +1,8 @@
 package http
 
 import (
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"reflect"
 	"slices"
@@ -9,13 +11,106 @@ import (
 	"unicode"
 )
 
+// NamingConvention controls how Go field names are converted to parameter names
+// when a field has no explicit tag.
+type NamingConvention string
+
+const (
+	// NamingAsIs uses the Go field name unchanged (e.g., "FieldName")
+	NamingAsIs NamingConvention = "as-is"
+	// NamingKebab converts field names to kebab-case (e.g., "field-name")
+	NamingKebab NamingConvention = "kebab"
+	// NamingSnake converts field names to snake_case (e.g., "field_name")
+	NamingSnake NamingConvention = "snake"
+	// NamingCamel converts field names to camelCase (e.g., "fieldName")
+	NamingCamel NamingConvention = "camel"
+)
+
+// Apply converts a Go field name according to the naming convention.
+// An empty or unknown convention leaves the name unchanged.
+func (c NamingConvention) Apply(name string) string {
+	switch c {
+	case NamingKebab:
+		return toKebabCase(name)
+	case NamingSnake:
+		return toSnakeCase(name)
+	case NamingCamel:
+		return toCamelCase(name)
+	default:
+		return name
+	}
+}
+
 type HTTPMarshalOptions struct {
 	// Prefix is prepended to all parameter names (e.g., "X" results in "X-Field-Name")
 	Prefix string
 	// IncludeStructName includes the struct type name in the parameter (e.g., "X-Example-Field-Name")
 	IncludeStructName bool
 	// DefaultKebabCase converts fieldSet names to kebab-case by default (e.g., "FieldName" becomes "field-name")
+	//
+	// Deprecated: use NamingConvention set to NamingKebab instead. DefaultKebabCase is
+	// only consulted when NamingConvention is empty.
 	DefaultKebabCase bool
+	// NamingConvention converts field names without an explicit tag (e.g., NamingSnake
+	// turns "FieldName" into "field_name"). When empty, DefaultKebabCase applies.
+	NamingConvention NamingConvention
+	// StrictScalarValues makes unmarshaling fail with ErrMultipleValues when a scalar
+	// (non-slice) field has more than one header or parameter occurrence.
+	//
+	// Deprecated: use ScalarValues set to ScalarValuesError instead. StrictScalarValues is
+	// only consulted when ScalarValues is empty.
+	StrictScalarValues bool
+	// ScalarValues controls which occurrence a scalar (non-slice) field is unmarshaled from
+	// when a header or query parameter occurs more than once, e.g. "?enabled=true&enabled=false".
+	// Headers and query parameters are treated the same way. When empty, ScalarValuesFirst
+	// applies, unless the deprecated StrictScalarValues is set.
+	ScalarValues ScalarValuePolicy
+}
+
+// ScalarValuePolicy controls how a scalar field is unmarshaled from repeated occurrences.
+type ScalarValuePolicy string
+
+const (
+	// ScalarValuesFirst uses the first occurrence and ignores the rest. This is the default.
+	ScalarValuesFirst ScalarValuePolicy = "first"
+	// ScalarValuesLast uses the last occurrence, matching clients that override earlier
+	// values by appending.
+	ScalarValuesLast ScalarValuePolicy = "last"
+	// ScalarValuesError fails with ErrMultipleValues. Silently picking one occurrence can
+	// hide parameter pollution or request smuggling attempts, and client bugs.
+	ScalarValuesError ScalarValuePolicy = "error"
+)
+
+// ErrMultipleValues is returned when unmarshaling with ScalarValuesError finds more than
+// one occurrence for a scalar field.
+var ErrMultipleValues = errors.New("multiple values for scalar field")
+
+// scalarValuePolicy returns the effective scalar value policy, honouring the deprecated
+// StrictScalarValues option when ScalarValues is unset
+func (o HTTPMarshalOptions) scalarValuePolicy() (ScalarValuePolicy, error) {
+	switch o.ScalarValues {
+	case ScalarValuesFirst, ScalarValuesLast, ScalarValuesError:
+		return o.ScalarValues, nil
+	case "":
+		if o.StrictScalarValues {
+			return ScalarValuesError, nil
+		}
+		return ScalarValuesFirst, nil
+	default:
+		return "", fmt.Errorf("unknown scalar value policy %q", o.ScalarValues)
+	}
+}
+
+// namingConvention returns the effective naming convention, honouring the deprecated
+// DefaultKebabCase option when NamingConvention is unset
+func (o HTTPMarshalOptions) namingConvention() NamingConvention {
+	if o.NamingConvention != "" {
+		return o.NamingConvention
+	}
+	if o.DefaultKebabCase {
+		return NamingKebab
+	}
+	return NamingAsIs
 }
 
 // DefaultHTTPMarshalOptions returns default options with no prefix and no struct name
@@ -59,6 +154,15 @@ func (d tagDetails) Skip() bool {
 	return d.skip
 }
 
+// Base64Encoding returns the encoding used for []byte fields. Values are URL-safe
+// base64 by default; the "base64std" modifier selects standard base64 instead.
+func (d tagDetails) Base64Encoding() *base64.Encoding {
+	if slices.Contains(d.modifiers, "base64std") {
+		return base64.StdEncoding
+	}
+	return base64.URLEncoding
+}
+
 func isNilAny(v any) bool {
 	if v == nil {
 		return true
@@ -97,7 +201,7 @@ func marshalFields(v any, tagName string, set fieldSet, opts HTTPMarshalOptions)
 	}
 
 	return walkStructFields(val, typ, tagName, opts, func(field reflect.Value, fieldType reflect.StructField, fieldName string) error {
-		if err := marshalField(set, fieldName, field); err != nil {
+		if err := marshalField(set, fieldName, field, getTagDetails(tagName, fieldType)); err != nil {
 			return fmt.Errorf("fieldSet %s: %w", fieldType.Name, err)
 		}
 		return nil
@@ -111,8 +215,13 @@ func unmarshalFields(set fieldSet, v any, tagName string, opts HTTPMarshalOption
 		return err
 	}
 
+	policy, err := opts.scalarValuePolicy()
+	if err != nil {
+		return err
+	}
+
 	return walkStructFields(val, typ, tagName, opts, func(field reflect.Value, fieldType reflect.StructField, fieldName string) error {
-		if err := unmarshalField(set, fieldName, field); err != nil {
+		if err := unmarshalField(set, fieldName, field, getTagDetails(tagName, fieldType), policy); err != nil {
 			return fmt.Errorf("fieldSet %s: %w", fieldType.Name, err)
 		}
 		return nil
@@ -192,10 +301,8 @@ func getFieldName(tagName string, field reflect.StructField, structName string,
 	if details.name != "" {
 		fieldName = details.name
 	} else {
-		// Convert fieldSet name to kebab-case if default is enabled
-		if opts.DefaultKebabCase {
-			fieldName = toKebabCase(fieldName)
-		}
+		// Convert fieldSet name using the configured naming convention
+		fieldName = opts.namingConvention().Apply(fieldName)
 	}
 
 	return buildFieldName(fieldName, structName, opts)
@@ -247,8 +354,33 @@ func toKebabCase(s string) string {
 	return result.String()
 }
 
+// toSnakeCase converts CamelCase to snake_case using the same word boundaries as toKebabCase
+// Examples: "FieldOne" -> "field_one", "UserID" -> "user_id", "HTTPHeader" -> "http_header"
+func toSnakeCase(s string) string {
+	return strings.ReplaceAll(toKebabCase(s), "-", "_")
+}
+
+// toCamelCase converts CamelCase to camelCase using the same word boundaries as toKebabCase
+// Examples: "FieldOne" -> "fieldOne", "UserID" -> "userId", "HTTPHeader" -> "httpHeader"
+func toCamelCase(s string) string {
+	words := strings.Split(toKebabCase(s), "-")
+
+	var result strings.Builder
+	for i, word := range words {
+		if i == 0 || word == "" {
+			result.WriteString(word)
+			continue
+		}
+		runes := []rune(word)
+		runes[0] = unicode.ToUpper(runes[0])
+		result.WriteString(string(runes))
+	}
+
+	return result.String()
+}
+
 // marshalField marshals a single field value to the fieldSet based on its type
-func marshalField(set fieldSet, fieldName string, field reflect.Value) error {
+func marshalField(set fieldSet, fieldName string, field reflect.Value, details tagDetails) error {
 	if fieldName == "" {
 		return nil // Skip fields with empty field names
 	}
@@ -257,6 +389,9 @@ func marshalField(set fieldSet, fieldName string, field reflect.Value) error {
 	case reflect.String:
 		return marshalStringField(set, fieldName, field)
 	case reflect.Slice:
+		if field.Type().Elem().Kind() == reflect.Uint8 {
+			return marshalBytesField(set, fieldName, field, details.Base64Encoding())
+		}
 		return marshalSliceField(set, fieldName, field)
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		return marshalIntField(set, fieldName, field)
@@ -294,6 +429,14 @@ func marshalSliceField(set fieldSet, fieldName string, field reflect.Value) erro
 	return nil
 }
 
+// marshalBytesField marshals a []byte field to the fieldSet as base64
+func marshalBytesField(set fieldSet, fieldName string, field reflect.Value, enc *base64.Encoding) error {
+	if field.Len() > 0 {
+		set.Set(fieldName, enc.EncodeToString(field.Bytes()))
+	}
+	return nil
+}
+
 // marshalIntField marshals an integer field to the fieldSet
 func marshalIntField(set fieldSet, fieldName string, field reflect.Value) error {
 	set.Set(fieldName, fmt.Sprintf("%d", field.Int()))
@@ -313,7 +456,7 @@ func marshalBoolField(set fieldSet, fieldName string, field reflect.Value) error
 }
 
 // unmarshalField unmarshals a field value into a fieldSet
-func unmarshalField(set fieldSet, fieldName string, field reflect.Value) error {
+func unmarshalField(set fieldSet, fieldName string, field reflect.Value, details tagDetails, policy ScalarValuePolicy) error {
 	if fieldName == "" {
 		return nil // Skip fields with empty filter names
 	}
@@ -327,10 +470,23 @@ func unmarshalField(set fieldSet, fieldName string, field reflect.Value) error {
 		return nil // No value in fieldSet, leave field as zero value
 	}
 
+	isScalar := field.Kind() != reflect.Slice || field.Type().Elem().Kind() == reflect.Uint8
+	if isScalar && len(values) > 1 {
+		switch policy {
+		case ScalarValuesError:
+			return fmt.Errorf("%s: %w (%d occurrences)", fieldName, ErrMultipleValues, len(values))
+		case ScalarValuesLast:
+			values = values[len(values)-1:]
+		}
+	}
+
 	switch field.Kind() {
 	case reflect.String:
 		return unmarshalStringField(field, values)
 	case reflect.Slice:
+		if field.Type().Elem().Kind() == reflect.Uint8 {
+			return unmarshalBytesField(field, values, details.Base64Encoding())
+		}
 		return unmarshalSliceField(field, values)
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		return unmarshalIntField(field, values)
@@ -364,6 +520,17 @@ func unmarshalSliceField(field reflect.Value, values []string) error {
 	return nil
 }
 
+// unmarshalBytesField unmarshals a base64 encoded []byte field from fieldSet values.
+// Padding is optional.
+func unmarshalBytesField(field reflect.Value, values []string, enc *base64.Encoding) error {
+	b, err := enc.WithPadding(base64.NoPadding).DecodeString(strings.TrimRight(values[0], "="))
+	if err != nil {
+		return fmt.Errorf("failed to decode base64: %w", err)
+	}
+	field.SetBytes(b)
+	return nil
+}
+
 // unmarshalIntField unmarshals an integer field from fieldSet values
 func unmarshalIntField(field reflect.Value, values []string) error {
 	var n int64
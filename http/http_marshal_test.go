@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestIsNilAny(t *testing.T) {
@@ -24,3 +25,140 @@ func TestIsNilAny(t *testing.T) {
 	slicePtr = &nonNilSlice
 	assert.False(t, isNilAny(slicePtr), "non-nil slice pointer should not be considered nil")
 }
+
+func TestNamingConventionApply(t *testing.T) {
+	tests := []struct {
+		convention NamingConvention
+		input      string
+		want       string
+	}{
+		{NamingAsIs, "UserID", "UserID"},
+		{"", "UserID", "UserID"},
+		{NamingKebab, "UserID", "user-id"},
+		{NamingKebab, "HTTPHeader", "http-header"},
+		{NamingSnake, "UserID", "user_id"},
+		{NamingSnake, "HTTPHeader", "http_header"},
+		{NamingSnake, "lowercase", "lowercase"},
+		{NamingCamel, "UserID", "userId"},
+		{NamingCamel, "HTTPHeader", "httpHeader"},
+		{NamingCamel, "FieldOne", "fieldOne"},
+		{NamingCamel, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.convention)+"/"+tt.input, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.convention.Apply(tt.input))
+		})
+	}
+}
+
+func TestNamingConventionRoundTrip(t *testing.T) {
+	type Params struct {
+		UserID   string
+		PageSize int
+		Tags     []string
+		Explicit string `query:"x" header:"X"`
+	}
+
+	in := Params{UserID: "u1", PageSize: 10, Tags: []string{"a", "b"}, Explicit: "e"}
+
+	tests := []struct {
+		convention NamingConvention
+		wantQuery  string
+	}{
+		{NamingAsIs, "PageSize=10&Tags=a&Tags=b&UserID=u1&x=e"},
+		{NamingKebab, "page-size=10&tags=a&tags=b&user-id=u1&x=e"},
+		{NamingSnake, "page_size=10&tags=a&tags=b&user_id=u1&x=e"},
+		{NamingCamel, "pageSize=10&tags=a&tags=b&userId=u1&x=e"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.convention), func(t *testing.T) {
+			opts := HTTPMarshalOptions{NamingConvention: tt.convention}
+
+			query, err := MarshalQuery(in, opts)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantQuery, query)
+
+			var fromQuery Params
+			assert.NoError(t, UnmarshalQuery(query, &fromQuery, opts))
+			assert.Equal(t, in, fromQuery)
+
+			header, err := MarshalHeader(in, opts)
+			assert.NoError(t, err)
+
+			var fromHeader Params
+			assert.NoError(t, UnmarshalHeader(header, &fromHeader, opts))
+			assert.Equal(t, in, fromHeader)
+		})
+	}
+}
+
+func TestNamingConventionOverridesDefaultKebabCase(t *testing.T) {
+	opts := HTTPMarshalOptions{DefaultKebabCase: true}
+	assert.Equal(t, NamingKebab, opts.namingConvention())
+
+	opts.NamingConvention = NamingSnake
+	assert.Equal(t, NamingSnake, opts.namingConvention())
+}
+
+type binaryExample struct {
+	Nonce     []byte `query:"nonce" header:"X-Nonce"`
+	Signature []byte `query:"sig,base64std" header:"X-Signature,base64std"`
+	Tags      []string
+}
+
+func TestBytesFieldRoundTrip(t *testing.T) {
+	tests := []struct {
+		name      string
+		nonce     []byte
+		signature []byte
+	}{
+		{name: "no padding", nonce: []byte{0xfb, 0xff, 0xfe}, signature: []byte{0xfb, 0xff, 0xfe}},
+		{name: "one padding char", nonce: []byte{0xfb, 0xff}, signature: []byte{0xfb, 0xff}},
+		{name: "two padding chars", nonce: []byte{0xfb}, signature: []byte{0xfb}},
+		{name: "empty", nonce: nil, signature: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := binaryExample{Nonce: tt.nonce, Signature: tt.signature, Tags: []string{"a"}}
+
+			values, err := MarshalQueryValues(in, DefaultHTTPMarshalOptions())
+			require.NoError(t, err)
+			if len(tt.nonce) > 0 {
+				assert.NotContains(t, values.Get("nonce"), "+")
+				assert.NotContains(t, values.Get("nonce"), "/")
+			}
+
+			var fromQuery binaryExample
+			require.NoError(t, UnmarshalQuery(values.Encode(), &fromQuery, DefaultHTTPMarshalOptions()))
+			assert.Equal(t, in, fromQuery)
+
+			header, err := MarshalHeader(in, DefaultHTTPMarshalOptions())
+			require.NoError(t, err)
+
+			var fromHeader binaryExample
+			require.NoError(t, UnmarshalHeader(header, &fromHeader, DefaultHTTPMarshalOptions()))
+			assert.Equal(t, in, fromHeader)
+		})
+	}
+}
+
+func TestBytesFieldEncoding(t *testing.T) {
+	in := binaryExample{Nonce: []byte{0xfb, 0xff}, Signature: []byte{0xfb, 0xff}}
+
+	values, err := MarshalQueryValues(in, DefaultHTTPMarshalOptions())
+	require.NoError(t, err)
+	assert.Equal(t, "-_8=", values.Get("nonce"))
+	assert.Equal(t, "+/8=", values.Get("sig"))
+
+	// Unpadded input is accepted
+	var out binaryExample
+	require.NoError(t, UnmarshalQuery("nonce=-_8", &out, DefaultHTTPMarshalOptions()))
+	assert.Equal(t, []byte{0xfb, 0xff}, out.Nonce)
+
+	// Standard alphabet is rejected for URL-safe fields
+	err = UnmarshalQuery("nonce=%2B%2F8%3D", &out, DefaultHTTPMarshalOptions())
+	assert.Error(t, err)
+}
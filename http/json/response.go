@@ -3,7 +3,13 @@ package json
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
 	"net/http"
+	"slices"
+	"strings"
 
 	"github.com/rs/zerolog"
 )
@@ -13,28 +19,92 @@ type Response struct {
 	Writer http.ResponseWriter
 	// Request http.Request
 	logger *zerolog.Logger
+
+	indentPrefix      string
+	indent            string
+	disableHTMLEscape bool
+	errorEnvelope     ErrorEnvelope
+}
+
+// ResponseOption configures how a Response encodes JSON.
+type ResponseOption func(*Response)
+
+// WithIndent pretty-prints responses, as json.Encoder.SetIndent does.
+func WithIndent(prefix, indent string) ResponseOption {
+	return func(r *Response) {
+		r.indentPrefix = prefix
+		r.indent = indent
+	}
+}
+
+// WithEscapeHTML controls whether &, < and > are escaped in JSON strings.
+// Escaping is enabled by default.
+func WithEscapeHTML(escape bool) ResponseOption {
+	return func(r *Response) {
+		r.disableHTMLEscape = !escape
+	}
+}
+
+// ErrorEnvelope builds the JSON body sent by the *WithMessage and *WithMessages error
+// helpers from the response message.
+type ErrorEnvelope func(message string) any
+
+// FlatErrorEnvelope returns an ErrorEnvelope that sends {field: message}, e.g.
+// {"message": "..."} for FlatErrorEnvelope("message").
+func FlatErrorEnvelope(field string) ErrorEnvelope {
+	return func(message string) any {
+		return map[string]string{field: message}
+	}
+}
+
+// NestedErrorEnvelope returns an ErrorEnvelope that sends {outer: {inner: message}}, e.g.
+// {"error": {"message": "..."}} for NestedErrorEnvelope("error", "message").
+func NestedErrorEnvelope(outer, inner string) ErrorEnvelope {
+	return func(message string) any {
+		return map[string]map[string]string{outer: {inner: message}}
+	}
+}
+
+// DefaultErrorEnvelope is the ErrorEnvelope used by responses created without
+// WithErrorEnvelope. It sends {"error": "..."}.
+var DefaultErrorEnvelope = FlatErrorEnvelope("error")
+
+// WithErrorEnvelope sets the shape of error response bodies. A nil envelope falls back
+// to DefaultErrorEnvelope.
+func WithErrorEnvelope(envelope ErrorEnvelope) ResponseOption {
+	return func(r *Response) {
+		r.errorEnvelope = envelope
+	}
 }
 
 // NewResponse creates a new Response helper with the provided ResponseWriter.
-func NewResponse(w http.ResponseWriter) *Response {
-	return &Response{
+func NewResponse(w http.ResponseWriter, opts ...ResponseOption) *Response {
+	r := &Response{
 		Writer: w,
 	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
 }
 
 // NewResponseWithLogger creates a new Response helper with a logger that includes request metadata.
-func NewResponseWithLogger(w http.ResponseWriter, r *http.Request, l zerolog.Logger) *Response {
+func NewResponseWithLogger(w http.ResponseWriter, r *http.Request, l zerolog.Logger, opts ...ResponseOption) *Response {
 	logger := l.With().
 		Str("method", r.Method).
 		Str("url", r.URL.Redacted()).
 		Str("remoteAddr", r.RemoteAddr).
 		Str("userAgent", r.UserAgent()).
 		Logger()
-	return &Response{
+	resp := &Response{
 		Writer: w,
 		// Request: r,
 		logger: &logger,
 	}
+	for _, opt := range opts {
+		opt(resp)
+	}
+	return resp
 }
 
 // BadRequestWithMessage sends a 400 Bad Request response with a JSON error message.
@@ -87,6 +157,16 @@ func (r *Response) UnauthorizedWithMessage(message string) {
 	r.UnauthorizedWithMessages(message, message)
 }
 
+// UnsupportedMediaTypeWithMessage sends a 415 Unsupported Media Type response.
+func (r *Response) UnsupportedMediaTypeWithMessage(message string) {
+	r.UnsupportedMediaTypeWithMessages(message, message)
+}
+
+// UnsupportedMediaTypeWithMessages sends a 415 Unsupported Media Type response and logs a separate message.
+func (r *Response) UnsupportedMediaTypeWithMessages(responseMessage, logMessage string) {
+	r.ErrorWithMessages(http.StatusUnsupportedMediaType, responseMessage, logMessage, nil)
+}
+
 // ConflictWithMessage sends a 409 Conflict response.
 func (r *Response) ConflictWithMessage(message string) {
 	r.ConflictWithMessages(message, message)
@@ -99,9 +179,12 @@ func (r *Response) ConflictWithMessages(responseMessage, logMessage string) {
 
 // ErrorWithMessages sends an error response with the specified status code and messages.
 func (r *Response) ErrorWithMessages(code int, responseMessage string, logMessage string, err error) {
-	data := map[string]string{"error": responseMessage}
+	envelope := r.errorEnvelope
+	if envelope == nil {
+		envelope = DefaultErrorEnvelope
+	}
 	r.logError(err, logMessage)
-	r.Data(code, data)
+	r.Data(code, envelope(responseMessage))
 }
 
 func (r *Response) logError(err error, message string) {
@@ -114,6 +197,8 @@ func (r *Response) logError(err error, message string) {
 func (r *Response) Data(status int, data any) {
 	r.Writer.Header().Set("Content-Type", "application/json; charset=utf-8") // normal header
 	encoder := json.NewEncoder(r.Writer)
+	encoder.SetIndent(r.indentPrefix, r.indent)
+	encoder.SetEscapeHTML(!r.disableHTMLEscape)
 	r.Writer.WriteHeader(status)
 
 	if data != nil {
@@ -178,11 +263,77 @@ func (r *Response) AcceptedWithMessage(message string) {
 	r.Data(http.StatusAccepted, map[string]string{"message": message})
 }
 
+// ErrUnsupportedMediaType is returned by ReadBody when the request's Content-Type is not
+// accepted. Handlers can respond with UnsupportedMediaTypeWithMessage.
+var ErrUnsupportedMediaType = errors.New("unsupported media type")
+
+// DefaultAcceptedContentType is the request content type accepted by ReadBody unless
+// replaced with WithAcceptedContentTypes.
+const DefaultAcceptedContentType = "application/json"
+
+// DefaultAcceptedContentTypes are the request content types accepted by ReadBody by default.
+//
+// Deprecated: ReadBody no longer reads this variable, and changing it has no effect. Use
+// DefaultAcceptedContentType, and WithAcceptedContentTypes to accept other types.
+var DefaultAcceptedContentTypes = []string{DefaultAcceptedContentType}
+
+// ReadBodyOption configures ReadBody.
+type ReadBodyOption func(*readBodyOptions)
+
+type readBodyOptions struct {
+	acceptedContentTypes []string
+	maxBodyBytes         int64
+}
+
+// WithAcceptedContentTypes replaces the media types ReadBody accepts, e.g.
+// "application/merge-patch+json". Matching ignores case and parameters such as charset.
+func WithAcceptedContentTypes(contentTypes ...string) ReadBodyOption {
+	return func(o *readBodyOptions) {
+		o.acceptedContentTypes = contentTypes
+	}
+}
+
+// WithMaxBodyBytes limits how many bytes of the request body are read. Reading past the
+// limit fails with an *http.MaxBytesError. A limit of zero or less disables the cap.
+func WithMaxBodyBytes(n int64) ReadBodyOption {
+	return func(o *readBodyOptions) {
+		o.maxBodyBytes = n
+	}
+}
+
+func (o readBodyOptions) body(req *http.Request) io.ReadCloser {
+	if o.maxBodyBytes > 0 {
+		return http.MaxBytesReader(nil, req.Body, o.maxBodyBytes)
+	}
+	return req.Body
+}
+
 // ReadBody reads and decodes the JSON request body into the specified type.
 // It automatically closes the request body.
-func ReadBody[T any](req *http.Request) (T, error) {
+//
+// The request's Content-Type must be one of the accepted media types (by default
+// DefaultAcceptedContentType); otherwise, including when it is missing, an error wrapping
+// ErrUnsupportedMediaType is returned without decoding the body:
+//
+//	body, err := ReadBody[Item](r)
+//	if errors.Is(err, ErrUnsupportedMediaType) {
+//		NewResponse(w).UnsupportedMediaTypeWithMessage(err.Error())
+//		return
+//	}
+func ReadBody[T any](req *http.Request, opts ...ReadBodyOption) (T, error) {
 	var t T
-	decoder := json.NewDecoder(req.Body)
+
+	o := readBodyOptions{acceptedContentTypes: []string{DefaultAcceptedContentType}}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if err := checkContentType(req.Header.Get("Content-Type"), o.acceptedContentTypes); err != nil {
+		_ = req.Body.Close()
+		return t, err
+	}
+
+	decoder := json.NewDecoder(o.body(req))
 	err := decoder.Decode(&t)
 	if err != nil {
 		_ = req.Body.Close()
@@ -190,3 +341,80 @@ func ReadBody[T any](req *http.Request) (T, error) {
 	}
 	return t, req.Body.Close()
 }
+
+// DefaultMaxStreamBodyBytes is the request body cap applied by DecodeStream unless
+// overridden with WithMaxBodyBytes (32MB).
+const DefaultMaxStreamBodyBytes = 32 * 1024 * 1024
+
+// DecodeStream decodes a JSON array request body one element at a time, calling fn for
+// each element in order, so that large bulk uploads can be processed without holding
+// the whole body in memory. It automatically closes the request body.
+//
+// Decoding stops at the first error, which is returned: an error from fn, a malformed
+// body (including a body that is not a JSON array), the body exceeding the size cap
+// (DefaultMaxStreamBodyBytes unless set with WithMaxBodyBytes), or cancellation of the
+// request's context. Content-Type is checked as for ReadBody.
+func DecodeStream[T any](req *http.Request, fn func(T) error, opts ...ReadBodyOption) error {
+	o := readBodyOptions{
+		acceptedContentTypes: []string{DefaultAcceptedContentType},
+		maxBodyBytes:         DefaultMaxStreamBodyBytes,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	body := o.body(req)
+	defer func() { _ = body.Close() }()
+
+	if err := checkContentType(req.Header.Get("Content-Type"), o.acceptedContentTypes); err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(body)
+	tok, err := decoder.Token()
+	if err != nil {
+		return fmt.Errorf("error reading start of array: %w", err)
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return fmt.Errorf("expected JSON array, got %v", tok)
+	}
+
+	ctx := req.Context()
+	for i := 0; decoder.More(); i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var t T
+		if err := decoder.Decode(&t); err != nil {
+			return fmt.Errorf("error decoding element %d: %w", i, err)
+		}
+		if err := fn(t); err != nil {
+			return err
+		}
+	}
+
+	if _, err := decoder.Token(); err != nil {
+		return fmt.Errorf("error reading end of array: %w", err)
+	}
+	return nil
+}
+
+// checkContentType returns an error wrapping ErrUnsupportedMediaType unless contentType's
+// media type is one of accepted.
+func checkContentType(contentType string, accepted []string) error {
+	if contentType == "" {
+		return fmt.Errorf("%w: missing Content-Type, expected one of %s", ErrUnsupportedMediaType, strings.Join(accepted, ", "))
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return fmt.Errorf("%w: invalid Content-Type %q: %v", ErrUnsupportedMediaType, contentType, err)
+	}
+
+	if !slices.ContainsFunc(accepted, func(a string) bool { return strings.EqualFold(a, mediaType) }) {
+		return fmt.Errorf("%w: %q, expected one of %s", ErrUnsupportedMediaType, mediaType, strings.Join(accepted, ", "))
+	}
+
+	return nil
+}
@@ -2,6 +2,7 @@ package json
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"io"
@@ -392,6 +393,7 @@ func TestReadBody_ValidJSON(t *testing.T) {
 
 	jsonData := `{"name":"test","value":123}`
 	req := httptest.NewRequest("POST", "/test", bytes.NewBufferString(jsonData))
+	req.Header.Set("Content-Type", "application/json")
 
 	result, err := ReadBody[TestStruct](req)
 
@@ -414,6 +416,7 @@ func TestReadBody_InvalidJSON(t *testing.T) {
 
 	invalidJSON := `{"name": "test"`
 	req := httptest.NewRequest("POST", "/test", bytes.NewBufferString(invalidJSON))
+	req.Header.Set("Content-Type", "application/json")
 
 	_, err := ReadBody[TestStruct](req)
 
@@ -428,6 +431,7 @@ func TestReadBody_EmptyBody(t *testing.T) {
 	}
 
 	req := httptest.NewRequest("POST", "/test", bytes.NewBufferString(""))
+	req.Header.Set("Content-Type", "application/json")
 
 	_, err := ReadBody[TestStruct](req)
 
@@ -436,6 +440,164 @@ func TestReadBody_EmptyBody(t *testing.T) {
 	}
 }
 
+func TestReadBody_ContentType(t *testing.T) {
+	type TestStruct struct {
+		Name string `json:"name"`
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		opts        []ReadBodyOption
+		wantErr     error
+	}{
+		{name: "json", contentType: "application/json"},
+		{name: "json with charset", contentType: "Application/JSON; charset=utf-8"},
+		{name: "missing", contentType: "", wantErr: ErrUnsupportedMediaType},
+		{name: "form", contentType: "application/x-www-form-urlencoded", wantErr: ErrUnsupportedMediaType},
+		{name: "xml", contentType: "application/xml", wantErr: ErrUnsupportedMediaType},
+		{name: "malformed", contentType: "application/json; =", wantErr: ErrUnsupportedMediaType},
+		{
+			name:        "custom accepted type",
+			contentType: "application/merge-patch+json",
+			opts:        []ReadBodyOption{WithAcceptedContentTypes("application/merge-patch+json")},
+		},
+		{
+			name:        "json not in custom accepted types",
+			contentType: "application/json",
+			opts:        []ReadBodyOption{WithAcceptedContentTypes("application/merge-patch+json")},
+			wantErr:     ErrUnsupportedMediaType,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/test", bytes.NewBufferString(`{"name":"test"}`))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+
+			result, err := ReadBody[TestStruct](req, tt.opts...)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Expected error %v, got: %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if result.Name != "test" {
+				t.Errorf("Expected name %q, got %q", "test", result.Name)
+			}
+		})
+	}
+}
+
+func TestDecodeStream(t *testing.T) {
+	type Item struct {
+		ID int `json:"id"`
+	}
+
+	errStop := errors.New("stop")
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name        string
+		body        string
+		contentType string
+		ctx         context.Context
+		opts        []ReadBodyOption
+		stopAt      int
+		wantIDs     []int
+		wantErr     error
+		wantAnyErr  bool
+	}{
+		{name: "elements in order", body: `[{"id":1},{"id":2},{"id":3}]`, wantIDs: []int{1, 2, 3}},
+		{name: "empty array", body: ` [ ] `},
+		{name: "callback error stops decoding", body: `[{"id":1},{"id":2},{"id":3}]`, stopAt: 2, wantIDs: []int{1, 2}, wantErr: errStop},
+		{name: "not an array", body: `{"id":1}`, wantAnyErr: true},
+		{name: "malformed element", body: `[{"id":1},{"id":`, wantIDs: []int{1}, wantAnyErr: true},
+		{name: "unterminated array", body: `[{"id":1}`, wantIDs: []int{1}, wantAnyErr: true},
+		{name: "wrong content type", body: `[]`, contentType: "text/plain", wantErr: ErrUnsupportedMediaType},
+		{name: "cancelled context", body: `[{"id":1}]`, ctx: cancelled, wantErr: context.Canceled},
+		{
+			name:       "body over cap",
+			body:       `[{"id":1},{"id":2},{"id":3}]`,
+			opts:       []ReadBodyOption{WithMaxBodyBytes(12)},
+			wantIDs:    []int{1},
+			wantAnyErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/test", strings.NewReader(tt.body))
+			if tt.ctx != nil {
+				req = req.WithContext(tt.ctx)
+			}
+			contentType := tt.contentType
+			if contentType == "" {
+				contentType = "application/json"
+			}
+			req.Header.Set("Content-Type", contentType)
+
+			var ids []int
+			err := DecodeStream(req, func(item Item) error {
+				ids = append(ids, item.ID)
+				if tt.stopAt > 0 && len(ids) == tt.stopAt {
+					return errStop
+				}
+				return nil
+			}, tt.opts...)
+
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Expected error %v, got: %v", tt.wantErr, err)
+				}
+			case tt.wantAnyErr:
+				if err == nil {
+					t.Fatal("Expected error, got nil")
+				}
+			case err != nil:
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+
+			if len(ids) != len(tt.wantIDs) {
+				t.Fatalf("Expected ids %v, got %v", tt.wantIDs, ids)
+			}
+			for i := range ids {
+				if ids[i] != tt.wantIDs[i] {
+					t.Errorf("Expected ids %v, got %v", tt.wantIDs, ids)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeStream_MaxBytesError(t *testing.T) {
+	req := httptest.NewRequest("POST", "/test", strings.NewReader(`[1,2,3,4,5,6,7,8,9]`))
+	req.Header.Set("Content-Type", "application/json")
+
+	err := DecodeStream(req, func(int) error { return nil }, WithMaxBodyBytes(4))
+
+	var maxBytesErr *http.MaxBytesError
+	if !errors.As(err, &maxBytesErr) {
+		t.Fatalf("Expected *http.MaxBytesError, got: %v", err)
+	}
+}
+
+func TestResponse_UnsupportedMediaType(t *testing.T) {
+	w := httptest.NewRecorder()
+	NewResponse(w).UnsupportedMediaTypeWithMessage("unsupported media type")
+
+	if w.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("Expected status code %d, got %d", http.StatusUnsupportedMediaType, w.Code)
+	}
+}
+
 func TestResponseWithLogger_ErrorLogging(t *testing.T) {
 	var logOutput bytes.Buffer
 	logger := zerolog.New(&logOutput)
@@ -621,3 +783,127 @@ func TestNotAcceptableWithMessages(t *testing.T) {
 		t.Errorf("Expected error message %q, got %q", "client not acceptable", result["error"])
 	}
 }
+
+func TestResponseEncoderOptions(t *testing.T) {
+	data := map[string]string{"url": "https://example.com/?a=1&b=<2>"}
+
+	tests := []struct {
+		name string
+		opts []ResponseOption
+		want string
+	}{
+		{
+			name: "defaults",
+			want: `{"url":"https://example.com/?a=1\u0026b=\u003c2\u003e"}` + "\n",
+		},
+		{
+			name: "indent",
+			opts: []ResponseOption{WithIndent("", "  ")},
+			want: "{\n  \"url\": \"https://example.com/?a=1\\u0026b=\\u003c2\\u003e\"\n}\n",
+		},
+		{
+			name: "no html escape",
+			opts: []ResponseOption{WithEscapeHTML(false)},
+			want: `{"url":"https://example.com/?a=1&b=<2>"}` + "\n",
+		},
+		{
+			name: "indent and no html escape",
+			opts: []ResponseOption{WithIndent("", "\t"), WithEscapeHTML(false)},
+			want: "{\n\t\"url\": \"https://example.com/?a=1&b=<2>\"\n}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			NewResponse(w, tt.opts...).OK(data)
+
+			if got := w.Body.String(); got != tt.want {
+				t.Errorf("Expected body %q, got %q", tt.want, got)
+			}
+		})
+	}
+
+	t.Run("with logger", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/test", nil)
+		NewResponseWithLogger(w, req, zerolog.Nop(), WithEscapeHTML(false)).OK(data)
+
+		want := `{"url":"https://example.com/?a=1&b=<2>"}` + "\n"
+		if got := w.Body.String(); got != want {
+			t.Errorf("Expected body %q, got %q", want, got)
+		}
+	})
+}
+
+func TestErrorEnvelope(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []ResponseOption
+		want string
+	}{
+		{
+			name: "default",
+			want: `{"error":"not found"}` + "\n",
+		},
+		{
+			name: "nil envelope",
+			opts: []ResponseOption{WithErrorEnvelope(nil)},
+			want: `{"error":"not found"}` + "\n",
+		},
+		{
+			name: "flat message",
+			opts: []ResponseOption{WithErrorEnvelope(FlatErrorEnvelope("message"))},
+			want: `{"message":"not found"}` + "\n",
+		},
+		{
+			name: "nested",
+			opts: []ResponseOption{WithErrorEnvelope(NestedErrorEnvelope("error", "message"))},
+			want: `{"error":{"message":"not found"}}` + "\n",
+		},
+		{
+			name: "custom",
+			opts: []ResponseOption{WithErrorEnvelope(func(message string) any {
+				return map[string]any{"ok": false, "detail": message}
+			})},
+			want: `{"detail":"not found","ok":false}` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			helpers := map[string]func(*Response){
+				"NotFoundWithMessage":     func(r *Response) { r.NotFoundWithMessage("not found") },
+				"BadRequestWithMessages":  func(r *Response) { r.BadRequestWithMessages("not found", "log") },
+				"InvalidInputWithMessage": func(r *Response) { r.InvalidInputWithMessage(errors.New("e"), "not found") },
+				"InternalServerErrorWithMessages": func(r *Response) {
+					r.InternalServerErrorWithMessages(errors.New("e"), "not found", "log")
+				},
+				"ErrorWithMessages": func(r *Response) { r.ErrorWithMessages(http.StatusTeapot, "not found", "log", nil) },
+			}
+			for helper, fn := range helpers {
+				w := httptest.NewRecorder()
+				fn(NewResponse(w, tt.opts...))
+
+				if got := w.Body.String(); got != tt.want {
+					t.Errorf("%s: expected body %q, got %q", helper, tt.want, got)
+				}
+			}
+		})
+	}
+
+	t.Run("package default", func(t *testing.T) {
+		orig := DefaultErrorEnvelope
+		t.Cleanup(func() { DefaultErrorEnvelope = orig })
+		DefaultErrorEnvelope = FlatErrorEnvelope("message")
+
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/test", nil)
+		NewResponseWithLogger(w, req, zerolog.Nop()).ForbiddenWithMessage("denied")
+
+		want := `{"message":"denied"}` + "\n"
+		if got := w.Body.String(); got != want {
+			t.Errorf("Expected body %q, got %q", want, got)
+		}
+	})
+}
@@ -33,8 +33,8 @@ func headerToSnakeCase(s string) string {
 }
 
 // StandardLogger creates a zerolog.Logger with standard fields for HTTP access logging.
-// The "ip" field is resolved from X-Forwarded-For or X-Real-IP headers when present,
-// falling back to RemoteAddr. Raw proxy headers and RemoteAddr are also included when set.
+// The "ip" field is the client IP resolved by WithTrustConfig if configured, otherwise
+// it is taken from X-Forwarded-For or X-Real-IP headers when present, falling back to RemoteAddr. Raw proxy headers and RemoteAddr are also included when set.
 func StandardLogger(r *http.Request, status, size int, duration time.Duration) *zerolog.Logger {
 	ctx := hlog.FromRequest(r).With().
 		Str("method", r.Method).
@@ -44,11 +44,15 @@ func StandardLogger(r *http.Request, status, size int, duration time.Duration) *
 		Dur("duration", duration).
 		Str("user_agent", r.UserAgent()).
 		Str("referer", r.Referer()).
-		Str("resolved_client_ip", GetClientIP(r)).
+		Str("resolved_client_ip", resolvedClientIP(r)).
 		Str("remote_addr", r.RemoteAddr).
 		Str("proto", r.Proto).
 		Str("host", r.Host)
 
+	if route := RouteTemplateFromContext(r.Context()); route != "" {
+		ctx = ctx.Str("route", route)
+	}
+
 	for _, h := range []string{"X-Forwarded-For", "X-Forwarded-Host", "X-Forwarded-Proto", "Forwarded", "Via", "X-Real-IP"} {
 		if v := r.Header.Get(h); v != "" {
 			ctx = ctx.Str(headerToSnakeCase(h), v)
@@ -30,6 +30,14 @@ var rateLimitRequests = promauto.NewCounterVec(
 	[]string{"result"},
 )
 
+var rateLimitRequestsByLabel = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "dioad_net_http_rate_limit_labelled_requests_total",
+		Help: "Count of requests evaluated by rate limiter, by metrics label",
+	},
+	[]string{"result", "label"},
+)
+
 type MetricSet struct {
 	RequestCounter    *prometheus.CounterVec
 	RequestDuration   *prometheus.HistogramVec
@@ -37,7 +45,10 @@ type MetricSet struct {
 	ResponseSize      *prometheus.HistogramVec
 	InFlightGauge     prometheus.Gauge
 	RateLimitRequests *prometheus.CounterVec
-	registry          *prometheus.Registry
+	// RateLimitRequestsByLabel is only incremented by rate limiters configured
+	// with WithMetricsLabelFunc or WithPrincipalMetricsLabel.
+	RateLimitRequestsByLabel *prometheus.CounterVec
+	registry                 *prometheus.Registry
 }
 
 func NewMetricSet(r *prometheus.Registry) *MetricSet {
@@ -80,7 +91,8 @@ func NewMetricSet(r *prometheus.Registry) *MetricSet {
 				Help: "Gauge of requests currently being served by the wrapped handler.",
 			},
 		),
-		RateLimitRequests: rateLimitRequests,
+		RateLimitRequests:        rateLimitRequests,
+		RateLimitRequestsByLabel: rateLimitRequestsByLabel,
 	}
 
 	return m
@@ -94,9 +106,11 @@ func (m *MetricSet) Register(r prometheus.Registerer) {
 		m.RequestSize,
 		m.InFlightGauge,
 	)
-	if err := r.Register(m.RateLimitRequests); err != nil {
-		if _, ok := err.(prometheus.AlreadyRegisteredError); !ok {
-			panic(err)
+	for _, c := range []prometheus.Collector{m.RateLimitRequests, m.RateLimitRequestsByLabel} {
+		if err := r.Register(c); err != nil {
+			if _, ok := err.(prometheus.AlreadyRegisteredError); !ok {
+				panic(err)
+			}
 		}
 	}
 }
@@ -107,14 +121,12 @@ func (m *MetricSet) Register(r prometheus.Registerer) {
 // result from using raw URL paths.
 func (m *MetricSet) Middleware(mux *http.ServeMux, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Use mux.Handler to derive the matched pattern before the mux routes
-		// the request. This avoids high-cardinality label values that would occur
+		// Use the route template (derived via mux.Handler before the mux routes
+		// the request). This avoids high-cardinality label values that would occur
 		// if we fell back to r.URL.Path (r.Pattern is empty outside the mux).
 		route := r.URL.Path
-		if mux != nil {
-			if _, pattern := mux.Handler(r); pattern != "" {
-				route = pattern
-			}
+		if pattern := routeTemplate(mux, r); pattern != "" {
+			route = pattern
 		}
 
 		labels := prometheus.Labels{
@@ -0,0 +1,82 @@
+// Package oidctest provides test doubles for token validation so that handlers
+// depending on OIDC validation can be exercised without a live identity provider.
+package oidctest
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var (
+	// ErrNoToken is returned by StaticValidator when it is asked to validate an empty token.
+	ErrNoToken = errors.New("no token provided")
+	// ErrInvalidToken is returned by StaticValidator when a token is not in its Tokens map.
+	ErrInvalidToken = errors.New("invalid token")
+)
+
+// StaticValidator is a token validator that returns preset claims or a preset error.
+// It satisfies the TokenValidator interface used by the http package.
+//
+// If Tokens is non-empty only the listed tokens are accepted and each maps to its own
+// claims; any other token is rejected with Err (or ErrInvalidToken if Err is nil).
+// Otherwise every non-empty token yields Claims, or Err if it is set.
+type StaticValidator struct {
+	// Name is returned by String and identifies the validator in error messages.
+	Name string
+	// Claims are returned for any token when Tokens is empty.
+	Claims any
+	// Tokens optionally maps specific token strings to the claims they produce.
+	Tokens map[string]any
+	// Err, if set, is returned instead of claims.
+	Err error
+
+	mu     sync.Mutex
+	tokens []string
+}
+
+// ValidateToken returns the preset claims or error for the given token and records the call.
+func (v *StaticValidator) ValidateToken(_ context.Context, token string) (any, error) {
+	v.mu.Lock()
+	v.tokens = append(v.tokens, token)
+	v.mu.Unlock()
+
+	if token == "" {
+		return nil, ErrNoToken
+	}
+
+	if len(v.Tokens) > 0 {
+		claims, ok := v.Tokens[token]
+		if !ok {
+			if v.Err != nil {
+				return nil, v.Err
+			}
+			return nil, ErrInvalidToken
+		}
+		return claims, nil
+	}
+
+	if v.Err != nil {
+		return nil, v.Err
+	}
+
+	return v.Claims, nil
+}
+
+// String returns the name of the validator.
+func (v *StaticValidator) String() string {
+	if v.Name == "" {
+		return "oidctest.StaticValidator"
+	}
+	return v.Name
+}
+
+// ValidatedTokens returns a copy of the tokens passed to ValidateToken, in call order.
+func (v *StaticValidator) ValidatedTokens() []string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	result := make([]string, len(v.tokens))
+	copy(result, v.tokens)
+	return result
+}
@@ -42,19 +42,40 @@ func (f *urlValuesWrapper) Values(key string) []string {
 // params := QueryParams{Search: "example", Tags: []string{"go", "http"}}
 // queryString, err := MarshalQuery(params, opts)
 func MarshalQuery(v any, opts HTTPMarshalOptions) (string, error) {
+	values, err := MarshalQueryValues(v, opts)
+	if err != nil {
+		return "", err
+	}
+
+	return values.Encode(), nil
+}
+
+// MarshalQueryValues encodes a struct into url.Values using the provided options.
+// Unlike MarshalQuery the result is not encoded, so callers can merge it into an
+// existing query or add further parameters before encoding.
+//
+// Example usage:
+//
+//	q := req.URL.Query()
+//	values, err := MarshalQueryValues(params, opts)
+//	for k, vs := range values {
+//	    q[k] = vs
+//	}
+//	req.URL.RawQuery = q.Encode()
+func MarshalQueryValues(v any, opts HTTPMarshalOptions) (url.Values, error) {
 	values := url.Values{}
 	if isNilAny(v) {
-		return "", nil
+		return values, nil
 	}
 
 	valueWrapper := &urlValuesWrapper{values: values}
 
 	err := marshalFields(v, QueryMarshalTagName, valueWrapper, opts)
 	if err != nil {
-		return "", fmt.Errorf("marshal query: %w", err)
+		return nil, fmt.Errorf("marshal query: %w", err)
 	}
 
-	return values.Encode(), nil
+	return values, nil
 }
 
 // UnmarshalQuery decodes a URI query string into a struct using the provided options.
@@ -80,6 +80,29 @@ func TestMarshalQueryNoPrefix(t *testing.T) {
 	assert.Equal(t, values.Get("FieldTwo"), "value2")
 }
 
+// TestMarshalQueryValues tests encoding into url.Values for composition with other parameters
+func TestMarshalQueryValues(t *testing.T) {
+	example := Example{
+		FieldOne: "value1",
+		FieldTwo: []string{"value2", "value3"},
+	}
+
+	values, err := MarshalQueryValues(example, DefaultHTTPMarshalOptions())
+	assert.NoError(t, err)
+
+	assert.Equal(t, "value1", values.Get("FieldOne"))
+	assert.Equal(t, []string{"value2", "value3"}, values["FieldTwo"])
+
+	values.Add("page", "2")
+	query, err := MarshalQuery(example, DefaultHTTPMarshalOptions())
+	assert.NoError(t, err)
+	assert.Equal(t, query+"&page=2", values.Encode())
+
+	empty, err := MarshalQueryValues(nil, DefaultHTTPMarshalOptions())
+	assert.NoError(t, err)
+	assert.Empty(t, empty)
+}
+
 func TestUnmarshalQuery(t *testing.T) {
 	var example Example
 
@@ -474,3 +497,67 @@ func TestQueryOrderPreservation(t *testing.T) {
 
 	assert.Equal(t, os.Ordered, result.Ordered, "Order of values not preserved")
 }
+
+func TestUnmarshalQueryScalarValuePolicy(t *testing.T) {
+	type Params struct {
+		Enabled bool     `query:"enabled"`
+		Limit   int      `query:"limit"`
+		Name    string   `query:"name"`
+		Tags    []string `query:"tag"`
+	}
+
+	const rawQuery = "enabled=true&enabled=false&limit=10&limit=20&name=a&tag=x&tag=y"
+
+	tests := []struct {
+		name    string
+		opts    HTTPMarshalOptions
+		want    Params
+		wantErr error
+	}{
+		{
+			name: "default first",
+			want: Params{Enabled: true, Limit: 10, Name: "a", Tags: []string{"x", "y"}},
+		},
+		{
+			name: "first",
+			opts: HTTPMarshalOptions{ScalarValues: ScalarValuesFirst},
+			want: Params{Enabled: true, Limit: 10, Name: "a", Tags: []string{"x", "y"}},
+		},
+		{
+			name: "last",
+			opts: HTTPMarshalOptions{ScalarValues: ScalarValuesLast},
+			want: Params{Enabled: false, Limit: 20, Name: "a", Tags: []string{"x", "y"}},
+		},
+		{
+			name:    "error",
+			opts:    HTTPMarshalOptions{ScalarValues: ScalarValuesError},
+			wantErr: ErrMultipleValues,
+		},
+		{
+			name:    "deprecated strict",
+			opts:    HTTPMarshalOptions{StrictScalarValues: true},
+			wantErr: ErrMultipleValues,
+		},
+		{
+			name: "policy overrides deprecated strict",
+			opts: HTTPMarshalOptions{StrictScalarValues: true, ScalarValues: ScalarValuesLast},
+			want: Params{Enabled: false, Limit: 20, Name: "a", Tags: []string{"x", "y"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Params
+			err := UnmarshalQuery(rawQuery, &got, tt.opts)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	var got Params
+	assert.Error(t, UnmarshalQuery(rawQuery, &got, HTTPMarshalOptions{ScalarValues: "random"}))
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"math"
 	"net/http"
+	"strconv"
 
 	"github.com/rs/zerolog"
 
@@ -18,6 +19,10 @@ var (
 // PrincipalFunc defines a function type that extracts a principal identifier from an HTTP request for rate limiting purposes.
 type PrincipalFunc func(*http.Request) (string, error)
 
+// MetricsLabelFunc derives the "label" value recorded against rate limit decisions for a principal.
+// The set of values it returns must be small and bounded; every distinct value creates new Prometheus series.
+type MetricsLabelFunc func(principal string) string
+
 // RateLimiter provides per-principal rate limiting for HTTP requests.
 type RateLimiter struct {
 	limiter           *ratelimit.RateLimiter
@@ -25,7 +30,12 @@ type RateLimiter struct {
 	source            ratelimit.RateLimitSource
 	requestsPerSecond float64
 	burst             int
-	logger            zerolog.Logger
+	maxEntries        int
+	metricsLabel      MetricsLabelFunc
+	// principalMetricsLabel is set by WithPrincipalMetricsLabel so a warning can be logged once configured
+	principalMetricsLabel bool
+	auditOnly             bool
+	logger                zerolog.Logger
 }
 
 // WithPrincipalFunc allows configuring the function used to extract the principal from incoming HTTP requests.
@@ -59,11 +69,62 @@ func WithRateLimitLogger(logger zerolog.Logger) func(*RateLimiter) {
 	}
 }
 
+// WithRateLimitAuditOnly lets requests over the limit through instead of rejecting them,
+// logging each one and recording it with result "would_block" in the rate limit metrics.
+// Use it to validate new limits against real traffic before enforcing them.
+func WithRateLimitAuditOnly() func(*RateLimiter) {
+	return func(rl *RateLimiter) {
+		rl.auditOnly = true
+	}
+}
+
+// WithMaxEntries bounds the number of principals tracked by the rate limiter, evicting the
+// least recently used principals once the bound is exceeded. See ratelimit.RateLimiter.MaxEntries.
+func WithMaxEntries(maxEntries int) func(*RateLimiter) {
+	return func(rl *RateLimiter) {
+		rl.maxEntries = maxEntries
+	}
+}
+
+// WithMetricsLabelFunc records allowed and blocked counts in the
+// dioad_net_http_rate_limit_labelled_requests_total counter under the label returned by labelFunc,
+// e.g. the tier returned by TierMetricsLabelFunc. labelFunc must return a bounded set of values.
+func WithMetricsLabelFunc(labelFunc MetricsLabelFunc) func(*RateLimiter) {
+	return func(rl *RateLimiter) {
+		rl.metricsLabel = labelFunc
+	}
+}
+
+// WithPrincipalMetricsLabel labels rate limit metrics with the raw principal.
+//
+// Warning: this creates a Prometheus series per principal seen, which is unbounded for
+// principals such as client IPs. Only use it when the set of principals is known to be small.
+func WithPrincipalMetricsLabel() func(*RateLimiter) {
+	return func(rl *RateLimiter) {
+		rl.metricsLabel = func(principal string) string { return principal }
+		rl.principalMetricsLabel = true
+	}
+}
+
+// TierMetricsLabelFunc returns a MetricsLabelFunc that labels principals by the limit source
+// assigns them, e.g. "rps=100,burst=100", or "default" when the source has no limit for the principal.
+// Cardinality is bounded by the number of distinct limits the source returns.
+func TierMetricsLabelFunc(source ratelimit.RateLimitSource) MetricsLabelFunc {
+	return func(principal string) string {
+		requestsPerSecond, burst, ok := source.GetLimit(principal)
+		if !ok {
+			return "default"
+		}
+		return fmt.Sprintf("rps=%s,burst=%d", strconv.FormatFloat(requestsPerSecond, 'g', -1, 64), burst)
+	}
+}
+
 type RateLimiterOption func(*RateLimiter)
 
 // ClientIPPrincipalFunc is a default PrincipalFunc that extracts the client's IP address from the request for rate limiting purposes.
+// It uses the client IP resolved by WithTrustConfig or ClientIPMiddleware when present.
 func ClientIPPrincipalFunc(r *http.Request) (string, error) {
-	return GetClientIP(r), nil
+	return resolvedClientIP(r), nil
 }
 
 func StaticPrincipalFunc(principal string) PrincipalFunc {
@@ -87,11 +148,16 @@ func NewRateLimiter(opts ...RateLimiterOption) *RateLimiter {
 		opt(r)
 	}
 
+	if r.principalMetricsLabel {
+		r.logger.Warn().Msg("rate limit metrics are labelled by principal, metric cardinality is unbounded")
+	}
+
 	rateLimiter := ratelimit.NewRateLimiter(r.requestsPerSecond, r.burst, r.logger)
 	if r.source != nil {
 		rateLimiter = ratelimit.NewRateLimiterWithSource(r.source, r.logger)
 	}
 
+	rateLimiter.MaxEntries = r.maxEntries
 	r.limiter = rateLimiter
 
 	return r
@@ -106,6 +172,14 @@ func (rl *RateLimiter) setRetryAfterHeader(w http.ResponseWriter, principal stri
 	w.Header().Set("Retry-After", fmt.Sprintf("%d", retryAfterSeconds))
 }
 
+// recordResult increments the rate limit counters for a decision.
+func (rl *RateLimiter) recordResult(result, principal string) {
+	rateLimitRequests.WithLabelValues(result).Inc()
+	if rl.metricsLabel != nil {
+		rateLimitRequestsByLabel.WithLabelValues(result, rl.metricsLabel(principal)).Inc()
+	}
+}
+
 // Middleware returns an HTTP middleware for rate limiting.
 func (rl *RateLimiter) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -114,13 +188,23 @@ func (rl *RateLimiter) Middleware(next http.Handler) http.Handler {
 			http.Error(w, "unable to determine principal for rate limiting", http.StatusBadRequest)
 			return
 		}
-		if !rl.limiter.Allow(p) {
-			rateLimitRequests.WithLabelValues("blocked").Inc()
+		allowed := rl.limiter.Allow(p)
+		if !allowed && rl.auditOnly {
+			rl.recordResult("would_block", p)
+			rl.logger.Warn().
+				Str("principal", p).
+				Str("reason", "rate limit exceeded").
+				Msg("request would be rate limited (audit only)")
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !allowed {
+			rl.recordResult("blocked", p)
 			rl.setRetryAfterHeader(w, p)
 			http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
 			return
 		}
-		rateLimitRequests.WithLabelValues("allowed").Inc()
+		rl.recordResult("allowed", p)
 		next.ServeHTTP(w, r)
 	})
 }
@@ -7,8 +7,13 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dioad/net/ratelimit"
 )
 
 func TestRateLimiter_Middleware(t *testing.T) {
@@ -205,3 +210,95 @@ func TestRateLimiter_EmptyPrincipal(t *testing.T) {
 	handler.ServeHTTP(rr, req)
 	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
 }
+
+func TestRateLimiter_WithMaxEntries(t *testing.T) {
+	rl := NewRateLimiter(
+		WithStaticRateLimit(1, 1),
+		WithMaxEntries(2),
+	)
+
+	handler := rl.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for _, addr := range []string{"192.0.2.1:1234", "192.0.2.2:1234", "192.0.2.3:1234"} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = addr
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+	}
+
+	assert.Equal(t, 2, rl.limiter.Len())
+}
+
+func TestRateLimiter_MetricsLabel(t *testing.T) {
+	source := ratelimit.NewMapSource(map[string]ratelimit.Limit{
+		"premium": {RequestsPerSecond: 100, Burst: 100},
+	})
+
+	tests := []struct {
+		name      string
+		opt       RateLimiterOption
+		principal string
+		label     string
+	}{
+		{name: "tier from source", opt: WithMetricsLabelFunc(TierMetricsLabelFunc(source)), principal: "premium", label: "rps=100,burst=100"},
+		{name: "default tier", opt: WithMetricsLabelFunc(TierMetricsLabelFunc(source)), principal: "metrics-unknown", label: "default"},
+		{name: "principal", opt: WithPrincipalMetricsLabel(), principal: "metrics-user", label: "metrics-user"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rl := NewRateLimiter(
+				WithStaticRateLimit(1, 1),
+				WithPrincipalFunc(StaticPrincipalFunc(tt.principal)),
+				tt.opt,
+			)
+			handler := rl.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+			allowed := testutil.ToFloat64(rateLimitRequestsByLabel.WithLabelValues("allowed", tt.label))
+			blocked := testutil.ToFloat64(rateLimitRequestsByLabel.WithLabelValues("blocked", tt.label))
+
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+			assert.Equal(t, allowed+1, testutil.ToFloat64(rateLimitRequestsByLabel.WithLabelValues("allowed", tt.label)))
+			assert.Equal(t, blocked+1, testutil.ToFloat64(rateLimitRequestsByLabel.WithLabelValues("blocked", tt.label)))
+		})
+	}
+}
+
+func TestRateLimiter_AuditOnly(t *testing.T) {
+	rl := NewRateLimiter(
+		WithStaticRateLimit(1, 1),
+		WithPrincipalFunc(StaticPrincipalFunc("audit-user")),
+		WithPrincipalMetricsLabel(),
+		WithRateLimitAuditOnly(),
+	)
+
+	var served int
+	handler := rl.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served++
+	}))
+
+	wouldBlock := testutil.ToFloat64(rateLimitRequestsByLabel.WithLabelValues("would_block", "audit-user"))
+
+	for range 3 {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, rec.Header().Get("Retry-After"))
+	}
+
+	assert.Equal(t, 3, served)
+	assert.Equal(t, wouldBlock+2, testutil.ToFloat64(rateLimitRequestsByLabel.WithLabelValues("would_block", "audit-user")))
+}
+
+func TestRateLimitLabelledMetricName(t *testing.T) {
+	rateLimitRequestsByLabel.WithLabelValues("allowed", "metric-name").Inc()
+
+	count, err := testutil.GatherAndCount(prometheus.DefaultGatherer, "dioad_net_http_rate_limit_labelled_requests_total")
+	require.NoError(t, err)
+	assert.Positive(t, count)
+}
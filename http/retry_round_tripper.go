@@ -0,0 +1,191 @@
+package http
+
+import (
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how RetryRoundTripper retries failed requests.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first. Values below 1 are treated as 1.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry. It doubles on each subsequent retry.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between attempts.
+	MaxBackoff time.Duration
+	// MaxRetryAfter is the longest Retry-After delay that will be honoured. If the server asks
+	// to wait longer, its response is returned instead of retrying. Zero means no limit.
+	MaxRetryAfter time.Duration
+	// RetryableStatus reports whether a response status should be retried. If nil, 429 and
+	// 5xx responses other than 501 Not Implemented are retried.
+	RetryableStatus func(status int) bool
+}
+
+// DefaultRetryPolicy returns a policy making up to 3 attempts with backoff starting at
+// 100ms and capped at 5s, honouring Retry-After delays of up to 30s.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     5 * time.Second,
+		MaxRetryAfter:  30 * time.Second,
+	}
+}
+
+func defaultRetryableStatus(status int) bool {
+	return status == http.StatusTooManyRequests ||
+		(status >= http.StatusInternalServerError && status != http.StatusNotImplemented)
+}
+
+// RetryRoundTripper is an http.RoundTripper that retries requests on network errors and
+// retryable responses, with exponential backoff.
+//
+// Only requests that are safe to resend are retried: idempotent methods (GET, HEAD, OPTIONS,
+// TRACE, PUT, DELETE) and POST, in each case only when the body can be rewound via
+// Request.GetBody (as set by http.NewRequest for in-memory bodies) or there is no body.
+// A Retry-After header on the response overrides the backoff, and waiting stops as soon as
+// the request's context is cancelled.
+//
+// It composes with other RoundTrippers; wrap an authenticating RoundTripper such as
+// BearerTokenRoundTripper so each attempt is authenticated:
+//
+//	transport := NewRetryRoundTripper(NewBearerTokenRoundTripper(ts, nil), DefaultRetryPolicy())
+type RetryRoundTripper struct {
+	// Base is the underlying RoundTripper. If nil, http.DefaultTransport is used.
+	Base http.RoundTripper
+	// Policy controls when and how often requests are retried.
+	Policy RetryPolicy
+}
+
+// NewRetryRoundTripper returns a RoundTripper that retries requests sent through base
+// according to policy.
+func NewRetryRoundTripper(base http.RoundTripper, policy RetryPolicy) *RetryRoundTripper {
+	return &RetryRoundTripper{
+		Base:   base,
+		Policy: policy,
+	}
+}
+
+// RoundTrip implements http.RoundTripper. The original request is not modified.
+func (t *RetryRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	maxAttempts := max(t.Policy.MaxAttempts, 1)
+	if !isRetryableRequest(req) {
+		maxAttempts = 1
+	}
+
+	for attempt := 1; ; attempt++ {
+		attemptReq := req
+		if attempt > 1 {
+			var err error
+			attemptReq, err = rewindRequest(req)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		resp, err := t.base().RoundTrip(attemptReq)
+		if attempt >= maxAttempts || !t.shouldRetry(resp, err) {
+			return resp, err
+		}
+
+		delay := t.backoff(attempt)
+		if resp != nil {
+			if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+				if t.Policy.MaxRetryAfter > 0 && retryAfter > t.Policy.MaxRetryAfter {
+					return resp, nil
+				}
+				delay = retryAfter
+			}
+			drainAndClose(resp.Body)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (t *RetryRoundTripper) shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	retryable := t.Policy.RetryableStatus
+	if retryable == nil {
+		retryable = defaultRetryableStatus
+	}
+	return retryable(resp.StatusCode)
+}
+
+// backoff returns the delay before the retry following the given attempt, with jitter of
+// up to half the delay to avoid synchronised retries.
+func (t *RetryRoundTripper) backoff(attempt int) time.Duration {
+	delay := t.Policy.InitialBackoff
+	for i := 1; i < attempt && (t.Policy.MaxBackoff <= 0 || delay < t.Policy.MaxBackoff); i++ {
+		delay *= 2
+	}
+	if t.Policy.MaxBackoff > 0 && delay > t.Policy.MaxBackoff {
+		delay = t.Policy.MaxBackoff
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
+
+func (t *RetryRoundTripper) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
+
+// isRetryableRequest reports whether req can safely be sent more than once.
+func isRetryableRequest(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace,
+		http.MethodPut, http.MethodDelete, http.MethodPost:
+	default:
+		return false
+	}
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// rewindRequest returns a copy of req with a fresh body for another attempt.
+func rewindRequest(req *http.Request) (*http.Request, error) {
+	clone := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		clone.Body = body
+	}
+	return clone, nil
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds or as an HTTP date.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return max(time.Until(t), 0), true
+	}
+	return 0, false
+}
+
+// drainAndClose discards a bounded amount of the body so the connection can be reused.
+func drainAndClose(body io.ReadCloser) {
+	_, _ = io.Copy(io.Discard, io.LimitReader(body, 4096))
+	_ = body.Close()
+}
@@ -0,0 +1,166 @@
+package http
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testRetryPolicy() RetryPolicy {
+	return RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}
+}
+
+// failingServer responds with status for the first failures requests, then 200 echoing the body.
+func failingServer(t *testing.T, failures int32, status int, header http.Header) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if calls.Add(1) <= failures {
+			for k, v := range header {
+				w.Header()[k] = v
+			}
+			w.WriteHeader(status)
+			return
+		}
+		_, _ = w.Write(body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func TestRetryRoundTripper_RetriesStatus(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		failures  int32
+		wantCalls int32
+		wantCode  int
+	}{
+		{name: "503 then success", status: http.StatusServiceUnavailable, failures: 2, wantCalls: 3, wantCode: http.StatusOK},
+		{name: "429 then success", status: http.StatusTooManyRequests, failures: 1, wantCalls: 2, wantCode: http.StatusOK},
+		{name: "attempts exhausted", status: http.StatusBadGateway, failures: 5, wantCalls: 3, wantCode: http.StatusBadGateway},
+		{name: "client error not retried", status: http.StatusBadRequest, failures: 5, wantCalls: 1, wantCode: http.StatusBadRequest},
+		{name: "not implemented not retried", status: http.StatusNotImplemented, failures: 5, wantCalls: 1, wantCode: http.StatusNotImplemented},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, calls := failingServer(t, tt.failures, tt.status, nil)
+			client := &http.Client{Transport: NewRetryRoundTripper(nil, testRetryPolicy())}
+
+			resp, err := client.Get(srv.URL)
+			require.NoError(t, err)
+			resp.Body.Close()
+
+			assert.Equal(t, tt.wantCode, resp.StatusCode)
+			assert.Equal(t, tt.wantCalls, calls.Load())
+		})
+	}
+}
+
+func TestRetryRoundTripper_PostBody(t *testing.T) {
+	srv, calls := failingServer(t, 1, http.StatusServiceUnavailable, nil)
+	client := &http.Client{Transport: NewRetryRoundTripper(nil, testRetryPolicy())}
+
+	// rewindable body is resent in full
+	resp, err := client.Post(srv.URL, "text/plain", strings.NewReader("payload"))
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "payload", string(body))
+	assert.Equal(t, int32(2), calls.Load())
+
+	// non-rewindable body is sent once
+	srv, calls = failingServer(t, 1, http.StatusServiceUnavailable, nil)
+	req, err := http.NewRequest(http.MethodPost, srv.URL, io.NopCloser(strings.NewReader("payload")))
+	require.NoError(t, err)
+	resp, err = client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, int32(1), calls.Load())
+
+	// PATCH is not idempotent
+	srv, calls = failingServer(t, 1, http.StatusServiceUnavailable, nil)
+	req, err = http.NewRequest(http.MethodPatch, srv.URL, strings.NewReader("payload"))
+	require.NoError(t, err)
+	resp, err = client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestRetryRoundTripper_RetryAfter(t *testing.T) {
+	srv, calls := failingServer(t, 1, http.StatusTooManyRequests, http.Header{"Retry-After": {"1"}})
+
+	policy := testRetryPolicy()
+	client := &http.Client{Transport: NewRetryRoundTripper(nil, policy)}
+
+	start := time.Now()
+	resp, err := client.Get(srv.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.GreaterOrEqual(t, time.Since(start), time.Second)
+
+	// a Retry-After beyond MaxRetryAfter returns the response immediately
+	srv, calls = failingServer(t, 1, http.StatusTooManyRequests, http.Header{"Retry-After": {"120"}})
+	policy.MaxRetryAfter = time.Second
+	client = &http.Client{Transport: NewRetryRoundTripper(nil, policy)}
+
+	resp, err = client.Get(srv.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestRetryRoundTripper_ContextCancel(t *testing.T) {
+	srv, calls := failingServer(t, 5, http.StatusServiceUnavailable, http.Header{"Retry-After": {"10"}})
+	client := &http.Client{Transport: NewRetryRoundTripper(nil, testRetryPolicy())}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	require.NoError(t, err)
+
+	start := time.Now()
+	_, err = client.Do(req)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 5*time.Second)
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestRetryRoundTripper_NetworkError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := srv.URL
+	srv.Close()
+
+	var attempts atomic.Int32
+	base := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		attempts.Add(1)
+		return http.DefaultTransport.RoundTrip(req)
+	})
+	client := &http.Client{Transport: NewRetryRoundTripper(base, testRetryPolicy())}
+
+	_, err := client.Get(url)
+	require.Error(t, err)
+	assert.Equal(t, int32(3), attempts.Load())
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -0,0 +1,50 @@
+package http
+
+import (
+	"context"
+	"net/http"
+)
+
+// httpContextKeyRouteTemplate is an unexported type used as a key for storing the route template in the context.
+type httpContextKeyRouteTemplate struct{}
+
+// ContextWithRouteTemplate returns a copy of ctx carrying the matched route template.
+func ContextWithRouteTemplate(ctx context.Context, template string) context.Context {
+	return context.WithValue(ctx, httpContextKeyRouteTemplate{}, template)
+}
+
+// RouteTemplateFromContext returns the route template (e.g. "GET /users/{id}") matched
+// for the request, as stored by RouteTemplateMiddleware. It returns an empty string if
+// no route matched or the middleware is not in use.
+func RouteTemplateFromContext(ctx context.Context) string {
+	template, _ := ctx.Value(httpContextKeyRouteTemplate{}).(string)
+	return template
+}
+
+// RouteTemplateMiddleware returns a middleware that looks up the route template mux
+// would match for each request and stores it in the request context, so that metrics,
+// logging and rate limiting can use it instead of the high-cardinality raw path.
+// Servers created with NewServer install it automatically.
+func RouteTemplateMiddleware(mux *http.ServeMux) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(ContextWithRouteTemplate(r.Context(), routeTemplate(mux, r))))
+		})
+	}
+}
+
+// routeTemplate returns the template stored in the request context, or the pattern mux
+// would match for r. It returns an empty string if no route matches.
+func routeTemplate(mux *http.ServeMux, r *http.Request) string {
+	if template := RouteTemplateFromContext(r.Context()); template != "" {
+		return template
+	}
+	if r.Pattern != "" {
+		return r.Pattern
+	}
+	if mux == nil {
+		return ""
+	}
+	_, pattern := mux.Handler(r)
+	return pattern
+}
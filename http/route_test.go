@@ -0,0 +1,69 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouteTemplateMiddleware(t *testing.T) {
+	mux := http.NewServeMux()
+
+	var got string
+	capture := func(w http.ResponseWriter, r *http.Request) {
+		got = RouteTemplateFromContext(r.Context())
+	}
+	mux.HandleFunc("GET /users/{id}", capture)
+	mux.HandleFunc("/static/", capture)
+
+	handler := RouteTemplateMiddleware(mux)(mux)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		want   string
+	}{
+		{name: "wildcard", method: http.MethodGet, path: "/users/42", want: "GET /users/{id}"},
+		{name: "prefix", method: http.MethodGet, path: "/static/css/site.css", want: "/static/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = ""
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tt.method, tt.path, nil))
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("not found", func(t *testing.T) {
+		var template string
+		seen := false
+		notFound := RouteTemplateMiddleware(mux)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			template = RouteTemplateFromContext(r.Context())
+			seen = true
+		}))
+		notFound.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/missing", nil))
+		assert.True(t, seen)
+		assert.Empty(t, template)
+	})
+}
+
+func TestRouteTemplateFromContext_Unset(t *testing.T) {
+	assert.Empty(t, RouteTemplateFromContext(t.Context()))
+}
+
+func TestServerRouteTemplate(t *testing.T) {
+	server := NewServer(Config{})
+
+	var got string
+	server.AddHandlerFunc("GET /items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		got = RouteTemplateFromContext(r.Context())
+	})
+	server.initialiseServer()
+
+	server.handler().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/items/7", nil))
+	assert.Equal(t, "GET /items/{id}", got)
+}
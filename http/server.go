@@ -5,6 +5,7 @@ import (
 	"context"
 	"crypto/tls"
 	"errors"
+	"fmt"
 	"io"
 	stdlog "log"
 	"net"
@@ -41,6 +42,15 @@ type Config struct {
 	EnableDebug bool
 	// EnableStatus enables the /status endpoint for server status
 	EnableStatus bool
+	// StatusTimeout bounds how long /status waits for each resource's Status call.
+	// Resources that do not respond in time are reported as errors. If zero, defaults
+	// to DefaultStatusTimeout.
+	StatusTimeout time.Duration
+	// EnableVersion enables the /version endpoint reporting BuildInfo and uptime,
+	// independently of EnableStatus
+	EnableVersion bool
+	// BuildInfo is reported by the /version endpoint and in the "Build" section of /status
+	BuildInfo BuildInfo
 	// EnableProxyProtocol enables the PROXY protocol for client IP forwarding
 	EnableProxyProtocol bool
 	// TLSConfig is the TLS configuration for the server
@@ -58,6 +68,10 @@ type Config struct {
 	// remain open before being closed. If zero, Go's http.Server defaults to
 	// ReadTimeout.
 	IdleTimeout time.Duration
+	// BaseContext optionally returns the base context for requests accepted on a listener,
+	// as http.Server.BaseContext. Use it to propagate cancellation or values to every
+	// handler. If nil, context.Background is used.
+	BaseContext func(net.Listener) context.Context
 }
 
 // defaultReadHeaderTimeout is applied when Config.ReadHeaderTimeout is zero.
@@ -90,6 +104,13 @@ type Server struct {
 	instrument     *middleware.Instrument
 	rootResource   RootResource
 	middlewares    []Middleware
+	startTime      time.Time // set by NewServer and never modified, so read without locking
+	trustConfig    *TrustConfig
+
+	headerSanitization *HeaderSanitizationConfig
+
+	shutdownHooksMu sync.Mutex
+	shutdownHooks   []func(context.Context) error
 }
 
 func newDefaultServer(config Config) *Server {
@@ -105,6 +126,11 @@ func newDefaultServer(config Config) *Server {
 		metricSet:      m,
 		HealthRegistry: NewHealthRegistry(log.Logger),
 		middlewares:    make([]Middleware, 0),
+		startTime:      time.Now(),
+	}
+	server.HealthRegistry.versionInfo = server.VersionInfo
+	if config.StatusTimeout > 0 {
+		server.HealthRegistry.statusTimeout = config.StatusTimeout
 	}
 
 	return server
@@ -135,9 +161,14 @@ func WithLogger(l zerolog.Logger) ServerOption {
 	}
 }
 
+// TokenValidator validates bearer tokens presented to the server. It is satisfied by
+// the validators returned from oidc.NewValidatorFromConfig and by oidctest.StaticValidator,
+// allowing handlers to be tested without a live identity provider.
+type TokenValidator = authjwt.TokenValidator
+
 // OAuth2ValidatorHandler returns a middleware that validates OAuth2 tokens using the provided configurations.
 func OAuth2ValidatorHandler(v []oidc.ValidatorConfig) (Middleware, error) {
-	var validators []authjwt.TokenValidator
+	var validators []TokenValidator
 	for _, cfg := range v {
 		validator, err := oidc.NewValidatorFromConfig(&cfg)
 		if err != nil {
@@ -146,13 +177,19 @@ func OAuth2ValidatorHandler(v []oidc.ValidatorConfig) (Middleware, error) {
 		validators = append(validators, validator)
 	}
 
+	return TokenValidatorHandler(validators...), nil
+}
+
+// TokenValidatorHandler returns a middleware that validates bearer tokens using the provided validators.
+// A token is accepted if any of the validators accepts it.
+func TokenValidatorHandler(validators ...TokenValidator) Middleware {
 	multiValidator := &authjwt.MultiValidator{Validators: validators}
 	authHandler := jwt.NewHandler(multiValidator, "auth_token", log.Logger)
 
 	// Convert from common generic wrapper standard back to pure http.Handler middleware
 	return func(next http.Handler) http.Handler {
 		return authHandler.Wrap(next)
-	}, nil
+	}
 }
 
 // CORSHandler returns a middleware that handles Cross-Origin Resource Sharing (CORS).
@@ -174,6 +211,15 @@ func WithOAuth2Validator(v []oidc.ValidatorConfig) ServerOption {
 	}
 }
 
+// WithTokenValidators returns a ServerOption that configures the server to validate bearer
+// tokens using the given validators. Unlike WithOAuth2Validator it accepts pre-built validators,
+// which makes it possible to inject fakes such as oidctest.StaticValidator in tests.
+func WithTokenValidators(validators ...TokenValidator) ServerOption {
+	return func(s *Server) {
+		s.Use(TokenValidatorHandler(validators...))
+	}
+}
+
 // CORSAllowLocalhostOrigin returns true if the given origin is a localhost origin.
 func CORSAllowLocalhostOrigin(origin string) bool {
 	u, err := url.Parse(origin)
@@ -310,6 +356,20 @@ func (s *Server) handler() http.Handler {
 		handler = s.LogHandler(handler)
 	}
 
+	handler = RouteTemplateMiddleware(s.Mux)(handler)
+
+	if s.trustConfig != nil {
+		handler = ClientIPMiddleware(*s.trustConfig)(handler)
+	}
+
+	if s.headerSanitization != nil {
+		config := *s.headerSanitization
+		if len(config.Trust.TrustedProxies) == 0 && s.trustConfig != nil {
+			config.Trust = *s.trustConfig
+		}
+		handler = HeaderSanitizationMiddleware(config)(handler)
+	}
+
 	return handler
 }
 
@@ -343,6 +403,9 @@ func (s *Server) addDefaultHandlers() {
 	if s.Config.EnableStatus {
 		s.AddHandlerFunc("GET /status", s.HealthRegistry.aggregateStatusHandler())
 	}
+	if s.Config.EnableVersion {
+		s.AddHandlerFunc("GET /version", s.versionHandler())
+	}
 	if s.Config.EnableHealth {
 		s.AddHandlerFunc("GET /health/live", s.HealthRegistry.aggregateLivenessHandler())
 		s.AddHandlerFunc("GET /health/ready", s.HealthRegistry.aggregateReadinessHandler())
@@ -386,6 +449,7 @@ func (s *Server) initialiseServer() {
 			Handler:           s.handler(),
 			Addr:              s.Config.ListenAddress,
 			ErrorLog:          errorLogger,
+			BaseContext:       s.Config.BaseContext,
 		}
 
 		s.server = server
@@ -469,9 +533,37 @@ func (s *Server) RegisterOnShutdown(f func()) {
 	s.server.RegisterOnShutdown(f)
 }
 
+// RegisterShutdownHook registers a function to drain or stop a resource the server
+// depends on, such as a rate limiter's cleanup goroutine or a prefix list refresher.
+//
+// Hooks are run synchronously by Shutdown once the listeners are closed and active
+// connections have finished, in the reverse order of registration (like defer), so a
+// resource registered after the resources it uses is stopped before them. Each hook
+// receives the context passed to Shutdown.
+func (s *Server) RegisterShutdownHook(hook func(context.Context) error) {
+	s.shutdownHooksMu.Lock()
+	defer s.shutdownHooksMu.Unlock()
+	s.shutdownHooks = append(s.shutdownHooks, hook)
+}
+
 // Shutdown gracefully shuts down the server without interrupting any active connections
-// It waits for all connections to finish or for the context to be canceled
+// It waits for all connections to finish or for the context to be canceled, then runs
+// the hooks registered with RegisterShutdownHook. Every hook is run even if the server
+// or an earlier hook fails; the errors are joined. Hooks are run at most once.
 func (s *Server) Shutdown(ctx context.Context) error {
 	s.initialiseServer()
-	return s.server.Shutdown(ctx)
+	err := s.server.Shutdown(ctx)
+
+	s.shutdownHooksMu.Lock()
+	hooks := s.shutdownHooks
+	s.shutdownHooks = nil
+	s.shutdownHooksMu.Unlock()
+
+	errs := []error{err}
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if hookErr := hooks[i](ctx); hookErr != nil {
+			errs = append(errs, fmt.Errorf("shutdown hook: %w", hookErr))
+		}
+	}
+	return errors.Join(errs...)
 }
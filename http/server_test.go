@@ -4,15 +4,19 @@ import (
 	"context"
 
 	"encoding/json"
+	"errors"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/dioad/net/http/oidctest"
 	dnt "github.com/dioad/net/tls"
 
 	"github.com/rs/zerolog"
@@ -304,6 +308,91 @@ func TestStatusEndpoint(t *testing.T) {
 	}
 }
 
+// SlowStatusResource implements StatusResource and blocks until released
+type SlowStatusResource struct {
+	MockResource
+	release chan struct{}
+}
+
+func (m *SlowStatusResource) Status() (any, error) {
+	<-m.release
+	return map[string]string{"status": "ok"}, nil
+}
+
+// TestStatusEndpointTimeout tests that a slow resource is reported as an error rather than blocking /status
+func TestStatusEndpointTimeout(t *testing.T) {
+	server := NewServer(Config{EnableStatus: true, StatusTimeout: 50 * time.Millisecond})
+
+	slow := &SlowStatusResource{release: make(chan struct{})}
+	defer close(slow.release)
+
+	server.AddResource("/fast", &MockStatusResource{})
+	server.AddResource("/slow", slow)
+	server.initialiseServer()
+
+	start := time.Now()
+	w := httptest.NewRecorder()
+	server.handler().ServeHTTP(w, httptest.NewRequest("GET", "/status", nil))
+	assert.Less(t, time.Since(start), time.Second)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	var statusResponse struct {
+		Routes    map[string]any
+		Errors    map[string]string
+		Durations map[string]float64
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &statusResponse))
+
+	assert.Contains(t, statusResponse.Routes, "/fast")
+	assert.Contains(t, statusResponse.Errors["/slow"], "timed out")
+	assert.Contains(t, statusResponse.Durations, "/fast")
+	assert.GreaterOrEqual(t, statusResponse.Durations["/slow"], 0.05)
+}
+
+// CountingSlowStatusResource implements StatusResource, counting calls and blocking until released
+type CountingSlowStatusResource struct {
+	MockResource
+	calls   atomic.Int32
+	release chan struct{}
+}
+
+func (m *CountingSlowStatusResource) Status() (any, error) {
+	m.calls.Add(1)
+	<-m.release
+	return map[string]string{"status": "ok"}, nil
+}
+
+// TestStatusTimeoutSharesCall tests that probes of a hung resource wait on its call in progress
+// rather than starting another each time
+func TestStatusTimeoutSharesCall(t *testing.T) {
+	registry := NewHealthRegistry(zerolog.Nop())
+	registry.statusTimeout = 10 * time.Millisecond
+
+	slow := &CountingSlowStatusResource{release: make(chan struct{})}
+	registry.Register("/slow", slow)
+
+	for range 5 {
+		results := registry.collectStatus()
+		require.Len(t, results, 1)
+		assert.ErrorContains(t, results[0].err, "timed out")
+	}
+	assert.Equal(t, int32(1), slow.calls.Load())
+
+	// Once the call completes, the next probe starts a new one
+	close(slow.release)
+	require.Eventually(t, func() bool {
+		registry.statusMu.Lock()
+		defer registry.statusMu.Unlock()
+		return len(registry.statusCalls) == 0
+	}, time.Second, 10*time.Millisecond)
+
+	results := registry.collectStatus()
+	require.Len(t, results, 1)
+	assert.NoError(t, results[0].err)
+	assert.Equal(t, int32(2), slow.calls.Load())
+}
+
 // MockStatusResource implements StatusResource for testing
 type MockHealthResource struct {
 	MockResource
@@ -424,6 +513,40 @@ func TestMiddleware(t *testing.T) {
 	}
 }
 
+func TestWithTokenValidators(t *testing.T) {
+	validator := &oidctest.StaticValidator{
+		Tokens: map[string]any{"good-token": map[string]any{"sub": "user"}},
+	}
+
+	server := NewServer(Config{}, WithTokenValidators(validator))
+	server.AddHandlerFunc("/test", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name       string
+		token      string
+		wantStatus int
+	}{
+		{name: "valid token", token: "good-token", wantStatus: http.StatusOK},
+		{name: "invalid token", token: "bad-token", wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/test", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			w := httptest.NewRecorder()
+
+			server.handler().ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+		})
+	}
+
+	assert.Equal(t, []string{"good-token", "bad-token"}, validator.ValidatedTokens())
+}
+
 // mockAuthMiddleware is a simple implementation of auth.Middleware for testing
 type mockAuthMiddleware struct {
 	handler http.Handler
@@ -467,3 +590,76 @@ func TestIdleTimeoutPassthrough(t *testing.T) {
 	assert.Equal(t, idle, s.server.IdleTimeout,
 		"Config.IdleTimeout should be passed through to the underlying http.Server")
 }
+
+func TestServerShutdownHooks(t *testing.T) {
+	s := NewServer(Config{})
+	s.AddHandlerFunc("/", func(w http.ResponseWriter, r *http.Request) {})
+
+	ln, err := nettest.NewLocalListener("tcp4")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	go func() { _ = s.Serve(ln) }()
+
+	require.Eventually(t, func() bool {
+		resp, err := http.Get("http://" + addr + "/")
+		if err != nil {
+			return false
+		}
+		_ = resp.Body.Close()
+		return true
+	}, time.Second, 10*time.Millisecond)
+
+	var order []string
+	errHook := errors.New("hook failed")
+	s.RegisterShutdownHook(func(ctx context.Context) error {
+		order = append(order, "first")
+		return nil
+	})
+	s.RegisterShutdownHook(func(ctx context.Context) error {
+		order = append(order, "second")
+		return errHook
+	})
+	s.RegisterShutdownHook(func(ctx context.Context) error {
+		if conn, err := net.Dial("tcp4", addr); err == nil {
+			_ = conn.Close()
+			t.Error("hook ran before the listener was closed")
+		}
+		order = append(order, "third")
+		return nil
+	})
+
+	err = s.Shutdown(context.Background())
+	assert.ErrorIs(t, err, errHook)
+	assert.Equal(t, []string{"third", "second", "first"}, order)
+
+	// Hooks are only run once
+	assert.NoError(t, s.Shutdown(context.Background()))
+	assert.Len(t, order, 3)
+}
+
+func TestServerBaseContext(t *testing.T) {
+	type ctxKey struct{}
+
+	s := NewServer(Config{
+		BaseContext: func(net.Listener) context.Context {
+			return context.WithValue(context.Background(), ctxKey{}, "base")
+		},
+	})
+	s.AddHandlerFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		v, _ := r.Context().Value(ctxKey{}).(string)
+		_, _ = io.WriteString(w, v)
+	})
+
+	ln, err := nettest.NewLocalListener("tcp4")
+	require.NoError(t, err)
+	go func() { _ = s.Serve(ln) }()
+	defer func() { _ = s.Shutdown(context.Background()) }()
+
+	resp, err := http.Get("http://" + ln.Addr().String() + "/")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "base", string(body))
+}
@@ -0,0 +1,78 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"golang.org/x/oauth2"
+)
+
+// BearerTokenRoundTripper is an http.RoundTripper that attaches an
+// "Authorization: Bearer" header obtained from an oauth2.TokenSource to
+// each outbound request.
+type BearerTokenRoundTripper struct {
+	// Source supplies tokens. It is wrapped in oauth2.ReuseTokenSource by
+	// NewBearerTokenRoundTripper so tokens are cached until they expire.
+	Source oauth2.TokenSource
+	// Base is the underlying RoundTripper. If nil, http.DefaultTransport is used.
+	Base http.RoundTripper
+}
+
+// NewBearerTokenRoundTripper returns a RoundTripper that authenticates requests
+// using tokens from ts, refreshing them as they expire. This allows a client to
+// call a server protected by WithOAuth2Validator:
+//
+//	client := &http.Client{Transport: NewBearerTokenRoundTripper(ts, nil)}
+//
+// If ts is nil, requests fail with an error rather than being sent unauthenticated.
+func NewBearerTokenRoundTripper(ts oauth2.TokenSource, base http.RoundTripper) *BearerTokenRoundTripper {
+	rt := &BearerTokenRoundTripper{Base: base}
+	if ts != nil {
+		rt.Source = oauth2.ReuseTokenSource(nil, ts)
+	}
+	return rt
+}
+
+// RoundTrip implements http.RoundTripper. The original request is not modified.
+// Errors never include the token value.
+func (t *BearerTokenRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	closeBody := func() {
+		if req.Body != nil {
+			_ = req.Body.Close()
+		}
+	}
+
+	if t.Source == nil {
+		closeBody()
+		return nil, errors.New("bearer token round tripper: no token source")
+	}
+
+	if err := req.Context().Err(); err != nil {
+		closeBody()
+		return nil, err
+	}
+
+	token, err := t.Source.Token()
+	if err != nil {
+		closeBody()
+		return nil, fmt.Errorf("bearer token round tripper: get token: %w", err)
+	}
+
+	if token.AccessToken == "" {
+		closeBody()
+		return nil, errors.New("bearer token round tripper: empty access token")
+	}
+
+	authReq := req.Clone(req.Context())
+	authReq.Header.Set("Authorization", "Bearer "+token.AccessToken)
+
+	return t.base().RoundTrip(authReq)
+}
+
+func (t *BearerTokenRoundTripper) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
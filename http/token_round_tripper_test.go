@@ -0,0 +1,88 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+type countingTokenSource struct {
+	calls atomic.Int32
+	token *oauth2.Token
+	err   error
+}
+
+func (s *countingTokenSource) Token() (*oauth2.Token, error) {
+	s.calls.Add(1)
+	return s.token, s.err
+}
+
+func TestBearerTokenRoundTripper(t *testing.T) {
+	var gotAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	source := &countingTokenSource{
+		token: &oauth2.Token{AccessToken: "secret-token", Expiry: time.Now().Add(time.Hour)},
+	}
+	client := &http.Client{Transport: NewBearerTokenRoundTripper(source, nil)}
+
+	for range 3 {
+		req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+		require.NoError(t, err)
+
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, "Bearer secret-token", gotAuth)
+		assert.Empty(t, req.Header.Get("Authorization"), "original request must not be modified")
+	}
+
+	// Token is reused until it expires
+	assert.Equal(t, int32(1), source.calls.Load())
+}
+
+func TestBearerTokenRoundTripper_TokenError(t *testing.T) {
+	source := &countingTokenSource{err: errors.New("idp unavailable")}
+	rt := NewBearerTokenRoundTripper(source, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+	_, err := rt.RoundTrip(req)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "idp unavailable")
+}
+
+func TestBearerTokenRoundTripper_CancelledContext(t *testing.T) {
+	source := &countingTokenSource{token: &oauth2.Token{AccessToken: "secret-token"}}
+	rt := NewBearerTokenRoundTripper(source, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com", nil).WithContext(ctx)
+	_, err := rt.RoundTrip(req)
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, int32(0), source.calls.Load())
+}
+
+func TestBearerTokenRoundTripper_NilSource(t *testing.T) {
+	rt := NewBearerTokenRoundTripper(nil, nil)
+	assert.Nil(t, rt.Source)
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+	_, err := rt.RoundTrip(req)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no token source")
+}
@@ -2,8 +2,12 @@ package ratelimit
 
 import (
 	"net"
+	"sync"
+	"sync/atomic"
 
 	"github.com/rs/zerolog"
+
+	net2 "github.com/dioad/net"
 )
 
 // Listener is a network listener that enforces rate limiting on all incoming connections.
@@ -11,6 +15,33 @@ type Listener struct {
 	net.Listener
 	RateLimiter *RateLimiter
 	Logger      zerolog.Logger
+
+	// TCPOptions, if set, are applied to each accepted connection before it is returned.
+	TCPOptions *net2.TCPConnOptions
+
+	// MaxTotalConns, if greater than zero, caps the number of accepted connections
+	// that may be open at once. Once the cap is reached Accept blocks until a
+	// connection is closed, or rejects new connections if RejectOverLimit is set.
+	MaxTotalConns int
+	// RejectOverLimit closes connections accepted past MaxTotalConns instead of
+	// pausing Accept.
+	RejectOverLimit bool
+
+	// AuditOnly logs connections that exceed the rate limit, and counts them in WouldDeny,
+	// but accepts them. Use it to validate new limits against real traffic before
+	// enforcing them. The MaxTotalConns cap is always enforced.
+	AuditOnly bool
+
+	wouldDeny   atomic.Uint64
+	limiterOnce sync.Once
+	limiter     *net2.ConnLimiter
+	closed      atomic.Bool
+}
+
+// IsClosedErr reports whether err, as returned by Listener.Accept, means the listener
+// has been closed. It is equivalent to errors.Is(err, net.ErrClosed).
+func IsClosedErr(err error) bool {
+	return net2.IsClosedErr(err)
 }
 
 // NewListener creates a new rate-limiting listener.
@@ -24,27 +55,109 @@ func NewListener(l net.Listener, rl *RateLimiter, logger zerolog.Logger) *Listen
 
 // Accept waits for and returns the next connection to the listener.
 // It checks each connection's source IP against the RateLimiter and closes it if the limit is exceeded.
+// Once the listener is closed, Accept returns an error matching net.ErrClosed.
 func (l *Listener) Accept() (net.Conn, error) {
+	limiter := l.connLimiter()
 	for {
+		if limiter != nil && !l.RejectOverLimit {
+			if !limiter.Acquire() {
+				return nil, net.ErrClosed
+			}
+		}
+
 		conn, err := l.Listener.Accept()
 		if err != nil {
+			if limiter != nil && !l.RejectOverLimit {
+				limiter.Release()
+			}
+			if l.closed.Load() {
+				err = net2.WrapClosedErr(err)
+			}
 			return nil, err
 		}
 
+		if limiter != nil && l.RejectOverLimit && !limiter.TryAcquire() {
+			l.Logger.Warn().
+				Str("remoteAddr", conn.RemoteAddr().String()).
+				Msg("connection limit reached, rejecting connection")
+			conn.Close()
+			continue
+		}
+
 		principal := l.getPrincipal(conn)
-		if !l.RateLimiter.Allow(principal) {
+		allowed := l.RateLimiter.Allow(principal)
+		if !allowed && l.AuditOnly {
+			l.wouldDeny.Add(1)
+			l.Logger.Warn().
+				Str("remoteAddr", conn.RemoteAddr().String()).
+				Str("principal", principal).
+				Str("reason", "rate limit exceeded").
+				Msg("connection would be rejected (audit only)")
+			allowed = true
+		}
+		if !allowed {
 			l.Logger.Warn().
 				Str("remoteAddr", conn.RemoteAddr().String()).
 				Str("principal", principal).
 				Msg("rate limit exceeded, rejecting connection")
 			conn.Close()
+			if limiter != nil {
+				limiter.Release()
+			}
 			continue
 		}
 
+		if l.TCPOptions != nil {
+			conn, err = l.TCPOptions.Apply(conn)
+			if err != nil {
+				l.Logger.Warn().
+					Err(err).
+					Str("remoteAddr", conn.RemoteAddr().String()).
+					Msg("failed to apply TCP options")
+			}
+		}
+
+		if limiter != nil {
+			conn = limiter.Track(conn)
+		}
+
 		return conn, nil
 	}
 }
 
+// Close closes the listener, unblocking any Accept waiting on MaxTotalConns.
+func (l *Listener) Close() error {
+	l.closed.Store(true)
+	if limiter := l.connLimiter(); limiter != nil {
+		limiter.Close()
+	}
+	return l.Listener.Close()
+}
+
+// WouldDeny returns the number of connections that were accepted in AuditOnly mode but
+// would otherwise have been rejected by the rate limiter.
+func (l *Listener) WouldDeny() uint64 {
+	return l.wouldDeny.Load()
+}
+
+// ActiveConns returns the number of accepted connections currently open.
+// It is only tracked when MaxTotalConns is set and otherwise returns 0.
+func (l *Listener) ActiveConns() int {
+	if limiter := l.connLimiter(); limiter != nil {
+		return limiter.Active()
+	}
+	return 0
+}
+
+func (l *Listener) connLimiter() *net2.ConnLimiter {
+	l.limiterOnce.Do(func() {
+		if l.MaxTotalConns > 0 {
+			l.limiter = net2.NewConnLimiter(l.MaxTotalConns)
+		}
+	})
+	return l.limiter
+}
+
 func (l *Listener) getPrincipal(conn net.Conn) string {
 	remoteAddr := conn.RemoteAddr().String()
 
@@ -1,6 +1,7 @@
 package ratelimit
 
 import (
+	"errors"
 	"net"
 	"sync/atomic"
 	"testing"
@@ -152,3 +153,132 @@ type mockAddr struct {
 
 func (m *mockAddr) Network() string { return m.network }
 func (m *mockAddr) String() string  { return m.addr }
+
+func TestListener_MaxTotalConns(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	rl := NewRateLimiter(1000, 1000, zerolog.Nop())
+	defer rl.Stop()
+
+	rlListener := NewListener(ln, rl, zerolog.Nop())
+	rlListener.MaxTotalConns = 1
+	defer rlListener.Close()
+
+	accepted := make(chan net.Conn, 2)
+	go func() {
+		for {
+			conn, err := rlListener.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- conn
+		}
+	}()
+
+	c1, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	defer c1.Close()
+
+	var first net.Conn
+	select {
+	case first = <-accepted:
+	case <-time.After(time.Second):
+		t.Fatal("first connection was not accepted")
+	}
+	assert.Equal(t, 1, rlListener.ActiveConns())
+
+	c2, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	defer c2.Close()
+
+	select {
+	case <-accepted:
+		t.Fatal("second connection accepted past MaxTotalConns")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	require.NoError(t, first.Close())
+
+	select {
+	case second := <-accepted:
+		second.Close()
+	case <-time.After(time.Second):
+		t.Fatal("second connection was not accepted after a slot was released")
+	}
+}
+
+// shutdownListener returns a plain error, not matching net.ErrClosed, once closed.
+type shutdownListener struct {
+	net.Listener
+	closed chan struct{}
+}
+
+func (l *shutdownListener) Accept() (net.Conn, error) {
+	<-l.closed
+	return nil, errors.New("listener shut down")
+}
+
+func (l *shutdownListener) Close() error {
+	close(l.closed)
+	return nil
+}
+
+func TestListener_AcceptAfterClose(t *testing.T) {
+	rl := NewRateLimiter(1.0, 1, zerolog.Nop())
+	defer rl.Stop()
+
+	tests := []struct {
+		name string
+		ln   func(t *testing.T) net.Listener
+	}{
+		{
+			name: "tcp listener",
+			ln: func(t *testing.T) net.Listener {
+				ln, err := net.Listen("tcp", "127.0.0.1:0")
+				require.NoError(t, err)
+				return ln
+			},
+		},
+		{
+			name: "listener returning plain error",
+			ln: func(t *testing.T) net.Listener {
+				return &shutdownListener{closed: make(chan struct{})}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rlListener := NewListener(tt.ln(t), rl, zerolog.Nop())
+			require.NoError(t, rlListener.Close())
+
+			_, err := rlListener.Accept()
+			assert.True(t, IsClosedErr(err), "got %v", err)
+		})
+	}
+}
+
+func TestListener_AuditOnly(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	rl := NewRateLimiter(0.001, 1, zerolog.Nop())
+	defer rl.Stop()
+
+	rlListener := NewListener(ln, rl, zerolog.Nop())
+	rlListener.AuditOnly = true
+	defer rlListener.Close()
+
+	for range 3 {
+		client, err := net.Dial("tcp", ln.Addr().String())
+		require.NoError(t, err)
+		defer client.Close()
+
+		conn, err := rlListener.Accept()
+		require.NoError(t, err)
+		defer conn.Close()
+	}
+
+	assert.Equal(t, uint64(2), rlListener.WouldDeny())
+}
@@ -1,6 +1,7 @@
 package ratelimit
 
 import (
+	"container/list"
 	"context"
 	"sync"
 	"time"
@@ -17,6 +18,8 @@ type limiterEntry struct {
 	// This field is intentionally retained for observability and potential future logic
 	// (e.g., metrics, debugging, or external consumers) even if not currently read here.
 	lastAllow bool
+	// element is the entry's position in the RateLimiter's LRU list.
+	element *list.Element
 }
 
 // RateLimitSource defines the interface for determining rate limits.
@@ -53,6 +56,15 @@ type RateLimiter struct {
 	// LimitSource provides dynamic rate limits per principal.
 	LimitSource RateLimitSource
 
+	// MaxEntries, if greater than zero, bounds the number of tracked principals.
+	// When a new principal would exceed it, the least recently used principals are
+	// evicted immediately, in addition to the periodic staleTTL sweep.
+	// Set it before the RateLimiter is used.
+	MaxEntries int
+
+	// lru orders principals from most (front) to least (back) recently used.
+	lru *list.List
+
 	// Background cleanup
 	ctx      context.Context
 	cancel   context.CancelFunc
@@ -249,6 +261,8 @@ func (rl *RateLimiter) Allow(principal string) bool {
 				lastUsed: time.Now(),
 			}
 			rl.limiters[principal] = entry
+			entry.element = rl.lruList().PushFront(principal)
+			rl.evictExcessLocked()
 		}
 		rl.mu.Unlock()
 	}
@@ -270,6 +284,7 @@ func (rl *RateLimiter) Allow(principal string) bool {
 	if currentEntry, stillExists := rl.limiters[principal]; stillExists && currentEntry == entry {
 		entry.lastUsed = time.Now()
 		entry.lastAllow = allowed
+		rl.lruList().MoveToFront(entry.element)
 	}
 	rl.mu.Unlock()
 
@@ -312,6 +327,56 @@ func (rl *RateLimiter) RetryAfter(principal string) time.Duration {
 	return delay
 }
 
+// Len returns the number of principals currently tracked.
+func (rl *RateLimiter) Len() int {
+	rl.mu.RLock()
+	defer rl.mu.RUnlock()
+	return len(rl.limiters)
+}
+
+// lruList returns the LRU list, creating it if necessary. The caller must hold rl.mu for writing.
+func (rl *RateLimiter) lruList() *list.List {
+	if rl.lru == nil {
+		rl.lru = list.New()
+	}
+	return rl.lru
+}
+
+// evictExcessLocked removes least recently used principals until at most MaxEntries remain.
+// The caller must hold rl.mu for writing.
+func (rl *RateLimiter) evictExcessLocked() {
+	if rl.MaxEntries <= 0 {
+		return
+	}
+
+	evicted := 0
+	for len(rl.limiters) > rl.MaxEntries {
+		oldest := rl.lruList().Back()
+		if oldest == nil {
+			break
+		}
+		rl.removeLocked(oldest.Value.(string))
+		evicted++
+	}
+
+	if evicted > 0 {
+		rl.logger.Debug().
+			Int("evicted_limiters", evicted).
+			Int("max_entries", rl.MaxEntries).
+			Msg("evicted least recently used rate limiters")
+	}
+}
+
+// removeLocked removes the principal's limiter. The caller must hold rl.mu for writing.
+func (rl *RateLimiter) removeLocked(principal string) {
+	if entry, ok := rl.limiters[principal]; ok {
+		if entry.element != nil {
+			rl.lruList().Remove(entry.element)
+		}
+		delete(rl.limiters, principal)
+	}
+}
+
 // start begins the background cleanup goroutine.
 func (rl *RateLimiter) start() {
 	rl.wg.Add(1)
@@ -359,7 +424,7 @@ func (rl *RateLimiter) cleanupExpiredLimiters() {
 
 	for principal, entry := range rl.limiters {
 		if now.Sub(entry.lastUsed) > rl.staleTTL {
-			delete(rl.limiters, principal)
+			rl.removeLocked(principal)
 			staleCount++
 		}
 	}
@@ -457,3 +457,56 @@ func TestRateLimiter_ConcurrentAccess(t *testing.T) {
 	assert.True(t, len(rl.limiters) <= 10) // Max 10 unique principals
 	rl.mu.RUnlock()
 }
+
+func TestRateLimiter_MaxEntries(t *testing.T) {
+	rl := NewRateLimiter(1, 1, zerolog.Nop())
+	defer rl.Stop()
+	rl.MaxEntries = 2
+
+	assert.True(t, rl.Allow("user1"))
+	assert.True(t, rl.Allow("user2"))
+	assert.Equal(t, 2, rl.Len())
+
+	// Touch user1 so that user2 becomes the least recently used
+	assert.False(t, rl.Allow("user1"))
+
+	assert.True(t, rl.Allow("user3"))
+	assert.Equal(t, 2, rl.Len())
+
+	rl.mu.RLock()
+	_, hasUser1 := rl.limiters["user1"]
+	_, hasUser2 := rl.limiters["user2"]
+	_, hasUser3 := rl.limiters["user3"]
+	rl.mu.RUnlock()
+
+	assert.True(t, hasUser1)
+	assert.False(t, hasUser2, "least recently used principal should be evicted")
+	assert.True(t, hasUser3)
+
+	// An evicted principal starts again with a fresh limiter
+	assert.True(t, rl.Allow("user2"))
+	assert.Equal(t, 2, rl.Len())
+}
+
+func TestRateLimiter_MaxEntriesConcurrent(t *testing.T) {
+	rl := NewRateLimiter(100, 100, zerolog.Nop())
+	defer rl.Stop()
+	rl.MaxEntries = 10
+
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := range 50 {
+				rl.Allow(strconv.Itoa(i*50 + j))
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 10, rl.Len())
+	rl.mu.RLock()
+	assert.Equal(t, len(rl.limiters), rl.lru.Len())
+	rl.mu.RUnlock()
+}
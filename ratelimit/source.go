@@ -0,0 +1,133 @@
+package ratelimit
+
+import (
+	"sync"
+)
+
+// Limit describes the rate limit applied to a principal.
+type Limit struct {
+	RequestsPerSecond float64
+	Burst             int
+}
+
+// MapSource is a RateLimitSource backed by a mutable map of per-principal limits.
+// It is safe for concurrent use, so limits can be changed while a RateLimiter is using it.
+// Principals without an entry report ok=false, causing the RateLimiter's defaults to apply.
+type MapSource struct {
+	mu     sync.RWMutex
+	limits map[string]Limit
+}
+
+// NewMapSource creates a MapSource populated with a copy of the given limits.
+func NewMapSource(limits map[string]Limit) *MapSource {
+	m := &MapSource{limits: make(map[string]Limit, len(limits))}
+	for principal, limit := range limits {
+		m.limits[principal] = limit
+	}
+	return m
+}
+
+// GetLimit returns the limit configured for the principal.
+func (m *MapSource) GetLimit(principal string) (float64, int, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	limit, ok := m.limits[principal]
+	if !ok {
+		return 0, 0, false
+	}
+	return limit.RequestsPerSecond, limit.Burst, true
+}
+
+// Set sets the limit for the principal, replacing any existing limit.
+func (m *MapSource) Set(principal string, requestsPerSecond float64, burst int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.limits == nil {
+		m.limits = make(map[string]Limit)
+	}
+	m.limits[principal] = Limit{RequestsPerSecond: requestsPerSecond, Burst: burst}
+}
+
+// Delete removes the limit for the principal.
+func (m *MapSource) Delete(principal string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.limits, principal)
+}
+
+// LimitCall records a single call to RateLimitSource.GetLimit and its result.
+type LimitCall struct {
+	Principal         string
+	RequestsPerSecond float64
+	Burst             int
+	OK                bool
+}
+
+// RecordingSource wraps a RateLimitSource and records every GetLimit call.
+// It is intended for asserting how a RateLimiter consults its source in tests.
+type RecordingSource struct {
+	Source RateLimitSource
+
+	mu    sync.Mutex
+	calls []LimitCall
+}
+
+// NewRecordingSource creates a RecordingSource wrapping source.
+func NewRecordingSource(source RateLimitSource) *RecordingSource {
+	return &RecordingSource{Source: source}
+}
+
+// GetLimit delegates to the wrapped source and records the call.
+// If no source is wrapped it reports ok=false.
+func (r *RecordingSource) GetLimit(principal string) (float64, int, bool) {
+	var rps float64
+	var burst int
+	var ok bool
+	if r.Source != nil {
+		rps, burst, ok = r.Source.GetLimit(principal)
+	}
+
+	r.mu.Lock()
+	r.calls = append(r.calls, LimitCall{
+		Principal:         principal,
+		RequestsPerSecond: rps,
+		Burst:             burst,
+		OK:                ok,
+	})
+	r.mu.Unlock()
+
+	return rps, burst, ok
+}
+
+// Calls returns a copy of the recorded calls in the order they were made.
+func (r *RecordingSource) Calls() []LimitCall {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := make([]LimitCall, len(r.calls))
+	copy(result, r.calls)
+	return result
+}
+
+// CallCount returns the number of recorded calls for the principal.
+func (r *RecordingSource) CallCount(principal string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	count := 0
+	for _, call := range r.calls {
+		if call.Principal == principal {
+			count++
+		}
+	}
+	return count
+}
+
+// Reset clears the recorded calls.
+func (r *RecordingSource) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = nil
+}
@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMapSource(t *testing.T) {
+	source := NewMapSource(map[string]Limit{
+		"premium": {RequestsPerSecond: 100, Burst: 10},
+	})
+
+	rps, burst, ok := source.GetLimit("premium")
+	assert.True(t, ok)
+	assert.Equal(t, 100.0, rps)
+	assert.Equal(t, 10, burst)
+
+	_, _, ok = source.GetLimit("unknown")
+	assert.False(t, ok)
+
+	source.Set("unknown", 1, 1)
+	_, burst, ok = source.GetLimit("unknown")
+	assert.True(t, ok)
+	assert.Equal(t, 1, burst)
+
+	source.Delete("premium")
+	_, _, ok = source.GetLimit("premium")
+	assert.False(t, ok)
+}
+
+func TestMapSource_ZeroValue(t *testing.T) {
+	var source MapSource
+
+	_, _, ok := source.GetLimit("user")
+	assert.False(t, ok)
+
+	source.Set("user", 1, 1)
+	_, _, ok = source.GetLimit("user")
+	assert.True(t, ok)
+}
+
+func TestMapSource_ConcurrentUpdates(t *testing.T) {
+	source := NewMapSource(nil)
+	rl := NewRateLimiterWithSource(source, zerolog.Nop())
+	defer rl.Stop()
+
+	var wg sync.WaitGroup
+	for i := range 10 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			source.Set("user", float64(i+1), i+1)
+		}()
+		go func() {
+			defer wg.Done()
+			rl.Allow("user")
+		}()
+	}
+	wg.Wait()
+}
+
+func TestRecordingSource(t *testing.T) {
+	source := NewRecordingSource(NewMapSource(map[string]Limit{
+		"free": {RequestsPerSecond: 1, Burst: 1},
+	}))
+
+	rl := NewRateLimiterWithConfig(5, 5, 0, 0, zerolog.Nop())
+	defer rl.Stop()
+	rl.LimitSource = source
+
+	assert.True(t, rl.Allow("free"))
+	assert.False(t, rl.Allow("free"))
+	assert.True(t, rl.Allow("other"))
+
+	assert.Equal(t, 2, source.CallCount("free"))
+	assert.Equal(t, 1, source.CallCount("other"))
+	assert.Equal(t, []LimitCall{
+		{Principal: "free", RequestsPerSecond: 1, Burst: 1, OK: true},
+		{Principal: "free", RequestsPerSecond: 1, Burst: 1, OK: true},
+		{Principal: "other", OK: false},
+	}, source.Calls())
+
+	source.Reset()
+	assert.Empty(t, source.Calls())
+}
@@ -1,6 +1,10 @@
 package tls
 
-import "crypto/tls"
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+)
 
 func convertTLSVersion(version string) uint16 {
 	switch version {
@@ -17,17 +21,29 @@ func convertTLSVersion(version string) uint16 {
 	}
 }
 
-func convertClientAuthType(authType string) tls.ClientAuthType {
+// ClientAuthTypes lists the accepted values for ServerConfig.ClientAuthType.
+// An empty value is equivalent to "NoClientCert".
+var ClientAuthTypes = []string{
+	"NoClientCert",
+	"RequestClientCert",
+	"RequireAnyClientCert",
+	"VerifyClientCertIfGiven",
+	"RequireAndVerifyClientCert",
+}
+
+func convertClientAuthType(authType string) (tls.ClientAuthType, error) {
 	switch authType {
+	case "", "NoClientCert":
+		return tls.NoClientCert, nil
 	case "RequestClientCert":
-		return tls.RequestClientCert
+		return tls.RequestClientCert, nil
 	case "RequireAnyClientCert":
-		return tls.RequireAnyClientCert
+		return tls.RequireAnyClientCert, nil
 	case "VerifyClientCertIfGiven":
-		return tls.VerifyClientCertIfGiven
+		return tls.VerifyClientCertIfGiven, nil
 	case "RequireAndVerifyClientCert":
-		return tls.RequireAndVerifyClientCert
+		return tls.RequireAndVerifyClientCert, nil
 	default:
-		return tls.NoClientCert
+		return tls.NoClientCert, fmt.Errorf("invalid client auth type %q: must be one of %s", authType, strings.Join(ClientAuthTypes, ", "))
 	}
 }
@@ -10,6 +10,7 @@ func Test_convertClientAuthType(t *testing.T) {
 		name     string
 		authType string
 		want     tls.ClientAuthType
+		wantErr  bool
 	}{
 		{
 			name:     "RequestClientCert",
@@ -36,10 +37,25 @@ func Test_convertClientAuthType(t *testing.T) {
 			authType: "NoClientCert",
 			want:     tls.NoClientCert,
 		},
+		{
+			name:     "empty",
+			authType: "",
+			want:     tls.NoClientCert,
+		},
+		{
+			name:     "unknown",
+			authType: "RequireClientCertz",
+			want:     tls.NoClientCert,
+			wantErr:  true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := convertClientAuthType(tt.authType); got != tt.want {
+			got, err := convertClientAuthType(tt.authType)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("convertClientAuthType() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
 				t.Errorf("convertClientAuthType() = %v, want %v", got, tt.want)
 			}
 		})
@@ -0,0 +1,114 @@
+package tls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ReloadableCertConfig serves a server certificate that can be replaced at runtime, for
+// example when a renewed certificate is written to disk.
+//
+// When a certificate is replaced, the previous one stays available for the overlap
+// window given to NewReloadableCertConfig. During that window, a handshake whose SNI is
+// not covered by the new certificate but is covered by the previous one is served the
+// previous certificate, so a rotation that changes the certificate's names does not
+// break clients at the moment of the swap. Once the window ends, only the current
+// certificate is served.
+//
+// Certificates are only used during a full handshake. Established connections are
+// unaffected by a swap and continue until they close. Resumed TLS sessions do not use
+// the certificate either; they keep working across a swap for as long as the
+// tls.Config's session ticket keys stay valid, independent of the overlap window.
+// Rotating ticket keys (tls.Config.SetSessionTicketKeys) together with the certificate
+// forces every client to do a full handshake with the new certificate.
+type ReloadableCertConfig struct {
+	overlap time.Duration
+	now     func() time.Time
+
+	mu            sync.RWMutex
+	current       *tls.Certificate
+	currentLeaf   *x509.Certificate
+	previous      *tls.Certificate
+	previousLeaf  *x509.Certificate
+	previousUntil time.Time
+}
+
+// NewReloadableCertConfig creates a ReloadableCertConfig that serves cert, keeping a
+// replaced certificate available for overlap. An overlap of zero or less disables it.
+func NewReloadableCertConfig(cert *tls.Certificate, overlap time.Duration) (*ReloadableCertConfig, error) {
+	leaf, err := certificateLeaf(cert)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ReloadableCertConfig{
+		overlap:     overlap,
+		now:         time.Now,
+		current:     cert,
+		currentLeaf: leaf,
+	}, nil
+}
+
+// SetCertificate replaces the served certificate. The replaced certificate remains
+// available for the overlap window, as described on ReloadableCertConfig.
+func (r *ReloadableCertConfig) SetCertificate(cert *tls.Certificate) error {
+	leaf, err := certificateLeaf(cert)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.previous, r.previousLeaf = r.current, r.currentLeaf
+	r.previousUntil = r.now().Add(r.overlap)
+	r.current, r.currentLeaf = cert, leaf
+	return nil
+}
+
+// GetCertificate returns the certificate for a handshake. It has the signature of
+// tls.Config.GetCertificate and is safe for concurrent use.
+func (r *ReloadableCertConfig) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if hello == nil || hello.ServerName == "" || r.previous == nil || !r.now().Before(r.previousUntil) {
+		return r.current, nil
+	}
+
+	if r.currentLeaf.VerifyHostname(hello.ServerName) != nil && r.previousLeaf.VerifyHostname(hello.ServerName) == nil {
+		return r.previous, nil
+	}
+	return r.current, nil
+}
+
+// TLSConfig returns a server tls.Config that serves the current certificate.
+func (r *ReloadableCertConfig) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: r.GetCertificate,
+	}
+}
+
+// certificateLeaf returns cert's parsed leaf certificate.
+func certificateLeaf(cert *tls.Certificate) (*x509.Certificate, error) {
+	if cert == nil {
+		return nil, errors.New("certificate is nil")
+	}
+	if cert.Leaf != nil {
+		return cert.Leaf, nil
+	}
+	if len(cert.Certificate) == 0 {
+		return nil, errors.New("certificate has no leaf")
+	}
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf("error parsing leaf certificate: %w", err)
+	}
+	return leaf, nil
+}
@@ -0,0 +1,100 @@
+package tls
+
+import (
+	"crypto/tls"
+	"testing"
+	"time"
+)
+
+func TestReloadableCertConfigOverlap(t *testing.T) {
+	newCert := func(names ...string) *tls.Certificate {
+		t.Helper()
+		cert, _, err := CreateSelfSignedKeyPair(SelfSignedConfig{
+			Duration: "1h",
+			KeyType:  KeyTypeECDSA,
+			SAN:      SANConfig{DNSNames: names},
+		})
+		if err != nil {
+			t.Fatalf("CreateSelfSignedKeyPair() error = %v", err)
+		}
+		return cert
+	}
+	oldCert := newCert("old.example.com", "both.example.com")
+	newerCert := newCert("new.example.com", "both.example.com")
+
+	r, err := NewReloadableCertConfig(oldCert, time.Minute)
+	if err != nil {
+		t.Fatalf("NewReloadableCertConfig() error = %v", err)
+	}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return now }
+
+	get := func(serverName string) *tls.Certificate {
+		t.Helper()
+		cert, err := r.GetCertificate(&tls.ClientHelloInfo{ServerName: serverName})
+		if err != nil {
+			t.Fatalf("GetCertificate(%q) error = %v", serverName, err)
+		}
+		return cert
+	}
+
+	if get("old.example.com") != oldCert {
+		t.Error("expected initial certificate before rotation")
+	}
+
+	if err := r.SetCertificate(newerCert); err != nil {
+		t.Fatalf("SetCertificate() error = %v", err)
+	}
+
+	tests := []struct {
+		serverName string
+		want       *tls.Certificate
+	}{
+		{serverName: "new.example.com", want: newerCert},
+		{serverName: "both.example.com", want: newerCert},
+		{serverName: "old.example.com", want: oldCert},
+		{serverName: "other.example.com", want: newerCert},
+		{serverName: "", want: newerCert},
+	}
+	for _, tt := range tests {
+		if got := get(tt.serverName); got != tt.want {
+			t.Errorf("during overlap, %q: got wrong certificate", tt.serverName)
+		}
+	}
+
+	now = now.Add(time.Minute)
+	if get("old.example.com") != newerCert {
+		t.Error("expected new certificate once the overlap has ended")
+	}
+}
+
+func TestReloadableCertConfigNoOverlap(t *testing.T) {
+	a, _, err := CreateSelfSignedKeyPair(SelfSignedConfig{Duration: "1h", KeyType: KeyTypeECDSA, SAN: SANConfig{DNSNames: []string{"a.example.com"}}})
+	if err != nil {
+		t.Fatalf("CreateSelfSignedKeyPair() error = %v", err)
+	}
+	b, _, err := CreateSelfSignedKeyPair(SelfSignedConfig{Duration: "1h", KeyType: KeyTypeECDSA, SAN: SANConfig{DNSNames: []string{"b.example.com"}}})
+	if err != nil {
+		t.Fatalf("CreateSelfSignedKeyPair() error = %v", err)
+	}
+
+	r, err := NewReloadableCertConfig(a, 0)
+	if err != nil {
+		t.Fatalf("NewReloadableCertConfig() error = %v", err)
+	}
+	if err := r.SetCertificate(b); err != nil {
+		t.Fatalf("SetCertificate() error = %v", err)
+	}
+
+	got, _ := r.GetCertificate(&tls.ClientHelloInfo{ServerName: "a.example.com"})
+	if got != b {
+		t.Error("expected new certificate with no overlap")
+	}
+
+	if err := r.SetCertificate(nil); err == nil {
+		t.Error("SetCertificate() expected error with nil certificate")
+	}
+	if _, err := NewReloadableCertConfig(&tls.Certificate{}, 0); err == nil {
+		t.Error("NewReloadableCertConfig() expected error with no leaf")
+	}
+}
@@ -0,0 +1,91 @@
+package tls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// WeightedCertificate is a certificate served by RotatingCertConfig, Weight times per
+// rotation. A Weight of zero or less is treated as one.
+type WeightedCertificate struct {
+	Certificate *tls.Certificate
+	Weight      int
+}
+
+// RotatingCertConfig serves a different certificate on each TLS handshake, cycling
+// through its certificates in weighted round-robin order.
+//
+// It is a testing and diagnostic helper for exercising clients against several key
+// types (RSA, ECDSA, Ed25519) from a single listener, e.g. during load or
+// interoperability testing. Certificates are chosen without regard to what the client
+// supports, so handshakes with clients that cannot use the chosen key type fail by
+// design. Do not use it to serve production traffic.
+type RotatingCertConfig struct {
+	schedule []*tls.Certificate
+	next     atomic.Uint64
+}
+
+// NewRotatingCertConfig creates a RotatingCertConfig that cycles through certs in order,
+// serving each one Weight times in a row.
+func NewRotatingCertConfig(certs ...WeightedCertificate) (*RotatingCertConfig, error) {
+	if len(certs) == 0 {
+		return nil, errors.New("at least one certificate is required")
+	}
+
+	r := &RotatingCertConfig{}
+	for i, c := range certs {
+		if c.Certificate == nil {
+			return nil, fmt.Errorf("certificate %d is nil", i)
+		}
+		for range max(c.Weight, 1) {
+			r.schedule = append(r.schedule, c.Certificate)
+		}
+	}
+	return r, nil
+}
+
+// NewSelfSignedRotatingCertConfig generates one self-signed certificate from config for
+// each of keyTypes (see SelfSignedConfig.KeyType) and returns a RotatingCertConfig that
+// serves them with equal weight, together with a pool containing every certificate.
+func NewSelfSignedRotatingCertConfig(config SelfSignedConfig, keyTypes ...string) (*RotatingCertConfig, *x509.CertPool, error) {
+	if len(keyTypes) == 0 {
+		return nil, nil, errors.New("at least one key type is required")
+	}
+
+	pool := x509.NewCertPool()
+	certs := make([]WeightedCertificate, 0, len(keyTypes))
+	for _, keyType := range keyTypes {
+		c := config
+		c.KeyType = keyType
+		cert, _, err := CreateSelfSignedKeyPair(c)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error creating %s certificate: %w", keyType, err)
+		}
+		pool.AddCert(cert.Leaf)
+		certs = append(certs, WeightedCertificate{Certificate: cert})
+	}
+
+	r, err := NewRotatingCertConfig(certs...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return r, pool, nil
+}
+
+// GetCertificate returns the next certificate in the rotation. It has the signature of
+// tls.Config.GetCertificate and is safe for concurrent use.
+func (r *RotatingCertConfig) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	i := (r.next.Add(1) - 1) % uint64(len(r.schedule))
+	return r.schedule[i], nil
+}
+
+// TLSConfig returns a server tls.Config that uses the rotation.
+func (r *RotatingCertConfig) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: r.GetCertificate,
+	}
+}
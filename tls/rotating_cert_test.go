@@ -0,0 +1,123 @@
+package tls
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"testing"
+)
+
+func TestCreateSelfSignedKeyPairKeyTypes(t *testing.T) {
+	tests := []struct {
+		keyType string
+		check   func(any) bool
+		wantErr bool
+	}{
+		{keyType: "", check: func(k any) bool { _, ok := k.(*rsa.PublicKey); return ok }},
+		{keyType: KeyTypeRSA, check: func(k any) bool { _, ok := k.(*rsa.PublicKey); return ok }},
+		{keyType: KeyTypeECDSA, check: func(k any) bool { _, ok := k.(*ecdsa.PublicKey); return ok }},
+		{keyType: "Ed25519", check: func(k any) bool { _, ok := k.(ed25519.PublicKey); return ok }},
+		{keyType: "dsa", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.keyType, func(t *testing.T) {
+			cert, _, err := CreateSelfSignedKeyPair(SelfSignedConfig{Duration: "1h", Bits: 1024, KeyType: tt.keyType})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("CreateSelfSignedKeyPair() expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CreateSelfSignedKeyPair() error = %v", err)
+			}
+			if !tt.check(cert.Leaf.PublicKey) {
+				t.Errorf("public key type = %T", cert.Leaf.PublicKey)
+			}
+			if _, isRSA := cert.Leaf.PublicKey.(*rsa.PublicKey); !isRSA && cert.Leaf.KeyUsage&x509.KeyUsageKeyEncipherment != 0 {
+				t.Error("non-RSA certificate has key encipherment usage")
+			}
+		})
+	}
+}
+
+func TestRotatingCertConfigWeights(t *testing.T) {
+	a := &tls.Certificate{}
+	b := &tls.Certificate{}
+
+	r, err := NewRotatingCertConfig(WeightedCertificate{Certificate: a, Weight: 2}, WeightedCertificate{Certificate: b})
+	if err != nil {
+		t.Fatalf("NewRotatingCertConfig() error = %v", err)
+	}
+
+	want := []*tls.Certificate{a, a, b, a, a, b}
+	for i, w := range want {
+		got, err := r.GetCertificate(nil)
+		if err != nil {
+			t.Fatalf("GetCertificate() error = %v", err)
+		}
+		if got != w {
+			t.Errorf("handshake %d: got wrong certificate", i)
+		}
+	}
+
+	if _, err := NewRotatingCertConfig(); err == nil {
+		t.Error("NewRotatingCertConfig() expected error with no certificates")
+	}
+	if _, err := NewRotatingCertConfig(WeightedCertificate{}); err == nil {
+		t.Error("NewRotatingCertConfig() expected error with nil certificate")
+	}
+}
+
+func TestNewSelfSignedRotatingCertConfig(t *testing.T) {
+	config := SelfSignedConfig{
+		Duration: "1h",
+		Bits:     1024,
+		SAN:      SANConfig{DNSNames: []string{"localhost"}},
+	}
+	r, pool, err := NewSelfSignedRotatingCertConfig(config, KeyTypeRSA, KeyTypeECDSA, KeyTypeEd25519)
+	if err != nil {
+		t.Fatalf("NewSelfSignedRotatingCertConfig() error = %v", err)
+	}
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", r.TLSConfig())
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	defer ln.Close()
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.(*tls.Conn).Handshake()
+			_ = conn.Close()
+		}
+	}()
+
+	seen := make(map[string]bool)
+	for range 3 {
+		conn, err := tls.Dial("tcp", ln.Addr().String(), &tls.Config{RootCAs: pool, ServerName: "localhost"})
+		if err != nil {
+			t.Fatalf("Dial() error = %v", err)
+		}
+		seen[fmt.Sprintf("%T", conn.ConnectionState().PeerCertificates[0].PublicKey)] = true
+		_ = conn.Close()
+	}
+
+	for _, keyType := range []string{"*rsa.PublicKey", "*ecdsa.PublicKey", "ed25519.PublicKey"} {
+		if !seen[keyType] {
+			t.Errorf("no handshake served a %s certificate, saw %v", keyType, seen)
+		}
+	}
+
+	if _, _, err := NewSelfSignedRotatingCertConfig(config); err == nil {
+		t.Error("NewSelfSignedRotatingCertConfig() expected error with no key types")
+	}
+}
@@ -2,6 +2,10 @@ package tls
 
 import (
 	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/tls"
@@ -11,6 +15,9 @@ import (
 	"fmt"
 	"math/big"
 	"net"
+	"net/mail"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/dioad/generics"
@@ -34,6 +41,18 @@ func CreateAndSaveSelfSignedKeyPair(config SelfSignedConfig, certPath, keyPath s
 func convertConfigToX509CertificateTemplate(config SelfSignedConfig) (*x509.Certificate, error) {
 
 	notBefore := time.Now().UTC()
+	if config.NotBefore != "" {
+		t, err := time.Parse(time.RFC3339, config.NotBefore)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing not before: %w", err)
+		}
+		notBefore = t.UTC()
+	}
+
+	serialNumber, err := parseSerialNumber(config.SerialNumber)
+	if err != nil {
+		return nil, err
+	}
 
 	duration, err := time.ParseDuration(config.Duration)
 	if err != nil {
@@ -53,8 +72,29 @@ func convertConfigToX509CertificateTemplate(config SelfSignedConfig) (*x509.Cert
 		return nil, fmt.Errorf("error parsing ip addresses: %w", err)
 	}
 
+	uris, err := generics.Map(func(u string) (*url.URL, error) {
+		parsedURI, err := url.Parse(u)
+		if err != nil {
+			return nil, err
+		}
+		if !parsedURI.IsAbs() {
+			return nil, fmt.Errorf("uri %q must be absolute", u)
+		}
+		return parsedURI, nil
+	}, config.SAN.URIs)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing uris: %w", err)
+	}
+
+	for _, email := range config.SAN.EmailAddresses {
+		addr, err := mail.ParseAddress(email)
+		if err != nil || addr.Address != email {
+			return nil, fmt.Errorf("error parsing email address: %s", email)
+		}
+	}
+
 	return &x509.Certificate{
-		SerialNumber: big.NewInt(1),
+		SerialNumber: serialNumber,
 		Subject: pkix.Name{
 			Country:            config.Subject.Country,
 			Organization:       config.Subject.Organization,
@@ -74,13 +114,15 @@ func convertConfigToX509CertificateTemplate(config SelfSignedConfig) (*x509.Cert
 		BasicConstraintsValid: true,
 		DNSNames:              config.SAN.DNSNames,
 		IPAddresses:           ipAddresses,
+		URIs:                  uris,
+		EmailAddresses:        config.SAN.EmailAddresses,
 	}, nil
 }
 
 // CreateSelfSignedKeyPair creates a self-signed key pair in memory.
 // pulled from inet.af/tcpproxy
 func CreateSelfSignedKeyPair(config SelfSignedConfig) (*tls.Certificate, *x509.CertPool, error) {
-	pkey, err := rsa.GenerateKey(rand.Reader, config.Bits)
+	pkey, err := generateKey(config)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -88,6 +130,10 @@ func CreateSelfSignedKeyPair(config SelfSignedConfig) (*tls.Certificate, *x509.C
 	if err != nil {
 		return nil, nil, err
 	}
+	if _, ok := pkey.(*rsa.PrivateKey); !ok {
+		// Key encipherment only applies to RSA key exchange.
+		template.KeyUsage &^= x509.KeyUsageKeyEncipherment
+	}
 
 	derBytes, err := x509.CreateCertificate(rand.Reader, template, template, pkey.Public(), pkey)
 	if err != nil {
@@ -99,7 +145,11 @@ func CreateSelfSignedKeyPair(config SelfSignedConfig) (*tls.Certificate, *x509.C
 	if err != nil {
 		return nil, nil, err
 	}
-	err = pem.Encode(&key, &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(pkey)})
+	keyBlock, err := privateKeyPEMBlock(pkey)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = pem.Encode(&key, keyBlock)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -116,3 +166,59 @@ func CreateSelfSignedKeyPair(config SelfSignedConfig) (*tls.Certificate, *x509.C
 
 	return &tlsCert, pool, nil
 }
+
+// generateKey generates a private key of the type requested by config.KeyType.
+func generateKey(config SelfSignedConfig) (crypto.Signer, error) {
+	switch strings.ToLower(config.KeyType) {
+	case "", KeyTypeRSA:
+		return rsa.GenerateKey(rand.Reader, config.Bits)
+	case KeyTypeECDSA:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case KeyTypeEd25519:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", config.KeyType)
+	}
+}
+
+// privateKeyPEMBlock encodes RSA keys as PKCS #1, for compatibility with existing
+// consumers, and all other keys as PKCS #8.
+func privateKeyPEMBlock(key crypto.Signer) (*pem.Block, error) {
+	if rsaKey, ok := key.(*rsa.PrivateKey); ok {
+		return &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}, nil
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return &pem.Block{Type: "PRIVATE KEY", Bytes: der}, nil
+}
+
+// maxSerialNumber bounds serial numbers to 20 octets, as required by RFC 5280 Section 4.1.2.2.
+var maxSerialNumber = new(big.Int).Lsh(big.NewInt(1), 159)
+
+// parseSerialNumber parses a decimal or 0x-prefixed hex serial number, or returns a random
+// 128-bit serial when s is empty.
+func parseSerialNumber(s string) (*big.Int, error) {
+	if s == "" {
+		serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+		if err != nil {
+			return nil, fmt.Errorf("error generating serial number: %w", err)
+		}
+		// Zero is not a valid serial number
+		return serial.Add(serial, big.NewInt(1)), nil
+	}
+
+	serial, ok := new(big.Int).SetString(s, 0)
+	if !ok {
+		return nil, fmt.Errorf("error parsing serial number: %q", s)
+	}
+	if serial.Sign() <= 0 {
+		return nil, fmt.Errorf("serial number must be positive: %s", s)
+	}
+	if serial.Cmp(maxSerialNumber) >= 0 {
+		return nil, fmt.Errorf("serial number exceeds 20 octets: %s", s)
+	}
+	return serial, nil
+}
@@ -3,19 +3,24 @@ package tls
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"path/filepath"
 	"time"
 
 	"github.com/dioad/generics"
+	"github.com/rs/zerolog"
 
 	"github.com/dioad/util"
 )
 
-// SANConfig specifies Subject Alternative Names for a certificate (DNS names and IP addresses).
+// SANConfig specifies Subject Alternative Names for a certificate (DNS names, IP addresses,
+// URIs such as SPIFFE IDs, and email addresses).
 type SANConfig struct {
-	DNSNames    []string `mapstructure:"dns-names" json:"dns_names,omitzero"`
-	IPAddresses []string `mapstructure:"ip-addresses" json:"ip_addresses,omitzero"`
+	DNSNames       []string `mapstructure:"dns-names" json:"dns_names,omitzero"`
+	IPAddresses    []string `mapstructure:"ip-addresses" json:"ip_addresses,omitzero"`
+	URIs           []string `mapstructure:"uris" json:"uris,omitzero"`
+	EmailAddresses []string `mapstructure:"email-addresses" json:"email_addresses,omitzero"`
 }
 
 // CertificateSubject defines X.509 certificate subject information.
@@ -31,15 +36,33 @@ type CertificateSubject struct {
 	CommonName         string   `mapstructure:"cn" json:"common_name,omitzero"`
 }
 
+// Key types supported by SelfSignedConfig.KeyType.
+const (
+	KeyTypeRSA     = "rsa"
+	KeyTypeECDSA   = "ecdsa"
+	KeyTypeEd25519 = "ed25519"
+)
+
 // SelfSignedConfig specifies parameters for generating a self-signed certificate.
 type SelfSignedConfig struct {
-	Subject        CertificateSubject `mapstructure:"subject" json:"subject"`
-	SAN            SANConfig          `mapstructure:"san" json:"san"`
-	Duration       string             `mapstructure:"duration" json:"duration,omitzero"`
-	IsCA           bool               `mapstructure:"ca" json:"is_ca,omitzero"`
-	Bits           int                `mapstructure:"bits" json:"bits,omitzero"`
-	CacheDirectory string             `mapstructure:"cache-directory" json:"cache_directory,omitzero"`
-	Alias          string             `mapstructure:"alias" json:"alias,omitzero"`
+	Subject  CertificateSubject `mapstructure:"subject" json:"subject"`
+	SAN      SANConfig          `mapstructure:"san" json:"san"`
+	Duration string             `mapstructure:"duration" json:"duration,omitzero"`
+	// NotBefore is the start of the validity period in RFC 3339 format, e.g.
+	// "2024-01-01T00:00:00Z". If empty, the current time is used. Duration is counted
+	// from NotBefore.
+	NotBefore string `mapstructure:"not-before" json:"not_before,omitzero"`
+	// SerialNumber is the certificate serial number, in decimal or with a 0x prefix in
+	// hex. It must be positive and at most 20 octets. If empty, a random 128-bit serial
+	// is used; only fix it for reproducible test fixtures.
+	SerialNumber string `mapstructure:"serial-number" json:"serial_number,omitzero"`
+	IsCA         bool   `mapstructure:"ca" json:"is_ca,omitzero"`
+	// KeyType is one of KeyTypeRSA (the default), KeyTypeECDSA (P-256) or KeyTypeEd25519.
+	KeyType string `mapstructure:"key-type" json:"key_type,omitzero"`
+	// Bits is the RSA key size; it is ignored for other key types.
+	Bits           int    `mapstructure:"bits" json:"bits,omitzero"`
+	CacheDirectory string `mapstructure:"cache-directory" json:"cache_directory,omitzero"`
+	Alias          string `mapstructure:"alias" json:"alias,omitzero"`
 }
 
 // LocalConfig specifies local certificate and key file locations.
@@ -68,17 +91,44 @@ type ServerConfig struct {
 
 	LocalConfig LocalConfig `json:"local" mapstructure:"local"`
 
+	// ClientAuthType is one of ClientAuthTypes and only applies when ClientCAFile is set.
+	// Unknown values cause NewServerTLSConfig to fail.
 	ClientAuthType string `mapstructure:"client-auth-type" json:"client_auth_type,omitzero"`
 	ClientCAFile   string `mapstructure:"client-ca-file" json:"client_ca_file,omitzero"`
 
 	NextProtos    []string `json:"next_protos,omitzero" mapstructure:"next-protos"`
 	TLSMinVersion string   `json:"tls_min_version,omitzero" mapstructure:"tls-min-version"`
+
+	// DisableSessionTickets turns off session ticket resumption entirely.
+	DisableSessionTickets bool `json:"disable_session_tickets,omitzero" mapstructure:"disable-session-tickets"`
+	// SessionTicketRotation rotates session ticket keys on an interval until the context
+	// passed to NewServerTLSConfig is cancelled. It is ignored when DisableSessionTickets
+	// is set.
+	SessionTicketRotation SessionTicketRotationConfig `json:"session_ticket_rotation" mapstructure:"session-ticket-rotation"`
+
+	// Sources optionally lists certificate sources (SourceAutoCert, SourceLocal,
+	// SourceSelfSigned) to try in order. The first that produces a certificate is used,
+	// e.g. []string{"local", "self-signed"} uses mounted certificate files if present and
+	// generates a self-signed certificate otherwise. If empty, exactly one source is
+	// chosen by precedence: auto-cert, then self-signed, then local.
+	Sources []string `json:"sources,omitzero" mapstructure:"sources"`
 }
 
+// Certificate sources for ServerConfig.Sources.
+const (
+	SourceAutoCert   = "auto-cert"
+	SourceLocal      = "local"
+	SourceSelfSigned = "self-signed"
+)
+
 // ConfigFunc is a function type that returns a TLS configuration.
 type ConfigFunc func() (*tls.Config, error)
 
 func configFuncFromConfig(ctx context.Context, c ServerConfig) ConfigFunc {
+	if len(c.Sources) > 0 {
+		return fallbackConfigFunc(ctx, c)
+	}
+
 	if !generics.IsZeroValue(c.AutoCert) {
 		return NewAutocertTLSConfigFunc(c.AutoCert)
 	} else if !generics.IsZeroValue(c.SelfSigned) {
@@ -89,8 +139,87 @@ func configFuncFromConfig(ctx context.Context, c ServerConfig) ConfigFunc {
 	return nil
 }
 
+// fallbackConfigFunc returns a ConfigFunc that tries each of c.Sources in order and
+// returns the first configuration that contains a certificate. Sources that are not
+// configured are skipped.
+func fallbackConfigFunc(ctx context.Context, c ServerConfig) ConfigFunc {
+	return func() (*tls.Config, error) {
+		logger := zerolog.Ctx(ctx)
+		var errs []error
+
+		for _, source := range c.Sources {
+			var configFunc ConfigFunc
+			switch source {
+			case SourceAutoCert:
+				if !generics.IsZeroValue(c.AutoCert) {
+					configFunc = NewAutocertTLSConfigFunc(c.AutoCert)
+				}
+			case SourceLocal:
+				if !generics.IsZeroValue(c.LocalConfig) {
+					configFunc = NewLocalTLSConfigFunc(ctx, c.LocalConfig)
+				}
+			case SourceSelfSigned:
+				if !generics.IsZeroValue(c.SelfSigned) {
+					configFunc = NewSelfSignedTLSConfigFunc(c.SelfSigned)
+				}
+			default:
+				return nil, unknownSourceError(source)
+			}
+
+			if configFunc == nil {
+				logger.Debug().Str("source", source).Msg("certificate source not configured, skipping")
+				continue
+			}
+
+			tlsConfig, err := configFunc()
+			if err == nil && tlsConfig == nil {
+				err = fmt.Errorf("no tls config produced")
+			}
+			if err != nil {
+				logger.Warn().Err(err).Str("source", source).Msg("certificate source failed, trying next")
+				errs = append(errs, fmt.Errorf("%s: %w", source, err))
+				continue
+			}
+
+			logger.Info().Str("source", source).Msg("using certificate source")
+			return tlsConfig, nil
+		}
+
+		if len(errs) == 0 {
+			return nil, fmt.Errorf("none of the certificate sources %v are configured", c.Sources)
+		}
+		return nil, fmt.Errorf("all certificate sources failed: %w", errors.Join(errs...))
+	}
+}
+
+// validateSources returns an error if any of sources is not a known certificate source.
+func validateSources(sources []string) error {
+	for _, source := range sources {
+		switch source {
+		case SourceAutoCert, SourceLocal, SourceSelfSigned:
+		default:
+			return unknownSourceError(source)
+		}
+	}
+	return nil
+}
+
+func unknownSourceError(source string) error {
+	return fmt.Errorf("unknown certificate source %q: must be one of %s, %s, %s", source, SourceAutoCert, SourceLocal, SourceSelfSigned)
+}
+
 // NewServerTLSConfig creates a TLS configuration for a server from the given config.
+// Every entry in c.Sources is validated before any source is tried.
 func NewServerTLSConfig(ctx context.Context, c ServerConfig) (*tls.Config, error) {
+	clientAuth, err := convertClientAuthType(c.ClientAuthType)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateSources(c.Sources); err != nil {
+		return nil, err
+	}
+
 	configFunc := configFuncFromConfig(ctx, c)
 	if configFunc == nil {
 		return nil, nil
@@ -123,7 +252,7 @@ func NewServerTLSConfig(ctx context.Context, c ServerConfig) (*tls.Config, error
 	}
 
 	if c.ClientCAFile != "" {
-		tlsConfig.ClientAuth = convertClientAuthType(c.ClientAuthType)
+		tlsConfig.ClientAuth = clientAuth
 
 		clientCAs, err := LoadCertPoolFromFile(c.ClientCAFile)
 		if err != nil {
@@ -132,6 +261,18 @@ func NewServerTLSConfig(ctx context.Context, c ServerConfig) (*tls.Config, error
 		tlsConfig.ClientCAs = clientCAs
 	}
 
+	if c.DisableSessionTickets {
+		tlsConfig.SessionTicketsDisabled = true
+	} else if c.SessionTicketRotation.Interval != "" {
+		interval, err := time.ParseDuration(c.SessionTicketRotation.Interval)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing session ticket rotation interval: %w", err)
+		}
+		if err := RotateSessionTicketKeys(ctx, tlsConfig, interval, c.SessionTicketRotation.PreviousKeys); err != nil {
+			return nil, err
+		}
+	}
+
 	return tlsConfig, nil
 }
 
@@ -8,7 +8,9 @@ import (
 	"os"
 	"path/filepath"
 	"slices"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestNewClientTLSConfig(t *testing.T) {
@@ -228,6 +230,11 @@ func TestNewServerTLSConfig(t *testing.T) {
 				}
 			},
 		},
+		{
+			name:        "with unknown client auth type",
+			c:           ServerConfig{ClientCAFile: caPath, ClientAuthType: "RequireClientCertz"},
+			expectError: true,
+		},
 		{
 			name: "with TLS min version",
 			c:    ServerConfig{TLSMinVersion: "TLS13"},
@@ -271,6 +278,42 @@ func TestNewServerTLSConfig(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: "with session tickets disabled",
+			c: ServerConfig{
+				LocalConfig:           LocalConfig{SinglePEMFile: singlePEMPath},
+				DisableSessionTickets: true,
+				SessionTicketRotation: SessionTicketRotationConfig{Interval: "1h"},
+			},
+			checkFunc: func(t *testing.T, got *tls.Config) {
+				if !got.SessionTicketsDisabled {
+					t.Errorf("SessionTicketsDisabled = false, want true")
+				}
+				if got.WrapSession != nil {
+					t.Errorf("WrapSession is set, expected no ticket rotation")
+				}
+			},
+		},
+		{
+			name: "with session ticket rotation",
+			c: ServerConfig{
+				LocalConfig:           LocalConfig{SinglePEMFile: singlePEMPath},
+				SessionTicketRotation: SessionTicketRotationConfig{Interval: "1h", PreviousKeys: 1},
+			},
+			checkFunc: func(t *testing.T, got *tls.Config) {
+				if got.WrapSession == nil || got.UnwrapSession == nil {
+					t.Errorf("WrapSession and UnwrapSession should be set")
+				}
+			},
+		},
+		{
+			name: "with invalid session ticket rotation interval",
+			c: ServerConfig{
+				LocalConfig:           LocalConfig{SinglePEMFile: singlePEMPath},
+				SessionTicketRotation: SessionTicketRotationConfig{Interval: "hourly"},
+			},
+			expectError: true,
+		},
 		{
 			name: "with self-signed config",
 			c: ServerConfig{
@@ -366,3 +409,232 @@ func TestNewSelfSignedTLSConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestNewServerTLSConfigSources(t *testing.T) {
+	tempDir := t.TempDir()
+
+	cert, _ := helperCreateSelfSignedKeyPair(t, tempDir)
+	singlePEMPath := filepath.Join(tempDir, "single.pem")
+	if err := SaveTLSCertificateToFile(cert, singlePEMPath, 0644); err != nil {
+		t.Fatalf("Failed to save certificate to single PEM file: %v", err)
+	}
+
+	selfSigned := SelfSignedConfig{
+		CacheDirectory: t.TempDir(),
+		Subject:        CertificateSubject{CommonName: "fallback"},
+		SAN:            SANConfig{DNSNames: []string{"localhost"}},
+		Duration:       "1h",
+		Bits:           1024,
+	}
+
+	leafCommonName := func(t *testing.T, got *tls.Config) string {
+		t.Helper()
+		if got == nil || len(got.Certificates) == 0 {
+			t.Fatalf("expected certificates, got none")
+		}
+		leaf, err := x509.ParseCertificate(got.Certificates[0].Certificate[0])
+		if err != nil {
+			t.Fatalf("failed to parse certificate: %v", err)
+		}
+		return leaf.Subject.CommonName
+	}
+
+	t.Run("local present", func(t *testing.T) {
+		got, err := NewServerTLSConfig(context.Background(), ServerConfig{
+			Sources:     []string{SourceLocal, SourceSelfSigned},
+			LocalConfig: LocalConfig{SinglePEMFile: singlePEMPath},
+			SelfSigned:  selfSigned,
+		})
+		if err != nil {
+			t.Fatalf("NewServerTLSConfig() error = %v", err)
+		}
+		if cn := leafCommonName(t, got); cn == "fallback" {
+			t.Errorf("expected local certificate, got self-signed fallback")
+		}
+	})
+
+	t.Run("local missing falls back to self-signed", func(t *testing.T) {
+		got, err := NewServerTLSConfig(context.Background(), ServerConfig{
+			Sources:     []string{SourceLocal, SourceSelfSigned},
+			LocalConfig: LocalConfig{SinglePEMFile: filepath.Join(tempDir, "missing.pem")},
+			SelfSigned:  selfSigned,
+		})
+		if err != nil {
+			t.Fatalf("NewServerTLSConfig() error = %v", err)
+		}
+		if cn := leafCommonName(t, got); cn != "fallback" {
+			t.Errorf("CommonName = %q, want %q", cn, "fallback")
+		}
+	})
+
+	t.Run("unconfigured sources skipped", func(t *testing.T) {
+		got, err := NewServerTLSConfig(context.Background(), ServerConfig{
+			Sources:    []string{SourceAutoCert, SourceLocal, SourceSelfSigned},
+			SelfSigned: selfSigned,
+		})
+		if err != nil {
+			t.Fatalf("NewServerTLSConfig() error = %v", err)
+		}
+		if cn := leafCommonName(t, got); cn != "fallback" {
+			t.Errorf("CommonName = %q, want %q", cn, "fallback")
+		}
+	})
+
+	t.Run("all sources fail", func(t *testing.T) {
+		_, err := NewServerTLSConfig(context.Background(), ServerConfig{
+			Sources:     []string{SourceLocal},
+			LocalConfig: LocalConfig{SinglePEMFile: filepath.Join(tempDir, "missing.pem")},
+		})
+		if err == nil {
+			t.Errorf("NewServerTLSConfig() expected error, got nil")
+		}
+	})
+
+	t.Run("unknown source", func(t *testing.T) {
+		_, err := NewServerTLSConfig(context.Background(), ServerConfig{
+			Sources:    []string{"vault"},
+			SelfSigned: selfSigned,
+		})
+		if err == nil {
+			t.Errorf("NewServerTLSConfig() expected error, got nil")
+		}
+	})
+
+	t.Run("unknown source after a working one", func(t *testing.T) {
+		_, err := NewServerTLSConfig(context.Background(), ServerConfig{
+			Sources:    []string{SourceSelfSigned, "vault"},
+			SelfSigned: selfSigned,
+		})
+		if err == nil || !strings.Contains(err.Error(), `unknown certificate source "vault"`) {
+			t.Errorf("NewServerTLSConfig() error = %v, want unknown certificate source", err)
+		}
+	})
+}
+
+func TestCreateSelfSignedKeyPairSANs(t *testing.T) {
+	tests := []struct {
+		name    string
+		san     SANConfig
+		wantErr bool
+	}{
+		{
+			name: "all SAN types",
+			san: SANConfig{
+				DNSNames:       []string{"svc.example.com"},
+				IPAddresses:    []string{"127.0.0.1", "::1"},
+				URIs:           []string{"spiffe://example.org/ns/default/sa/svc"},
+				EmailAddresses: []string{"ops@example.com"},
+			},
+		},
+		{name: "invalid ip", san: SANConfig{IPAddresses: []string{"not-an-ip"}}, wantErr: true},
+		{name: "relative uri", san: SANConfig{URIs: []string{"/ns/default"}}, wantErr: true},
+		{name: "invalid uri", san: SANConfig{URIs: []string{"spiffe://%zz"}}, wantErr: true},
+		{name: "invalid email", san: SANConfig{EmailAddresses: []string{"not an email"}}, wantErr: true},
+		{name: "email with display name", san: SANConfig{EmailAddresses: []string{"Ops <ops@example.com>"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cert, _, err := CreateSelfSignedKeyPair(SelfSignedConfig{Duration: "1h", Bits: 1024, SAN: tt.san})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("CreateSelfSignedKeyPair() expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CreateSelfSignedKeyPair() error = %v", err)
+			}
+
+			leaf, err := x509.ParseCertificate(cert.Certificate[0])
+			if err != nil {
+				t.Fatalf("ParseCertificate() error = %v", err)
+			}
+
+			if !slices.Equal(leaf.DNSNames, tt.san.DNSNames) {
+				t.Errorf("DNSNames = %v, want %v", leaf.DNSNames, tt.san.DNSNames)
+			}
+			if len(leaf.IPAddresses) != len(tt.san.IPAddresses) {
+				t.Errorf("IPAddresses = %v, want %v", leaf.IPAddresses, tt.san.IPAddresses)
+			}
+			if len(leaf.URIs) != 1 || leaf.URIs[0].String() != tt.san.URIs[0] {
+				t.Errorf("URIs = %v, want %v", leaf.URIs, tt.san.URIs)
+			}
+			if !slices.Equal(leaf.EmailAddresses, tt.san.EmailAddresses) {
+				t.Errorf("EmailAddresses = %v, want %v", leaf.EmailAddresses, tt.san.EmailAddresses)
+			}
+		})
+	}
+}
+
+func TestCreateSelfSignedKeyPairSerialAndNotBefore(t *testing.T) {
+	tests := []struct {
+		name       string
+		serial     string
+		notBefore  string
+		wantSerial string
+		wantErr    bool
+	}{
+		{name: "decimal serial", serial: "12345", notBefore: "2024-01-01T00:00:00Z", wantSerial: "12345"},
+		{name: "hex serial", serial: "0x1f", wantSerial: "31"},
+		{name: "maximum serial", serial: "0x7fffffffffffffffffffffffffffffffffffffff", wantSerial: "730750818665451459101842416358141509827966271487"},
+		{name: "zero serial", serial: "0", wantErr: true},
+		{name: "negative serial", serial: "-5", wantErr: true},
+		{name: "serial over 20 octets", serial: "0x8000000000000000000000000000000000000000", wantErr: true},
+		{name: "invalid serial", serial: "abc", wantErr: true},
+		{name: "invalid not before", notBefore: "yesterday", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cert, _, err := CreateSelfSignedKeyPair(SelfSignedConfig{
+				Duration:     "24h",
+				KeyType:      KeyTypeECDSA,
+				SerialNumber: tt.serial,
+				NotBefore:    tt.notBefore,
+			})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("CreateSelfSignedKeyPair() expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CreateSelfSignedKeyPair() error = %v", err)
+			}
+
+			if got := cert.Leaf.SerialNumber.String(); got != tt.wantSerial {
+				t.Errorf("SerialNumber = %s, want %s", got, tt.wantSerial)
+			}
+			if tt.notBefore != "" {
+				want, _ := time.Parse(time.RFC3339, tt.notBefore)
+				if !cert.Leaf.NotBefore.Equal(want) {
+					t.Errorf("NotBefore = %v, want %v", cert.Leaf.NotBefore, want)
+				}
+				if !cert.Leaf.NotAfter.Equal(want.Add(24 * time.Hour)) {
+					t.Errorf("NotAfter = %v, want %v", cert.Leaf.NotAfter, want.Add(24*time.Hour))
+				}
+			}
+		})
+	}
+}
+
+func TestCreateSelfSignedKeyPairRandomSerial(t *testing.T) {
+	config := SelfSignedConfig{Duration: "1h", KeyType: KeyTypeECDSA}
+
+	first, _, err := CreateSelfSignedKeyPair(config)
+	if err != nil {
+		t.Fatalf("CreateSelfSignedKeyPair() error = %v", err)
+	}
+	second, _, err := CreateSelfSignedKeyPair(config)
+	if err != nil {
+		t.Fatalf("CreateSelfSignedKeyPair() error = %v", err)
+	}
+
+	if first.Leaf.SerialNumber.Sign() <= 0 {
+		t.Errorf("SerialNumber = %s, want positive", first.Leaf.SerialNumber)
+	}
+	if first.Leaf.SerialNumber.Cmp(second.Leaf.SerialNumber) == 0 {
+		t.Error("expected random serial numbers to differ")
+	}
+}
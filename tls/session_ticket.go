@@ -0,0 +1,114 @@
+package tls
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// DefaultSessionTicketPreviousKeys is the number of previous session ticket keys kept by
+// SessionTicketRotationConfig when PreviousKeys is zero.
+const DefaultSessionTicketPreviousKeys = 2
+
+// SessionTicketRotationConfig specifies how often TLS session ticket keys are rotated.
+type SessionTicketRotationConfig struct {
+	// Interval is how often a new ticket key is generated, e.g. "1h". If empty, Go's
+	// default ticket key management is used.
+	Interval string `mapstructure:"interval" json:"interval,omitzero"`
+	// PreviousKeys is how many previous keys are kept so that recently issued tickets
+	// can still be used to resume. Tickets stay valid for up to Interval*(PreviousKeys+1).
+	// Zero uses DefaultSessionTicketPreviousKeys; a negative value keeps none.
+	PreviousKeys int `mapstructure:"previous-keys" json:"previous_keys,omitzero"`
+}
+
+// sessionTicketRotator generates and rotates session ticket keys. The keys are held on
+// a private tls.Config that encrypts and decrypts tickets, so rotation also applies to
+// clones of the server's tls.Config, such as the one made by http.Server.
+type sessionTicketRotator struct {
+	ticketConfig *tls.Config
+	previousKeys int
+
+	mu   sync.Mutex
+	keys [][32]byte
+}
+
+func newSessionTicketRotator(previousKeys int) *sessionTicketRotator {
+	if previousKeys == 0 {
+		previousKeys = DefaultSessionTicketPreviousKeys
+	}
+	return &sessionTicketRotator{
+		ticketConfig: &tls.Config{},
+		previousKeys: max(previousKeys, 0),
+	}
+}
+
+// install makes config encrypt and decrypt session tickets with the rotator's keys.
+func (r *sessionTicketRotator) install(config *tls.Config) {
+	config.WrapSession = r.ticketConfig.EncryptTicket
+	config.UnwrapSession = r.ticketConfig.DecryptTicket
+}
+
+// rotate generates a new key used to encrypt new tickets, keeping up to previousKeys
+// older keys for decrypting existing tickets.
+func (r *sessionTicketRotator) rotate() error {
+	var key [32]byte
+	if _, err := rand.Read(key[:]); err != nil {
+		return fmt.Errorf("error generating session ticket key: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	keys := append([][32]byte{key}, r.keys...)
+	if len(keys) > r.previousKeys+1 {
+		keys = keys[:r.previousKeys+1]
+	}
+	r.keys = keys
+	r.ticketConfig.SetSessionTicketKeys(keys)
+	return nil
+}
+
+// run rotates the keys every interval until ctx is cancelled.
+func (r *sessionTicketRotator) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.rotate(); err != nil {
+				zerolog.Ctx(ctx).Error().Err(err).Msg("session ticket key rotation failed")
+			}
+		}
+	}
+}
+
+// RotateSessionTicketKeys makes config encrypt session tickets with a freshly generated
+// key and replaces it with a new one every interval until ctx is cancelled, keeping
+// previousKeys older keys (see SessionTicketRotationConfig.PreviousKeys) so that
+// recently issued tickets still resume. Tickets that cannot be decrypted fall back to a
+// full handshake.
+//
+// It sets config.WrapSession and config.UnwrapSession, and rotation carries over to
+// clones of config.
+func RotateSessionTicketKeys(ctx context.Context, config *tls.Config, interval time.Duration, previousKeys int) error {
+	if interval <= 0 {
+		return fmt.Errorf("session ticket rotation interval must be positive, got %v", interval)
+	}
+
+	r := newSessionTicketRotator(previousKeys)
+	if err := r.rotate(); err != nil {
+		return err
+	}
+	r.install(config)
+
+	go r.run(ctx, interval)
+	return nil
+}
@@ -0,0 +1,176 @@
+package tls
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestSessionTicketRotatorKeys(t *testing.T) {
+	r := newSessionTicketRotator(1)
+
+	var history [][32]byte
+	for i := range 3 {
+		if err := r.rotate(); err != nil {
+			t.Fatalf("rotate() error = %v", err)
+		}
+		history = append([][32]byte{r.keys[0]}, history...)
+
+		if want := min(i+1, 2); len(r.keys) != want {
+			t.Fatalf("after %d rotations, got %d keys, want %d", i+1, len(r.keys), want)
+		}
+		for j, key := range r.keys {
+			if key != history[j] {
+				t.Errorf("after %d rotations, key %d is not the expected key", i+1, j)
+			}
+		}
+	}
+	if history[0] == history[1] {
+		t.Error("rotate() did not generate a new key")
+	}
+
+	if n := len(rotateN(t, newSessionTicketRotator(0), 5)); n != DefaultSessionTicketPreviousKeys+1 {
+		t.Errorf("default previous keys: got %d keys, want %d", n, DefaultSessionTicketPreviousKeys+1)
+	}
+	if n := len(rotateN(t, newSessionTicketRotator(-1), 3)); n != 1 {
+		t.Errorf("no previous keys: got %d keys, want 1", n)
+	}
+}
+
+// rotateN rotates r n times and returns the resulting keys.
+func rotateN(t *testing.T, r *sessionTicketRotator, n int) [][32]byte {
+	t.Helper()
+	for range n {
+		if err := r.rotate(); err != nil {
+			t.Fatalf("rotate() error = %v", err)
+		}
+	}
+	return r.keys
+}
+
+func TestSessionTicketRotationHandshakes(t *testing.T) {
+	cert, _, err := CreateSelfSignedKeyPair(SelfSignedConfig{
+		Duration: "1h",
+		KeyType:  KeyTypeECDSA,
+		SAN:      SANConfig{DNSNames: []string{"localhost"}},
+	})
+	if err != nil {
+		t.Fatalf("CreateSelfSignedKeyPair() error = %v", err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert.Leaf)
+
+	tests := []struct {
+		name         string
+		previousKeys int
+		wantResumed  bool
+	}{
+		{name: "previous key kept", previousKeys: 1, wantResumed: true},
+		{name: "no previous keys", previousKeys: -1, wantResumed: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serverConfig := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*cert},
+			}
+			r := newSessionTicketRotator(tt.previousKeys)
+			if err := r.rotate(); err != nil {
+				t.Fatalf("rotate() error = %v", err)
+			}
+			r.install(serverConfig)
+
+			// Handshakes use a clone, as http.Server does, so rotation must carry over
+			serving := serverConfig.Clone()
+			clientConfig := &tls.Config{
+				RootCAs:            pool,
+				ServerName:         "localhost",
+				ClientSessionCache: tls.NewLRUClientSessionCache(1),
+			}
+
+			if resumed := handshake(t, serving, clientConfig); resumed {
+				t.Fatal("first handshake unexpectedly resumed")
+			}
+
+			if err := r.rotate(); err != nil {
+				t.Fatalf("rotate() error = %v", err)
+			}
+
+			if resumed := handshake(t, serving, clientConfig); resumed != tt.wantResumed {
+				t.Errorf("handshake after rotation: resumed = %v, want %v", resumed, tt.wantResumed)
+			}
+		})
+	}
+}
+
+// handshake completes a TLS handshake over a loopback TCP connection, exchanging one byte
+// so that the client receives any session tickets, and reports whether it resumed.
+func handshake(t *testing.T, serverConfig, clientConfig *tls.Config) bool {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen error = %v", err)
+	}
+	defer ln.Close()
+
+	errCh := make(chan error, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			errCh <- err
+			return
+		}
+		defer conn.Close()
+		_ = conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+		server := tls.Server(conn, serverConfig)
+		if err := server.Handshake(); err != nil {
+			errCh <- err
+			return
+		}
+		_, err = server.Write([]byte{1})
+		errCh <- err
+	}()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("dial error = %v", err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	client := tls.Client(conn, clientConfig)
+	if err := client.Handshake(); err != nil {
+		t.Fatalf("client handshake error = %v", err)
+	}
+	if _, err := client.Read(make([]byte, 1)); err != nil {
+		t.Fatalf("client read error = %v", err)
+	}
+	if err := <-errCh; err != nil {
+		t.Fatalf("server error = %v", err)
+	}
+
+	return client.ConnectionState().DidResume
+}
+
+func TestRotateSessionTicketKeys(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	config := &tls.Config{}
+	if err := RotateSessionTicketKeys(ctx, config, 0, 0); err == nil {
+		t.Error("RotateSessionTicketKeys() expected error with zero interval")
+	}
+	if err := RotateSessionTicketKeys(ctx, config, time.Hour, 0); err != nil {
+		t.Fatalf("RotateSessionTicketKeys() error = %v", err)
+	}
+	if config.WrapSession == nil || config.UnwrapSession == nil {
+		t.Error("RotateSessionTicketKeys() did not install ticket wrapping")
+	}
+}